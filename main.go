@@ -4,6 +4,9 @@ import (
 	"brainwars/pkg/auth"
 	dbpkg "brainwars/pkg/db"
 	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz"
+	"brainwars/pkg/room"
+	user "brainwars/pkg/users"
 	"brainwars/pkg/users/model"
 	"brainwars/web/routes"
 	"context"
@@ -58,6 +61,16 @@ func main() {
 		log.Fatalf("Failed to initialize the authenticator: %v", err)
 	}
 
-	route := routes.Initialize(ctx, l, authenticator)
+	quizGenerator, err := quiz.NewQuizGenerator(ctx)
+	if err != nil {
+		log.Fatalf("Failed to initialize the quiz generator: %v", err)
+	}
+
+	// one pool for the whole app, the services share it
+	userService := user.NewService(dbConn.Db)
+	quizService := quiz.NewService(dbConn.Db, quizGenerator)
+	roomService := room.NewService(dbConn.Db, quizService, userService)
+
+	route := routes.Initialize(ctx, l, authenticator, roomService, quizService, userService)
 	route.Run(":" + viper.GetString("app.port"))
 }
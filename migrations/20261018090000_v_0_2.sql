@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- snapshot of the in memory game state of a room. it is checkpointed at every question transition
+-- so that a room which is still STARTED can be reloaded after a deploy or a crash
+CREATE TABLE IF NOT EXISTS game_state (
+  room_code TEXT PRIMARY KEY,
+  room_status TEXT NOT NULL,
+  state JSONB NOT NULL,
+  answer_history JSONB NOT NULL, -- every player's answers which are not yet stored in the answer table
+  created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_by TEXT NOT NULL,
+  updated_by TEXT NOT NULL
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS game_state;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- question generation of a room runs in the background. the job row tracks it
+-- through PENDING -> RUNNING -> SUCCEEDED/FAILED so the lobby can show its progress
+CREATE TABLE IF NOT EXISTS generation_job (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  room_code TEXT NOT NULL,
+  topic TEXT,
+  question_count INT NOT NULL,
+  status TEXT NOT NULL,
+  error_message TEXT,
+  created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_by TEXT NOT NULL,
+  updated_by TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS generation_job_room_code_idx ON generation_job (room_code);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS generation_job;
+-- +goose StatementEnd
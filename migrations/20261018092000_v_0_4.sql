@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- difficulty the questions of the room were generated with, harder questions score more
+ALTER TABLE question ADD COLUMN IF NOT EXISTS difficulty TEXT NOT NULL DEFAULT 'easy';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE question DROP COLUMN IF EXISTS difficulty;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- personal question bank. the questions belong to the user and not to a room so that they can be
+-- reused, a room built from the bank gets a copy of the selected questions in its question row
+CREATE TABLE IF NOT EXISTS bank_question (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  question TEXT NOT NULL,
+  options JSONB NOT NULL,
+  answer INT NOT NULL, -- option id of the right answer
+  difficulty TEXT NOT NULL DEFAULT 'easy',
+  tags TEXT[] NOT NULL DEFAULT '{}',
+  is_deleted BOOLEAN NOT NULL DEFAULT false,
+  created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_by TEXT NOT NULL,
+  updated_by TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS bank_question_user_id_idx ON bank_question (user_id);
+CREATE INDEX IF NOT EXISTS bank_question_tags_idx ON bank_question USING GIN (tags);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS bank_question;
+-- +goose StatementEnd
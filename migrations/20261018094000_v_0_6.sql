@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- role of the member in the room, a HOST wrote the questions so he only watches the game
+ALTER TABLE room_member ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'PLAYER';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE room_member DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd
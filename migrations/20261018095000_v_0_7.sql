@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- how long the player took to answer as measured by the server, and why the answer looks suspicious
+ALTER TABLE answer ADD COLUMN IF NOT EXISTS latency_ms INT NOT NULL DEFAULT 0;
+ALTER TABLE answer ADD COLUMN IF NOT EXISTS flag_reason TEXT NOT NULL DEFAULT '';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE answer DROP COLUMN IF EXISTS flag_reason;
+ALTER TABLE answer DROP COLUMN IF EXISTS latency_ms;
+-- +goose StatementEnd
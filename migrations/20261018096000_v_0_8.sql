@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- time limits were stored in minutes, they are in seconds from now on
+UPDATE question SET time_limit = time_limit * 60;
+
+-- time limit of a single question in seconds, 0 means the time limit of the room is used
+ALTER TABLE bank_question ADD COLUMN IF NOT EXISTS time_limit INT NOT NULL DEFAULT 0;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE bank_question DROP COLUMN IF EXISTS time_limit;
+UPDATE question SET time_limit = GREATEST(time_limit / 60, 1);
+-- +goose StatementEnd
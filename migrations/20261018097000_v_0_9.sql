@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- what the answer added to the score and how the scoring rule of the room came up with it
+ALTER TABLE answer ADD COLUMN IF NOT EXISTS points INT NOT NULL DEFAULT 0;
+ALTER TABLE answer ADD COLUMN IF NOT EXISTS score_reason TEXT NOT NULL DEFAULT '';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE answer DROP COLUMN IF EXISTS score_reason;
+ALTER TABLE answer DROP COLUMN IF EXISTS points;
+-- +goose StatementEnd
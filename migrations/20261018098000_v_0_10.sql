@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- most right answers in a row the player had in the game
+ALTER TABLE leaderboard ADD COLUMN IF NOT EXISTS best_streak INT NOT NULL DEFAULT 0;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE leaderboard DROP COLUMN IF EXISTS best_streak;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- power-ups the player used on the question, kept for the analysis page
+ALTER TABLE answer ADD COLUMN IF NOT EXISTS power_ups TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE answer DROP COLUMN IF EXISTS power_ups;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- answer of the question types which do not fit in answer_option (multi select, numeric, ordering, free text)
+ALTER TABLE answer ADD COLUMN IF NOT EXISTS answer_data JSONB NOT NULL DEFAULT '{}';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE answer DROP COLUMN IF EXISTS answer_data;
+-- +goose StatementEnd
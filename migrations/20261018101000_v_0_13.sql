@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- public rooms are listed in the room browser, room_lock keeps everyone who is not a member yet out
+ALTER TABLE room ADD COLUMN IF NOT EXISTS is_public BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS room_public_idx ON room (room_status) WHERE is_public = true AND is_deleted = false;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS room_public_idx;
+ALTER TABLE room DROP COLUMN IF EXISTS is_public;
+-- +goose StatementEnd
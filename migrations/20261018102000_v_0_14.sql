@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- short invite codes that are easy to read out, a code points to a room till it expires or is revoked
+CREATE TABLE IF NOT EXISTS room_invite (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  invite_code TEXT NOT NULL UNIQUE,
+  room_code TEXT NOT NULL,
+  expires_on TIMESTAMP NOT NULL,
+  is_revoked BOOLEAN NOT NULL DEFAULT false,
+  created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_by TEXT NOT NULL,
+  updated_by TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS room_invite_room_code_idx ON room_invite (room_code);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS room_invite;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the instance running the job, so that a restarted instance can fail the jobs it was running right away
+ALTER TABLE generation_job ADD COLUMN IF NOT EXISTS instance_id TEXT NOT NULL DEFAULT '';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE generation_job DROP COLUMN IF EXISTS instance_id;
+-- +goose StatementEnd
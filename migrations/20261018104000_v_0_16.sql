@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- bank questions can be of every question type, the answer of the types which are not
+-- single choice (right options, number, accepted texts) is kept in answer_key
+ALTER TABLE bank_question ADD COLUMN IF NOT EXISTS question_type TEXT NOT NULL DEFAULT 'single_choice';
+ALTER TABLE bank_question ADD COLUMN IF NOT EXISTS answer_key JSONB NOT NULL DEFAULT '{}';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE bank_question DROP COLUMN IF EXISTS answer_key;
+ALTER TABLE bank_question DROP COLUMN IF EXISTS question_type;
+-- +goose StatementEnd
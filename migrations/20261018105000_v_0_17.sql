@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- the matchmaking queues are kept in the database so every instance sees the same queues. a player
+-- has one ticket, it goes from QUEUED to FORMING while his room is set up and then to MATCHED or
+-- FAILED till he picks up the result
+CREATE TABLE IF NOT EXISTS match_ticket (
+  user_id UUID PRIMARY KEY,
+  username TEXT NOT NULL,
+  category TEXT NOT NULL,
+  difficulty TEXT NOT NULL,
+  status TEXT NOT NULL,
+  room_code TEXT NOT NULL DEFAULT '',
+  queued_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS match_ticket_queue_idx ON match_ticket (category, difficulty, queued_on) WHERE status = 'QUEUED';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS match_ticket;
+-- +goose StatementEnd
@@ -7,10 +7,10 @@ import (
 	"context"
 )
 
-func JoinGameAsBots(ctx context.Context, req roommodel.RoomMemberReq, roomCode string) error {
+func JoinGameAsBots(ctx context.Context, roomService *room.Service, req roommodel.RoomMemberReq, roomCode string) error {
 	l := logs.GetLoggerctx(ctx)
 	// check if he has already joined the room if he has then redirect him to the room
-	roomMember, err := room.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
+	roomMember, err := roomService.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
 		UserID: req.UserID,
 		RoomID: req.RoomID,
 	})
@@ -20,7 +20,7 @@ func JoinGameAsBots(ctx context.Context, req roommodel.RoomMemberReq, roomCode s
 	}
 
 	if roomMember == nil {
-		_, err := room.JoinRoomWithRoomCode(ctx, roommodel.RoomMemberReq{
+		_, err := roomService.JoinRoomWithRoomCode(ctx, roommodel.RoomMemberReq{
 			UserID:   req.UserID,
 			RoomID:   req.RoomID,
 			RoomCode: roomCode,
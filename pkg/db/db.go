@@ -1,6 +1,7 @@
 package dbpkg
 
 import (
+	"brainwars/pkg/db/dbal"
 	"context"
 	"fmt"
 	"log"
@@ -22,6 +23,13 @@ type DBConfig struct {
 	SSLMode  string
 }
 
+// Pool is what the services need from the shared connection pool. *pgxpool.Pool is the real one,
+// the tests hand the services the fake in pkg/db/dbtest
+type Pool interface {
+	dbal.DBTX
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
 // Dbconn holds the database connection pool
 type Dbconn struct {
 	Db     *pgxpool.Pool
@@ -43,7 +51,7 @@ func loadEnv() DBConfig {
 
 }
 
-// InitDB initializes the database connection
+// InitDB initializes the database connection pool. it is called once at startup and the pool is shared by all the services
 func InitDB() (*Dbconn, error) {
 	config := loadEnv()
 
@@ -76,17 +84,11 @@ func InitDB() (*Dbconn, error) {
 	}
 
 	// Setup connection pool
-	poolConfig, err := pgxpool.ParseConfig(targetDSN)
+	poolConfig, err := newPoolConfig(targetDSN)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse database config: %v", err)
+		return nil, err
 	}
 
-	poolConfig.MaxConns = 20
-	poolConfig.MinConns = 5
-	poolConfig.HealthCheckPeriod = 30 * time.Second
-	poolConfig.MaxConnLifetime = 30 * time.Minute
-	poolConfig.MaxConnIdleTime = 5 * time.Minute
-
 	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to target database: %v", err)
@@ -96,6 +98,21 @@ func InitDB() (*Dbconn, error) {
 	return &Dbconn{Db: pool, Config: config}, nil
 }
 
+// newPoolConfig sizes the pool shared by all the services
+func newPoolConfig(dsn string) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database config: %v", err)
+	}
+
+	poolConfig.MaxConns = 20
+	poolConfig.MinConns = 5
+	poolConfig.HealthCheckPeriod = 30 * time.Second
+	poolConfig.MaxConnLifetime = 30 * time.Minute
+	poolConfig.MaxConnIdleTime = 5 * time.Minute
+	return poolConfig, nil
+}
+
 func databaseExists(ctx context.Context, conn *pgx.Conn, name string) (bool, error) {
 	var exists bool
 	err := conn.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM pg_database WHERE datname=$1)", name).Scan(&exists)
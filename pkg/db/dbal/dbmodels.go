@@ -21,18 +21,79 @@ type Answer struct {
 	UpdatedOn      pgtype.Timestamp
 	CreatedBy      string
 	UpdatedBy      string
+	LatencyMs      int32
+	FlagReason     string
+	Points         int32
+	ScoreReason    string
+	PowerUps       []string
+	AnswerData     []byte
+}
+
+type BankQuestion struct {
+	ID           pgtype.UUID
+	UserID       pgtype.UUID
+	Question     string
+	Options      []byte
+	Answer       int32
+	Difficulty   string
+	Tags         []string
+	IsDeleted    bool
+	CreatedOn    pgtype.Timestamp
+	UpdatedOn    pgtype.Timestamp
+	CreatedBy    string
+	UpdatedBy    string
+	TimeLimit    int32
+	QuestionType string
+	AnswerKey    []byte
+}
+
+type GameState struct {
+	RoomCode      string
+	RoomStatus    string
+	State         []byte
+	AnswerHistory []byte
+	CreatedOn     pgtype.Timestamp
+	UpdatedOn     pgtype.Timestamp
+	CreatedBy     string
+	UpdatedBy     string
+}
+
+type GenerationJob struct {
+	ID            pgtype.UUID
+	RoomCode      string
+	Topic         pgtype.Text
+	QuestionCount int32
+	Status        string
+	ErrorMessage  pgtype.Text
+	CreatedOn     pgtype.Timestamp
+	UpdatedOn     pgtype.Timestamp
+	CreatedBy     string
+	UpdatedBy     string
+	InstanceID    string
 }
 
 type Leaderboard struct {
-	ID        pgtype.UUID
-	RoomCode  string
-	UserID    pgtype.UUID
-	Score     float64
-	CreatedOn pgtype.Timestamp
-	UpdatedOn pgtype.Timestamp
-	CreatedBy string
-	UpdatedBy string
-	IsDeleted bool
+	ID         pgtype.UUID
+	RoomCode   string
+	UserID     pgtype.UUID
+	Score      float64
+	CreatedOn  pgtype.Timestamp
+	UpdatedOn  pgtype.Timestamp
+	CreatedBy  string
+	UpdatedBy  string
+	IsDeleted  bool
+	BestStreak int32
+}
+
+type MatchTicket struct {
+	UserID     pgtype.UUID
+	Username   string
+	Category   string
+	Difficulty string
+	Status     string
+	RoomCode   string
+	QueuedOn   pgtype.Timestamp
+	UpdatedOn  pgtype.Timestamp
 }
 
 type Question struct {
@@ -46,6 +107,7 @@ type Question struct {
 	UpdatedOn     pgtype.Timestamp
 	CreatedBy     string
 	UpdatedBy     string
+	Difficulty    string
 }
 
 type Room struct {
@@ -64,6 +126,19 @@ type Room struct {
 	UpdatedOn  pgtype.Timestamp
 	CreatedBy  string
 	UpdatedBy  string
+	IsPublic   bool
+}
+
+type RoomInvite struct {
+	ID         pgtype.UUID
+	InviteCode string
+	RoomCode   string
+	ExpiresOn  pgtype.Timestamp
+	IsRevoked  bool
+	CreatedOn  pgtype.Timestamp
+	UpdatedOn  pgtype.Timestamp
+	CreatedBy  string
+	UpdatedBy  string
 }
 
 type RoomMember struct {
@@ -80,6 +155,7 @@ type RoomMember struct {
 	UpdatedOn        pgtype.Timestamp
 	CreatedBy        string
 	UpdatedBy        string
+	Role             string
 }
 
 type User struct {
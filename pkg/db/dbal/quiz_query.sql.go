@@ -24,8 +24,14 @@ INSERT INTO answer (id,
     created_by,
     updated_by,
     created_on,
-    updated_on)
-VALUES ($10,$1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+    updated_on,
+    latency_ms,
+    flag_reason,
+    points,
+    score_reason,
+    power_ups,
+    answer_data)
+VALUES ($10,$1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW(), $11, $12, $13, $14, $15, $16)
 `
 
 type CreateAnswerParams struct {
@@ -39,6 +45,12 @@ type CreateAnswerParams struct {
 	CreatedBy      string
 	UpdatedBy      string
 	ID             pgtype.UUID
+	LatencyMs      int32
+	FlagReason     string
+	Points         int32
+	ScoreReason    string
+	PowerUps       []string
+	AnswerData     []byte
 }
 
 // -------------------------- answers --------------------------------------
@@ -54,10 +66,140 @@ func (q *Queries) CreateAnswer(ctx context.Context, arg CreateAnswerParams) erro
 		arg.CreatedBy,
 		arg.UpdatedBy,
 		arg.ID,
+		arg.LatencyMs,
+		arg.FlagReason,
+		arg.Points,
+		arg.ScoreReason,
+		arg.PowerUps,
+		arg.AnswerData,
 	)
 	return err
 }
 
+const createBankQuestion = `-- name: CreateBankQuestion :one
+
+INSERT INTO bank_question (id,
+    user_id,
+    question,
+    options,
+    answer,
+    difficulty,
+    tags,
+    created_by,
+    updated_by,
+    created_on,
+    updated_on,
+    time_limit,
+    question_type,
+    answer_key)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, NOW(), NOW(), $9, $10, $11)
+RETURNING id, user_id, question, options, answer, difficulty, tags, is_deleted, created_on, updated_on, created_by, updated_by, time_limit, question_type, answer_key
+`
+
+type CreateBankQuestionParams struct {
+	ID           pgtype.UUID
+	UserID       pgtype.UUID
+	Question     string
+	Options      []byte
+	Answer       int32
+	Difficulty   string
+	Tags         []string
+	CreatedBy    string
+	TimeLimit    int32
+	QuestionType string
+	AnswerKey    []byte
+}
+
+// -------------------------- question bank --------------------------------------
+func (q *Queries) CreateBankQuestion(ctx context.Context, arg CreateBankQuestionParams) (BankQuestion, error) {
+	row := q.db.QueryRow(ctx, createBankQuestion,
+		arg.ID,
+		arg.UserID,
+		arg.Question,
+		arg.Options,
+		arg.Answer,
+		arg.Difficulty,
+		arg.Tags,
+		arg.CreatedBy,
+		arg.TimeLimit,
+		arg.QuestionType,
+		arg.AnswerKey,
+	)
+	var i BankQuestion
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Question,
+		&i.Options,
+		&i.Answer,
+		&i.Difficulty,
+		&i.Tags,
+		&i.IsDeleted,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
+		&i.TimeLimit,
+		&i.QuestionType,
+		&i.AnswerKey,
+	)
+	return i, err
+}
+
+const createGenerationJob = `-- name: CreateGenerationJob :one
+
+INSERT INTO generation_job (id,
+    room_code,
+    topic,
+    question_count,
+    status,
+    created_by,
+    updated_by,
+    created_on,
+    updated_on,
+    instance_id)
+VALUES ($1, $2, $3, $4, $5, $6, $6, NOW(), NOW(), $7)
+RETURNING id, room_code, topic, question_count, status, error_message, created_on, updated_on, created_by, updated_by, instance_id
+`
+
+type CreateGenerationJobParams struct {
+	ID            pgtype.UUID
+	RoomCode      string
+	Topic         pgtype.Text
+	QuestionCount int32
+	Status        string
+	CreatedBy     string
+	InstanceID    string
+}
+
+// -------------------------- generation job --------------------------------------
+func (q *Queries) CreateGenerationJob(ctx context.Context, arg CreateGenerationJobParams) (GenerationJob, error) {
+	row := q.db.QueryRow(ctx, createGenerationJob,
+		arg.ID,
+		arg.RoomCode,
+		arg.Topic,
+		arg.QuestionCount,
+		arg.Status,
+		arg.CreatedBy,
+		arg.InstanceID,
+	)
+	var i GenerationJob
+	err := row.Scan(
+		&i.ID,
+		&i.RoomCode,
+		&i.Topic,
+		&i.QuestionCount,
+		&i.Status,
+		&i.ErrorMessage,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
+		&i.InstanceID,
+	)
+	return i, err
+}
+
 const createQuestion = `-- name: CreateQuestion :exec
 INSERT INTO question (id,
     room_code,
@@ -68,8 +210,9 @@ INSERT INTO question (id,
     created_by,
     updated_by, 
     created_on, 
-    updated_on)
-VALUES ($7,$1, $2, $3, $4, $5,$6, $8,NOW(), NOW())
+    updated_on,
+    difficulty)
+VALUES ($7,$1, $2, $3, $4, $5,$6, $8,NOW(), NOW(), $9)
 `
 
 type CreateQuestionParams struct {
@@ -81,6 +224,7 @@ type CreateQuestionParams struct {
 	CreatedBy     string
 	ID            pgtype.UUID
 	UpdatedBy     string
+	Difficulty    string
 }
 
 // --------------------------- questions --------------------------------
@@ -94,12 +238,137 @@ func (q *Queries) CreateQuestion(ctx context.Context, arg CreateQuestionParams)
 		arg.CreatedBy,
 		arg.ID,
 		arg.UpdatedBy,
+		arg.Difficulty,
 	)
 	return err
 }
 
+const deleteBankQuestion = `-- name: DeleteBankQuestion :exec
+UPDATE bank_question
+SET is_deleted = true,
+    updated_on = NOW(),
+    updated_by = $3
+WHERE id = $1
+AND user_id = $2
+`
+
+type DeleteBankQuestionParams struct {
+	ID        pgtype.UUID
+	UserID    pgtype.UUID
+	UpdatedBy string
+}
+
+func (q *Queries) DeleteBankQuestion(ctx context.Context, arg DeleteBankQuestionParams) error {
+	_, err := q.db.Exec(ctx, deleteBankQuestion, arg.ID, arg.UserID, arg.UpdatedBy)
+	return err
+}
+
+const deleteGameStateByRoomCode = `-- name: DeleteGameStateByRoomCode :exec
+DELETE FROM game_state
+WHERE room_code = $1
+`
+
+func (q *Queries) DeleteGameStateByRoomCode(ctx context.Context, roomCode string) error {
+	_, err := q.db.Exec(ctx, deleteGameStateByRoomCode, roomCode)
+	return err
+}
+
+const failInstanceGenerationJobs = `-- name: FailInstanceGenerationJobs :many
+UPDATE generation_job
+SET status = 'FAILED',
+    error_message = $1,
+    updated_on = NOW()
+WHERE status IN ('PENDING', 'RUNNING')
+AND instance_id = $2
+RETURNING id, room_code, topic, question_count, status, error_message, created_on, updated_on, created_by, updated_by, instance_id
+`
+
+type FailInstanceGenerationJobsParams struct {
+	ErrorMessage pgtype.Text
+	InstanceID   string
+}
+
+func (q *Queries) FailInstanceGenerationJobs(ctx context.Context, arg FailInstanceGenerationJobsParams) ([]GenerationJob, error) {
+	rows, err := q.db.Query(ctx, failInstanceGenerationJobs, arg.ErrorMessage, arg.InstanceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GenerationJob
+	for rows.Next() {
+		var i GenerationJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomCode,
+			&i.Topic,
+			&i.QuestionCount,
+			&i.Status,
+			&i.ErrorMessage,
+			&i.CreatedOn,
+			&i.UpdatedOn,
+			&i.CreatedBy,
+			&i.UpdatedBy,
+			&i.InstanceID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const failStaleGenerationJobs = `-- name: FailStaleGenerationJobs :many
+UPDATE generation_job
+SET status = 'FAILED',
+    error_message = $1,
+    updated_on = NOW()
+WHERE status IN ('PENDING', 'RUNNING')
+AND updated_on < NOW() - make_interval(secs => $2::INT)
+RETURNING id, room_code, topic, question_count, status, error_message, created_on, updated_on, created_by, updated_by, instance_id
+`
+
+type FailStaleGenerationJobsParams struct {
+	ErrorMessage pgtype.Text
+	StaleSeconds int32
+}
+
+func (q *Queries) FailStaleGenerationJobs(ctx context.Context, arg FailStaleGenerationJobsParams) ([]GenerationJob, error) {
+	rows, err := q.db.Query(ctx, failStaleGenerationJobs, arg.ErrorMessage, arg.StaleSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GenerationJob
+	for rows.Next() {
+		var i GenerationJob
+		if err := rows.Scan(
+			&i.ID,
+			&i.RoomCode,
+			&i.Topic,
+			&i.QuestionCount,
+			&i.Status,
+			&i.ErrorMessage,
+			&i.CreatedOn,
+			&i.UpdatedOn,
+			&i.CreatedBy,
+			&i.UpdatedBy,
+			&i.InstanceID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAnswerByRoomCodeAndUserID = `-- name: GetAnswerByRoomCodeAndUserID :many
-SELECT id, room_code, user_id, question_id, question_data_id, answer_option, is_correct, answer_time, created_on, updated_on, created_by, updated_by
+SELECT id, room_code, user_id, question_id, question_data_id, answer_option, is_correct, answer_time, created_on, updated_on, created_by, updated_by, latency_ms, flag_reason, points, score_reason, power_ups, answer_data
 FROM answer
 WHERE room_code = $1
 AND user_id = $2
@@ -132,6 +401,12 @@ func (q *Queries) GetAnswerByRoomCodeAndUserID(ctx context.Context, arg GetAnswe
 			&i.UpdatedOn,
 			&i.CreatedBy,
 			&i.UpdatedBy,
+			&i.LatencyMs,
+			&i.FlagReason,
+			&i.Points,
+			&i.ScoreReason,
+			&i.PowerUps,
+			&i.AnswerData,
 		); err != nil {
 			return nil, err
 		}
@@ -143,8 +418,71 @@ func (q *Queries) GetAnswerByRoomCodeAndUserID(ctx context.Context, arg GetAnswe
 	return items, nil
 }
 
+const getBankQuestionByID = `-- name: GetBankQuestionByID :one
+SELECT id, user_id, question, options, answer, difficulty, tags, is_deleted, created_on, updated_on, created_by, updated_by, time_limit, question_type, answer_key
+FROM bank_question
+WHERE id = $1
+AND user_id = $2
+AND is_deleted = false
+`
+
+type GetBankQuestionByIDParams struct {
+	ID     pgtype.UUID
+	UserID pgtype.UUID
+}
+
+func (q *Queries) GetBankQuestionByID(ctx context.Context, arg GetBankQuestionByIDParams) (BankQuestion, error) {
+	row := q.db.QueryRow(ctx, getBankQuestionByID, arg.ID, arg.UserID)
+	var i BankQuestion
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Question,
+		&i.Options,
+		&i.Answer,
+		&i.Difficulty,
+		&i.Tags,
+		&i.IsDeleted,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
+		&i.TimeLimit,
+		&i.QuestionType,
+		&i.AnswerKey,
+	)
+	return i, err
+}
+
+const getLatestGenerationJobByRoomCode = `-- name: GetLatestGenerationJobByRoomCode :one
+SELECT id, room_code, topic, question_count, status, error_message, created_on, updated_on, created_by, updated_by, instance_id
+FROM generation_job
+WHERE room_code = $1
+ORDER BY created_on DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestGenerationJobByRoomCode(ctx context.Context, roomCode string) (GenerationJob, error) {
+	row := q.db.QueryRow(ctx, getLatestGenerationJobByRoomCode, roomCode)
+	var i GenerationJob
+	err := row.Scan(
+		&i.ID,
+		&i.RoomCode,
+		&i.Topic,
+		&i.QuestionCount,
+		&i.Status,
+		&i.ErrorMessage,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
+		&i.InstanceID,
+	)
+	return i, err
+}
+
 const getQuestionsByRoomCode = `-- name: GetQuestionsByRoomCode :one
-SELECT id, room_code, topic, question_count, question_data, time_limit, created_on, updated_on, created_by, updated_by
+SELECT id, room_code, topic, question_count, question_data, time_limit, created_on, updated_on, created_by, updated_by, difficulty
 FROM question
 WHERE room_code = $1
 ORDER BY created_on ASC
@@ -164,12 +502,13 @@ func (q *Queries) GetQuestionsByRoomCode(ctx context.Context, roomCode string) (
 		&i.UpdatedOn,
 		&i.CreatedBy,
 		&i.UpdatedBy,
+		&i.Difficulty,
 	)
 	return i, err
 }
 
 const listAnswersByRoomCode = `-- name: ListAnswersByRoomCode :many
-SELECT id, room_code, user_id, question_id, question_data_id, answer_option, is_correct, answer_time, created_on, updated_on, created_by, updated_by
+SELECT id, room_code, user_id, question_id, question_data_id, answer_option, is_correct, answer_time, created_on, updated_on, created_by, updated_by, latency_ms, flag_reason, points, score_reason, power_ups, answer_data
 FROM answer
 WHERE room_code = $1
 ORDER BY created_on ASC
@@ -197,6 +536,150 @@ func (q *Queries) ListAnswersByRoomCode(ctx context.Context, roomCode string) ([
 			&i.UpdatedOn,
 			&i.CreatedBy,
 			&i.UpdatedBy,
+			&i.LatencyMs,
+			&i.FlagReason,
+			&i.Points,
+			&i.ScoreReason,
+			&i.PowerUps,
+			&i.AnswerData,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBankQuestionsByIDs = `-- name: ListBankQuestionsByIDs :many
+SELECT id, user_id, question, options, answer, difficulty, tags, is_deleted, created_on, updated_on, created_by, updated_by, time_limit, question_type, answer_key
+FROM bank_question
+WHERE user_id = $1
+AND id = ANY($2::UUID[])
+AND is_deleted = false
+`
+
+type ListBankQuestionsByIDsParams struct {
+	UserID pgtype.UUID
+	Ids    []pgtype.UUID
+}
+
+func (q *Queries) ListBankQuestionsByIDs(ctx context.Context, arg ListBankQuestionsByIDsParams) ([]BankQuestion, error) {
+	rows, err := q.db.Query(ctx, listBankQuestionsByIDs, arg.UserID, arg.Ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BankQuestion
+	for rows.Next() {
+		var i BankQuestion
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Question,
+			&i.Options,
+			&i.Answer,
+			&i.Difficulty,
+			&i.Tags,
+			&i.IsDeleted,
+			&i.CreatedOn,
+			&i.UpdatedOn,
+			&i.CreatedBy,
+			&i.UpdatedBy,
+			&i.TimeLimit,
+			&i.QuestionType,
+			&i.AnswerKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBankQuestionsByUserID = `-- name: ListBankQuestionsByUserID :many
+SELECT id, user_id, question, options, answer, difficulty, tags, is_deleted, created_on, updated_on, created_by, updated_by, time_limit, question_type, answer_key
+FROM bank_question
+WHERE user_id = $1
+AND is_deleted = false
+AND ($2::TEXT = '' OR question ILIKE '%' || $2::TEXT || '%')
+AND ($3::TEXT = '' OR $3::TEXT = ANY(tags))
+ORDER BY updated_on DESC
+`
+
+type ListBankQuestionsByUserIDParams struct {
+	UserID pgtype.UUID
+	Search string
+	Tag    string
+}
+
+func (q *Queries) ListBankQuestionsByUserID(ctx context.Context, arg ListBankQuestionsByUserIDParams) ([]BankQuestion, error) {
+	rows, err := q.db.Query(ctx, listBankQuestionsByUserID, arg.UserID, arg.Search, arg.Tag)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []BankQuestion
+	for rows.Next() {
+		var i BankQuestion
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Question,
+			&i.Options,
+			&i.Answer,
+			&i.Difficulty,
+			&i.Tags,
+			&i.IsDeleted,
+			&i.CreatedOn,
+			&i.UpdatedOn,
+			&i.CreatedBy,
+			&i.UpdatedBy,
+			&i.TimeLimit,
+			&i.QuestionType,
+			&i.AnswerKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listGameStatesByRoomStatus = `-- name: ListGameStatesByRoomStatus :many
+SELECT gs.room_code, gs.room_status, gs.state, gs.answer_history, gs.created_on, gs.updated_on, gs.created_by, gs.updated_by
+FROM game_state gs
+INNER JOIN room r ON r.room_code = gs.room_code
+WHERE gs.room_status = $1
+AND r.is_deleted = false
+`
+
+func (q *Queries) ListGameStatesByRoomStatus(ctx context.Context, roomStatus string) ([]GameState, error) {
+	rows, err := q.db.Query(ctx, listGameStatesByRoomStatus, roomStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GameState
+	for rows.Next() {
+		var i GameState
+		if err := rows.Scan(
+			&i.RoomCode,
+			&i.RoomStatus,
+			&i.State,
+			&i.AnswerHistory,
+			&i.CreatedOn,
+			&i.UpdatedOn,
+			&i.CreatedBy,
+			&i.UpdatedBy,
 		); err != nil {
 			return nil, err
 		}
@@ -237,6 +720,107 @@ func (q *Queries) UpdateAnswer(ctx context.Context, arg UpdateAnswerParams) erro
 	return err
 }
 
+const updateBankQuestion = `-- name: UpdateBankQuestion :one
+UPDATE bank_question
+SET question = $3,
+    options = $4,
+    answer = $5,
+    difficulty = $6,
+    tags = $7,
+    time_limit = $9,
+    question_type = $10,
+    answer_key = $11,
+    updated_on = NOW(),
+    updated_by = $8
+WHERE id = $1
+AND user_id = $2
+AND is_deleted = false
+RETURNING id, user_id, question, options, answer, difficulty, tags, is_deleted, created_on, updated_on, created_by, updated_by, time_limit, question_type, answer_key
+`
+
+type UpdateBankQuestionParams struct {
+	ID           pgtype.UUID
+	UserID       pgtype.UUID
+	Question     string
+	Options      []byte
+	Answer       int32
+	Difficulty   string
+	Tags         []string
+	UpdatedBy    string
+	TimeLimit    int32
+	QuestionType string
+	AnswerKey    []byte
+}
+
+func (q *Queries) UpdateBankQuestion(ctx context.Context, arg UpdateBankQuestionParams) (BankQuestion, error) {
+	row := q.db.QueryRow(ctx, updateBankQuestion,
+		arg.ID,
+		arg.UserID,
+		arg.Question,
+		arg.Options,
+		arg.Answer,
+		arg.Difficulty,
+		arg.Tags,
+		arg.UpdatedBy,
+		arg.TimeLimit,
+		arg.QuestionType,
+		arg.AnswerKey,
+	)
+	var i BankQuestion
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Question,
+		&i.Options,
+		&i.Answer,
+		&i.Difficulty,
+		&i.Tags,
+		&i.IsDeleted,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
+		&i.TimeLimit,
+		&i.QuestionType,
+		&i.AnswerKey,
+	)
+	return i, err
+}
+
+const updateGenerationJobStatus = `-- name: UpdateGenerationJobStatus :one
+UPDATE generation_job
+SET status = $2,
+    error_message = $3,
+    updated_on = NOW()
+WHERE id = $1
+RETURNING id, room_code, topic, question_count, status, error_message, created_on, updated_on, created_by, updated_by, instance_id
+`
+
+type UpdateGenerationJobStatusParams struct {
+	ID           pgtype.UUID
+	Status       string
+	ErrorMessage pgtype.Text
+}
+
+func (q *Queries) UpdateGenerationJobStatus(ctx context.Context, arg UpdateGenerationJobStatusParams) (GenerationJob, error) {
+	row := q.db.QueryRow(ctx, updateGenerationJobStatus, arg.ID, arg.Status, arg.ErrorMessage)
+	var i GenerationJob
+	err := row.Scan(
+		&i.ID,
+		&i.RoomCode,
+		&i.Topic,
+		&i.QuestionCount,
+		&i.Status,
+		&i.ErrorMessage,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
+		&i.InstanceID,
+	)
+	return i, err
+}
+
 const updateQuestionByID = `-- name: UpdateQuestionByID :exec
 UPDATE question
 SET 
@@ -269,3 +853,42 @@ func (q *Queries) UpdateQuestionByID(ctx context.Context, arg UpdateQuestionByID
 	)
 	return err
 }
+
+const upsertGameState = `-- name: UpsertGameState :exec
+
+INSERT INTO game_state (room_code,
+    room_status,
+    state,
+    answer_history,
+    created_by,
+    updated_by,
+    created_on,
+    updated_on)
+VALUES ($1, $2, $3, $4, $5, $5, NOW(), NOW())
+ON CONFLICT (room_code) DO UPDATE
+SET room_status = EXCLUDED.room_status,
+    state = EXCLUDED.state,
+    answer_history = EXCLUDED.answer_history,
+    updated_on = NOW(),
+    updated_by = EXCLUDED.updated_by
+`
+
+type UpsertGameStateParams struct {
+	RoomCode      string
+	RoomStatus    string
+	State         []byte
+	AnswerHistory []byte
+	CreatedBy     string
+}
+
+// -------------------------- game state --------------------------------------
+func (q *Queries) UpsertGameState(ctx context.Context, arg UpsertGameStateParams) error {
+	_, err := q.db.Exec(ctx, upsertGameState,
+		arg.RoomCode,
+		arg.RoomStatus,
+		arg.State,
+		arg.AnswerHistory,
+		arg.CreatedBy,
+	)
+	return err
+}
@@ -11,6 +11,23 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countQueuedMatchTickets = `-- name: CountQueuedMatchTickets :one
+SELECT COUNT(*) FROM match_ticket
+WHERE category = $1 AND difficulty = $2 AND status = 'QUEUED'
+`
+
+type CountQueuedMatchTicketsParams struct {
+	Category   string
+	Difficulty string
+}
+
+func (q *Queries) CountQueuedMatchTickets(ctx context.Context, arg CountQueuedMatchTicketsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countQueuedMatchTickets, arg.Category, arg.Difficulty)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const creatLeaderBoard = `-- name: CreatLeaderBoard :exec
 INSERT INTO leaderboard (
   id, 
@@ -66,10 +83,11 @@ INSERT INTO room (
   created_by, 
   updated_by,
   game_type,
-  room_status
+  room_status,
+  is_public
 ) 
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW(), $10, $11, $12,$13)
-RETURNING id, room_code, room_name, room_owner, room_chat, room_meta, room_lock, game_type, room_status, is_active, is_deleted, created_on, updated_on, created_by, updated_by
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW(), $10, $11, $12,$13, $14)
+RETURNING id, room_code, room_name, room_owner, room_chat, room_meta, room_lock, game_type, room_status, is_active, is_deleted, created_on, updated_on, created_by, updated_by, is_public
 `
 
 type CreateRoomParams struct {
@@ -86,6 +104,7 @@ type CreateRoomParams struct {
 	UpdatedBy  string
 	GameType   string
 	RoomStatus string
+	IsPublic   bool
 }
 
 // --------------------------------- room table ---------------------------------------------------------------------
@@ -104,6 +123,7 @@ func (q *Queries) CreateRoom(ctx context.Context, arg CreateRoomParams) (Room, e
 		arg.UpdatedBy,
 		arg.GameType,
 		arg.RoomStatus,
+		arg.IsPublic,
 	)
 	var i Room
 	err := row.Scan(
@@ -122,6 +142,54 @@ func (q *Queries) CreateRoom(ctx context.Context, arg CreateRoomParams) (Room, e
 		&i.UpdatedOn,
 		&i.CreatedBy,
 		&i.UpdatedBy,
+		&i.IsPublic,
+	)
+	return i, err
+}
+
+const createRoomInvite = `-- name: CreateRoomInvite :one
+INSERT INTO room_invite (
+  id,
+  invite_code,
+  room_code,
+  expires_on,
+  created_on,
+  updated_on,
+  created_by,
+  updated_by
+)
+VALUES ($1, $2, $3, NOW() + make_interval(hours => $4::INT), NOW(), NOW(), $5, $5)
+RETURNING id, invite_code, room_code, expires_on, is_revoked, created_on, updated_on, created_by, updated_by
+`
+
+type CreateRoomInviteParams struct {
+	ID          pgtype.UUID
+	InviteCode  string
+	RoomCode    string
+	ExpiryHours int32
+	CreatedBy   string
+}
+
+// ------------------------------------ Room Invite ------------------------------------------------------------------------
+func (q *Queries) CreateRoomInvite(ctx context.Context, arg CreateRoomInviteParams) (RoomInvite, error) {
+	row := q.db.QueryRow(ctx, createRoomInvite,
+		arg.ID,
+		arg.InviteCode,
+		arg.RoomCode,
+		arg.ExpiryHours,
+		arg.CreatedBy,
+	)
+	var i RoomInvite
+	err := row.Scan(
+		&i.ID,
+		&i.InviteCode,
+		&i.RoomCode,
+		&i.ExpiresOn,
+		&i.IsRevoked,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
 	)
 	return i, err
 }
@@ -141,10 +209,11 @@ INSERT INTO room_member (
   created_on, 
   updated_on, 
   created_by, 
-  updated_by    
+  updated_by,
+  role
 )   
-VALUES ($1, $2,$10, $3, $4, NOW(), $5, $6, $7, NOW(), NOW(), $8, $9)
-RETURNING id, room_code, room_id, user_id, is_bot, joined_on, room_member_status, is_active, is_deleted, created_on, updated_on, created_by, updated_by
+VALUES ($1, $2,$10, $3, $4, NOW(), $5, $6, $7, NOW(), NOW(), $8, $9, $11)
+RETURNING id, room_code, room_id, user_id, is_bot, joined_on, room_member_status, is_active, is_deleted, created_on, updated_on, created_by, updated_by, role
 `
 
 type CreateRoomMemberParams struct {
@@ -158,6 +227,7 @@ type CreateRoomMemberParams struct {
 	CreatedBy        string
 	UpdatedBy        string
 	RoomID           pgtype.UUID
+	Role             string
 }
 
 // ------------------------------------ Room Member ------------------------------------------------------------------------
@@ -173,6 +243,7 @@ func (q *Queries) CreateRoomMember(ctx context.Context, arg CreateRoomMemberPara
 		arg.CreatedBy,
 		arg.UpdatedBy,
 		arg.RoomID,
+		arg.Role,
 	)
 	var i RoomMember
 	err := row.Scan(
@@ -189,12 +260,65 @@ func (q *Queries) CreateRoomMember(ctx context.Context, arg CreateRoomMemberPara
 		&i.UpdatedOn,
 		&i.CreatedBy,
 		&i.UpdatedBy,
+		&i.Role,
+	)
+	return i, err
+}
+
+const deleteMatchTicketByUserIDAndStatus = `-- name: DeleteMatchTicketByUserIDAndStatus :exec
+DELETE FROM match_ticket
+WHERE user_id = $1 AND status = $2
+`
+
+type DeleteMatchTicketByUserIDAndStatusParams struct {
+	UserID pgtype.UUID
+	Status string
+}
+
+func (q *Queries) DeleteMatchTicketByUserIDAndStatus(ctx context.Context, arg DeleteMatchTicketByUserIDAndStatusParams) error {
+	_, err := q.db.Exec(ctx, deleteMatchTicketByUserIDAndStatus, arg.UserID, arg.Status)
+	return err
+}
+
+const getActiveRoomInviteByRoomCode = `-- name: GetActiveRoomInviteByRoomCode :one
+SELECT id, invite_code, room_code, expires_on, is_revoked, created_on, updated_on, created_by, updated_by FROM room_invite
+WHERE room_code = $1 AND is_revoked = false AND expires_on > NOW()
+ORDER BY created_on DESC
+LIMIT 1
+`
+
+// latest invite of the room that can still be used
+func (q *Queries) GetActiveRoomInviteByRoomCode(ctx context.Context, roomCode string) (RoomInvite, error) {
+	row := q.db.QueryRow(ctx, getActiveRoomInviteByRoomCode, roomCode)
+	var i RoomInvite
+	err := row.Scan(
+		&i.ID,
+		&i.InviteCode,
+		&i.RoomCode,
+		&i.ExpiresOn,
+		&i.IsRevoked,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
 	)
 	return i, err
 }
 
+const getBestStreakByUserID = `-- name: GetBestStreakByUserID :one
+SELECT COALESCE(MAX(best_streak), 0)::INT AS best_streak FROM leaderboard
+WHERE user_id = $1 AND is_deleted = false
+`
+
+func (q *Queries) GetBestStreakByUserID(ctx context.Context, userID pgtype.UUID) (int32, error) {
+	row := q.db.QueryRow(ctx, getBestStreakByUserID, userID)
+	var best_streak int32
+	err := row.Scan(&best_streak)
+	return best_streak, err
+}
+
 const getLeaderBoardByID = `-- name: GetLeaderBoardByID :many
-SELECT id, room_code, user_id, score, created_on, updated_on, created_by, updated_by, is_deleted FROM leaderboard
+SELECT id, room_code, user_id, score, created_on, updated_on, created_by, updated_by, is_deleted, best_streak FROM leaderboard
 WHERE id = $1 AND is_deleted = false
 `
 
@@ -217,6 +341,7 @@ func (q *Queries) GetLeaderBoardByID(ctx context.Context, id pgtype.UUID) ([]Lea
 			&i.CreatedBy,
 			&i.UpdatedBy,
 			&i.IsDeleted,
+			&i.BestStreak,
 		); err != nil {
 			return nil, err
 		}
@@ -228,8 +353,29 @@ func (q *Queries) GetLeaderBoardByID(ctx context.Context, id pgtype.UUID) ([]Lea
 	return items, nil
 }
 
+const getMatchTicketByUserID = `-- name: GetMatchTicketByUserID :one
+SELECT user_id, username, category, difficulty, status, room_code, queued_on, updated_on FROM match_ticket
+WHERE user_id = $1
+`
+
+func (q *Queries) GetMatchTicketByUserID(ctx context.Context, userID pgtype.UUID) (MatchTicket, error) {
+	row := q.db.QueryRow(ctx, getMatchTicketByUserID, userID)
+	var i MatchTicket
+	err := row.Scan(
+		&i.UserID,
+		&i.Username,
+		&i.Category,
+		&i.Difficulty,
+		&i.Status,
+		&i.RoomCode,
+		&i.QueuedOn,
+		&i.UpdatedOn,
+	)
+	return i, err
+}
+
 const getRoomByID = `-- name: GetRoomByID :many
-SELECT id, room_code, room_name, room_owner, room_chat, room_meta, room_lock, game_type, room_status, is_active, is_deleted, created_on, updated_on, created_by, updated_by FROM room
+SELECT id, room_code, room_name, room_owner, room_chat, room_meta, room_lock, game_type, room_status, is_active, is_deleted, created_on, updated_on, created_by, updated_by, is_public FROM room
 WHERE id = $1 AND is_deleted = false
 `
 
@@ -258,6 +404,7 @@ func (q *Queries) GetRoomByID(ctx context.Context, id pgtype.UUID) ([]Room, erro
 			&i.UpdatedOn,
 			&i.CreatedBy,
 			&i.UpdatedBy,
+			&i.IsPublic,
 		); err != nil {
 			return nil, err
 		}
@@ -270,7 +417,7 @@ func (q *Queries) GetRoomByID(ctx context.Context, id pgtype.UUID) ([]Room, erro
 }
 
 const getRoomByRoomCode = `-- name: GetRoomByRoomCode :many
-SELECT id, room_code, room_name, room_owner, room_chat, room_meta, room_lock, game_type, room_status, is_active, is_deleted, created_on, updated_on, created_by, updated_by FROM room
+SELECT id, room_code, room_name, room_owner, room_chat, room_meta, room_lock, game_type, room_status, is_active, is_deleted, created_on, updated_on, created_by, updated_by, is_public FROM room
 WHERE room_code = $1 AND is_deleted = false
 `
 
@@ -299,6 +446,7 @@ func (q *Queries) GetRoomByRoomCode(ctx context.Context, roomCode string) ([]Roo
 			&i.UpdatedOn,
 			&i.CreatedBy,
 			&i.UpdatedBy,
+			&i.IsPublic,
 		); err != nil {
 			return nil, err
 		}
@@ -311,7 +459,7 @@ func (q *Queries) GetRoomByRoomCode(ctx context.Context, roomCode string) ([]Roo
 }
 
 const getRoomMemberByID = `-- name: GetRoomMemberByID :many
-SELECT room_member.id, room_code, room_id, user_id, is_bot, joined_on, room_member_status, room_member.is_active, room_member.is_deleted, room_member.created_on, room_member.updated_on, room_member.created_by, room_member.updated_by, users.id, auth0_sub, username, user_type, bot_type, user_meta, premium, users.is_active, users.is_deleted, users.created_on, users.updated_on, users.created_by, users.updated_by FROM room_member INNER JOIN users ON room_member.user_id = users.id
+SELECT room_member.id, room_code, room_id, user_id, is_bot, joined_on, room_member_status, room_member.is_active, room_member.is_deleted, room_member.created_on, room_member.updated_on, room_member.created_by, room_member.updated_by, room_member.role, users.id, auth0_sub, username, user_type, bot_type, user_meta, premium, users.is_active, users.is_deleted, users.created_on, users.updated_on, users.created_by, users.updated_by FROM room_member INNER JOIN users ON room_member.user_id = users.id
 WHERE room_member.id = $1 AND room_member.is_deleted = false
 `
 
@@ -329,6 +477,7 @@ type GetRoomMemberByIDRow struct {
 	UpdatedOn        pgtype.Timestamp
 	CreatedBy        string
 	UpdatedBy        string
+	Role             string
 	ID_2             pgtype.UUID
 	Auth0Sub         pgtype.Text
 	Username         string
@@ -367,6 +516,7 @@ func (q *Queries) GetRoomMemberByID(ctx context.Context, id pgtype.UUID) ([]GetR
 			&i.UpdatedOn,
 			&i.CreatedBy,
 			&i.UpdatedBy,
+			&i.Role,
 			&i.ID_2,
 			&i.Auth0Sub,
 			&i.Username,
@@ -392,7 +542,7 @@ func (q *Queries) GetRoomMemberByID(ctx context.Context, id pgtype.UUID) ([]GetR
 }
 
 const getRoomMemberByRoomCodeAndUserID = `-- name: GetRoomMemberByRoomCodeAndUserID :many
-SELECT room_member.id, room_code, room_id, user_id, is_bot, joined_on, room_member_status, room_member.is_active, room_member.is_deleted, room_member.created_on, room_member.updated_on, room_member.created_by, room_member.updated_by, users.id, auth0_sub, username, user_type, bot_type, user_meta, premium, users.is_active, users.is_deleted, users.created_on, users.updated_on, users.created_by, users.updated_by FROM room_member INNER JOIN users ON room_member.user_id = users.id
+SELECT room_member.id, room_code, room_id, user_id, is_bot, joined_on, room_member_status, room_member.is_active, room_member.is_deleted, room_member.created_on, room_member.updated_on, room_member.created_by, room_member.updated_by, room_member.role, users.id, auth0_sub, username, user_type, bot_type, user_meta, premium, users.is_active, users.is_deleted, users.created_on, users.updated_on, users.created_by, users.updated_by FROM room_member INNER JOIN users ON room_member.user_id = users.id
 WHERE room_code = $1 AND user_id = $2 AND room_member.is_deleted = false
 `
 
@@ -415,6 +565,7 @@ type GetRoomMemberByRoomCodeAndUserIDRow struct {
 	UpdatedOn        pgtype.Timestamp
 	CreatedBy        string
 	UpdatedBy        string
+	Role             string
 	ID_2             pgtype.UUID
 	Auth0Sub         pgtype.Text
 	Username         string
@@ -453,6 +604,7 @@ func (q *Queries) GetRoomMemberByRoomCodeAndUserID(ctx context.Context, arg GetR
 			&i.UpdatedOn,
 			&i.CreatedBy,
 			&i.UpdatedBy,
+			&i.Role,
 			&i.ID_2,
 			&i.Auth0Sub,
 			&i.Username,
@@ -477,8 +629,31 @@ func (q *Queries) GetRoomMemberByRoomCodeAndUserID(ctx context.Context, arg GetR
 	return items, nil
 }
 
+const getValidRoomInviteByInviteCode = `-- name: GetValidRoomInviteByInviteCode :one
+SELECT id, invite_code, room_code, expires_on, is_revoked, created_on, updated_on, created_by, updated_by FROM room_invite
+WHERE invite_code = $1 AND is_revoked = false AND expires_on > NOW()
+`
+
+// the invite is left out once it is revoked or expired
+func (q *Queries) GetValidRoomInviteByInviteCode(ctx context.Context, inviteCode string) (RoomInvite, error) {
+	row := q.db.QueryRow(ctx, getValidRoomInviteByInviteCode, inviteCode)
+	var i RoomInvite
+	err := row.Scan(
+		&i.ID,
+		&i.InviteCode,
+		&i.RoomCode,
+		&i.ExpiresOn,
+		&i.IsRevoked,
+		&i.CreatedOn,
+		&i.UpdatedOn,
+		&i.CreatedBy,
+		&i.UpdatedBy,
+	)
+	return i, err
+}
+
 const listLeaderBoardByRoomCode = `-- name: ListLeaderBoardByRoomCode :many
-SELECT id, room_code, user_id, score, created_on, updated_on, created_by, updated_by, is_deleted FROM leaderboard
+SELECT id, room_code, user_id, score, created_on, updated_on, created_by, updated_by, is_deleted, best_streak FROM leaderboard
 WHERE room_code = $1 AND is_deleted = false 
 ORDER BY score DESC
 `
@@ -502,6 +677,116 @@ func (q *Queries) ListLeaderBoardByRoomCode(ctx context.Context, roomCode string
 			&i.CreatedBy,
 			&i.UpdatedBy,
 			&i.IsDeleted,
+			&i.BestStreak,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPublicRooms = `-- name: ListPublicRooms :many
+SELECT r.room_code, r.room_name, r.game_type, r.created_on,
+  COALESCE(q.topic, j.topic, '')::TEXT AS topic,
+  COALESCE(q.difficulty, '')::TEXT AS difficulty,
+  (SELECT COUNT(*) FROM room_member rm
+    WHERE rm.room_id = r.id AND rm.is_deleted = false
+      AND rm.room_member_status NOT IN ('LEAVE_QUIZ', 'KICKED_QUIZ', 'BANNED_QUIZ'))::INT AS member_count
+FROM room r
+LEFT JOIN question q ON q.room_code = r.room_code
+LEFT JOIN generation_job j ON j.room_code = r.room_code
+WHERE r.is_public = true
+  AND r.room_lock = false
+  AND r.room_status = 'WAITING'
+  AND r.game_type <> 'SINGLE_PLAYER'
+  AND r.is_deleted = false
+ORDER BY r.created_on DESC
+LIMIT $1
+`
+
+type ListPublicRoomsRow struct {
+	RoomCode    string
+	RoomName    pgtype.Text
+	GameType    string
+	CreatedOn   pgtype.Timestamp
+	Topic       string
+	Difficulty  string
+	MemberCount int32
+}
+
+// open lobbies of the room browser, the topic comes from the generation job while the questions are generated
+func (q *Queries) ListPublicRooms(ctx context.Context, limit int32) ([]ListPublicRoomsRow, error) {
+	rows, err := q.db.Query(ctx, listPublicRooms, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListPublicRoomsRow
+	for rows.Next() {
+		var i ListPublicRoomsRow
+		if err := rows.Scan(
+			&i.RoomCode,
+			&i.RoomName,
+			&i.GameType,
+			&i.CreatedOn,
+			&i.Topic,
+			&i.Difficulty,
+			&i.MemberCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listQueuedMatchTickets = `-- name: ListQueuedMatchTickets :many
+SELECT user_id, username, category, difficulty, status, room_code, queued_on, updated_on, (queued_on <= NOW() - make_interval(secs => $1::INT))::BOOL AS waited
+FROM match_ticket
+WHERE status = 'QUEUED'
+ORDER BY category, difficulty, queued_on
+FOR UPDATE
+`
+
+type ListQueuedMatchTicketsRow struct {
+	UserID     pgtype.UUID
+	Username   string
+	Category   string
+	Difficulty string
+	Status     string
+	RoomCode   string
+	QueuedOn   pgtype.Timestamp
+	UpdatedOn  pgtype.Timestamp
+	Waited     bool
+}
+
+// the tickets stay locked till the transaction ends so two instances dont put a player in two rooms
+func (q *Queries) ListQueuedMatchTickets(ctx context.Context, waitSeconds int32) ([]ListQueuedMatchTicketsRow, error) {
+	rows, err := q.db.Query(ctx, listQueuedMatchTickets, waitSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListQueuedMatchTicketsRow
+	for rows.Next() {
+		var i ListQueuedMatchTicketsRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Username,
+			&i.Category,
+			&i.Difficulty,
+			&i.Status,
+			&i.RoomCode,
+			&i.QueuedOn,
+			&i.UpdatedOn,
+			&i.Waited,
 		); err != nil {
 			return nil, err
 		}
@@ -514,7 +799,7 @@ func (q *Queries) ListLeaderBoardByRoomCode(ctx context.Context, roomCode string
 }
 
 const listRoomMembersByRoomCode = `-- name: ListRoomMembersByRoomCode :many
-SELECT room_member.id, room_code, room_id, user_id, is_bot, joined_on, room_member_status, room_member.is_active, room_member.is_deleted, room_member.created_on, room_member.updated_on, room_member.created_by, room_member.updated_by, users.id, auth0_sub, username, user_type, bot_type, user_meta, premium, users.is_active, users.is_deleted, users.created_on, users.updated_on, users.created_by, users.updated_by FROM room_member INNER JOIN users ON room_member.user_id = users.id
+SELECT room_member.id, room_code, room_id, user_id, is_bot, joined_on, room_member_status, room_member.is_active, room_member.is_deleted, room_member.created_on, room_member.updated_on, room_member.created_by, room_member.updated_by, room_member.role, users.id, auth0_sub, username, user_type, bot_type, user_meta, premium, users.is_active, users.is_deleted, users.created_on, users.updated_on, users.created_by, users.updated_by FROM room_member INNER JOIN users ON room_member.user_id = users.id
 WHERE room_code = $1 AND room_member.is_deleted = false
 `
 
@@ -532,6 +817,7 @@ type ListRoomMembersByRoomCodeRow struct {
 	UpdatedOn        pgtype.Timestamp
 	CreatedBy        string
 	UpdatedBy        string
+	Role             string
 	ID_2             pgtype.UUID
 	Auth0Sub         pgtype.Text
 	Username         string
@@ -570,6 +856,7 @@ func (q *Queries) ListRoomMembersByRoomCode(ctx context.Context, roomCode string
 			&i.UpdatedOn,
 			&i.CreatedBy,
 			&i.UpdatedBy,
+			&i.Role,
 			&i.ID_2,
 			&i.Auth0Sub,
 			&i.Username,
@@ -595,7 +882,7 @@ func (q *Queries) ListRoomMembersByRoomCode(ctx context.Context, roomCode string
 }
 
 const listRoomsByUserID = `-- name: ListRoomsByUserID :many
-SELECT r.id, r.room_code, r.room_name, r.room_owner, r.room_chat, r.room_meta, r.room_lock, r.game_type, r.room_status, r.is_active, r.is_deleted, r.created_on, r.updated_on, r.created_by, r.updated_by,q.topic,q.time_limit
+SELECT r.id, r.room_code, r.room_name, r.room_owner, r.room_chat, r.room_meta, r.room_lock, r.game_type, r.room_status, r.is_active, r.is_deleted, r.created_on, r.updated_on, r.created_by, r.updated_by,q.topic,q.time_limit,q.difficulty
 FROM room r
 INNER JOIN room_member rm ON rm.room_id = r.id
 inner join question q on r.room_code = q.room_code
@@ -623,6 +910,7 @@ type ListRoomsByUserIDRow struct {
 	UpdatedBy  string
 	Topic      pgtype.Text
 	TimeLimit  int32
+	Difficulty string
 }
 
 func (q *Queries) ListRoomsByUserID(ctx context.Context, userID pgtype.UUID) ([]ListRoomsByUserIDRow, error) {
@@ -652,6 +940,7 @@ func (q *Queries) ListRoomsByUserID(ctx context.Context, userID pgtype.UUID) ([]
 			&i.UpdatedBy,
 			&i.Topic,
 			&i.TimeLimit,
+			&i.Difficulty,
 		); err != nil {
 			return nil, err
 		}
@@ -663,6 +952,25 @@ func (q *Queries) ListRoomsByUserID(ctx context.Context, userID pgtype.UUID) ([]
 	return items, nil
 }
 
+const revokeRoomInvitesByRoomCode = `-- name: RevokeRoomInvitesByRoomCode :exec
+UPDATE room_invite
+SET
+  is_revoked = true,
+  updated_on = NOW(),
+  updated_by = $2
+WHERE room_code = $1 AND is_revoked = false
+`
+
+type RevokeRoomInvitesByRoomCodeParams struct {
+	RoomCode  string
+	UpdatedBy string
+}
+
+func (q *Queries) RevokeRoomInvitesByRoomCode(ctx context.Context, arg RevokeRoomInvitesByRoomCodeParams) error {
+	_, err := q.db.Exec(ctx, revokeRoomInvitesByRoomCode, arg.RoomCode, arg.UpdatedBy)
+	return err
+}
+
 const updateLeaderBoardScoreByID = `-- name: UpdateLeaderBoardScoreByID :exec
 UPDATE leaderboard
 SET 
@@ -687,16 +995,18 @@ const updateLeaderBoardScoreByUserIDAndRoomCode = `-- name: UpdateLeaderBoardSco
 UPDATE leaderboard
 SET 
   score = $3,
+  best_streak = $5,
   updated_on = NOW(),
   updated_by = $4
 WHERE room_code = $1 AND user_id = $2 AND is_deleted = false
 `
 
 type UpdateLeaderBoardScoreByUserIDAndRoomCodeParams struct {
-	RoomCode  string
-	UserID    pgtype.UUID
-	Score     float64
-	UpdatedBy string
+	RoomCode   string
+	UserID     pgtype.UUID
+	Score      float64
+	UpdatedBy  string
+	BestStreak int32
 }
 
 func (q *Queries) UpdateLeaderBoardScoreByUserIDAndRoomCode(ctx context.Context, arg UpdateLeaderBoardScoreByUserIDAndRoomCodeParams) error {
@@ -705,10 +1015,30 @@ func (q *Queries) UpdateLeaderBoardScoreByUserIDAndRoomCode(ctx context.Context,
 		arg.UserID,
 		arg.Score,
 		arg.UpdatedBy,
+		arg.BestStreak,
 	)
 	return err
 }
 
+const updateMatchTicketStatusByUserIDs = `-- name: UpdateMatchTicketStatusByUserIDs :exec
+UPDATE match_ticket
+SET status = $1,
+    room_code = $2,
+    updated_on = NOW()
+WHERE user_id = ANY($3::UUID[])
+`
+
+type UpdateMatchTicketStatusByUserIDsParams struct {
+	Status   string
+	RoomCode string
+	UserIds  []pgtype.UUID
+}
+
+func (q *Queries) UpdateMatchTicketStatusByUserIDs(ctx context.Context, arg UpdateMatchTicketStatusByUserIDsParams) error {
+	_, err := q.db.Exec(ctx, updateMatchTicketStatusByUserIDs, arg.Status, arg.RoomCode, arg.UserIds)
+	return err
+}
+
 const updateRoomByID = `-- name: UpdateRoomByID :exec
 UPDATE room
 SET 
@@ -751,6 +1081,26 @@ func (q *Queries) UpdateRoomByID(ctx context.Context, arg UpdateRoomByIDParams)
 	return err
 }
 
+const updateRoomLockByRoomCode = `-- name: UpdateRoomLockByRoomCode :exec
+UPDATE room
+SET 
+  room_lock = $2,
+  updated_on = NOW(),
+  updated_by = $3
+WHERE room_code = $1 AND is_deleted = false
+`
+
+type UpdateRoomLockByRoomCodeParams struct {
+	RoomCode  string
+	RoomLock  bool
+	UpdatedBy string
+}
+
+func (q *Queries) UpdateRoomLockByRoomCode(ctx context.Context, arg UpdateRoomLockByRoomCodeParams) error {
+	_, err := q.db.Exec(ctx, updateRoomLockByRoomCode, arg.RoomCode, arg.RoomLock, arg.UpdatedBy)
+	return err
+}
+
 const updateRoomMemberByID = `-- name: UpdateRoomMemberByID :exec
 UPDATE room_member
 SET 
@@ -833,3 +1183,67 @@ func (q *Queries) UpdateRoomMetaAndStatusByRoomCode(ctx context.Context, arg Upd
 	)
 	return err
 }
+
+const updateRoomStatusByRoomCode = `-- name: UpdateRoomStatusByRoomCode :exec
+UPDATE room
+SET 
+  room_status = $2,
+  updated_on = NOW(),
+  updated_by = $3
+WHERE room_code = $1 AND is_deleted = false
+`
+
+type UpdateRoomStatusByRoomCodeParams struct {
+	RoomCode   string
+	RoomStatus string
+	UpdatedBy  string
+}
+
+func (q *Queries) UpdateRoomStatusByRoomCode(ctx context.Context, arg UpdateRoomStatusByRoomCodeParams) error {
+	_, err := q.db.Exec(ctx, updateRoomStatusByRoomCode, arg.RoomCode, arg.RoomStatus, arg.UpdatedBy)
+	return err
+}
+
+const upsertMatchTicket = `-- name: UpsertMatchTicket :exec
+INSERT INTO match_ticket (
+  user_id,
+  username,
+  category,
+  difficulty,
+  status,
+  room_code,
+  queued_on,
+  updated_on
+)
+VALUES ($1, $2, $3, $4, 'QUEUED', '', NOW(), NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET username = EXCLUDED.username,
+    category = EXCLUDED.category,
+    difficulty = EXCLUDED.difficulty,
+    status = 'QUEUED',
+    room_code = '',
+    queued_on = NOW(),
+    updated_on = NOW()
+WHERE match_ticket.status <> 'FORMING'
+OR match_ticket.updated_on < NOW() - make_interval(secs => $5::INT)
+`
+
+type UpsertMatchTicketParams struct {
+	UserID       pgtype.UUID
+	Username     string
+	Category     string
+	Difficulty   string
+	StaleSeconds int32
+}
+
+// a ticket whose room is being set up is kept, unless the instance setting it up went away
+func (q *Queries) UpsertMatchTicket(ctx context.Context, arg UpsertMatchTicketParams) error {
+	_, err := q.db.Exec(ctx, upsertMatchTicket,
+		arg.UserID,
+		arg.Username,
+		arg.Category,
+		arg.Difficulty,
+		arg.StaleSeconds,
+	)
+	return err
+}
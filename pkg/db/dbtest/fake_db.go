@@ -0,0 +1,247 @@
+// Package dbtest has a fake of the connection pool so the services can be tested without postgres.
+// The queries are told apart by their sqlc name, a test tells the fake which rows a query returns and
+// looks at the calls it got afterwards. A query nobody set up returns no rows.
+
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Handler answers a query with its rows, every row holds the values of the columns in the order they are scanned
+type Handler func(args []any) ([][]any, error)
+
+// Call is a query the fake got
+type Call struct {
+	Name string
+	Args []any
+}
+
+// FakeDB stands in for the pgx pool
+type FakeDB struct {
+	mu        sync.Mutex
+	handlers  map[string]Handler
+	calls     []Call
+	commits   int
+	rollbacks int
+}
+
+func New() *FakeDB {
+	return &FakeDB{handlers: make(map[string]Handler)}
+}
+
+// On sets the handler of the query with the sqlc name
+func (f *FakeDB) On(name string, handler Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[name] = handler
+}
+
+// Returns makes the query return the rows every time
+func (f *FakeDB) Returns(name string, rows ...[]any) {
+	f.On(name, func(args []any) ([][]any, error) { return rows, nil })
+}
+
+// Fails makes the query fail with the error
+func (f *FakeDB) Fails(name string, err error) {
+	f.On(name, func(args []any) ([][]any, error) { return nil, err })
+}
+
+// Calls lists the calls of the query with the sqlc name
+func (f *FakeDB) Calls(name string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	calls := []Call{}
+	for _, call := range f.calls {
+		if call.Name == name {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Commits is the number of transactions committed
+func (f *FakeDB) Commits() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.commits
+}
+
+// Rollbacks is the number of transactions rolled back
+func (f *FakeDB) Rollbacks() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rollbacks
+}
+
+// queryName is the sqlc name in the "-- name: X :kind" line of the query
+func queryName(query string) string {
+	_, after, found := strings.Cut(query, "-- name: ")
+	if !found {
+		return ""
+	}
+	fields := strings.Fields(after)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func (f *FakeDB) run(query string, args []any) ([][]any, error) {
+	name := queryName(query)
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{Name: name, Args: args})
+	handler, ok := f.handlers[name]
+	f.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	return handler(args)
+}
+
+func (f *FakeDB) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	rows, err := f.run(query, args)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pgconn.NewCommandTag(fmt.Sprintf("UPDATE %d", len(rows))), nil
+}
+
+func (f *FakeDB) Query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	rows, err := f.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows, index: -1}, nil
+}
+
+func (f *FakeDB) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	rows, err := f.run(query, args)
+	return &fakeRow{rows: rows, err: err}
+}
+
+// BeginTx runs the transaction on the fake itself, commit and rollback are only counted
+func (f *FakeDB) BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error) {
+	return &fakeTx{db: f}, nil
+}
+
+type fakeTx struct {
+	pgx.Tx // the methods the services do not use are left out
+	db     *FakeDB
+}
+
+func (t *fakeTx) Exec(ctx context.Context, query string, args ...any) (pgconn.CommandTag, error) {
+	return t.db.Exec(ctx, query, args...)
+}
+
+func (t *fakeTx) Query(ctx context.Context, query string, args ...any) (pgx.Rows, error) {
+	return t.db.Query(ctx, query, args...)
+}
+
+func (t *fakeTx) QueryRow(ctx context.Context, query string, args ...any) pgx.Row {
+	return t.db.QueryRow(ctx, query, args...)
+}
+
+func (t *fakeTx) Commit(ctx context.Context) error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.commits++
+	return nil
+}
+
+func (t *fakeTx) Rollback(ctx context.Context) error {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.db.rollbacks++
+	return nil
+}
+
+type fakeRow struct {
+	rows [][]any
+	err  error
+}
+
+func (r *fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.rows) == 0 {
+		return pgx.ErrNoRows
+	}
+	return scanRow(r.rows[0], dest)
+}
+
+type fakeRows struct {
+	rows  [][]any
+	index int
+}
+
+func (r *fakeRows) Close()                                       {}
+func (r *fakeRows) Err() error                                   { return nil }
+func (r *fakeRows) CommandTag() pgconn.CommandTag                { return pgconn.NewCommandTag("SELECT") }
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *fakeRows) RawValues() [][]byte                          { return nil }
+func (r *fakeRows) Conn() *pgx.Conn                              { return nil }
+
+func (r *fakeRows) Next() bool {
+	r.index++
+	return r.index < len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	return scanRow(r.rows[r.index], dest)
+}
+
+func (r *fakeRows) Values() ([]any, error) {
+	return r.rows[r.index], nil
+}
+
+func scanRow(row []any, dest []any) error {
+	if len(row) != len(dest) {
+		return fmt.Errorf("dbtest: row has %d columns, scanned into %d", len(row), len(dest))
+	}
+	for i := range dest {
+		err := assign(dest[i], row[i])
+		if err != nil {
+			return fmt.Errorf("dbtest: column %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// assign puts the value in the pointer the way pgx would, the pgtype values scan themselves
+func assign(dest any, value any) error {
+	target := reflect.ValueOf(dest)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return fmt.Errorf("cannot scan into %T", dest)
+	}
+	target = target.Elem()
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(target.Type()) {
+		target.Set(v)
+		return nil
+	}
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(value)
+	}
+	if v.Kind() != reflect.String && target.Kind() == reflect.String {
+		return fmt.Errorf("cannot scan %T into %T", value, dest)
+	}
+	if v.Type().ConvertibleTo(target.Type()) {
+		target.Set(v.Convert(target.Type()))
+		return nil
+	}
+	return fmt.Errorf("cannot scan %T into %T", value, dest)
+}
@@ -9,8 +9,9 @@ INSERT INTO question (id,
     created_by,
     updated_by, 
     created_on, 
-    updated_on)
-VALUES ($7,$1, $2, $3, $4, $5,$6, $8,NOW(), NOW());
+    updated_on,
+    difficulty)
+VALUES ($7,$1, $2, $3, $4, $5,$6, $8,NOW(), NOW(), $9);
 
 -- name: UpdateQuestionByID :exec
 UPDATE question
@@ -44,8 +45,14 @@ INSERT INTO answer (id,
     created_by,
     updated_by,
     created_on,
-    updated_on)
-VALUES ($10,$1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW());
+    updated_on,
+    latency_ms,
+    flag_reason,
+    points,
+    score_reason,
+    power_ups,
+    answer_data)
+VALUES ($10,$1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW(), $11, $12, $13, $14, $15, $16);
 
 -- name: UpdateAnswer :exec
 UPDATE answer
@@ -67,3 +74,150 @@ SELECT *
 FROM answer
 WHERE room_code = $1
 ORDER BY created_on ASC;
+
+---------------------------- game state --------------------------------------
+
+-- name: UpsertGameState :exec
+INSERT INTO game_state (room_code,
+    room_status,
+    state,
+    answer_history,
+    created_by,
+    updated_by,
+    created_on,
+    updated_on)
+VALUES ($1, $2, $3, $4, $5, $5, NOW(), NOW())
+ON CONFLICT (room_code) DO UPDATE
+SET room_status = EXCLUDED.room_status,
+    state = EXCLUDED.state,
+    answer_history = EXCLUDED.answer_history,
+    updated_on = NOW(),
+    updated_by = EXCLUDED.updated_by;
+
+-- name: ListGameStatesByRoomStatus :many
+SELECT gs.*
+FROM game_state gs
+INNER JOIN room r ON r.room_code = gs.room_code
+WHERE gs.room_status = $1
+AND r.is_deleted = false;
+
+-- name: DeleteGameStateByRoomCode :exec
+DELETE FROM game_state
+WHERE room_code = $1;
+
+---------------------------- generation job --------------------------------------
+
+-- name: CreateGenerationJob :one
+INSERT INTO generation_job (id,
+    room_code,
+    topic,
+    question_count,
+    status,
+    created_by,
+    updated_by,
+    created_on,
+    updated_on,
+    instance_id)
+VALUES ($1, $2, $3, $4, $5, $6, $6, NOW(), NOW(), $7)
+RETURNING *;
+
+-- name: FailInstanceGenerationJobs :many
+UPDATE generation_job
+SET status = 'FAILED',
+    error_message = @error_message,
+    updated_on = NOW()
+WHERE status IN ('PENDING', 'RUNNING')
+AND instance_id = @instance_id
+RETURNING *;
+
+-- name: FailStaleGenerationJobs :many
+UPDATE generation_job
+SET status = 'FAILED',
+    error_message = @error_message,
+    updated_on = NOW()
+WHERE status IN ('PENDING', 'RUNNING')
+AND updated_on < NOW() - make_interval(secs => @stale_seconds::INT)
+RETURNING *;
+
+-- name: GetLatestGenerationJobByRoomCode :one
+SELECT *
+FROM generation_job
+WHERE room_code = $1
+ORDER BY created_on DESC
+LIMIT 1;
+
+-- name: UpdateGenerationJobStatus :one
+UPDATE generation_job
+SET status = $2,
+    error_message = $3,
+    updated_on = NOW()
+WHERE id = $1
+RETURNING *;
+
+---------------------------- question bank --------------------------------------
+
+-- name: CreateBankQuestion :one
+INSERT INTO bank_question (id,
+    user_id,
+    question,
+    options,
+    answer,
+    difficulty,
+    tags,
+    created_by,
+    updated_by,
+    created_on,
+    updated_on,
+    time_limit,
+    question_type,
+    answer_key)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, NOW(), NOW(), $9, $10, $11)
+RETURNING *;
+
+-- name: UpdateBankQuestion :one
+UPDATE bank_question
+SET question = $3,
+    options = $4,
+    answer = $5,
+    difficulty = $6,
+    tags = $7,
+    time_limit = $9,
+    question_type = $10,
+    answer_key = $11,
+    updated_on = NOW(),
+    updated_by = $8
+WHERE id = $1
+AND user_id = $2
+AND is_deleted = false
+RETURNING *;
+
+-- name: DeleteBankQuestion :exec
+UPDATE bank_question
+SET is_deleted = true,
+    updated_on = NOW(),
+    updated_by = $3
+WHERE id = $1
+AND user_id = $2;
+
+-- name: GetBankQuestionByID :one
+SELECT *
+FROM bank_question
+WHERE id = $1
+AND user_id = $2
+AND is_deleted = false;
+
+-- name: ListBankQuestionsByUserID :many
+SELECT *
+FROM bank_question
+WHERE user_id = @user_id
+AND is_deleted = false
+AND (@search::TEXT = '' OR question ILIKE '%' || @search::TEXT || '%')
+AND (@tag::TEXT = '' OR @tag::TEXT = ANY(tags))
+ORDER BY updated_on DESC;
+
+-- name: ListBankQuestionsByIDs :many
+SELECT *
+FROM bank_question
+WHERE user_id = @user_id
+AND id = ANY(@ids::UUID[])
+AND is_deleted = false;
@@ -4,11 +4,12 @@ CREATE TABLE IF NOT EXISTS question (
   topic TEXT,
   question_count INT NOT NULL,
   question_data JSONB NOT NULL,
-  time_limit INT NOT NULL, -- max time for each question
+  time_limit INT NOT NULL, -- max time for each question in seconds
   created_on TIMESTAMP NOT NULL DEFAULT NOW(),
   updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
   created_by TEXT NOT NULL,
-  updated_by TEXT NOT NULL
+  updated_by TEXT NOT NULL,
+  difficulty TEXT NOT NULL DEFAULT 'easy' -- easy, medium or hard
 );
 
 -- everybody in the room's answers will be stored here
@@ -24,5 +25,57 @@ CREATE TABLE IF NOT EXISTS answer (
   created_on TIMESTAMP NOT NULL DEFAULT NOW(),
   updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
   created_by TEXT NOT NULL,
+  updated_by TEXT NOT NULL,
+  latency_ms INT NOT NULL DEFAULT 0, -- time from the question being sent to the answer reaching the server
+  flag_reason TEXT NOT NULL DEFAULT '', -- set when the latency is not humanly possible
+  points INT NOT NULL DEFAULT 0, -- what the question added to the score
+  score_reason TEXT NOT NULL DEFAULT '', -- how the scoring rule of the room came up with the points
+  power_ups TEXT[] NOT NULL DEFAULT '{}', -- power-ups the player used on the question
+  answer_data JSONB NOT NULL DEFAULT '{}' -- answer of the question types which are not single choice
+);
+
+-- snapshot of the in memory game state of a room which is checkpointed at every question transition
+CREATE TABLE IF NOT EXISTS game_state (
+  room_code TEXT PRIMARY KEY,
+  room_status TEXT NOT NULL,
+  state JSONB NOT NULL,
+  answer_history JSONB NOT NULL, -- every player's answers which are not yet stored in the answer table
+  created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_by TEXT NOT NULL,
   updated_by TEXT NOT NULL
 );
+
+-- background question generation job of a room
+CREATE TABLE IF NOT EXISTS generation_job (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  room_code TEXT NOT NULL,
+  topic TEXT,
+  question_count INT NOT NULL,
+  status TEXT NOT NULL, -- PENDING, RUNNING, SUCCEEDED, FAILED
+  error_message TEXT,
+  created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_by TEXT NOT NULL,
+  updated_by TEXT NOT NULL,
+  instance_id TEXT NOT NULL DEFAULT '' -- instance running the job
+);
+
+-- personal question bank of a user, the questions can be reused across rooms
+CREATE TABLE IF NOT EXISTS bank_question (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  question TEXT NOT NULL,
+  options JSONB NOT NULL,
+  answer INT NOT NULL, -- option id of the right answer
+  difficulty TEXT NOT NULL DEFAULT 'easy',
+  tags TEXT[] NOT NULL DEFAULT '{}',
+  is_deleted BOOLEAN NOT NULL DEFAULT false,
+  created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_by TEXT NOT NULL,
+  updated_by TEXT NOT NULL,
+  time_limit INT NOT NULL DEFAULT 0, -- seconds, 0 means the time limit of the room
+  question_type TEXT NOT NULL DEFAULT 'single_choice',
+  answer_key JSONB NOT NULL DEFAULT '{}' -- answer of the types which are not single choice
+);
@@ -15,13 +15,14 @@ INSERT INTO room (
   created_by, 
   updated_by,
   game_type,
-  room_status
+  room_status,
+  is_public
 ) 
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW(), $10, $11, $12,$13)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW(), $10, $11, $12,$13, $14)
 RETURNING *;
 
 -- name: ListRoomsByUserID :many
-SELECT r.*,q.topic,q.time_limit
+SELECT r.*,q.topic,q.time_limit,q.difficulty
 FROM room r
 INNER JOIN room_member rm ON rm.room_id = r.id
 inner join question q on r.room_code = q.room_code
@@ -62,6 +63,41 @@ SET
   updated_by = $4
 WHERE room_code = $1 AND is_deleted = false;
 
+-- name: UpdateRoomStatusByRoomCode :exec
+UPDATE room
+SET 
+  room_status = $2,
+  updated_on = NOW(),
+  updated_by = $3
+WHERE room_code = $1 AND is_deleted = false;
+
+-- name: UpdateRoomLockByRoomCode :exec
+UPDATE room
+SET 
+  room_lock = $2,
+  updated_on = NOW(),
+  updated_by = $3
+WHERE room_code = $1 AND is_deleted = false;
+
+-- name: ListPublicRooms :many
+-- open lobbies of the room browser, the topic comes from the generation job while the questions are generated
+SELECT r.room_code, r.room_name, r.game_type, r.created_on,
+  COALESCE(q.topic, j.topic, '')::TEXT AS topic,
+  COALESCE(q.difficulty, '')::TEXT AS difficulty,
+  (SELECT COUNT(*) FROM room_member rm
+    WHERE rm.room_id = r.id AND rm.is_deleted = false
+      AND rm.room_member_status NOT IN ('LEAVE_QUIZ', 'KICKED_QUIZ', 'BANNED_QUIZ'))::INT AS member_count
+FROM room r
+LEFT JOIN question q ON q.room_code = r.room_code
+LEFT JOIN generation_job j ON j.room_code = r.room_code
+WHERE r.is_public = true
+  AND r.room_lock = false
+  AND r.room_status = 'WAITING'
+  AND r.game_type <> 'SINGLE_PLAYER'
+  AND r.is_deleted = false
+ORDER BY r.created_on DESC
+LIMIT $1;
+
 
 -------------------------------------- Room Member ------------------------------------------------------------------------
 
@@ -79,9 +115,10 @@ INSERT INTO room_member (
   created_on, 
   updated_on, 
   created_by, 
-  updated_by    
+  updated_by,
+  role
 )   
-VALUES ($1, $2,$10, $3, $4, NOW(), $5, $6, $7, NOW(), NOW(), $8, $9)
+VALUES ($1, $2,$10, $3, $4, NOW(), $5, $6, $7, NOW(), NOW(), $8, $9, $11)
 RETURNING *;
 
 -- name: ListRoomMembersByRoomCode :many
@@ -134,6 +171,10 @@ SELECT * FROM leaderboard
 WHERE room_code = $1 AND is_deleted = false 
 ORDER BY score DESC;
 
+-- name: GetBestStreakByUserID :one
+SELECT COALESCE(MAX(best_streak), 0)::INT AS best_streak FROM leaderboard
+WHERE user_id = $1 AND is_deleted = false;
+
 -- name: GetLeaderBoardByID :many
 SELECT * FROM leaderboard
 WHERE id = $1 AND is_deleted = false;
@@ -150,6 +191,98 @@ WHERE id = $1 AND is_deleted = false;
 UPDATE leaderboard
 SET 
   score = $3,
+  best_streak = $5,
   updated_on = NOW(),
   updated_by = $4
 WHERE room_code = $1 AND user_id = $2 AND is_deleted = false;
+
+
+-------------------------------------- Room Invite ------------------------------------------------------------------------
+
+-- name: CreateRoomInvite :one
+INSERT INTO room_invite (
+  id,
+  invite_code,
+  room_code,
+  expires_on,
+  created_on,
+  updated_on,
+  created_by,
+  updated_by
+)
+VALUES ($1, $2, $3, NOW() + make_interval(hours => $4::INT), NOW(), NOW(), $5, $5)
+RETURNING *;
+
+-- name: GetValidRoomInviteByInviteCode :one
+-- the invite is left out once it is revoked or expired
+SELECT * FROM room_invite
+WHERE invite_code = $1 AND is_revoked = false AND expires_on > NOW();
+
+-- name: GetActiveRoomInviteByRoomCode :one
+-- latest invite of the room that can still be used
+SELECT * FROM room_invite
+WHERE room_code = $1 AND is_revoked = false AND expires_on > NOW()
+ORDER BY created_on DESC
+LIMIT 1;
+
+-- name: RevokeRoomInvitesByRoomCode :exec
+UPDATE room_invite
+SET
+  is_revoked = true,
+  updated_on = NOW(),
+  updated_by = $2
+WHERE room_code = $1 AND is_revoked = false;
+
+
+-------------------------------------- Match Ticket ------------------------------------------------------------------------
+
+-- name: UpsertMatchTicket :exec
+-- a ticket whose room is being set up is kept, unless the instance setting it up went away
+INSERT INTO match_ticket (
+  user_id,
+  username,
+  category,
+  difficulty,
+  status,
+  room_code,
+  queued_on,
+  updated_on
+)
+VALUES (@user_id, @username, @category, @difficulty, 'QUEUED', '', NOW(), NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET username = EXCLUDED.username,
+    category = EXCLUDED.category,
+    difficulty = EXCLUDED.difficulty,
+    status = 'QUEUED',
+    room_code = '',
+    queued_on = NOW(),
+    updated_on = NOW()
+WHERE match_ticket.status <> 'FORMING'
+OR match_ticket.updated_on < NOW() - make_interval(secs => @stale_seconds::INT);
+
+-- name: GetMatchTicketByUserID :one
+SELECT * FROM match_ticket
+WHERE user_id = $1;
+
+-- name: CountQueuedMatchTickets :one
+SELECT COUNT(*) FROM match_ticket
+WHERE category = $1 AND difficulty = $2 AND status = 'QUEUED';
+
+-- name: ListQueuedMatchTickets :many
+-- the tickets stay locked till the transaction ends so two instances dont put a player in two rooms
+SELECT *, (queued_on <= NOW() - make_interval(secs => @wait_seconds::INT))::BOOL AS waited
+FROM match_ticket
+WHERE status = 'QUEUED'
+ORDER BY category, difficulty, queued_on
+FOR UPDATE;
+
+-- name: UpdateMatchTicketStatusByUserIDs :exec
+UPDATE match_ticket
+SET status = @status,
+    room_code = @room_code,
+    updated_on = NOW()
+WHERE user_id = ANY(@user_ids::UUID[]);
+
+-- name: DeleteMatchTicketByUserIDAndStatus :exec
+DELETE FROM match_ticket
+WHERE user_id = $1 AND status = $2;
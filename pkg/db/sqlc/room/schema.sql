@@ -13,7 +13,8 @@ CREATE TABLE IF NOT EXISTS room (
   created_on TIMESTAMP NOT NULL DEFAULT NOW(),
   updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
   created_by TEXT NOT NULL,
-  updated_by TEXT NOT NULL
+  updated_by TEXT NOT NULL,
+  is_public BOOLEAN NOT NULL DEFAULT false -- listed in the room browser
 );
 
 CREATE TABLE IF NOT EXISTS room_member (
@@ -29,7 +30,8 @@ CREATE TABLE IF NOT EXISTS room_member (
   created_on TIMESTAMP NOT NULL DEFAULT NOW(),
   updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
   created_by TEXT NOT NULL,
-  updated_by TEXT NOT NULL
+  updated_by TEXT NOT NULL,
+  role TEXT NOT NULL DEFAULT 'PLAYER' -- PLAYER or HOST
 );
 
 CREATE TABLE IF NOT EXISTS leaderboard (
@@ -42,5 +44,29 @@ CREATE TABLE IF NOT EXISTS leaderboard (
   created_by TEXT NOT NULL,
   updated_by TEXT NOT NULL,
   is_deleted BOOL NOT NULL,
+  best_streak INT NOT NULL DEFAULT 0, -- most right answers in a row in the game
   UNIQUE (room_code, user_id)
 );
+
+CREATE TABLE IF NOT EXISTS room_invite (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  invite_code TEXT NOT NULL UNIQUE, -- short code that is easy to read out
+  room_code TEXT NOT NULL,
+  expires_on TIMESTAMP NOT NULL,
+  is_revoked BOOLEAN NOT NULL DEFAULT false,
+  created_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  created_by TEXT NOT NULL,
+  updated_by TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS match_ticket (
+  user_id UUID PRIMARY KEY, -- a player waits in one queue at a time
+  username TEXT NOT NULL,
+  category TEXT NOT NULL,
+  difficulty TEXT NOT NULL,
+  status TEXT NOT NULL, -- QUEUED, FORMING, MATCHED, FAILED
+  room_code TEXT NOT NULL DEFAULT '', -- filled once his room is ready
+  queued_on TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_on TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,340 @@
+// The question bank holds the questions a user writes himself. They are bound to the user and not
+// to a room, so the same questions can be picked again and again to build new rooms. A bank question
+// can be of any question type, the answer of the types which are not single choice is kept as json.
+
+package quiz
+
+import (
+	"brainwars/pkg/db/dbal"
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var ErrBankQuestionNotFound = errors.New("question not found in your question bank")
+
+// bankAnswerKey is the answer of the question types which are not single choice
+type bankAnswerKey struct {
+	Answers         []int    `json:"answers,omitempty"`
+	NumericAnswer   float64  `json:"numericAnswer,omitempty"`
+	Tolerance       float64  `json:"tolerance,omitempty"`
+	AcceptedAnswers []string `json:"acceptedAnswers,omitempty"`
+}
+
+// CreateBankQuestion adds a question to the bank of the user
+func (s *Service) CreateBankQuestion(ctx context.Context, req *model.BankQuestionReq) (*model.BankQuestion, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	options, answerKey, err := prepareBankQuestion(req)
+	if err != nil {
+		return nil, err
+	}
+	question, err := s.q.CreateBankQuestion(ctx, dbal.CreateBankQuestionParams{
+		ID: pgtype.UUID{
+			Bytes: uuid.New(),
+			Valid: true,
+		},
+		UserID: pgtype.UUID{
+			Bytes: req.UserID,
+			Valid: true,
+		},
+		Question:     req.Question,
+		Options:      options,
+		Answer:       int32(req.Answer),
+		Difficulty:   string(req.Difficulty),
+		Tags:         req.Tags,
+		CreatedBy:    req.UserID.String(),
+		TimeLimit:    int32(req.TimeLimit),
+		QuestionType: string(req.Type),
+		AnswerKey:    answerKey,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not create bank question in database", err)
+		return nil, err
+	}
+
+	return toBankQuestion(question)
+}
+
+// UpdateBankQuestion edits a question of the user's bank
+func (s *Service) UpdateBankQuestion(ctx context.Context, req *model.BankQuestionReq) (*model.BankQuestion, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	options, answerKey, err := prepareBankQuestion(req)
+	if err != nil {
+		return nil, err
+	}
+	question, err := s.q.UpdateBankQuestion(ctx, dbal.UpdateBankQuestionParams{
+		ID: pgtype.UUID{
+			Bytes: req.ID,
+			Valid: true,
+		},
+		UserID: pgtype.UUID{
+			Bytes: req.UserID,
+			Valid: true,
+		},
+		Question:     req.Question,
+		Options:      options,
+		Answer:       int32(req.Answer),
+		Difficulty:   string(req.Difficulty),
+		Tags:         req.Tags,
+		UpdatedBy:    req.UserID.String(),
+		TimeLimit:    int32(req.TimeLimit),
+		QuestionType: string(req.Type),
+		AnswerKey:    answerKey,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrBankQuestionNotFound
+	}
+	if err != nil {
+		l.Sugar().Error("Could not update bank question in database", err)
+		return nil, err
+	}
+
+	return toBankQuestion(question)
+}
+
+// DeleteBankQuestion removes the question from the user's bank, rooms which used it keep their copy
+func (s *Service) DeleteBankQuestion(ctx context.Context, userID, questionID uuid.UUID) error {
+	l := logs.GetLoggerctx(ctx)
+	err := s.q.DeleteBankQuestion(ctx, dbal.DeleteBankQuestionParams{
+		ID: pgtype.UUID{
+			Bytes: questionID,
+			Valid: true,
+		},
+		UserID: pgtype.UUID{
+			Bytes: userID,
+			Valid: true,
+		},
+		UpdatedBy: userID.String(),
+	})
+	if err != nil {
+		l.Sugar().Error("Could not delete bank question in database", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetBankQuestion returns a question of the user's bank
+func (s *Service) GetBankQuestion(ctx context.Context, userID, questionID uuid.UUID) (*model.BankQuestion, error) {
+	l := logs.GetLoggerctx(ctx)
+	question, err := s.q.GetBankQuestionByID(ctx, dbal.GetBankQuestionByIDParams{
+		ID: pgtype.UUID{
+			Bytes: questionID,
+			Valid: true,
+		},
+		UserID: pgtype.UUID{
+			Bytes: userID,
+			Valid: true,
+		},
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrBankQuestionNotFound
+	}
+	if err != nil {
+		l.Sugar().Error("Could not get bank question in database", err)
+		return nil, err
+	}
+
+	return toBankQuestion(question)
+}
+
+// ListBankQuestions lists the questions of the user's bank matching the search text and tag
+func (s *Service) ListBankQuestions(ctx context.Context, filter model.BankQuestionFilter) ([]*model.BankQuestion, error) {
+	l := logs.GetLoggerctx(ctx)
+	questions, err := s.q.ListBankQuestionsByUserID(ctx, dbal.ListBankQuestionsByUserIDParams{
+		UserID: pgtype.UUID{
+			Bytes: filter.UserID,
+			Valid: true,
+		},
+		Search: strings.TrimSpace(filter.Search),
+		Tag:    normalizeTag(filter.Tag),
+	})
+	if err != nil {
+		l.Sugar().Error("Could not list bank questions in database", err)
+		return nil, err
+	}
+
+	bankQuestions := []*model.BankQuestion{}
+	for _, question := range questions {
+		bankQuestion, err := toBankQuestion(question)
+		if err != nil {
+			return nil, err
+		}
+		bankQuestions = append(bankQuestions, bankQuestion)
+	}
+	return bankQuestions, nil
+}
+
+// BankQuestionData turns the selected bank questions into the question data of a room, in the order
+// they were selected. the difficulty of the room is the average difficulty of the selection
+func (s *Service) BankQuestionData(ctx context.Context, userID uuid.UUID, questionIDs []uuid.UUID) ([]*model.QuestionData, model.Difficulty, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	ids := []pgtype.UUID{}
+	for _, id := range questionIDs {
+		ids = append(ids, pgtype.UUID{Bytes: id, Valid: true})
+	}
+	questions, err := s.q.ListBankQuestionsByIDs(ctx, dbal.ListBankQuestionsByIDsParams{
+		UserID: pgtype.UUID{
+			Bytes: userID,
+			Valid: true,
+		},
+		Ids: ids,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not list bank questions by ids in database", err)
+		return nil, "", err
+	}
+
+	byID := map[uuid.UUID]*model.BankQuestion{}
+	for _, question := range questions {
+		bankQuestion, err := toBankQuestion(question)
+		if err != nil {
+			return nil, "", err
+		}
+		byID[bankQuestion.ID] = bankQuestion
+	}
+
+	difficultyLevels := map[model.Difficulty]int{model.Easy: 1, model.Medium: 2, model.Hard: 3}
+	totalLevel := 0
+	questData := []*model.QuestionData{}
+	types := []model.QuestionType{}
+	for _, id := range questionIDs {
+		bankQuestion, exists := byID[id]
+		if !exists {
+			return nil, "", fmt.Errorf("question %s: %w", id, ErrBankQuestionNotFound)
+		}
+		totalLevel += difficultyLevels[bankQuestion.Difficulty]
+		// every room gets its own copy, so the answers of a room dont mix with the ones of other rooms
+		question := bankQuestion.QuestionData()
+		question.ID = uuid.New()
+		questData = append(questData, question)
+		types = append(types, GetQuestionKind(bankQuestion.Type).Type())
+	}
+
+	err = validateQuestions(questData, len(questionIDs), types)
+	if err != nil {
+		return nil, "", err
+	}
+
+	difficulty := model.Easy
+	switch level := float64(totalLevel) / float64(len(questionIDs)); {
+	case level >= 2.5:
+		difficulty = model.Hard
+	case level >= 1.5:
+		difficulty = model.Medium
+	}
+	return questData, difficulty, nil
+}
+
+// prepareBankQuestion cleans up the request, checks it like a generated question of its type and
+// returns the options and the answer key json
+func prepareBankQuestion(req *model.BankQuestionReq) ([]byte, []byte, error) {
+	req.Question = strings.TrimSpace(req.Question)
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range req.Tags {
+		tag = normalizeTag(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	req.Tags = tags
+
+	req.Type = GetQuestionKind(req.Type).Type()
+	accepted := []string{}
+	for _, answer := range req.AcceptedAnswers {
+		if answer = strings.TrimSpace(answer); answer != "" {
+			accepted = append(accepted, answer)
+		}
+	}
+	req.AcceptedAnswers = accepted
+	if req.Type == model.TrueFalse && len(req.Options) == 0 {
+		req.Options = []model.Options{{ID: 1, Option: "True"}, {ID: 2, Option: "False"}}
+	}
+	// only the answer of the type is kept
+	if (&model.QuestionData{Type: req.Type}).SingleAnswer() {
+		req.Answers, req.NumericAnswer, req.Tolerance, req.AcceptedAnswers = nil, 0, 0, nil
+	} else {
+		req.Answer = 0
+	}
+
+	err := validateQuestions([]*model.QuestionData{{
+		Question:        req.Question,
+		Options:         req.Options,
+		Answer:          req.Answer,
+		Type:            req.Type,
+		Answers:         req.Answers,
+		NumericAnswer:   req.NumericAnswer,
+		Tolerance:       req.Tolerance,
+		AcceptedAnswers: req.AcceptedAnswers,
+	}}, 1, []model.QuestionType{req.Type})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options, err := json.Marshal(req.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	answerKey, err := json.Marshal(bankAnswerKey{
+		Answers:         req.Answers,
+		NumericAnswer:   req.NumericAnswer,
+		Tolerance:       req.Tolerance,
+		AcceptedAnswers: req.AcceptedAnswers,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return options, answerKey, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+func toBankQuestion(question dbal.BankQuestion) (*model.BankQuestion, error) {
+	options := []model.Options{}
+	err := json.Unmarshal(question.Options, &options)
+	if err != nil {
+		return nil, err
+	}
+	answerKey := bankAnswerKey{}
+	if len(question.AnswerKey) > 0 {
+		err = json.Unmarshal(question.AnswerKey, &answerKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	bankQuestion := &model.BankQuestion{
+		ID:              question.ID.Bytes,
+		UserID:          question.UserID.Bytes,
+		Question:        question.Question,
+		Options:         options,
+		Answer:          int(question.Answer),
+		Type:            model.QuestionType(question.QuestionType),
+		Answers:         answerKey.Answers,
+		NumericAnswer:   answerKey.NumericAnswer,
+		Tolerance:       answerKey.Tolerance,
+		AcceptedAnswers: answerKey.AcceptedAnswers,
+		Difficulty:      model.Difficulty(question.Difficulty),
+		Tags:            question.Tags,
+		TimeLimit:       int(question.TimeLimit),
+		CreatedOn:       question.CreatedOn.Time,
+		UpdatedOn:       question.UpdatedOn.Time,
+	}
+	bankQuestion.RightAnswer = GetQuestionKind(bankQuestion.Type).RightAnswer(bankQuestion.QuestionData())
+	return bankQuestion, nil
+}
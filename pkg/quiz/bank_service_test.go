@@ -0,0 +1,114 @@
+package quiz
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz/model"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// bankQuestionRow is a row of the bank question table
+func bankQuestionRow(t *testing.T, id uuid.UUID, question *model.QuestionData) []any {
+	t.Helper()
+	options, err := json.Marshal(question.Options)
+	if err != nil {
+		t.Fatalf("marshal options: %v", err)
+	}
+	answerKey, err := json.Marshal(bankAnswerKey{Answers: question.Answers, NumericAnswer: question.NumericAnswer, Tolerance: question.Tolerance, AcceptedAnswers: question.AcceptedAnswers})
+	if err != nil {
+		t.Fatalf("marshal answer key: %v", err)
+	}
+	return []any{
+		pgtype.UUID{Bytes: id, Valid: true}, pgtype.UUID{Bytes: uuid.New(), Valid: true}, question.Question, options, int32(question.Answer),
+		string(model.Medium), []string{}, false, pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", int32(question.TimeLimit), string(question.Type), answerKey,
+	}
+}
+
+func TestPrepareBankQuestion(t *testing.T) {
+	fourOptions := []model.Options{{ID: 1, Option: "Red"}, {ID: 2, Option: "Green"}, {ID: 3, Option: "Blue"}, {ID: 4, Option: "Purple"}}
+
+	tests := []struct {
+		name    string
+		req     model.BankQuestionReq
+		want    model.QuestionType
+		wantErr bool
+	}{
+		{"no type is single choice", model.BankQuestionReq{Question: "Which is a warm color?", Options: fourOptions, Answer: 1}, model.SingleChoice, false},
+		{"true false gets its options", model.BankQuestionReq{Question: "The sun is a star.", Type: model.TrueFalse, Answer: 1}, model.TrueFalse, false},
+		{"multi select", model.BankQuestionReq{Question: "Which are primary colors?", Type: model.MultiSelect, Options: fourOptions, Answer: 2, Answers: []int{1, 3}}, model.MultiSelect, false},
+		{"multi select without the right options", model.BankQuestionReq{Question: "Which are primary colors?", Type: model.MultiSelect, Options: fourOptions}, model.MultiSelect, true},
+		{"numeric", model.BankQuestionReq{Question: "When did World War II end?", Type: model.Numeric, NumericAnswer: 1945, Tolerance: 5}, model.Numeric, false},
+		{"numeric with options", model.BankQuestionReq{Question: "When did World War II end?", Type: model.Numeric, Options: fourOptions, NumericAnswer: 1945}, model.Numeric, true},
+		{"ordering", model.BankQuestionReq{Question: "Order by wavelength.", Type: model.Ordering, Options: fourOptions, Answers: []int{1, 2, 3, 4}}, model.Ordering, false},
+		{"free text", model.BankQuestionReq{Question: "Which planet is red?", Type: model.FreeText, AcceptedAnswers: []string{" Mars ", ""}}, model.FreeText, false},
+		{"free text without accepted answers", model.BankQuestionReq{Question: "Which planet is red?", Type: model.FreeText, AcceptedAnswers: []string{" "}}, model.FreeText, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			options, answerKey, err := prepareBankQuestion(&req)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if req.Type != tt.want {
+				t.Errorf("got the type %s, want %s", req.Type, tt.want)
+			}
+
+			// what is stored is played as a question of the type
+			question := &model.BankQuestion{Question: req.Question, Answer: req.Answer, Type: req.Type}
+			key := bankAnswerKey{}
+			if err := json.Unmarshal(options, &question.Options); err != nil {
+				t.Fatalf("stored options: %v", err)
+			}
+			if err := json.Unmarshal(answerKey, &key); err != nil {
+				t.Fatalf("stored answer key: %v", err)
+			}
+			question.Answers, question.NumericAnswer, question.Tolerance, question.AcceptedAnswers = key.Answers, key.NumericAnswer, key.Tolerance, key.AcceptedAnswers
+			if err := validateQuestions([]*model.QuestionData{question.QuestionData()}, 1, []model.QuestionType{tt.want}); err != nil {
+				t.Errorf("the stored question is not valid: %v", err)
+			}
+		})
+	}
+}
+
+func TestBankQuestionDataGivesEveryRoomItsOwnCopy(t *testing.T) {
+	db := dbtest.New()
+	s := NewService(db, nil)
+	numericID, choiceID := uuid.New(), uuid.New()
+	choice := fakeQuestion("What is the capital of France?", 2, "Berlin", "Paris", "Madrid", "Rome")
+	choice.TimeLimit = 45
+	db.Returns("ListBankQuestionsByIDs",
+		bankQuestionRow(t, numericID, &model.QuestionData{Question: "When did World War II end?", Type: model.Numeric, NumericAnswer: 1945, Tolerance: 5}),
+		bankQuestionRow(t, choiceID, choice),
+	)
+
+	first, difficulty, err := s.BankQuestionData(testContext(), uuid.New(), []uuid.UUID{numericID, choiceID})
+	if err != nil {
+		t.Fatalf("bank question data: %v", err)
+	}
+	second, _, err := s.BankQuestionData(testContext(), uuid.New(), []uuid.UUID{numericID, choiceID})
+	if err != nil {
+		t.Fatalf("bank question data: %v", err)
+	}
+
+	if difficulty != model.Medium {
+		t.Errorf("got the difficulty %s, want medium", difficulty)
+	}
+	if len(first) != 2 || first[0].Type != model.Numeric || first[0].NumericAnswer != 1945 || first[0].Tolerance != 5 {
+		t.Fatalf("the numeric question lost its type or answer: %+v", first[0])
+	}
+	if first[0].TimeLimit != 0 || first[1].TimeLimit != 45 {
+		t.Errorf("got the time limits %d and %d seconds, want the room's and 45", first[0].TimeLimit, first[1].TimeLimit)
+	}
+	for i := range first {
+		if first[i].ID == uuid.Nil || first[i].ID == numericID || first[i].ID == choiceID || first[i].ID == second[i].ID {
+			t.Errorf("question %d has the id %s in both rooms, every room needs its own copy", i+1, first[i].ID)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// The players of an elimination game are ranked by how long they lasted, the score only settles who
+// comes first among the players who went out together.
+
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"sort"
+)
+
+// RankByElimination sorts the participants of an elimination game, the survivors come first and then
+// the players who went out the latest. the positions are filled in as well
+func RankByElimination(participants []model.Participant) {
+	sort.SliceStable(participants, func(i, j int) bool {
+		a, b := participants[i], participants[j]
+		if a.Eliminated != b.Eliminated {
+			return !a.Eliminated
+		}
+		if a.EliminatedRound != b.EliminatedRound {
+			return a.EliminatedRound > b.EliminatedRound
+		}
+		return a.Score > b.Score
+	})
+	for i := range participants {
+		participants[i].Position = i + 1
+	}
+}
@@ -0,0 +1,62 @@
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"testing"
+)
+
+func TestRankByElimination(t *testing.T) {
+	tests := []struct {
+		name         string
+		participants []model.Participant
+		want         []string // usernames from the first place
+	}{
+		{
+			name: "survivor first",
+			participants: []model.Participant{
+				{Username: "out", Score: 900, Eliminated: true, EliminatedRound: 3},
+				{Username: "survivor", Score: 100},
+			},
+			want: []string{"survivor", "out"},
+		},
+		{
+			name: "later rounds rank higher",
+			participants: []model.Participant{
+				{Username: "round1", Score: 500, Eliminated: true, EliminatedRound: 1},
+				{Username: "round4", Score: 100, Eliminated: true, EliminatedRound: 4},
+				{Username: "round2", Score: 300, Eliminated: true, EliminatedRound: 2},
+			},
+			want: []string{"round4", "round2", "round1"},
+		},
+		{
+			name: "score settles the same round",
+			participants: []model.Participant{
+				{Username: "low", Score: 100, Eliminated: true, EliminatedRound: 2},
+				{Username: "high", Score: 300, Eliminated: true, EliminatedRound: 2},
+				{Username: "alive", Score: 50},
+			},
+			want: []string{"alive", "high", "low"},
+		},
+		{
+			name: "ties keep their order",
+			participants: []model.Participant{
+				{Username: "first", Score: 100},
+				{Username: "second", Score: 100},
+			},
+			want: []string{"first", "second"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			RankByElimination(tt.participants)
+			for i, participant := range tt.participants {
+				if participant.Username != tt.want[i] {
+					t.Fatalf("place %d is %s, want %s", i+1, participant.Username, tt.want[i])
+				}
+				if participant.Position != i+1 {
+					t.Errorf("%s has the position %d, want %d", participant.Username, participant.Position, i+1)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// fakeDefaultTopic is the question set served for the topics which have no set of their own
+const fakeDefaultTopic = "default"
+
+// fakeGenerator serves canned question sets, the same topic and count always give the same questions
+type fakeGenerator struct {
+	sets map[string][]*model.QuestionData // map key is the lower cased topic
+}
+
+// newFakeGenerator loads the question sets from the file (a json object of topic to questions),
+// without a file the built in set is used for every topic
+func newFakeGenerator(file string) (*fakeGenerator, error) {
+	g := &fakeGenerator{
+		sets: map[string][]*model.QuestionData{fakeDefaultTopic: fakeQuestions},
+	}
+	if file == "" {
+		return g, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fake question sets: %v", err)
+	}
+	sets := map[string][]*model.QuestionData{}
+	err = json.Unmarshal(data, &sets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse fake question sets: %v", err)
+	}
+	for topic, questions := range sets {
+		if len(questions) > 0 {
+			g.sets[strings.ToLower(topic)] = questions
+		}
+	}
+	return g, nil
+}
+
+func (g *fakeGenerator) GenerateQuestions(ctx context.Context, req *model.QuizReq) ([]*model.QuestionData, error) {
+	set, exists := g.sets[strings.ToLower(strings.TrimSpace(req.Topic))]
+	if !exists {
+		set = g.sets[fakeDefaultTopic]
+	}
+	set = filterTypes(set, req.QuestionTypes)
+	if len(set) == 0 {
+		set = filterTypes(fakeQuestions, req.QuestionTypes)
+	}
+
+	// the set is repeated when more questions are asked than it has
+	questData := make([]*model.QuestionData, 0, req.Count)
+	for i := 0; i < req.Count; i++ {
+		q := *set[i%len(set)]
+		q.Options = append([]model.Options{}, q.Options...)
+		q.Answers = append([]int{}, q.Answers...)
+		q.AcceptedAnswers = append([]string{}, q.AcceptedAnswers...)
+		questData = append(questData, &q)
+	}
+	return questData, nil
+}
+
+var fakeQuestions = []*model.QuestionData{
+	fakeQuestion("What is the capital of France?", 2, "Berlin", "Paris", "Madrid", "Rome"),
+	fakeQuestion("How many legs does a spider have?", 3, "Six", "Four", "Eight", "Ten"),
+	fakeQuestion("Which planet is known as the Red Planet?", 1, "Mars", "Venus", "Jupiter", "Saturn"),
+	fakeQuestion("What is 7 times 8?", 4, "54", "48", "64", "56"),
+	fakeQuestion("Which gas do plants absorb from the air?", 2, "Oxygen", "Carbon dioxide", "Nitrogen", "Helium"),
+	fakeQuestion("Who wrote Romeo and Juliet?", 1, "Shakespeare", "Dickens", "Tolstoy", "Homer"),
+	fakeQuestion("What is the largest ocean on Earth?", 3, "Atlantic", "Indian", "Pacific", "Arctic"),
+	fakeQuestion("How many continents are there?", 4, "Five", "Six", "Eight", "Seven"),
+	fakeQuestion("What is the boiling point of water in Celsius?", 1, "100", "90", "120", "80"),
+	fakeQuestion("Which animal is known as the king of the jungle?", 2, "Tiger", "Lion", "Elephant", "Bear"),
+	fakeTypedQuestion(model.TrueFalse, "The sun is a star.", 1, nil, "True", "False"),
+	fakeTypedQuestion(model.TrueFalse, "Bats are birds.", 2, nil, "True", "False"),
+	fakeTypedQuestion(model.MultiSelect, "Which of these are primary colors?", 0, []int{1, 3}, "Red", "Green", "Blue", "Purple"),
+	fakeTypedQuestion(model.MultiSelect, "Which of these are mammals?", 0, []int{2, 4}, "Shark", "Dolphin", "Penguin", "Whale"),
+	{Type: model.Numeric, Question: "In which year did World War II end?", NumericAnswer: 1945, Tolerance: 5},
+	{Type: model.Numeric, Question: "How many days are there in a leap year?", NumericAnswer: 366, Tolerance: 3},
+	fakeTypedQuestion(model.Ordering, "Order these planets from the closest to the sun.", 0, []int{2, 4, 1, 3}, "Earth", "Mercury", "Mars", "Venus"),
+	fakeTypedQuestion(model.Ordering, "Order these numbers from the smallest.", 0, []int{3, 1, 2}, "Ten", "Hundred", "One"),
+	{Type: model.FreeText, Question: "What is the chemical symbol of gold?", AcceptedAnswers: []string{"Au"}},
+	{Type: model.FreeText, Question: "Who painted the Mona Lisa?", AcceptedAnswers: []string{"Leonardo da Vinci", "Da Vinci", "Leonardo"}},
+}
+
+// filterTypes keeps the questions of the asked types, questions without a type are single choice
+func filterTypes(set []*model.QuestionData, types []model.QuestionType) []*model.QuestionData {
+	allowedTypes := map[model.QuestionType]bool{}
+	for _, questionType := range questionTypes(types) {
+		allowedTypes[questionType] = true
+	}
+	filtered := []*model.QuestionData{}
+	for _, q := range set {
+		if allowedTypes[GetQuestionKind(q.Type).Type()] {
+			filtered = append(filtered, q)
+		}
+	}
+	return filtered
+}
+
+func fakeQuestion(question string, answer int, options ...string) *model.QuestionData {
+	q := &model.QuestionData{
+		Question: question,
+		Answer:   answer,
+	}
+	for i, option := range options {
+		q.Options = append(q.Options, model.Options{ID: i + 1, Option: option})
+	}
+	return q
+}
+
+func fakeTypedQuestion(questionType model.QuestionType, question string, answer int, answers []int, options ...string) *model.QuestionData {
+	q := fakeQuestion(question, answer, options...)
+	q.Type = questionType
+	q.Answers = answers
+	return q
+}
@@ -0,0 +1,226 @@
+// Generating the questions of a room calls the llm which can take a while, so it runs as a
+// background job. The job row moves PENDING -> RUNNING -> SUCCEEDED or FAILED and every change is
+// handed to the job listeners. The websocket manager is one of them, it pushes the progress to the
+// players waiting in the lobby instead of making them wait on the websocket upgrade.
+// A job is run by a goroutine of the instance which created the room, so a crash leaves it PENDING or
+// RUNNING for good. The job row keeps the instance running it and an instance fails its own left over
+// jobs when it boots again. The jobs of an instance which never comes back are failed once they have
+// not moved for longer than any generation takes.
+
+package quiz
+
+import (
+	"brainwars/pkg/db/dbal"
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz/model"
+	"context"
+	"errors"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/spf13/viper"
+)
+
+const defaultJobStaleSecond = 600
+
+// errJobStale is the error of the jobs whose goroutine died along with its server
+var errJobStale = errors.New("the question generation was interrupted, please create the room again")
+
+// InstanceID names this server in the jobs it runs, it has to stay the same across restarts
+// and be unique to every instance. app.instanceId is used when set, otherwise the hostname
+func InstanceID() string {
+	instanceID := viper.GetString("app.instanceId")
+	if instanceID != "" {
+		return instanceID
+	}
+	instanceID, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return instanceID
+}
+
+// JobListener is called after every status change of a generation job
+type JobListener func(ctx context.Context, job *model.GenerationJob)
+
+// OnJobUpdate registers a listener for the generation job updates
+func (s *Service) OnJobUpdate(listener JobListener) {
+	s.jobMu.Lock()
+	s.jobListeners = append(s.jobListeners, listener)
+	s.jobMu.Unlock()
+}
+
+// CreateGenerationJob creates a pending generation job for the room. it takes the queries so that
+// the job can be created in the same transaction as the room
+func CreateGenerationJob(ctx context.Context, q *dbal.Queries, req *model.QuestionReq) (*model.GenerationJob, error) {
+	l := logs.GetLoggerctx(ctx)
+	job, err := q.CreateGenerationJob(ctx, dbal.CreateGenerationJobParams{
+		ID: pgtype.UUID{
+			Bytes: uuid.New(),
+			Valid: true,
+		},
+		RoomCode: req.RoomCode,
+		Topic: pgtype.Text{
+			String: req.Topic,
+			Valid:  req.Topic != "",
+		},
+		QuestionCount: int32(req.QuestionCount),
+		Status:        string(model.JobPending),
+		CreatedBy:     req.CreatedBy,
+		InstanceID:    InstanceID(),
+	})
+	if err != nil {
+		l.Sugar().Error("Could not create generation job in database", err)
+		return nil, err
+	}
+
+	return toGenerationJob(job), nil
+}
+
+// GetGenerationJobByRoomCode returns the latest generation job of the room, nil if the room has none
+func (s *Service) GetGenerationJobByRoomCode(ctx context.Context, roomCode string) (*model.GenerationJob, error) {
+	l := logs.GetLoggerctx(ctx)
+	job, err := s.q.GetLatestGenerationJobByRoomCode(ctx, roomCode)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		l.Sugar().Error("Could not get generation job in database", err)
+		return nil, err
+	}
+
+	return toGenerationJob(job), nil
+}
+
+// RunGenerationJob generates and stores the questions of the room while keeping the job status up to date
+func (s *Service) RunGenerationJob(ctx context.Context, job *model.GenerationJob, req *model.QuestionReq) error {
+	l := logs.GetLoggerctx(ctx)
+
+	err := s.updateJobStatus(ctx, job, model.JobRunning, nil)
+	if err != nil {
+		return err
+	}
+
+	jobErr := s.SetupQuizQuestions(ctx, req)
+	if jobErr != nil {
+		l.Sugar().Errorf("generation job %s of room %s failed %v", job.ID, job.RoomCode, jobErr)
+		err = s.updateJobStatus(ctx, job, model.JobFailed, jobErr)
+		if err != nil {
+			l.Sugar().Error("Could not mark the generation job as failed", err)
+		}
+		return jobErr
+	}
+
+	return s.updateJobStatus(ctx, job, model.JobSucceeded, nil)
+}
+
+// JobStaleSecond is how long a job may stay pending or running before it is taken as dead
+func JobStaleSecond() int {
+	stale := viper.GetInt("llm.jobStaleSecond")
+	if stale <= 0 {
+		stale = defaultJobStaleSecond
+	}
+	return stale
+}
+
+// FailInstanceGenerationJobs fails the jobs which this instance was running when it went down
+// and tells the listeners about them. it is called on boot, it returns the failed jobs
+func (s *Service) FailInstanceGenerationJobs(ctx context.Context) ([]*model.GenerationJob, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	instanceID := InstanceID()
+	if instanceID == "" {
+		return nil, nil // jobs without an instance are left to the stale check
+	}
+	leftJobs, err := s.q.FailInstanceGenerationJobs(ctx, dbal.FailInstanceGenerationJobsParams{
+		ErrorMessage: pgtype.Text{String: errJobStale.Error(), Valid: true},
+		InstanceID:   instanceID,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not fail the generation jobs of this instance in database", err)
+		return nil, err
+	}
+	return s.notifyFailedJobs(ctx, leftJobs), nil
+}
+
+// FailStaleGenerationJobs fails the jobs which were left pending or running by a server that went down
+// and tells the listeners about them. it returns the failed jobs
+func (s *Service) FailStaleGenerationJobs(ctx context.Context) ([]*model.GenerationJob, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	staleJobs, err := s.q.FailStaleGenerationJobs(ctx, dbal.FailStaleGenerationJobsParams{
+		ErrorMessage: pgtype.Text{String: errJobStale.Error(), Valid: true},
+		StaleSeconds: int32(JobStaleSecond()),
+	})
+	if err != nil {
+		l.Sugar().Error("Could not fail the stale generation jobs in database", err)
+		return nil, err
+	}
+	return s.notifyFailedJobs(ctx, staleJobs), nil
+}
+
+// notifyFailedJobs tells the listeners about the jobs a server that went down left behind
+func (s *Service) notifyFailedJobs(ctx context.Context, failedJobs []dbal.GenerationJob) []*model.GenerationJob {
+	l := logs.GetLoggerctx(ctx)
+
+	s.jobMu.RLock()
+	listeners := s.jobListeners
+	s.jobMu.RUnlock()
+	jobs := []*model.GenerationJob{}
+	for _, failedJob := range failedJobs {
+		job := toGenerationJob(failedJob)
+		l.Sugar().Infof("generation job %s of room %s was left behind by a server that went down, failed it", job.ID, job.RoomCode)
+		for _, listener := range listeners {
+			listener(ctx, job)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// updateJobStatus stores the new status of the job and tells the listeners about it
+func (s *Service) updateJobStatus(ctx context.Context, job *model.GenerationJob, status model.JobStatus, jobErr error) error {
+	l := logs.GetLoggerctx(ctx)
+
+	errorMessage := pgtype.Text{}
+	if jobErr != nil {
+		errorMessage = pgtype.Text{String: jobErr.Error(), Valid: true}
+	}
+	updated, err := s.q.UpdateGenerationJobStatus(ctx, dbal.UpdateGenerationJobStatusParams{
+		ID: pgtype.UUID{
+			Bytes: job.ID,
+			Valid: true,
+		},
+		Status:       string(status),
+		ErrorMessage: errorMessage,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not update generation job status in database", err)
+		return err
+	}
+	*job = *toGenerationJob(updated)
+
+	s.jobMu.RLock()
+	listeners := s.jobListeners
+	s.jobMu.RUnlock()
+	for _, listener := range listeners {
+		listener(ctx, job)
+	}
+	return nil
+}
+
+func toGenerationJob(job dbal.GenerationJob) *model.GenerationJob {
+	return &model.GenerationJob{
+		ID:            job.ID.Bytes,
+		RoomCode:      job.RoomCode,
+		Topic:         job.Topic.String,
+		QuestionCount: int(job.QuestionCount),
+		Status:        model.JobStatus(job.Status),
+		ErrorMessage:  job.ErrorMessage.String,
+		CreatedOn:     job.CreatedOn.Time,
+		UpdatedOn:     job.UpdatedOn.Time,
+		CreatedBy:     job.CreatedBy,
+	}
+}
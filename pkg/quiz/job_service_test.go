@@ -0,0 +1,165 @@
+package quiz
+
+import (
+	"brainwars/pkg/db/dbal"
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/spf13/viper"
+)
+
+// failingGenerator is an llm which is down
+type failingGenerator struct{}
+
+func (failingGenerator) GenerateQuestions(ctx context.Context, req *model.QuizReq) ([]*model.QuestionData, error) {
+	return nil, errors.New("llm is down")
+}
+
+// storeJobStatus makes the fake db update the job row the way postgres would
+func storeJobStatus(db *dbtest.FakeDB, roomCode string) {
+	db.On("UpdateGenerationJobStatus", func(args []any) ([][]any, error) {
+		return [][]any{{
+			args[0], roomCode, pgtype.Text{String: "general", Valid: true}, int32(3), args[1], args[2],
+			pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", "instance",
+		}}, nil
+	})
+}
+
+func TestRunGenerationJob(t *testing.T) {
+	fake, err := newFakeGenerator("")
+	if err != nil {
+		t.Fatalf("fake generator: %v", err)
+	}
+	tests := []struct {
+		name      string
+		generator QuizGenerator
+		dbErr     error // error of storing the questions
+		statuses  []model.JobStatus
+		message   string
+	}{
+		{"questions generated", fake, nil, []model.JobStatus{model.JobRunning, model.JobSucceeded}, ""},
+		{"llm fails", failingGenerator{}, nil, []model.JobStatus{model.JobRunning, model.JobFailed}, "llm is down"},
+		{"questions cannot be stored", fake, errors.New("db is down"), []model.JobStatus{model.JobRunning, model.JobFailed}, "db is down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New()
+			storeJobStatus(db, "ROOM")
+			if tt.dbErr != nil {
+				db.Fails("CreateQuestion", tt.dbErr)
+			}
+			s := NewService(db, tt.generator)
+			statuses := []model.JobStatus{}
+			s.OnJobUpdate(func(ctx context.Context, job *model.GenerationJob) {
+				statuses = append(statuses, job.Status)
+			})
+
+			job := &model.GenerationJob{ID: uuid.New(), RoomCode: "ROOM", QuestionCount: 3, Status: model.JobPending}
+			err := s.RunGenerationJob(testContext(), job, &model.QuestionReq{
+				RoomCode: "ROOM", Topic: "general", QuestionCount: 3, CreatedBy: "owner", TimeLimit: 10, Difficulty: model.Easy,
+			})
+
+			if (tt.message == "") != (err == nil) {
+				t.Fatalf("got error %v", err)
+			}
+			if len(statuses) != len(tt.statuses) {
+				t.Fatalf("listeners got %v, want %v", statuses, tt.statuses)
+			}
+			for i := range statuses {
+				if statuses[i] != tt.statuses[i] {
+					t.Fatalf("listeners got %v, want %v", statuses, tt.statuses)
+				}
+			}
+			if job.Status != tt.statuses[len(tt.statuses)-1] || job.ErrorMessage != tt.message {
+				t.Errorf("job ended %s with %q, want %s with %q", job.Status, job.ErrorMessage, tt.statuses[len(tt.statuses)-1], tt.message)
+			}
+		})
+	}
+}
+
+func TestRunGenerationJobStoresTheGeneratedQuestions(t *testing.T) {
+	fake, err := newFakeGenerator("")
+	if err != nil {
+		t.Fatalf("fake generator: %v", err)
+	}
+	db := dbtest.New()
+	storeJobStatus(db, "ROOM")
+	s := NewService(db, fake)
+
+	job := &model.GenerationJob{ID: uuid.New(), RoomCode: "ROOM", QuestionCount: 3}
+	err = s.RunGenerationJob(testContext(), job, &model.QuestionReq{
+		RoomCode: "ROOM", Topic: "general", QuestionCount: 3, CreatedBy: "owner", TimeLimit: 10,
+		QuestionTypes: []model.QuestionType{model.Numeric, model.FreeText},
+	})
+	if err != nil {
+		t.Fatalf("run generation job: %v", err)
+	}
+
+	calls := db.Calls("CreateQuestion")
+	if len(calls) != 1 {
+		t.Fatalf("questions were stored %d times, want once", len(calls))
+	}
+	questions := []*model.QuestionData{}
+	// the question data is the fourth argument of the insert
+	if err := json.Unmarshal(calls[0].Args[3].([]byte), &questions); err != nil {
+		t.Fatalf("stored question data: %v", err)
+	}
+	if err := validateQuestions(questions, 3, []model.QuestionType{model.Numeric, model.FreeText}); err != nil {
+		t.Errorf("stored questions are invalid: %v", err)
+	}
+	for _, q := range questions {
+		if q.ID == uuid.Nil {
+			t.Errorf("%q was stored without an id", q.Question)
+		}
+	}
+}
+
+func TestJobsOfThisInstanceAreFailedOnBoot(t *testing.T) {
+	viper.Set("app.instanceId", "instance-1")
+	t.Cleanup(func() { viper.Set("app.instanceId", nil) })
+	ctx := testContext()
+	db := dbtest.New()
+	s := NewService(db, nil)
+
+	// the job is stamped with the instance which runs it
+	db.On("CreateGenerationJob", func(args []any) ([][]any, error) {
+		return [][]any{{
+			args[0], args[1], args[2], args[3], args[4], pgtype.Text{},
+			pgtype.Timestamp{}, pgtype.Timestamp{}, args[5], args[5], args[6],
+		}}, nil
+	})
+	_, err := CreateGenerationJob(ctx, dbal.New(db), &model.QuestionReq{RoomCode: "ROOM", QuestionCount: 3, CreatedBy: "owner"})
+	if err != nil {
+		t.Fatalf("create generation job: %v", err)
+	}
+	if calls := db.Calls("CreateGenerationJob"); len(calls) != 1 || calls[0].Args[6] != "instance-1" {
+		t.Fatalf("job was created with %+v, want it stamped with instance-1", calls)
+	}
+
+	notified := []*model.GenerationJob{}
+	s.OnJobUpdate(func(ctx context.Context, job *model.GenerationJob) {
+		notified = append(notified, job)
+	})
+	db.Returns("FailInstanceGenerationJobs", []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, "ROOM", pgtype.Text{}, int32(3), string(model.JobFailed),
+		pgtype.Text{String: errJobStale.Error(), Valid: true}, pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", "instance-1",
+	})
+
+	jobs, err := s.FailInstanceGenerationJobs(ctx)
+	if err != nil {
+		t.Fatalf("fail instance generation jobs: %v", err)
+	}
+	calls := db.Calls("FailInstanceGenerationJobs")
+	if len(calls) != 1 || calls[0].Args[1] != "instance-1" {
+		t.Fatalf("jobs were failed with %+v, want the jobs of instance-1", calls)
+	}
+	if len(jobs) != 1 || len(notified) != 1 || notified[0].RoomCode != "ROOM" || notified[0].Status != model.JobFailed {
+		t.Errorf("the lobby should hear about the failed job, got %+v and listeners got %+v", jobs, notified)
+	}
+}
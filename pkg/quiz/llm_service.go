@@ -0,0 +1,382 @@
+// The questions of a quiz come from a QuizGenerator. Which one is used is picked from the viper
+// config (llm.provider). gemini and any openai compatible endpoint (openai itself or a local ollama)
+// are called through an LLMProvider with the brainwars prompt, the fake serves canned question sets
+// so that the whole game can be played offline.
+// The output of a model is validated and when it is broken the problems are sent back to the model
+// as a repair prompt (llm.maxRepairs times). Providers with a json schema mode are asked to follow
+// the question schema so that most of the answers are valid in the first place.
+
+package quiz
+
+import (
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/genai"
+)
+
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+	ProviderOllama = "ollama"
+	ProviderFake   = "fake"
+
+	defaultGeminiModel      = "gemini-2.0-flash-lite"
+	defaultOpenAIBaseURL    = "https://api.openai.com/v1"
+	defaultOllamaBaseURL    = "http://localhost:11434/v1"
+	defaultLLMTimeoutSecond = 60
+	defaultLLMMaxRepairs    = 2
+
+	LLMRoleUser      = "user"
+	LLMRoleAssistant = "assistant"
+)
+
+// QuizGenerator generates the questions of a quiz
+type QuizGenerator interface {
+	GenerateQuestions(ctx context.Context, req *model.QuizReq) ([]*model.QuestionData, error)
+}
+
+// LLMMessage is one turn of the conversation with the language model
+type LLMMessage struct {
+	Role    string // LLMRoleUser or LLMRoleAssistant
+	Content string
+}
+
+// LLMProvider sends the conversation to a language model and returns the text it answered with.
+// when the provider has a json schema mode the answer is held to the schema, otherwise it is ignored
+type LLMProvider interface {
+	Complete(ctx context.Context, messages []LLMMessage, schema *JSONSchema) (string, error)
+}
+
+// JSONSchema is the subset of json schema which both gemini and openai structured outputs understand
+type JSONSchema struct {
+	Type                 string                 `json:"type"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+}
+
+// NewQuizGenerator picks the generator from the viper config (llm.provider)
+func NewQuizGenerator(ctx context.Context) (QuizGenerator, error) {
+	provider := viper.GetString("llm.provider")
+	switch provider {
+	case "", ProviderGemini:
+		gemini, err := newGeminiProvider(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newLLMGenerator(gemini), nil
+	case ProviderOpenAI:
+		return newLLMGenerator(newOpenAIProvider(ProviderOpenAI, defaultOpenAIBaseURL, os.Getenv("OPENAI_API_KEY"))), nil
+	case ProviderOllama:
+		return newLLMGenerator(newOpenAIProvider(ProviderOllama, defaultOllamaBaseURL, "")), nil
+	case ProviderFake:
+		return newFakeGenerator(viper.GetString("llm.fake.file"))
+	default:
+		return nil, fmt.Errorf("unknown llm provider %s", provider)
+	}
+}
+
+/********************** LLM GENERATOR **************************************/
+
+// llmGenerator asks a language model for the questions with the brainwars prompt
+type llmGenerator struct {
+	provider   LLMProvider
+	maxRepairs int // how many times a broken output is sent back to the model
+}
+
+func newLLMGenerator(provider LLMProvider) *llmGenerator {
+	// 0 turns the repairs off, only a missing setting gets the default
+	maxRepairs := defaultLLMMaxRepairs
+	if viper.IsSet("llm.maxRepairs") {
+		maxRepairs = max(viper.GetInt("llm.maxRepairs"), 0)
+	}
+	return &llmGenerator{provider: provider, maxRepairs: maxRepairs}
+}
+
+func (g *llmGenerator) GenerateQuestions(ctx context.Context, req *model.QuizReq) ([]*model.QuestionData, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	messages := []LLMMessage{{Role: LLMRoleUser, Content: getSystemPrompt(req)}}
+	schema := questionSchema()
+	var invalidErr error
+	for attempt := 0; attempt <= g.maxRepairs; attempt++ {
+		llmResponse, err := g.provider.Complete(ctx, messages, schema)
+		if err != nil {
+			return nil, fmt.Errorf("get data from llm failed: %v", err)
+		}
+
+		questData, err := parseQuestions(llmResponse)
+		if err == nil {
+			err = validateQuestions(questData, req.Count, req.QuestionTypes)
+		}
+		if err == nil {
+			return questData, nil
+		}
+
+		invalidErr = err
+		l.Sugar().Warnf("llm output of attempt %d is invalid %v", attempt+1, err)
+		messages = append(messages,
+			LLMMessage{Role: LLMRoleAssistant, Content: llmResponse},
+			LLMMessage{Role: LLMRoleUser, Content: getRepairPrompt(err)},
+		)
+	}
+
+	return nil, fmt.Errorf("llm output is still invalid after %d repairs: %v", g.maxRepairs, invalidErr)
+}
+
+// parseQuestions reads the questions out of the llm output, in json schema mode they come wrapped in an object
+func parseQuestions(llmResponse string) ([]*model.QuestionData, error) {
+	llmResponse = strings.TrimSpace(llmResponse)
+	if strings.HasPrefix(llmResponse, "{") {
+		wrapped := struct {
+			Questions []*model.QuestionData `json:"questions"`
+		}{}
+		err := json.Unmarshal([]byte(llmResponse), &wrapped)
+		if err == nil {
+			return wrapped.Questions, nil
+		}
+	}
+
+	cleaned, err := clearnllmOutput(llmResponse)
+	if err != nil {
+		return nil, fmt.Errorf("clean llm output failed: %v", err)
+	}
+	questData := []*model.QuestionData{}
+	err = json.Unmarshal([]byte(cleaned), &questData)
+	if err != nil {
+		return nil, fmt.Errorf("output is not a json array of questions: %v", err)
+	}
+	return questData, nil
+}
+
+// questionSchema is the shape of the output asked from the providers with a json schema mode.
+// the root has to be an object for openai so the questions are wrapped in one
+func questionSchema() *JSONSchema {
+	closed := false
+	option := &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"id":     {Type: "integer"},
+			"option": {Type: "string"},
+		},
+		Required:             []string{"id", "option"},
+		AdditionalProperties: &closed,
+	}
+	question := &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"type":            {Type: "string"},
+			"question":        {Type: "string"},
+			"answer":          {Type: "integer"},
+			"options":         {Type: "array", Items: option},
+			"answers":         {Type: "array", Items: &JSONSchema{Type: "integer"}},
+			"numericAnswer":   {Type: "number"},
+			"tolerance":       {Type: "number"},
+			"acceptedAnswers": {Type: "array", Items: &JSONSchema{Type: "string"}},
+		},
+		// strict schemas need every field, the fields a type does not use come empty
+		Required:             []string{"type", "question", "answer", "options", "answers", "numericAnswer", "tolerance", "acceptedAnswers"},
+		AdditionalProperties: &closed,
+	}
+	return &JSONSchema{
+		Type: "object",
+		Properties: map[string]*JSONSchema{
+			"questions": {Type: "array", Items: question},
+		},
+		Required:             []string{"questions"},
+		AdditionalProperties: &closed,
+	}
+}
+
+func llmTimeout() time.Duration {
+	timeout := viper.GetInt("llm.timeoutSecond")
+	if timeout <= 0 {
+		timeout = defaultLLMTimeoutSecond
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+/********************** GEMINI **************************************/
+
+type geminiProvider struct {
+	client *genai.Client
+	model  string
+}
+
+func newGeminiProvider(ctx context.Context) (*geminiProvider, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:  os.Getenv("GEMINI_API_KEY"),
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gemini client: %v", err)
+	}
+
+	model := viper.GetString("llm.gemini.model")
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	return &geminiProvider{client: client, model: model}, nil
+}
+
+func (p *geminiProvider) Complete(ctx context.Context, messages []LLMMessage, schema *JSONSchema) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, llmTimeout())
+	defer cancel()
+
+	contents := []*genai.Content{}
+	for _, message := range messages {
+		role := genai.Role(genai.RoleUser)
+		if message.Role == LLMRoleAssistant {
+			role = genai.RoleModel
+		}
+		contents = append(contents, genai.NewContentFromText(message.Content, role))
+	}
+
+	var config *genai.GenerateContentConfig
+	if schema != nil {
+		config = &genai.GenerateContentConfig{
+			ResponseMIMEType: "application/json",
+			ResponseSchema:   toGenaiSchema(schema),
+		}
+	}
+
+	result, err := p.client.Models.GenerateContent(ctx, p.model, contents, config)
+	if err != nil {
+		return "", err
+	}
+	return result.Text(), nil
+}
+
+// toGenaiSchema converts the schema to the openapi flavoured one gemini takes
+func toGenaiSchema(schema *JSONSchema) *genai.Schema {
+	converted := &genai.Schema{
+		Type:     genai.Type(strings.ToUpper(schema.Type)),
+		Required: schema.Required,
+	}
+	if schema.Items != nil {
+		converted.Items = toGenaiSchema(schema.Items)
+	}
+	if len(schema.Properties) > 0 {
+		converted.Properties = map[string]*genai.Schema{}
+		for name, property := range schema.Properties {
+			converted.Properties[name] = toGenaiSchema(property)
+		}
+	}
+	return converted
+}
+
+/********************** OPENAI COMPATIBLE **************************************/
+
+// openAIProvider talks to any endpoint which serves the openai chat completions api,
+// ollama serves it under /v1 so a local model works the same way
+type openAIProvider struct {
+	baseURL    string
+	model      string
+	apiKey     string
+	jsonSchema bool // endpoint supports response_format json_schema (openai, ollama 0.5+)
+	client     *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionReq struct {
+	Model          string          `json:"model"`
+	Messages       []chatMessage   `json:"messages"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+type responseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema struct {
+		Name   string      `json:"name"`
+		Strict bool        `json:"strict"`
+		Schema *JSONSchema `json:"schema"`
+	} `json:"json_schema"`
+}
+
+type chatCompletionRes struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// newOpenAIProvider reads llm.<name>.baseURL, llm.<name>.model and llm.<name>.jsonSchema from the config
+func newOpenAIProvider(name, defaultBaseURL, apiKey string) *openAIProvider {
+	baseURL := viper.GetString("llm." + name + ".baseURL")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &openAIProvider{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		model:      viper.GetString("llm." + name + ".model"),
+		apiKey:     apiKey,
+		jsonSchema: viper.GetBool("llm." + name + ".jsonSchema"),
+		client:     &http.Client{Timeout: llmTimeout()},
+	}
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, messages []LLMMessage, schema *JSONSchema) (string, error) {
+	completionReq := chatCompletionReq{Model: p.model}
+	for _, message := range messages {
+		completionReq.Messages = append(completionReq.Messages, chatMessage{Role: message.Role, Content: message.Content})
+	}
+	if p.jsonSchema && schema != nil {
+		completionReq.ResponseFormat = &responseFormat{Type: "json_schema"}
+		completionReq.ResponseFormat.JSONSchema.Name = "quiz"
+		completionReq.ResponseFormat.JSONSchema.Strict = true
+		completionReq.ResponseFormat.JSONSchema.Schema = schema
+	}
+
+	body, err := json.Marshal(completionReq)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("chat completion failed with status %d: %s", res.StatusCode, resBody)
+	}
+
+	completion := chatCompletionRes{}
+	err = json.Unmarshal(resBody, &completion)
+	if err != nil {
+		return "", err
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("chat completion returned no choices")
+	}
+	return completion.Choices[0].Message.Content, nil
+}
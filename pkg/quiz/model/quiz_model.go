@@ -9,11 +9,24 @@ import (
 )
 
 type QuizReq struct {
-	Topic      string     `validate:"required"`
-	Count      int        `validate:"required"`
-	Difficulty Difficulty `validate:"required"`
+	Topic         string         `validate:"required"`
+	Count         int            `validate:"required"`
+	Difficulty    Difficulty     `validate:"required,oneof=easy medium hard"`
+	QuestionTypes []QuestionType `validate:"dive,oneof=single_choice true_false multi_select numeric ordering free_text"` // single choice when empty
 }
 
+// QuestionType is the kind of answer a question asks for
+type QuestionType string
+
+const (
+	SingleChoice QuestionType = "single_choice" // one of the options is right, questions without a type are single choice
+	TrueFalse    QuestionType = "true_false"    // the options are true and false
+	MultiSelect  QuestionType = "multi_select"  // every right option has to be picked, picking some of them gives partial credit
+	Numeric      QuestionType = "numeric"       // a number, the closer it is to the answer the more points
+	Ordering     QuestionType = "ordering"      // the options are put in the right order
+	FreeText     QuestionType = "free_text"     // a short text which is matched loosely against the accepted answers
+)
+
 type Difficulty string
 
 const (
@@ -22,6 +35,84 @@ const (
 	Hard   Difficulty = "hard"
 )
 
+// Multiplier is applied on the score of a correct answer, harder questions are worth more
+func (d Difficulty) Multiplier() float64 {
+	switch d {
+	case Medium:
+		return 1.5
+	case Hard:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// QuestionTimeLimit is the time given to answer the question at the index
+func (q *Question) QuestionTimeLimit(idx int) time.Duration {
+	seconds := q.TimeLimit
+	if idx >= 0 && idx < len(q.QuestionData) && q.QuestionData[idx].TimeLimit > 0 {
+		seconds = q.QuestionData[idx].TimeLimit
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// ScoringRuleName is the scoring preset a room is played with
+type ScoringRuleName string
+
+const (
+	ClassicScoring  ScoringRuleName = "classic"
+	SpeedScoring    ScoringRuleName = "speed"
+	StreakScoring   ScoringRuleName = "streak"
+	NegativeScoring ScoringRuleName = "negative"
+	AccuracyScoring ScoringRuleName = "accuracy"
+)
+
+// ScoringMetaEvent is the type of the room meta entry which records the scoring rule of the room
+const ScoringMetaEvent = "scoring_rule"
+
+// ScoringMeta is kept in the room meta so the analysis page can explain how the scores were reached
+type ScoringMeta struct {
+	Rule        ScoringRuleName `json:"rule"`
+	Description string          `json:"description"`
+}
+
+// TeamMetaEvent is the type of the room meta entry which records the number of teams of a room played in team mode
+const TeamMetaEvent = "teams"
+
+// TeamMeta is kept in the room meta of a room played in team mode
+type TeamMeta struct {
+	Count int `json:"count"`
+}
+
+// TeamScore is the total of a team, the sum of the scores of its members
+type TeamScore struct {
+	Team     int      `json:"team"` // 1 based
+	Name     string   `json:"name"`
+	Score    int      `json:"score"`
+	Members  []string `json:"members"` // usernames
+	Position int      `json:"position"`
+}
+
+// PowerUp is a one time help a player can use while a question runs
+type PowerUp string
+
+const (
+	FiftyFifty   PowerUp = "fifty_fifty"   // removes two wrong options of the current question for the player
+	DoublePoints PowerUp = "double_points" // doubles the score of the next answered question
+	TimeFreeze   PowerUp = "time_freeze"   // adds seconds to the player's own deadline of the current question
+)
+
+// PowerUpState is the power-ups of a player and what they change in the running game.
+// questions are counted from 1 here so that 0 means the power-up was not used
+type PowerUpState struct {
+	Inventory     map[PowerUp]int `json:"inventory"`     // power-ups left
+	HiddenOptions []int           `json:"hiddenOptions"` // options the 50/50 removed from the question HiddenOn
+	HiddenOn      int             `json:"hiddenOn"`
+	DoubleOn      int             `json:"doubleOn"`     // question the double points apply to
+	ExtraSeconds  int             `json:"extraSeconds"` // seconds the time freeze added to the question ExtraOn
+	ExtraOn       int             `json:"extraOn"`
+}
+
 type Options struct {
 	ID     int    `json:"id"`
 	Option string `json:"option"`
@@ -32,6 +123,45 @@ type QuestionData struct {
 	Question string    `json:"question"`
 	Options  []Options `json:"options"`
 	Answer   int       `json:"answer"` // option id: which option is correct
+	// time limit of this question in seconds, the time limit of the room is used when it is 0
+	TimeLimit int          `json:"timeLimit,omitempty"`
+	Type      QuestionType `json:"type,omitempty"` // single choice when empty
+	// the answer of the types which are not single choice
+	Answers         []int    `json:"answers,omitempty"` // multi select: ids of the right options, ordering: option ids in the right order
+	NumericAnswer   float64  `json:"numericAnswer,omitempty"`
+	Tolerance       float64  `json:"tolerance,omitempty"` // numeric: how far off an answer can be and still get some points
+	AcceptedAnswers []string `json:"acceptedAnswers,omitempty"`
+}
+
+// SingleAnswer tells if the question is answered with one option id
+func (q *QuestionData) SingleAnswer() bool {
+	return q.Type == "" || q.Type == SingleChoice || q.Type == TrueFalse
+}
+
+// AnswerData is the answer to the question types which do not fit in a single option id
+type AnswerData struct {
+	Options []int    `json:"options,omitempty"` // multi select: the picked option ids, ordering: the option ids in the given order
+	Number  *float64 `json:"number,omitempty"`
+	Text    string   `json:"text,omitempty"`
+}
+
+// Equal tells if both are the same answer
+func (a *AnswerData) Equal(b *AnswerData) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if len(a.Options) != len(b.Options) || a.Text != b.Text || (a.Number == nil) != (b.Number == nil) {
+		return false
+	}
+	if a.Number != nil && *a.Number != *b.Number {
+		return false
+	}
+	for i := range a.Options {
+		if a.Options[i] != b.Options[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // QuestionReq represents the request to create a question
@@ -41,7 +171,9 @@ type QuestionReq struct {
 	QuestionCount int             `validate:"required"`
 	QuestionData  []*QuestionData `validate:"required"`
 	CreatedBy     string          `validate:"required"`
-	TimeLimit     int             `validate:"required"`
+	TimeLimit     int             `validate:"required"` // seconds
+	Difficulty    Difficulty
+	QuestionTypes []QuestionType // types the questions are generated in
 }
 
 // EditQuestionReq represents the request to update a question
@@ -62,7 +194,8 @@ type Question struct {
 	Topic         string
 	QuestionCount int // total number of questions for that room
 	QuestionData  []*QuestionData
-	TimeLimit     int
+	TimeLimit     int // seconds for each question unless the question has its own
+	Difficulty    Difficulty
 	CreatedOn     time.Time
 	UpdatedOn     time.Time
 	CreatedBy     string
@@ -75,13 +208,25 @@ type AnswerReq struct {
 	RoomCode       string
 	UserID         uuid.UUID `json:"playerID"`
 	QuestionID     uuid.UUID
-	QuestionDataID uuid.UUID `json:"questionDataID"`
-	AnswerOption   int32     `json:"answerOption"`
+	QuestionDataID uuid.UUID   `json:"questionDataID"`
+	AnswerOption   int32       `json:"answerOption"`
+	AnswerData     *AnswerData `json:"answerData,omitempty"` // answer of the types which are not single choice
 	IsCorrect      bool
-	AnswerTime     time.Time
+	AnswerTime     time.Time // always stamped by the server when the answer is received
+	LatencyMs      int       // time taken since the question was sent
+	FlagReason     string    // why the answer looks suspicious, empty when it does not
+	Points         int       // what the question added to the score, changed answers included
+	ScoreReason    string    // how the scoring rule came up with the points
+	PowerUps       []PowerUp // power-ups the player used on the question
 	CreatedBy      string
 }
 
+// reasons an answer gets flagged as suspicious
+const (
+	FlagTooFast        = "TOO_FAST"        // answered quicker than a human can read the question
+	FlagUniformLatency = "UNIFORM_LATENCY" // every answer of the player took about the same time
+)
+
 // EditAnswerReq represents the request to update an answer
 type EditAnswerReq struct {
 	ID           uuid.UUID
@@ -103,8 +248,16 @@ type Answer struct {
 	QuestionDataID uuid.UUID
 	QuestionData   *QuestionData
 	AnswerOption   int32
+	AnswerData     *AnswerData
 	IsCorrect      bool
 	AnswerTime     time.Time
+	LatencyMs      int
+	FlagReason     string
+	Points         int
+	ScoreReason    string
+	PowerUps       []PowerUp
+	GivenAnswer    string // the answer written out, for the analysis page
+	RightAnswer    string
 	CreatedBy      string
 	UpdatedBy      string
 }
@@ -119,6 +272,47 @@ type GameState struct {
 	Participants         []Participant        `json:"participants"`
 	StartTime            time.Time            `json:"startTime"`
 	CurrentQuestionIndex int                  `json:"currentQuestionIndex"`
+	QuestionStartTime    time.Time            `json:"questionStartTime"` // when the current question was sent
+	PausedAt             time.Time            `json:"pausedAt"`          // when the host paused the current question, zero while it runs
+	ScoringRule          ScoringRuleName      `json:"scoringRule"`
+	// power-ups of every player, map key is the userID. kept apart from the participants since
+	// the participants are sent to the whole room and the 50/50 of a player is only for him
+	PowerUps map[uuid.UUID]*PowerUpState `json:"powerUps"`
+	// the answer of the closed question is being shown, the next question is not sent yet
+	Revealing bool `json:"revealing"`
+	// rooms in team mode, the team of every member is kept here from the lobby on since the
+	// members only become participants with their first answer. map key is the userID
+	TeamCount int               `json:"teamCount,omitempty"`
+	Teams     map[uuid.UUID]int `json:"teams,omitempty"`
+	// elimination rooms, the players who dropped out in the order they did
+	Elimination  bool          `json:"elimination,omitempty"`
+	Eliminations []Elimination `json:"eliminations,omitempty"`
+}
+
+type EliminationReason string
+
+const (
+	EliminatedWrong   EliminationReason = "wrong"   // answered wrong
+	EliminatedMissed  EliminationReason = "missed"  // did not answer in time
+	EliminatedSlowest EliminationReason = "slowest" // everyone was right and he was the slowest
+)
+
+// Elimination is a player who dropped out of an elimination game
+type Elimination struct {
+	UserID   uuid.UUID         `json:"userId"`
+	Username string            `json:"username"`
+	Round    int               `json:"round"` // question number he dropped out on
+	Reason   EliminationReason `json:"reason"`
+}
+
+// AnswerHistory is the in memory answers of a player map[questionID]map[userID]answer
+type AnswerHistory map[uuid.UUID]map[uuid.UUID]*AnswerReq
+
+// GameCheckpoint is the snapshot of a running game which is stored at every question transition
+type GameCheckpoint struct {
+	RoomCode      string
+	GameState     *GameState
+	AnswerHistory map[uuid.UUID]AnswerHistory // map key is the userID
 }
 
 type Participant struct {
@@ -127,10 +321,20 @@ type Participant struct {
 	IsBot               bool      `json:"isBot"`
 	Score               int       `json:"score"`
 	Position            int
-	IsReady             bool      `json:"isReady"`
-	LastAnsweredQestion uuid.UUID `json:"answerID"`
-	LastChoosenOption   int       `json:"chosenOption"`
-	IsExited            bool      `json:"exited"`
+	IsReady             bool        `json:"isReady"`
+	LastAnsweredQestion uuid.UUID   `json:"answerID"`
+	LastChoosenOption   int         `json:"chosenOption"`
+	IsExited            bool        `json:"exited"`
+	Streak              int         `json:"streak"`         // correct answers in a row
+	BestStreak          int         `json:"bestStreak"`     // longest streak of the game so far
+	StreakBefore        int         `json:"streakBefore"`   // streak when the last answered question was first answered
+	QuestionPoints      int         `json:"questionPoints"` // points of the last answered question, taken back when the answer changes
+	AnswerChanges       int         `json:"answerChanges"`  // times the last answered question was answered again
+	LatencyMs           int         `json:"latencyMs"`      // time the latest answer of the last answered question took
+	LastAnswerData      *AnswerData `json:"answerData,omitempty"`
+	Team                int         `json:"team,omitempty"` // 1 based, 0 when the room is not in team mode
+	Eliminated          bool        `json:"eliminated,omitempty"`
+	EliminatedRound     int         `json:"eliminatedRound,omitempty"` // question number he dropped out on
 }
 
 type QuizError struct {
@@ -141,4 +345,90 @@ type EndGamePayload struct {
 	Message      string        `json:"message"`
 	Participants []Participant `json:"scores"`
 	FinishTime   time.Time     `json:"finishTime"`
+	Difficulty   Difficulty    `json:"difficulty,omitempty"`
+	Scoring      *ScoringMeta  `json:"scoring,omitempty"`
+	Teams        []TeamScore   `json:"teams,omitempty"`
+	Eliminations []Elimination `json:"eliminations,omitempty"` // order the players dropped out of an elimination game
+}
+
+// JobStatus is the state of a question generation job
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "PENDING"
+	JobRunning   JobStatus = "RUNNING"
+	JobSucceeded JobStatus = "SUCCEEDED"
+	JobFailed    JobStatus = "FAILED"
+)
+
+// GenerationJob is the background job which generates the questions of a room
+type GenerationJob struct {
+	ID            uuid.UUID `json:"id"`
+	RoomCode      string    `json:"roomCode"`
+	Topic         string    `json:"topic"`
+	QuestionCount int       `json:"questionCount"`
+	Status        JobStatus `json:"status"`
+	ErrorMessage  string    `json:"-"` // raw error of the llm call, it is not sent to the players
+	CreatedOn     time.Time `json:"createdOn"`
+	UpdatedOn     time.Time `json:"updatedOn"`
+	CreatedBy     string    `json:"createdBy"`
+}
+
+// BankQuestion is a question in the personal question bank of a user
+type BankQuestion struct {
+	ID              uuid.UUID
+	UserID          uuid.UUID
+	Question        string
+	Options         []Options
+	Answer          int          // option id of the right answer
+	Type            QuestionType // single choice when empty
+	Answers         []int        // multi select: ids of the right options, ordering: option ids in the right order
+	NumericAnswer   float64
+	Tolerance       float64
+	AcceptedAnswers []string
+	RightAnswer     string // the answer written out for the bank page
+	Difficulty      Difficulty
+	Tags            []string
+	TimeLimit       int // seconds, 0 means the time limit of the room
+	CreatedOn       time.Time
+	UpdatedOn       time.Time
+}
+
+// QuestionData is the question as it is played, without an id since every room gets its own copy
+func (q *BankQuestion) QuestionData() *QuestionData {
+	return &QuestionData{
+		Question:        q.Question,
+		Options:         q.Options,
+		Answer:          q.Answer,
+		TimeLimit:       q.TimeLimit,
+		Type:            q.Type,
+		Answers:         q.Answers,
+		NumericAnswer:   q.NumericAnswer,
+		Tolerance:       q.Tolerance,
+		AcceptedAnswers: q.AcceptedAnswers,
+	}
+}
+
+// BankQuestionReq represents the request to create or edit a question in the bank
+type BankQuestionReq struct {
+	ID              uuid.UUID    // empty while creating
+	UserID          uuid.UUID    `validate:"required"`
+	Question        string       `validate:"required,max=300"`
+	Type            QuestionType `validate:"omitempty,oneof=single_choice true_false multi_select numeric ordering free_text"`
+	Options         []Options    `validate:"max=6"` // numeric and free text questions have none
+	Answer          int          `validate:"min=0,max=6"`
+	Answers         []int        `validate:"max=6"`
+	NumericAnswer   float64
+	Tolerance       float64    `validate:"min=0"`
+	AcceptedAnswers []string   `validate:"max=10,dive,max=50"`
+	Difficulty      Difficulty `validate:"required,oneof=easy medium hard"`
+	Tags            []string   `validate:"max=10,dive,max=30"`
+	TimeLimit       int        `validate:"min=0,max=300"` // seconds, 0 means the time limit of the room
+}
+
+// BankQuestionFilter narrows down the questions listed from the bank
+type BankQuestionFilter struct {
+	UserID uuid.UUID
+	Search string // part of the question text
+	Tag    string
 }
@@ -2,14 +2,11 @@ package quiz
 
 import (
 	"brainwars/pkg/quiz/model"
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
-
-	"google.golang.org/genai"
 )
 
 func getSystemPrompt(req *model.QuizReq) string {
@@ -26,55 +23,51 @@ Input:
 Output Requirements:
 
 - **Strictly adhere to the following output structure as a single JSON string:**
-    "[{"question":"","answer":"","options":[{"id":1,"option":""},{"id":2,"option":""},{"id":3,"option":""},{"id":4,"option":""}]},{"question":"","answer":"","options":[{"id":1,"option":""},{"id":2,"option":""},{"id":3,"option":""},{"id":4,"option":""}]}]"
+    "[{"type":"","question":"","answer":0,"options":[{"id":1,"option":""}],"answers":[],"numericAnswer":0,"tolerance":0,"acceptedAnswers":[]}]"
 - **DO NOT include any Markdown code block fences (like %sjson or %s) or any other wrapping text.** Your entire output must be *only* the  string.
-- Option IDs are fixed: always 1, 2, 3, 4.
-- The 'answer' should be the option ID (1, 2, 3, or 4) corresponding to the correct option.
+- Every question has a "type", use only these types and mix them:
+%s
+- Always fill every field, the fields a type does not use are left empty or 0.
 - Options generated should be concise, not exceeding 5 words.
 - Provide concise, relevant questions based on the given input.
 - Keep questions short, friendly, and easy to understand.
 - Limit question length to less than 30 words.
 - Give crisp questions without unnecessary detail.
-- Always include the correct answer option.
+- Always include the correct answer.
 - Provide questions for which you are confident about the answer.
 - Treat the input as strictly a quiz topic.
-- Generate 'n' questions, where 'n' is the number provided in the input, along with 4 options and the right answer for each.
-
-Example output:
-"[{"question": "this is test question 1","answer": 1, "options": [{"id": 1, "option": "ans 1"}, {"id": 2, "option": "ans 2"}, {"id": 3, "option": "ans 3"}, {"id": 4, "option": "ans 4"}] }, {"question": "this is test question 2","answer": 2, "options": [{"id": 1, "option": "ans 1"}, {"id": 2, "option": "ans 2"}, {"id": 3, "option": "ans 3"}, {"id": 4, "option": "ans 4"}]}]"
+- Generate 'n' questions, where 'n' is the number provided in the input.
 
 Tone & Style:
 
 - Be kind, supportive, and approachable.
 - Use simple language.
-- Select the most famous and well-known questions related to the topic.`, req.Topic, req.Difficulty, req.Count, "```", "```")
+- Select the most famous and well-known questions related to the topic.`, req.Topic, req.Difficulty, req.Count, "```", "```", typePrompts(req.QuestionTypes))
 
 	return prompt
 }
 
-func callGemini(ctx context.Context, prompt string) (string, error) {
-	// url := viper.GetString("llm.gemini.url")
-	apiKey := os.Getenv("GEMINI_API_KEY")
-
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
-	})
-	if err != nil {
-		return "", err
+// typePrompts describes the format of every asked question type
+func typePrompts(types []model.QuestionType) string {
+	prompts := []string{}
+	for _, questionType := range questionTypes(types) {
+		prompts = append(prompts, GetQuestionKind(questionType).Prompt())
 	}
+	return strings.Join(prompts, "\n")
+}
 
-	result, err := client.Models.GenerateContent(
-		ctx,
-		"gemini-2.0-flash-lite",
-		genai.Text(prompt),
-		nil,
-	)
-	if err != nil {
-		return "", err
+// getRepairPrompt asks the llm to fix the output it gave with the problems found in it
+func getRepairPrompt(err error) string {
+	problems := []string{err.Error()}
+	validationErr := &QuestionValidationError{}
+	if errors.As(err, &validationErr) {
+		problems = validationErr.Problems
 	}
 
-	return result.Text(), nil
+	return fmt.Sprintf(`Your previous output is not valid. Fix these problems:
+- %s
+
+Answer again with all the questions in the same output structure. Every question has to follow the format of its type and no question may be repeated. Output only the JSON.`, strings.Join(problems, "\n- "))
 }
 
 func clearnllmOutput(s string) (string, error) {
@@ -94,6 +87,9 @@ func clearnllmOutput(s string) (string, error) {
 
 	// Extract the substring, including the brackets
 	jsonCandidate := s[startIndex : endIndex+1]
+	if json.Valid([]byte(jsonCandidate)) {
+		return jsonCandidate, nil // plain json, nothing to unescape
+	}
 
 	// 1. Add outer quotes to make it a valid Go string literal for strconv.Unquote
 	//    The inputString itself acts as the content of a string literal.
@@ -106,8 +102,7 @@ func clearnllmOutput(s string) (string, error) {
 	// 2. Use strconv.Unquote to unescape the string
 	jsonCandidate, err := strconv.Unquote(quotedInput)
 	if err != nil {
-		fmt.Printf("Error unquoting string: %v\n", err)
-		return "", err
+		return "", fmt.Errorf("output is neither json nor an escaped json string: %v", err)
 	}
 
 	// Optionally, you can add a basic validation here using json.Valid
@@ -0,0 +1,498 @@
+// Besides the four option single choice questions a quiz can have true/false, multi select, numeric,
+// ordering and free text questions. Every type knows how its questions are validated, how much of an
+// answer is right, how the llm is asked for it and how a bot answers it. The questions stored before
+// the types existed have no type and are played as single choice.
+
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// QuestionKind is the behaviour of one question type
+type QuestionKind interface {
+	Type() model.QuestionType
+	// Prompt tells the llm how a question of the type looks
+	Prompt() string
+	// Validate lists what is wrong with the question, empty when it is fine
+	Validate(q *model.QuestionData) []string
+	// Credit is the share of the answer which is right, 1 for a right answer and 0 for a wrong one
+	Credit(q *model.QuestionData, option int, data *model.AnswerData) float64
+	// DescribeAnswer writes out an answer for the analysis page and the reveal
+	DescribeAnswer(q *model.QuestionData, option int, data *model.AnswerData) string
+	RightAnswer(q *model.QuestionData) string
+	// BotAnswer is the answer of a bot, it is a guess just like the single choice bots pick a random option
+	BotAnswer(q *model.QuestionData) (option int, data *model.AnswerData)
+}
+
+// QuestionKinds lists the question types in the order they are offered while creating a room
+func QuestionKinds() []QuestionKind {
+	return []QuestionKind{singleChoiceKind{}, trueFalseKind{}, multiSelectKind{}, numericKind{}, orderingKind{}, freeTextKind{}}
+}
+
+// GetQuestionKind returns the behaviour of the question type, unknown and empty types are single choice
+func GetQuestionKind(questionType model.QuestionType) QuestionKind {
+	for _, kind := range QuestionKinds() {
+		if kind.Type() == questionType {
+			return kind
+		}
+	}
+	return singleChoiceKind{}
+}
+
+// HideAnswer is the copy of the question which is sent to the players, without anything that gives away the answer
+func HideAnswer(q *model.QuestionData) *model.QuestionData {
+	cq := *q
+	cq.Answer = -1 // making sure that the answer isnt shown in ws
+	cq.Answers = nil
+	cq.NumericAnswer = 0
+	cq.Tolerance = 0
+	cq.AcceptedAnswers = nil
+	cq.Options = append([]model.Options{}, q.Options...)
+	if q.Type == model.Ordering {
+		// the options may be stored in the right order
+		rand.Shuffle(len(cq.Options), func(i, j int) { cq.Options[i], cq.Options[j] = cq.Options[j], cq.Options[i] })
+	}
+	return &cq
+}
+
+// validateOptions checks the options have the ids 1 to n and a text of their own
+func validateOptions(q *model.QuestionData, min int, max int) []string {
+	problems := []string{}
+	if len(q.Options) < min || len(q.Options) > max {
+		if min == max {
+			problems = append(problems, fmt.Sprintf("has %d options, expected exactly %d", len(q.Options), min))
+		} else {
+			problems = append(problems, fmt.Sprintf("has %d options, expected between %d and %d", len(q.Options), min, max))
+		}
+	}
+	ids := map[int]bool{}
+	options := map[string]bool{}
+	for _, option := range q.Options {
+		if option.ID < 1 || option.ID > len(q.Options) {
+			problems = append(problems, fmt.Sprintf("has an option with id %d, ids must be between 1 and %d", option.ID, len(q.Options)))
+		} else if ids[option.ID] {
+			problems = append(problems, fmt.Sprintf("has the option id %d more than once", option.ID))
+		}
+		ids[option.ID] = true
+
+		optionText := strings.ToLower(strings.TrimSpace(option.Option))
+		if optionText == "" {
+			problems = append(problems, fmt.Sprintf("has an empty option with id %d", option.ID))
+		} else if options[optionText] {
+			problems = append(problems, fmt.Sprintf("has the option %q more than once", option.Option))
+		}
+		options[optionText] = true
+	}
+	return problems
+}
+
+func optionText(q *model.QuestionData, id int) string {
+	for _, option := range q.Options {
+		if option.ID == id {
+			return option.Option
+		}
+	}
+	return ""
+}
+
+func optionTexts(q *model.QuestionData, ids []int, sep string) string {
+	texts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		texts = append(texts, optionText(q, id))
+	}
+	return strings.Join(texts, sep)
+}
+
+func randomOption(q *model.QuestionData) int {
+	if len(q.Options) == 0 {
+		return 0
+	}
+	return q.Options[rand.Intn(len(q.Options))].ID
+}
+
+/********************** SINGLE CHOICE **************************************/
+
+type singleChoiceKind struct{}
+
+func (singleChoiceKind) Type() model.QuestionType { return model.SingleChoice }
+
+func (singleChoiceKind) Prompt() string {
+	return `- single_choice: exactly 4 options with the ids 1, 2, 3 and 4, "answer" is the id of the correct option. Example:
+{"type":"single_choice","question":"What is the capital of France?","answer":2,"options":[{"id":1,"option":"Berlin"},{"id":2,"option":"Paris"},{"id":3,"option":"Madrid"},{"id":4,"option":"Rome"}],"answers":[],"numericAnswer":0,"tolerance":0,"acceptedAnswers":[]}`
+}
+
+func (singleChoiceKind) Validate(q *model.QuestionData) []string {
+	problems := validateOptions(q, optionCount, optionCount)
+	if q.Answer < 1 || q.Answer > optionCount {
+		problems = append(problems, fmt.Sprintf("has the answer %d, it must be the option id between 1 and %d", q.Answer, optionCount))
+	}
+	return problems
+}
+
+func (singleChoiceKind) Credit(q *model.QuestionData, option int, data *model.AnswerData) float64 {
+	if option == q.Answer {
+		return 1
+	}
+	return 0
+}
+
+func (singleChoiceKind) DescribeAnswer(q *model.QuestionData, option int, data *model.AnswerData) string {
+	return optionText(q, option)
+}
+
+func (singleChoiceKind) RightAnswer(q *model.QuestionData) string {
+	return optionText(q, q.Answer)
+}
+
+func (singleChoiceKind) BotAnswer(q *model.QuestionData) (int, *model.AnswerData) {
+	return randomOption(q), nil
+}
+
+/********************** TRUE / FALSE **************************************/
+
+type trueFalseKind struct {
+	singleChoiceKind
+}
+
+func (trueFalseKind) Type() model.QuestionType { return model.TrueFalse }
+
+func (trueFalseKind) Prompt() string {
+	return `- true_false: a statement with exactly 2 options, id 1 is "True" and id 2 is "False", "answer" is the id of the correct one. Example:
+{"type":"true_false","question":"The sun is a star.","answer":1,"options":[{"id":1,"option":"True"},{"id":2,"option":"False"}],"answers":[],"numericAnswer":0,"tolerance":0,"acceptedAnswers":[]}`
+}
+
+func (trueFalseKind) Validate(q *model.QuestionData) []string {
+	problems := validateOptions(q, 2, 2)
+	if !strings.EqualFold(optionText(q, 1), "true") || !strings.EqualFold(optionText(q, 2), "false") {
+		problems = append(problems, `must have the options 1 "True" and 2 "False"`)
+	}
+	if q.Answer != 1 && q.Answer != 2 {
+		problems = append(problems, fmt.Sprintf("has the answer %d, it must be 1 for true or 2 for false", q.Answer))
+	}
+	return problems
+}
+
+/********************** MULTI SELECT **************************************/
+
+type multiSelectKind struct{}
+
+func (multiSelectKind) Type() model.QuestionType { return model.MultiSelect }
+
+func (multiSelectKind) Prompt() string {
+	return `- multi_select: exactly 4 options with the ids 1, 2, 3 and 4 of which one or more are correct, "answers" lists the ids of all the correct options and "answer" is 0. Example:
+{"type":"multi_select","question":"Which of these are primary colors?","answer":0,"options":[{"id":1,"option":"Red"},{"id":2,"option":"Green"},{"id":3,"option":"Blue"},{"id":4,"option":"Purple"}],"answers":[1,3],"numericAnswer":0,"tolerance":0,"acceptedAnswers":[]}`
+}
+
+func (multiSelectKind) Validate(q *model.QuestionData) []string {
+	problems := validateOptions(q, optionCount, optionCount)
+	if len(q.Answers) == 0 {
+		problems = append(problems, "has no correct options in answers")
+	}
+	seen := map[int]bool{}
+	for _, id := range q.Answers {
+		if id < 1 || id > optionCount {
+			problems = append(problems, fmt.Sprintf("has the answer %d, answers must be option ids between 1 and %d", id, optionCount))
+		} else if seen[id] {
+			problems = append(problems, fmt.Sprintf("has the answer %d more than once", id))
+		}
+		seen[id] = true
+	}
+	return problems
+}
+
+// Credit gives a share for every right pick and takes one back for every wrong pick
+func (multiSelectKind) Credit(q *model.QuestionData, option int, data *model.AnswerData) float64 {
+	if data == nil || len(q.Answers) == 0 {
+		return 0
+	}
+	right := map[int]bool{}
+	for _, id := range q.Answers {
+		right[id] = true
+	}
+	picked := map[int]bool{}
+	hits := 0
+	for _, id := range data.Options {
+		if picked[id] {
+			continue
+		}
+		picked[id] = true
+		if right[id] {
+			hits++
+		} else {
+			hits--
+		}
+	}
+	return math.Max(0, float64(hits)/float64(len(right)))
+}
+
+func (multiSelectKind) DescribeAnswer(q *model.QuestionData, option int, data *model.AnswerData) string {
+	if data == nil {
+		return ""
+	}
+	return optionTexts(q, data.Options, ", ")
+}
+
+func (multiSelectKind) RightAnswer(q *model.QuestionData) string {
+	return optionTexts(q, q.Answers, ", ")
+}
+
+func (multiSelectKind) BotAnswer(q *model.QuestionData) (int, *model.AnswerData) {
+	picks := []int{}
+	for _, option := range q.Options {
+		if rand.Intn(2) == 0 {
+			picks = append(picks, option.ID)
+		}
+	}
+	if len(picks) == 0 {
+		picks = append(picks, randomOption(q))
+	}
+	return 0, &model.AnswerData{Options: picks}
+}
+
+/********************** NUMERIC **************************************/
+
+type numericKind struct{}
+
+func (numericKind) Type() model.QuestionType { return model.Numeric }
+
+func (numericKind) Prompt() string {
+	return `- numeric: the answer is a number, "numericAnswer" is the correct number and "tolerance" is how far off an answer can be and still get some of the points, "options" is empty and "answer" is 0. Example:
+{"type":"numeric","question":"In which year did World War II end?","answer":0,"options":[],"answers":[],"numericAnswer":1945,"tolerance":5,"acceptedAnswers":[]}`
+}
+
+func (numericKind) Validate(q *model.QuestionData) []string {
+	problems := []string{}
+	if len(q.Options) != 0 {
+		problems = append(problems, "is numeric and must not have options")
+	}
+	if q.Tolerance < 0 {
+		problems = append(problems, fmt.Sprintf("has the tolerance %g, it cannot be negative", q.Tolerance))
+	}
+	return problems
+}
+
+// tolerance is how far off an answer gets some of the points, a tenth of the answer when the question has none
+func tolerance(q *model.QuestionData) float64 {
+	if q.Tolerance > 0 {
+		return q.Tolerance
+	}
+	return math.Max(1, math.Abs(q.NumericAnswer)/10)
+}
+
+// Credit is full for the exact number and goes down to nothing at the tolerance
+func (numericKind) Credit(q *model.QuestionData, option int, data *model.AnswerData) float64 {
+	if data == nil || data.Number == nil {
+		return 0
+	}
+	diff := math.Abs(*data.Number - q.NumericAnswer)
+	if diff < 1e-9 {
+		return 1
+	}
+	return math.Max(0, 1-diff/tolerance(q))
+}
+
+func (numericKind) DescribeAnswer(q *model.QuestionData, option int, data *model.AnswerData) string {
+	if data == nil || data.Number == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*data.Number, 'f', -1, 64)
+}
+
+func (numericKind) RightAnswer(q *model.QuestionData) string {
+	return strconv.FormatFloat(q.NumericAnswer, 'f', -1, 64)
+}
+
+// BotAnswer guesses somewhere around the answer
+func (numericKind) BotAnswer(q *model.QuestionData) (int, *model.AnswerData) {
+	guess := q.NumericAnswer + (rand.Float64()*4-2)*tolerance(q)
+	if q.NumericAnswer == math.Trunc(q.NumericAnswer) {
+		guess = math.Round(guess)
+	}
+	return 0, &model.AnswerData{Number: &guess}
+}
+
+/********************** ORDERING **************************************/
+
+type orderingKind struct{}
+
+func (orderingKind) Type() model.QuestionType { return model.Ordering }
+
+func (orderingKind) Prompt() string {
+	return `- ordering: 3 to 6 options which have to be put in order, "answers" lists all the option ids in the correct order and "answer" is 0. Example:
+{"type":"ordering","question":"Order these planets from the closest to the sun.","answer":0,"options":[{"id":1,"option":"Earth"},{"id":2,"option":"Mercury"},{"id":3,"option":"Mars"},{"id":4,"option":"Venus"}],"answers":[2,4,1,3],"numericAnswer":0,"tolerance":0,"acceptedAnswers":[]}`
+}
+
+func (orderingKind) Validate(q *model.QuestionData) []string {
+	problems := validateOptions(q, 3, 6)
+	if len(q.Answers) != len(q.Options) {
+		problems = append(problems, fmt.Sprintf("has %d ids in answers, expected all the %d options in order", len(q.Answers), len(q.Options)))
+	}
+	seen := map[int]bool{}
+	for _, id := range q.Answers {
+		if optionText(q, id) == "" {
+			problems = append(problems, fmt.Sprintf("has the answer %d which is not an option id", id))
+		} else if seen[id] {
+			problems = append(problems, fmt.Sprintf("has the answer %d more than once", id))
+		}
+		seen[id] = true
+	}
+	return problems
+}
+
+// Credit is the share of the options which are in the right place
+func (orderingKind) Credit(q *model.QuestionData, option int, data *model.AnswerData) float64 {
+	if data == nil || len(q.Answers) == 0 || len(data.Options) != len(q.Answers) {
+		return 0
+	}
+	inPlace := 0
+	for i, id := range data.Options {
+		if q.Answers[i] == id {
+			inPlace++
+		}
+	}
+	return float64(inPlace) / float64(len(q.Answers))
+}
+
+func (orderingKind) DescribeAnswer(q *model.QuestionData, option int, data *model.AnswerData) string {
+	if data == nil {
+		return ""
+	}
+	return optionTexts(q, data.Options, " → ")
+}
+
+func (orderingKind) RightAnswer(q *model.QuestionData) string {
+	return optionTexts(q, q.Answers, " → ")
+}
+
+func (orderingKind) BotAnswer(q *model.QuestionData) (int, *model.AnswerData) {
+	order := make([]int, 0, len(q.Options))
+	for _, option := range q.Options {
+		order = append(order, option.ID)
+	}
+	rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	return 0, &model.AnswerData{Options: order}
+}
+
+/********************** FREE TEXT **************************************/
+
+type freeTextKind struct{}
+
+func (freeTextKind) Type() model.QuestionType { return model.FreeText }
+
+func (freeTextKind) Prompt() string {
+	return `- free_text: the answer is one to three words typed by the player, "acceptedAnswers" lists the correct answer and its common spellings, "options" is empty and "answer" is 0. Example:
+{"type":"free_text","question":"Which planet is known as the Red Planet?","answer":0,"options":[],"answers":[],"numericAnswer":0,"tolerance":0,"acceptedAnswers":["Mars"]}`
+}
+
+func (freeTextKind) Validate(q *model.QuestionData) []string {
+	problems := []string{}
+	if len(q.Options) != 0 {
+		problems = append(problems, "is free text and must not have options")
+	}
+	if len(q.AcceptedAnswers) == 0 {
+		problems = append(problems, "has no accepted answers")
+	}
+	for _, accepted := range q.AcceptedAnswers {
+		if normalizeText(accepted) == "" {
+			problems = append(problems, "has an empty accepted answer")
+		}
+	}
+	return problems
+}
+
+// Credit is full when the text is close enough to one of the accepted answers, small typos are forgiven
+func (freeTextKind) Credit(q *model.QuestionData, option int, data *model.AnswerData) float64 {
+	if data == nil {
+		return 0
+	}
+	given := normalizeText(data.Text)
+	if given == "" {
+		return 0
+	}
+	for _, accepted := range q.AcceptedAnswers {
+		want := normalizeText(accepted)
+		if levenshtein(given, want) <= allowedTypos(want) {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (freeTextKind) DescribeAnswer(q *model.QuestionData, option int, data *model.AnswerData) string {
+	if data == nil {
+		return ""
+	}
+	return data.Text
+}
+
+func (freeTextKind) RightAnswer(q *model.QuestionData) string {
+	if len(q.AcceptedAnswers) == 0 {
+		return ""
+	}
+	return q.AcceptedAnswers[0]
+}
+
+// BotAnswer knows the answer half of the time
+func (freeTextKind) BotAnswer(q *model.QuestionData) (int, *model.AnswerData) {
+	text := "no idea"
+	if len(q.AcceptedAnswers) > 0 && rand.Intn(2) == 0 {
+		text = q.AcceptedAnswers[rand.Intn(len(q.AcceptedAnswers))]
+	}
+	return 0, &model.AnswerData{Text: text}
+}
+
+// normalizeText lower cases the text and drops the punctuation, the extra spaces and a leading article
+func normalizeText(text string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return ' '
+	}, text)
+	words := strings.Fields(cleaned)
+	if len(words) > 1 && (words[0] == "the" || words[0] == "a" || words[0] == "an") {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// allowedTypos is how many edits a free text answer may be away from the accepted one
+func allowedTypos(accepted string) int {
+	switch length := len([]rune(accepted)); {
+	case length <= 3:
+		return 0
+	case length <= 7:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// levenshtein is the number of single letter edits between the texts
+func levenshtein(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr := make([]int, len(rb)+1)
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev = curr
+	}
+	return prev[len(rb)]
+}
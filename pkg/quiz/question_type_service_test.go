@@ -0,0 +1,117 @@
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"math"
+	"strings"
+	"testing"
+)
+
+func number(n float64) *model.AnswerData {
+	return &model.AnswerData{Number: &n}
+}
+
+func picks(ids ...int) *model.AnswerData {
+	return &model.AnswerData{Options: ids}
+}
+
+func TestQuestionKindCredit(t *testing.T) {
+	singleChoice := fakeQuestion("What is the capital of France?", 2, "Berlin", "Paris", "Madrid", "Rome")
+	untyped := *singleChoice
+	untyped.Type = ""
+	trueFalse := fakeTypedQuestion(model.TrueFalse, "Bats are birds.", 2, nil, "True", "False")
+	multiSelect := fakeTypedQuestion(model.MultiSelect, "Which of these are primary colors?", 0, []int{1, 3}, "Red", "Green", "Blue", "Purple")
+	numeric := &model.QuestionData{Type: model.Numeric, Question: "In which year did World War II end?", NumericAnswer: 1945, Tolerance: 5}
+	numericNoTolerance := &model.QuestionData{Type: model.Numeric, Question: "How many days are there in a leap year?", NumericAnswer: 300}
+	ordering := fakeTypedQuestion(model.Ordering, "Order these planets from the closest to the sun.", 0, []int{2, 4, 1, 3}, "Earth", "Mercury", "Mars", "Venus")
+	freeText := &model.QuestionData{Type: model.FreeText, Question: "Who painted the Mona Lisa?", AcceptedAnswers: []string{"Leonardo da Vinci", "Da Vinci"}}
+	gold := &model.QuestionData{Type: model.FreeText, Question: "What is the chemical symbol of gold?", AcceptedAnswers: []string{"Au"}}
+
+	tests := []struct {
+		name     string
+		question *model.QuestionData
+		option   int
+		data     *model.AnswerData
+		credit   float64
+	}{
+		{"single choice right", singleChoice, 2, nil, 1},
+		{"single choice wrong", singleChoice, 3, nil, 0},
+		{"untyped is single choice", &untyped, 2, nil, 1},
+		{"true false right", trueFalse, 2, nil, 1},
+		{"true false wrong", trueFalse, 1, nil, 0},
+		{"multi select all right", multiSelect, 0, picks(3, 1), 1},
+		{"multi select half right", multiSelect, 0, picks(1), 0.5},
+		{"multi select a wrong pick takes one back", multiSelect, 0, picks(1, 3, 2), 0.5},
+		{"multi select never below zero", multiSelect, 0, picks(2, 4), 0},
+		{"multi select picks count once", multiSelect, 0, picks(1, 1), 0.5},
+		{"multi select without picks", multiSelect, 0, nil, 0},
+		{"numeric exact", numeric, 0, number(1945), 1},
+		{"numeric half way to the tolerance", numeric, 0, number(1947.5), 0.5},
+		{"numeric at the tolerance", numeric, 0, number(1940), 0},
+		{"numeric without a tolerance uses a tenth", numericNoTolerance, 0, number(285), 0.5},
+		{"numeric without a number", numeric, 0, &model.AnswerData{}, 0},
+		{"ordering right", ordering, 0, picks(2, 4, 1, 3), 1},
+		{"ordering half in place", ordering, 0, picks(2, 4, 3, 1), 0.5},
+		{"ordering with options missing", ordering, 0, picks(2, 4, 1), 0},
+		{"free text exact", freeText, 0, &model.AnswerData{Text: "Leonardo da Vinci"}, 1},
+		{"free text another accepted spelling", freeText, 0, &model.AnswerData{Text: "the da vinci!"}, 1},
+		{"free text with a typo", freeText, 0, &model.AnswerData{Text: "leonardo da vinchi"}, 1},
+		{"free text wrong", freeText, 0, &model.AnswerData{Text: "Picasso"}, 0},
+		{"free text short answers allow no typo", gold, 0, &model.AnswerData{Text: "Ag"}, 0},
+		{"free text empty", gold, 0, &model.AnswerData{Text: " ?"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			credit := GetQuestionKind(tt.question.Type).Credit(tt.question, tt.option, tt.data)
+			if math.Abs(credit-tt.credit) > 1e-9 {
+				t.Errorf("got credit %g, want %g", credit, tt.credit)
+			}
+		})
+	}
+}
+
+func TestQuestionKindValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		question *model.QuestionData
+		problem  string // empty when the question is valid
+	}{
+		{"true false", fakeTypedQuestion(model.TrueFalse, "The sun is a star.", 1, nil, "True", "False"), ""},
+		{"true false in the wrong order", fakeTypedQuestion(model.TrueFalse, "The sun is a star.", 1, nil, "False", "True"), `must have the options 1 "True" and 2 "False"`},
+		{"true false answer", fakeTypedQuestion(model.TrueFalse, "The sun is a star.", 3, nil, "True", "False"), "it must be 1 for true or 2 for false"},
+		{"multi select without answers", fakeTypedQuestion(model.MultiSelect, "Pick some", 0, nil, "a", "b", "c", "d"), "has no correct options"},
+		{"multi select answer twice", fakeTypedQuestion(model.MultiSelect, "Pick some", 0, []int{1, 1}, "a", "b", "c", "d"), "has the answer 1 more than once"},
+		{"numeric with options", fakeTypedQuestion(model.Numeric, "How many?", 0, nil, "a", "b"), "must not have options"},
+		{"numeric negative tolerance", &model.QuestionData{Type: model.Numeric, Question: "How many?", Tolerance: -1}, "cannot be negative"},
+		{"ordering", fakeTypedQuestion(model.Ordering, "Order these", 0, []int{3, 1, 2}, "Ten", "Hundred", "One"), ""},
+		{"ordering too few options", fakeTypedQuestion(model.Ordering, "Order these", 0, []int{2, 1}, "a", "b"), "expected between 3 and 6"},
+		{"ordering missing answers", fakeTypedQuestion(model.Ordering, "Order these", 0, []int{3, 1}, "a", "b", "c"), "expected all the 3 options in order"},
+		{"ordering unknown id", fakeTypedQuestion(model.Ordering, "Order these", 0, []int{3, 1, 7}, "a", "b", "c"), "has the answer 7 which is not an option id"},
+		{"free text", &model.QuestionData{Type: model.FreeText, Question: "Symbol of gold?", AcceptedAnswers: []string{"Au"}}, ""},
+		{"free text without answers", &model.QuestionData{Type: model.FreeText, Question: "Symbol of gold?"}, "has no accepted answers"},
+		{"free text blank answer", &model.QuestionData{Type: model.FreeText, Question: "Symbol of gold?", AcceptedAnswers: []string{"!!"}}, "has an empty accepted answer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := strings.Join(GetQuestionKind(tt.question.Type).Validate(tt.question), "; ")
+			if tt.problem == "" && problems != "" {
+				t.Errorf("expected no problems, got %q", problems)
+			}
+			if !strings.Contains(problems, tt.problem) {
+				t.Errorf("%q does not mention %q", problems, tt.problem)
+			}
+		})
+	}
+}
+
+func TestHideAnswer(t *testing.T) {
+	for _, q := range fakeQuestions {
+		hidden := HideAnswer(q)
+		if hidden.Answer != -1 || hidden.Answers != nil || hidden.NumericAnswer != 0 || hidden.Tolerance != 0 || hidden.AcceptedAnswers != nil {
+			t.Errorf("%q gives away its answer: %+v", q.Question, hidden)
+		}
+		if len(hidden.Options) != len(q.Options) {
+			t.Errorf("%q lost its options", q.Question)
+		}
+	}
+}
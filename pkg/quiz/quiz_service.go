@@ -8,23 +8,49 @@ import (
 	roommodel "brainwars/pkg/room/model"
 	"context"
 	"encoding/json"
+	"errors"
+	"sync"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Service is the quiz service, it runs its queries on the shared connection pool
+type Service struct {
+	db        dbpkg.Pool
+	q         *dbal.Queries
+	generator QuizGenerator // where the questions come from, see llm.provider in the config
+
+	jobMu        sync.RWMutex
+	jobListeners []JobListener // told about every status change of a generation job
+}
+
+func NewService(db dbpkg.Pool, generator QuizGenerator) *Service {
+	return &Service{
+		db:        db,
+		q:         dbal.New(db),
+		generator: generator,
+	}
+}
+
 // this is run in a goroutine
-func SetupQuizQuestions(ctx context.Context, req *model.QuestionReq) error {
+func (s *Service) SetupQuizQuestions(ctx context.Context, req *model.QuestionReq) error {
 	l := logs.GetLoggerctx(ctx)
 
-	questionData, err := GenerateQuiz(ctx, &model.QuizReq{
-		Topic: req.Topic,
-		Count: req.QuestionCount,
+	questionData, err := s.GenerateQuiz(ctx, &model.QuizReq{
+		Topic:         req.Topic,
+		Count:         req.QuestionCount,
+		Difficulty:    req.Difficulty,
+		QuestionTypes: req.QuestionTypes,
 	})
 	if err != nil {
 		l.Sugar().Error("Could not generate quiz", err)
 		return err
 	}
+	if len(questionData) == 0 {
+		l.Sugar().Error("llm did not generate any questions for topic ", req.Topic)
+		return errors.New("no questions generated")
+	}
 	// Create question request
 
 	questionReq := model.QuestionReq{
@@ -34,10 +60,11 @@ func SetupQuizQuestions(ctx context.Context, req *model.QuestionReq) error {
 		CreatedBy:     string(req.CreatedBy),
 		QuestionCount: req.QuestionCount,
 		TimeLimit:     req.TimeLimit,
+		Difficulty:    req.Difficulty,
 	}
 
 	// Create questions
-	err = CreateQuestion(ctx, questionReq)
+	err = s.CreateQuestion(ctx, questionReq)
 	if err != nil {
 		l.Sugar().Error("Could not create question", err)
 		return err
@@ -70,26 +97,12 @@ func SetupQuizQuestions(ctx context.Context, req *model.QuestionReq) error {
 // 	return questData, nil
 // }
 
-func GenerateQuiz(ctx context.Context, req *model.QuizReq) (questData []*model.QuestionData, err error) {
+func (s *Service) GenerateQuiz(ctx context.Context, req *model.QuizReq) (questData []*model.QuestionData, err error) {
 	l := logs.GetLoggerctx(ctx)
 
-	systemPrompt := getSystemPrompt(req)
-	llmResponse, err := callGemini(ctx, systemPrompt)
-	if err != nil {
-		l.Sugar().Error("get data from llm failed", err)
-		return nil, err
-	}
-	llmResponse, err = clearnllmOutput(llmResponse)
-	if err != nil {
-		l.Sugar().Error("clean llm output failed", err)
-		return nil, err
-	}
-
-	questData = []*model.QuestionData{}
-	// sample
-	err = json.Unmarshal([]byte(llmResponse), &questData)
+	questData, err = s.generator.GenerateQuestions(ctx, req)
 	if err != nil {
-		l.Sugar().Error("prompt unmarshell from llms failed", err)
+		l.Sugar().Error("generate questions failed", err)
 		return nil, err
 	}
 	for i := range questData {
@@ -100,7 +113,13 @@ func GenerateQuiz(ctx context.Context, req *model.QuizReq) (questData []*model.Q
 }
 
 // CreateQuestion creates a new question in the database
-func CreateQuestion(ctx context.Context, req model.QuestionReq) error {
+func (s *Service) CreateQuestion(ctx context.Context, req model.QuestionReq) error {
+	return InsertQuestion(ctx, s.q, req)
+}
+
+// InsertQuestion stores the questions of a room. it takes the queries so that a room built
+// from the question bank gets its questions in the same transaction as the room
+func InsertQuestion(ctx context.Context, q *dbal.Queries, req model.QuestionReq) error {
 	l := logs.GetLoggerctx(ctx)
 
 	quesJson, err := json.Marshal(req.QuestionData)
@@ -118,17 +137,13 @@ func CreateQuestion(ctx context.Context, req model.QuestionReq) error {
 		RoomCode:      req.RoomCode,
 		ID:            pgtype.UUID{Bytes: uuid.New(), Valid: true},
 		TimeLimit:     int32(req.TimeLimit),
+		Difficulty:    string(req.Difficulty),
 	}
-
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
+	if params.Difficulty == "" {
+		params.Difficulty = string(model.Easy)
 	}
-	defer dbConn.Db.Close()
 
-	dBal := dbal.New(dbConn.Db)
-	err = dBal.CreateQuestion(ctx, params)
+	err = q.CreateQuestion(ctx, params)
 	if err != nil {
 		l.Sugar().Error("Could not create question in database", err)
 		return err
@@ -138,7 +153,7 @@ func CreateQuestion(ctx context.Context, req model.QuestionReq) error {
 }
 
 // UpdateQuestionByID updates a question by its ID
-func UpdateQuestionByID(ctx context.Context, req model.EditQuestionReq) error {
+func (s *Service) UpdateQuestionByID(ctx context.Context, req model.EditQuestionReq) error {
 	l := logs.GetLoggerctx(ctx)
 
 	quesJson, err := json.Marshal(req.QuestionData)
@@ -156,15 +171,7 @@ func UpdateQuestionByID(ctx context.Context, req model.EditQuestionReq) error {
 		TimeLimit:     int32(req.TimeLimit),
 	}
 
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	err = dBal.UpdateQuestionByID(ctx, params)
+	err = s.q.UpdateQuestionByID(ctx, params)
 	if err != nil {
 		l.Sugar().Error("Could not update question in database", err)
 		return err
@@ -174,17 +181,9 @@ func UpdateQuestionByID(ctx context.Context, req model.EditQuestionReq) error {
 }
 
 // ListQuestionsByRoomCode lists questions by room Code
-func ListQuestionsByRoomCode(ctx context.Context, roomCode string) (questionDetails *model.Question, err error) {
+func (s *Service) ListQuestionsByRoomCode(ctx context.Context, roomCode string) (questionDetails *model.Question, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	question, err := dBal.GetQuestionsByRoomCode(ctx, roomCode)
+	question, err := s.q.GetQuestionsByRoomCode(ctx, roomCode)
 	if err != nil {
 		l.Sugar().Error("Could not list questions in database", err)
 		return nil, err
@@ -201,6 +200,7 @@ func ListQuestionsByRoomCode(ctx context.Context, roomCode string) (questionDeta
 		UpdatedBy:     question.UpdatedBy,
 		QuestionCount: int(question.QuestionCount),
 		TimeLimit:     int(question.TimeLimit),
+		Difficulty:    model.Difficulty(question.Difficulty),
 	}
 
 	qs := []*model.QuestionData{}
@@ -218,8 +218,13 @@ func ListQuestionsByRoomCode(ctx context.Context, roomCode string) (questionDeta
 }
 
 // CreateAnswer creates a new answer in the database
-func CreateAnswer(ctx context.Context, req *model.AnswerReq) error {
+func (s *Service) CreateAnswer(ctx context.Context, req *model.AnswerReq) error {
 	l := logs.GetLoggerctx(ctx)
+	answerData, err := answerDataJson(req.AnswerData)
+	if err != nil {
+		l.Sugar().Error("Could not marshal answer data", err)
+		return err
+	}
 	params := dbal.CreateAnswerParams{
 		RoomCode:       req.RoomCode,
 		UserID:         pgtype.UUID{Bytes: req.UserID, Valid: true},
@@ -231,17 +236,15 @@ func CreateAnswer(ctx context.Context, req *model.AnswerReq) error {
 		CreatedBy:      req.CreatedBy,
 		UpdatedBy:      req.CreatedBy,
 		ID:             pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		LatencyMs:      int32(req.LatencyMs),
+		FlagReason:     req.FlagReason,
+		Points:         int32(req.Points),
+		ScoreReason:    req.ScoreReason,
+		PowerUps:       powerUpNames(req.PowerUps),
+		AnswerData:     answerData,
 	}
 
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	err = dBal.CreateAnswer(ctx, params)
+	err = s.q.CreateAnswer(ctx, params)
 	if err != nil {
 		l.Sugar().Error("Could not create answer in database", err)
 		return err
@@ -250,8 +253,41 @@ func CreateAnswer(ctx context.Context, req *model.AnswerReq) error {
 	return nil
 }
 
+// power-ups are stored as text in the answer table
+func powerUpNames(powerUps []model.PowerUp) []string {
+	names := make([]string, 0, len(powerUps))
+	for _, powerUp := range powerUps {
+		names = append(names, string(powerUp))
+	}
+	return names
+}
+
+func toPowerUps(names []string) []model.PowerUp {
+	powerUps := make([]model.PowerUp, 0, len(names))
+	for _, name := range names {
+		powerUps = append(powerUps, model.PowerUp(name))
+	}
+	return powerUps
+}
+
+// the answer data is stored as an empty object for the single choice answers
+func answerDataJson(data *model.AnswerData) ([]byte, error) {
+	if data == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(data)
+}
+
+func toAnswerData(data []byte) *model.AnswerData {
+	answerData := &model.AnswerData{}
+	if err := json.Unmarshal(data, answerData); err != nil || answerData.Equal(&model.AnswerData{}) {
+		return nil
+	}
+	return answerData
+}
+
 // UpdateAnswer updates an existing answer in the database
-func UpdateAnswer(ctx context.Context, req model.EditAnswerReq) error {
+func (s *Service) UpdateAnswer(ctx context.Context, req model.EditAnswerReq) error {
 	l := logs.GetLoggerctx(ctx)
 	params := dbal.UpdateAnswerParams{
 		ID:           pgtype.UUID{Bytes: req.ID, Valid: true},
@@ -261,15 +297,7 @@ func UpdateAnswer(ctx context.Context, req model.EditAnswerReq) error {
 		UpdatedBy:    req.UpdatedBy,
 	}
 
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	err = dBal.UpdateAnswer(ctx, params)
+	err := s.q.UpdateAnswer(ctx, params)
 	if err != nil {
 		l.Sugar().Error("Could not update answer in database", err)
 		return err
@@ -278,18 +306,10 @@ func UpdateAnswer(ctx context.Context, req model.EditAnswerReq) error {
 	return nil
 }
 
-func GetAnswerByRoomCodeAndUserID(ctx context.Context, req roommodel.RoomCodeReq) ([]*model.Answer, error) {
+func (s *Service) GetAnswerByRoomCodeAndUserID(ctx context.Context, req roommodel.RoomCodeReq) ([]*model.Answer, error) {
 	l := logs.GetLoggerctx(ctx)
 	var answerDetails []*model.Answer
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	answers, err := dBal.GetAnswerByRoomCodeAndUserID(ctx, dbal.GetAnswerByRoomCodeAndUserIDParams{
+	answers, err := s.q.GetAnswerByRoomCodeAndUserID(ctx, dbal.GetAnswerByRoomCodeAndUserIDParams{
 		RoomCode: req.RoomCode,
 		UserID:   pgtype.UUID{Bytes: req.UserID, Valid: true},
 	})
@@ -308,6 +328,12 @@ func GetAnswerByRoomCodeAndUserID(ctx context.Context, req roommodel.RoomCodeReq
 		AnswerOption:   answers[0].AnswerOption,
 		IsCorrect:      answers[0].IsCorrect,
 		AnswerTime:     answers[0].AnswerTime.Time,
+		LatencyMs:      int(answers[0].LatencyMs),
+		FlagReason:     answers[0].FlagReason,
+		Points:         int(answers[0].Points),
+		ScoreReason:    answers[0].ScoreReason,
+		PowerUps:       toPowerUps(answers[0].PowerUps),
+		AnswerData:     toAnswerData(answers[0].AnswerData),
 		CreatedBy:      answers[0].CreatedBy,
 		UpdatedBy:      answers[0].UpdatedBy,
 	})
@@ -316,18 +342,10 @@ func GetAnswerByRoomCodeAndUserID(ctx context.Context, req roommodel.RoomCodeReq
 }
 
 // ListAnswersByRoomCode lists answers by room Code
-func ListAnswersByRoomCode(ctx context.Context, roomCode string) ([]*model.Answer, error) {
+func (s *Service) ListAnswersByRoomCode(ctx context.Context, roomCode string) ([]*model.Answer, error) {
 	l := logs.GetLoggerctx(ctx)
 	var answerDetails []*model.Answer
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	answers, err := dBal.ListAnswersByRoomCode(ctx, roomCode)
+	answers, err := s.q.ListAnswersByRoomCode(ctx, roomCode)
 	if err != nil {
 		l.Sugar().Error("Could not list answers in database", err)
 		return nil, err
@@ -344,6 +362,12 @@ func ListAnswersByRoomCode(ctx context.Context, roomCode string) ([]*model.Answe
 			AnswerOption:   answer.AnswerOption,
 			IsCorrect:      answer.IsCorrect,
 			AnswerTime:     answer.AnswerTime.Time,
+			LatencyMs:      int(answer.LatencyMs),
+			FlagReason:     answer.FlagReason,
+			Points:         int(answer.Points),
+			ScoreReason:    answer.ScoreReason,
+			PowerUps:       toPowerUps(answer.PowerUps),
+			AnswerData:     toAnswerData(answer.AnswerData),
 			CreatedBy:      answer.CreatedBy,
 			UpdatedBy:      answer.UpdatedBy,
 		})
@@ -351,3 +375,79 @@ func ListAnswersByRoomCode(ctx context.Context, roomCode string) ([]*model.Answe
 
 	return answerDetails, nil
 }
+
+/********************** GAME STATE CHECKPOINT **************************************/
+
+// SaveGameCheckpoint stores the snapshot of a running game so that it can be restored after a restart
+func (s *Service) SaveGameCheckpoint(ctx context.Context, req *model.GameCheckpoint) error {
+	l := logs.GetLoggerctx(ctx)
+
+	stateJson, err := json.Marshal(req.GameState)
+	if err != nil {
+		l.Sugar().Error("Could not marshal game state", err)
+		return err
+	}
+	historyJson, err := json.Marshal(req.AnswerHistory)
+	if err != nil {
+		l.Sugar().Error("Could not marshal answer history", err)
+		return err
+	}
+
+	err = s.q.UpsertGameState(ctx, dbal.UpsertGameStateParams{
+		RoomCode:      req.RoomCode,
+		RoomStatus:    string(req.GameState.RoomStatus),
+		State:         stateJson,
+		AnswerHistory: historyJson,
+		CreatedBy:     "system",
+	})
+	if err != nil {
+		l.Sugar().Error("Could not upsert game state in database", err)
+		return err
+	}
+
+	return nil
+}
+
+// ListGameCheckpointsByRoomStatus lists the checkpoints of all the games which were in the given status when they were saved
+func (s *Service) ListGameCheckpointsByRoomStatus(ctx context.Context, roomStatus roommodel.RoomStatus) ([]*model.GameCheckpoint, error) {
+	l := logs.GetLoggerctx(ctx)
+	var checkpoints []*model.GameCheckpoint
+	gameStates, err := s.q.ListGameStatesByRoomStatus(ctx, string(roomStatus))
+	if err != nil {
+		l.Sugar().Error("Could not list game states in database", err)
+		return nil, err
+	}
+
+	for _, gs := range gameStates {
+		checkpoint := &model.GameCheckpoint{
+			RoomCode:      gs.RoomCode,
+			GameState:     &model.GameState{},
+			AnswerHistory: map[uuid.UUID]model.AnswerHistory{},
+		}
+		err = json.Unmarshal(gs.State, checkpoint.GameState)
+		if err != nil {
+			l.Sugar().Error("Could not unmarshal game state", err)
+			return nil, err
+		}
+		err = json.Unmarshal(gs.AnswerHistory, &checkpoint.AnswerHistory)
+		if err != nil {
+			l.Sugar().Error("Could not unmarshal answer history", err)
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	return checkpoints, nil
+}
+
+// DeleteGameCheckpoint removes the checkpoint once the game has ended and the answers are stored
+func (s *Service) DeleteGameCheckpoint(ctx context.Context, roomCode string) error {
+	l := logs.GetLoggerctx(ctx)
+	err := s.q.DeleteGameStateByRoomCode(ctx, roomCode)
+	if err != nil {
+		l.Sugar().Error("Could not delete game state in database", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package quiz
+
+import (
+	"brainwars/pkg/db/dbal"
+	"brainwars/pkg/db/dbtest"
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz/model"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+func testContext() context.Context {
+	return logs.SetLoggerctx(context.Background(), zap.NewNop())
+}
+
+func TestQuestionsKeepTheDifficultyOfTheRoom(t *testing.T) {
+	tests := []struct {
+		name       string
+		difficulty model.Difficulty
+		stored     string
+	}{
+		{"chosen difficulty", model.Hard, "hard"},
+		{"no difficulty is easy", "", "easy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New()
+			s := NewService(db, nil)
+
+			err := InsertQuestion(testContext(), dbal.New(db), model.QuestionReq{RoomCode: "ROOM", QuestionCount: 1, TimeLimit: 10, Difficulty: tt.difficulty})
+			if err != nil {
+				t.Fatalf("insert question: %v", err)
+			}
+			calls := db.Calls("CreateQuestion")
+			// the difficulty is the last argument of the insert
+			if len(calls) != 1 || calls[0].Args[8] != tt.stored {
+				t.Fatalf("questions were stored with %+v, want the difficulty %s", calls, tt.stored)
+			}
+
+			db.Returns("GetQuestionsByRoomCode", []any{
+				pgtype.UUID{Bytes: uuid.New(), Valid: true}, "ROOM", pgtype.Text{}, int32(1), []byte("[]"), int32(10),
+				pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", calls[0].Args[8],
+			})
+			questions, err := s.ListQuestionsByRoomCode(testContext(), "ROOM")
+			if err != nil {
+				t.Fatalf("list questions: %v", err)
+			}
+			// the game scales the scores by it
+			if string(questions.Difficulty) != tt.stored {
+				t.Errorf("the game got the difficulty %q, want %q", questions.Difficulty, tt.stored)
+			}
+		})
+	}
+}
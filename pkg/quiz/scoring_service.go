@@ -0,0 +1,272 @@
+// Scoring is not hardcoded in the answer handler anymore. Every room picks one of the scoring presets
+// while it is created, the preset is recorded in the room meta and every answer is scored by it.
+// A score always comes with the reason so the analysis page can explain how it was reached.
+
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	changePenalty              = 50   // taken for every time a player changes the answer of a question
+	wrongAnswerPoint           = 50   // taken for a wrong answer by the negative marking rule
+	defaultStreakStep          = 0.25 // the streak rule adds this to the multiplier for every right answer in a row
+	defaultMaxStreakMultiplier = 2
+)
+
+func streakStep() float64 {
+	step := viper.GetFloat64("game.streakStep")
+	if step <= 0 {
+		step = defaultStreakStep
+	}
+	return step
+}
+
+func maxStreakMultiplier() float64 {
+	multiplier := viper.GetFloat64("game.maxStreakMultiplier")
+	if multiplier < 1 {
+		multiplier = defaultMaxStreakMultiplier
+	}
+	return multiplier
+}
+
+// StreakMultiplier is what the streak rule multiplies a right answer with after the given streak
+func StreakMultiplier(streak int) float64 {
+	return math.Min(1+streakStep()*float64(streak), maxStreakMultiplier())
+}
+
+// ScoringRule decides how many points an answer is worth
+type ScoringRule interface {
+	Name() model.ScoringRuleName
+	Description() string
+	// Score is called for every answer of a question, a changed answer replaces the score of the earlier one
+	Score(answer ScoredAnswer) Score
+}
+
+// ScoredAnswer is everything a scoring rule looks at
+type ScoredAnswer struct {
+	Credit     float64       // share of the answer which is right, 1 for a right answer and 0 for a wrong one
+	Latency    time.Duration // time since the question was sent
+	TimeLimit  time.Duration
+	Difficulty model.Difficulty
+	Changes    int // how many times the player changed the answer of this question
+	Streak     int // right answers in a row before this question
+}
+
+// Score is the points of an answer and how they were reached
+type Score struct {
+	Points int
+	Reason string
+}
+
+// ScoringRules lists the presets in the order they are offered while creating a room
+func ScoringRules() []ScoringRule {
+	return []ScoringRule{classicRule{}, speedRule{}, streakRule{}, negativeRule{}, accuracyRule{}}
+}
+
+// GetScoringRule returns the preset with the name, unknown names get the classic rule
+func GetScoringRule(name model.ScoringRuleName) ScoringRule {
+	for _, rule := range ScoringRules() {
+		if rule.Name() == name {
+			return rule
+		}
+	}
+	return classicRule{}
+}
+
+type roomMetaEntry struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ScoringRoomMeta is the room meta of a new room, it records the scoring rule of the room
+func ScoringRoomMeta(name model.ScoringRuleName) ([]byte, error) {
+	rule := GetScoringRule(name)
+	payload, err := json.Marshal(model.ScoringMeta{
+		Rule:        rule.Name(),
+		Description: rule.Description(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal([]roomMetaEntry{{Type: model.ScoringMetaEvent, Payload: payload}})
+}
+
+// ScoringRuleFromRoomMeta finds the scoring rule recorded in the room meta, rooms created before the
+// rules existed are played with the classic rule
+func ScoringRuleFromRoomMeta(roomMeta string) ScoringRule {
+	entries := []roomMetaEntry{}
+	if err := json.Unmarshal([]byte(roomMeta), &entries); err != nil {
+		return classicRule{}
+	}
+	for _, entry := range entries {
+		if entry.Type != model.ScoringMetaEvent {
+			continue
+		}
+		meta := model.ScoringMeta{}
+		if err := json.Unmarshal(entry.Payload, &meta); err == nil {
+			return GetScoringRule(meta.Rule)
+		}
+	}
+	return classicRule{}
+}
+
+// scoreBuilder adds up the parts of a score and writes down each of them
+type scoreBuilder struct {
+	points float64
+	parts  []string
+}
+
+func (b *scoreBuilder) add(points float64, what string) {
+	if points == 0 {
+		return
+	}
+	b.points += points
+	b.parts = append(b.parts, fmt.Sprintf("%+d %s", int(math.Round(points)), what))
+}
+
+// addRight adds the points of a right answer, a partly right answer gets its share of them
+func (b *scoreBuilder) addRight(points float64, credit float64) {
+	if credit >= 1 {
+		b.add(points, "right answer")
+		return
+	}
+	b.add(points*credit, fmt.Sprintf("%d%% right answer", int(math.Round(credit*100))))
+}
+
+func (b *scoreBuilder) scale(factor float64, what string) {
+	if factor == 1 || b.points == 0 {
+		return
+	}
+	b.points *= factor
+	b.parts = append(b.parts, fmt.Sprintf("x%g %s", factor, what))
+}
+
+func (b *scoreBuilder) penalizeChanges(changes int) {
+	switch {
+	case changes == 1:
+		b.add(-changePenalty, "for changing the answer")
+	case changes > 1:
+		b.add(-float64(changes*changePenalty), fmt.Sprintf("for changing the answer %d times", changes))
+	}
+}
+
+func (b *scoreBuilder) score() Score {
+	reason := strings.Join(b.parts, ", ")
+	if reason == "" {
+		reason = "no points"
+	}
+	return Score{Points: int(math.Round(b.points)), Reason: reason}
+}
+
+// speedShare is the share of the question's time that was left when the answer came in
+func speedShare(a ScoredAnswer) float64 {
+	if a.TimeLimit <= 0 {
+		return 0
+	}
+	share := 1 - a.Latency.Seconds()/a.TimeLimit.Seconds()
+	return math.Max(0, math.Min(1, share))
+}
+
+type classicRule struct{}
+
+func (classicRule) Name() model.ScoringRuleName { return model.ClassicScoring }
+
+func (classicRule) Description() string {
+	return "100 points for a right answer and up to 100 more for answering fast, scaled by the difficulty. Changing an answer costs 50 points."
+}
+
+func (classicRule) Score(a ScoredAnswer) Score {
+	b := &scoreBuilder{}
+	if a.Credit > 0 {
+		b.addRight(100, a.Credit)
+		b.add(100*a.Credit*speedShare(a), "speed bonus")
+		b.scale(a.Difficulty.Multiplier(), string(a.Difficulty)+" difficulty")
+	}
+	b.penalizeChanges(a.Changes)
+	return b.score()
+}
+
+type speedRule struct{}
+
+func (speedRule) Name() model.ScoringRuleName { return model.SpeedScoring }
+
+func (speedRule) Description() string {
+	return "50 points for a right answer and up to 250 more for answering fast, scaled by the difficulty. Changing an answer costs 50 points."
+}
+
+func (speedRule) Score(a ScoredAnswer) Score {
+	b := &scoreBuilder{}
+	if a.Credit > 0 {
+		b.addRight(50, a.Credit)
+		b.add(250*a.Credit*speedShare(a), "speed bonus")
+		b.scale(a.Difficulty.Multiplier(), string(a.Difficulty)+" difficulty")
+	}
+	b.penalizeChanges(a.Changes)
+	return b.score()
+}
+
+type streakRule struct{}
+
+func (streakRule) Name() model.ScoringRuleName { return model.StreakScoring }
+
+func (streakRule) Description() string {
+	return fmt.Sprintf("Classic scoring where every right answer in a row adds %g to the multiplier, up to %gx. A wrong or missed answer breaks the streak.",
+		streakStep(), maxStreakMultiplier())
+}
+
+func (streakRule) Score(a ScoredAnswer) Score {
+	b := &scoreBuilder{}
+	if a.Credit > 0 {
+		b.addRight(100, a.Credit)
+		b.add(100*a.Credit*speedShare(a), "speed bonus")
+		b.scale(a.Difficulty.Multiplier(), string(a.Difficulty)+" difficulty")
+		b.scale(StreakMultiplier(a.Streak), fmt.Sprintf("streak of %d", a.Streak))
+	}
+	b.penalizeChanges(a.Changes)
+	return b.score()
+}
+
+type negativeRule struct{}
+
+func (negativeRule) Name() model.ScoringRuleName { return model.NegativeScoring }
+
+func (negativeRule) Description() string {
+	return "100 points for a right answer and minus 50 for a wrong one, both scaled by the difficulty. Speed does not matter. Changing an answer costs 50 points."
+}
+
+func (negativeRule) Score(a ScoredAnswer) Score {
+	b := &scoreBuilder{}
+	if a.Credit > 0 {
+		b.addRight(100, a.Credit)
+	} else {
+		b.add(-wrongAnswerPoint, "wrong answer")
+	}
+	b.scale(a.Difficulty.Multiplier(), string(a.Difficulty)+" difficulty")
+	b.penalizeChanges(a.Changes)
+	return b.score()
+}
+
+type accuracyRule struct{}
+
+func (accuracyRule) Name() model.ScoringRuleName { return model.AccuracyScoring }
+
+func (accuracyRule) Description() string {
+	return "100 points for every right answer, nothing else counts."
+}
+
+func (accuracyRule) Score(a ScoredAnswer) Score {
+	b := &scoreBuilder{}
+	if a.Credit > 0 {
+		b.addRight(100, a.Credit)
+	}
+	return b.score()
+}
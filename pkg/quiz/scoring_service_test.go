@@ -0,0 +1,72 @@
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScoringRules(t *testing.T) {
+	tenSeconds := 10 * time.Second
+	tests := []struct {
+		name   string
+		rule   model.ScoringRuleName
+		answer ScoredAnswer
+		points int
+		reason string
+	}{
+		{"classic right at half time", model.ClassicScoring, ScoredAnswer{Credit: 1, Latency: 5 * time.Second, TimeLimit: tenSeconds, Difficulty: model.Easy}, 150, "+100 right answer, +50 speed bonus"},
+		{"classic medium difficulty", model.ClassicScoring, ScoredAnswer{Credit: 1, Latency: 5 * time.Second, TimeLimit: tenSeconds, Difficulty: model.Medium}, 225, "x1.5 medium difficulty"},
+		{"classic half right", model.ClassicScoring, ScoredAnswer{Credit: 0.5, Latency: tenSeconds, TimeLimit: tenSeconds, Difficulty: model.Easy}, 50, "+50 50% right answer"},
+		{"classic changed answer", model.ClassicScoring, ScoredAnswer{Credit: 1, Latency: tenSeconds, TimeLimit: tenSeconds, Changes: 1}, 50, "-50 for changing the answer"},
+		{"classic wrong", model.ClassicScoring, ScoredAnswer{Credit: 0, Latency: time.Second, TimeLimit: tenSeconds}, 0, "no points"},
+		{"classic wrong and changed twice", model.ClassicScoring, ScoredAnswer{Credit: 0, Changes: 2}, -100, "-100 for changing the answer 2 times"},
+		{"speed instant", model.SpeedScoring, ScoredAnswer{Credit: 1, TimeLimit: tenSeconds}, 300, "+250 speed bonus"},
+		{"speed at the buzzer", model.SpeedScoring, ScoredAnswer{Credit: 1, Latency: tenSeconds, TimeLimit: tenSeconds}, 50, "+50 right answer"},
+		{"speed late answers get no bonus", model.SpeedScoring, ScoredAnswer{Credit: 1, Latency: 2 * tenSeconds, TimeLimit: tenSeconds}, 50, "+50 right answer"},
+		{"streak of two", model.StreakScoring, ScoredAnswer{Credit: 1, Latency: tenSeconds, TimeLimit: tenSeconds, Streak: 2}, 150, "x1.5 streak of 2"},
+		{"streak is capped", model.StreakScoring, ScoredAnswer{Credit: 1, Latency: tenSeconds, TimeLimit: tenSeconds, Streak: 10}, 200, "x2 streak of 10"},
+		{"streak wrong", model.StreakScoring, ScoredAnswer{Credit: 0, Streak: 5}, 0, "no points"},
+		{"negative right", model.NegativeScoring, ScoredAnswer{Credit: 1, Latency: time.Second, TimeLimit: tenSeconds}, 100, "+100 right answer"},
+		{"negative wrong on hard", model.NegativeScoring, ScoredAnswer{Credit: 0, Difficulty: model.Hard}, -100, "-50 wrong answer, x2 hard difficulty"},
+		{"accuracy ignores the changes", model.AccuracyScoring, ScoredAnswer{Credit: 1, Changes: 3, Difficulty: model.Hard}, 100, "+100 right answer"},
+		{"accuracy wrong", model.AccuracyScoring, ScoredAnswer{Credit: 0}, 0, "no points"},
+		{"unknown rule is classic", "bogus", ScoredAnswer{Credit: 1, TimeLimit: tenSeconds}, 200, "+100 speed bonus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score := GetScoringRule(tt.rule).Score(tt.answer)
+			if score.Points != tt.points {
+				t.Errorf("got %d points (%s), want %d", score.Points, score.Reason, tt.points)
+			}
+			if !strings.Contains(score.Reason, tt.reason) {
+				t.Errorf("reason %q does not mention %q", score.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestScoringRuleFromRoomMeta(t *testing.T) {
+	speedMeta, err := ScoringRoomMeta(model.SpeedScoring)
+	if err != nil {
+		t.Fatalf("scoring room meta: %v", err)
+	}
+	tests := []struct {
+		name     string
+		roomMeta string
+		want     model.ScoringRuleName
+	}{
+		{"recorded rule", string(speedMeta), model.SpeedScoring},
+		{"room created before the rules", "", model.ClassicScoring},
+		{"meta without a scoring entry", `[{"type":"END_GAME","payload":{}}]`, model.ClassicScoring},
+		{"unknown rule", `[{"type":"` + model.ScoringMetaEvent + `","payload":{"rule":"bogus"}}]`, model.ClassicScoring},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScoringRuleFromRoomMeta(tt.roomMeta).Name(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// A room created in team mode records its number of teams in the room meta next to the scoring rule.
+// The score of a team is the sum of the scores of its members.
+
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+var teamNames = []string{"Red", "Blue", "Green", "Yellow"}
+
+// TeamName is the name the team is shown with
+func TeamName(team int) string {
+	if team >= 1 && team <= len(teamNames) {
+		return teamNames[team-1]
+	}
+	return fmt.Sprintf("Team %d", team)
+}
+
+// TeamRoomMeta adds the number of teams to the room meta, rooms which are not in team mode are left as they are
+func TeamRoomMeta(roomMeta []byte, teams int) ([]byte, error) {
+	if teams <= 0 {
+		return roomMeta, nil
+	}
+	entries := []roomMetaEntry{}
+	if err := json.Unmarshal(roomMeta, &entries); err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(model.TeamMeta{Count: teams})
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, roomMetaEntry{Type: model.TeamMetaEvent, Payload: payload})
+	return json.Marshal(entries)
+}
+
+// TeamCountFromRoomMeta finds the number of teams recorded in the room meta, 0 when the room is free for all
+func TeamCountFromRoomMeta(roomMeta string) int {
+	entries := []roomMetaEntry{}
+	if err := json.Unmarshal([]byte(roomMeta), &entries); err != nil {
+		return 0
+	}
+	for _, entry := range entries {
+		if entry.Type != model.TeamMetaEvent {
+			continue
+		}
+		meta := model.TeamMeta{}
+		if err := json.Unmarshal(entry.Payload, &meta); err == nil {
+			return meta.Count
+		}
+	}
+	return 0
+}
+
+// TeamScores adds up the scores of the participants by team, the best team comes first.
+// every team is listed even when none of its members has answered yet
+func TeamScores(participants []model.Participant, teamCount int) []model.TeamScore {
+	if teamCount <= 0 {
+		return nil
+	}
+	teams := make([]model.TeamScore, teamCount)
+	for i := range teams {
+		teams[i] = model.TeamScore{Team: i + 1, Name: TeamName(i + 1), Members: []string{}}
+	}
+	for _, participant := range participants {
+		if participant.Team < 1 || participant.Team > teamCount {
+			continue
+		}
+		team := &teams[participant.Team-1]
+		team.Score += participant.Score
+		team.Members = append(team.Members, participant.Username)
+	}
+	sort.SliceStable(teams, func(i, j int) bool {
+		return teams[i].Score > teams[j].Score
+	})
+	for i := range teams {
+		teams[i].Position = i + 1
+	}
+	return teams
+}
@@ -0,0 +1,95 @@
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"testing"
+)
+
+func TestTeamRoomMeta(t *testing.T) {
+	scoringMeta, err := ScoringRoomMeta(model.SpeedScoring)
+	if err != nil {
+		t.Fatalf("scoring room meta: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		teams int
+		want  int
+	}{
+		{"two teams", 2, 2},
+		{"four teams", 4, 4},
+		{"free for all", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roomMeta, err := TeamRoomMeta(scoringMeta, tt.teams)
+			if err != nil {
+				t.Fatalf("team room meta: %v", err)
+			}
+
+			if got := TeamCountFromRoomMeta(string(roomMeta)); got != tt.want {
+				t.Errorf("got %d teams, want %d", got, tt.want)
+			}
+			// the scoring rule recorded before is kept
+			if rule := ScoringRuleFromRoomMeta(string(roomMeta)).Name(); rule != model.SpeedScoring {
+				t.Errorf("got the scoring rule %s, want %s", rule, model.SpeedScoring)
+			}
+		})
+	}
+
+	if _, err := TeamRoomMeta([]byte("not json"), 2); err == nil {
+		t.Errorf("a room meta which is not json should fail")
+	}
+}
+
+func TestTeamCountFromRoomMeta(t *testing.T) {
+	tests := []struct {
+		name     string
+		roomMeta string
+		want     int
+	}{
+		{"recorded teams", `[{"type":"` + model.TeamMetaEvent + `","payload":{"count":3}}]`, 3},
+		{"room created before the teams", "", 0},
+		{"meta without a team entry", `[{"type":"END_GAME","payload":{}}]`, 0},
+		{"broken team entry", `[{"type":"` + model.TeamMetaEvent + `","payload":"three"}]`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TeamCountFromRoomMeta(tt.roomMeta); got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTeamScores(t *testing.T) {
+	participants := []model.Participant{
+		{Username: "ann", Team: 1, Score: 100},
+		{Username: "bob", Team: 2, Score: 250},
+		{Username: "cid", Team: 1, Score: 200},
+		{Username: "dan", Team: 0, Score: 900}, // the host or a player who joined after the teams were locked
+	}
+
+	teams := TeamScores(participants, 3)
+
+	want := []model.TeamScore{
+		{Team: 1, Name: "Red", Score: 300, Position: 1, Members: []string{"ann", "cid"}},
+		{Team: 2, Name: "Blue", Score: 250, Position: 2, Members: []string{"bob"}},
+		{Team: 3, Name: "Green", Score: 0, Position: 3, Members: []string{}},
+	}
+	if len(teams) != len(want) {
+		t.Fatalf("got %d teams, want %d", len(teams), len(want))
+	}
+	for i := range want {
+		got := teams[i]
+		if got.Team != want[i].Team || got.Name != want[i].Name || got.Score != want[i].Score || got.Position != want[i].Position || len(got.Members) != len(want[i].Members) {
+			t.Errorf("got %+v in place %d, want %+v", got, i+1, want[i])
+		}
+	}
+	if TeamScores(participants, 0) != nil {
+		t.Errorf("a free for all game has no teams")
+	}
+	if name := TeamName(7); name != "Team 7" {
+		t.Errorf("got the name %q, want Team 7", name)
+	}
+}
@@ -0,0 +1,79 @@
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"fmt"
+	"strings"
+)
+
+const optionCount = 4 // single choice and multi select questions have exactly 4 options with the ids 1 to 4
+
+// QuestionValidationError lists everything that is wrong with the generated questions
+type QuestionValidationError struct {
+	Problems []string
+}
+
+func (e *QuestionValidationError) Error() string {
+	return "invalid questions: " + strings.Join(e.Problems, "; ")
+}
+
+// validateQuestions checks the generated questions before they are stored for a room,
+// every question has to be of one of the asked types
+func validateQuestions(questions []*model.QuestionData, count int, types []model.QuestionType) error {
+	problems := []string{}
+	types = questionTypes(types)
+	allowedTypes := map[model.QuestionType]bool{}
+	for _, questionType := range types {
+		allowedTypes[questionType] = true
+	}
+	if len(questions) != count {
+		problems = append(problems, fmt.Sprintf("expected %d questions, got %d", count, len(questions)))
+	}
+
+	seen := map[string]int{} // normalized question text to its position
+	for i, q := range questions {
+		pos := i + 1
+		if q == nil {
+			problems = append(problems, fmt.Sprintf("question %d is null", pos))
+			continue
+		}
+
+		text := strings.ToLower(strings.TrimSpace(q.Question))
+		if text == "" {
+			problems = append(problems, fmt.Sprintf("question %d has an empty question text", pos))
+		} else if first, exists := seen[text]; exists {
+			problems = append(problems, fmt.Sprintf("question %d is a duplicate of question %d", pos, first))
+		} else {
+			seen[text] = pos
+		}
+
+		kind := GetQuestionKind(q.Type)
+		if !allowedTypes[kind.Type()] {
+			problems = append(problems, fmt.Sprintf("question %d has the type %s, it must be one of %s", pos, kind.Type(), typeNames(types)))
+		}
+		for _, problem := range kind.Validate(q) {
+			problems = append(problems, fmt.Sprintf("question %d %s", pos, problem))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &QuestionValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// questionTypes are the types the questions are generated in, single choice when none are asked for
+func questionTypes(types []model.QuestionType) []model.QuestionType {
+	if len(types) == 0 {
+		return []model.QuestionType{model.SingleChoice}
+	}
+	return types
+}
+
+func typeNames(types []model.QuestionType) string {
+	names := make([]string, 0, len(types))
+	for _, questionType := range types {
+		names = append(names, string(questionType))
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,166 @@
+package quiz
+
+import (
+	"brainwars/pkg/quiz/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// fakeQuestionSet asks the fake llm for the questions of the types
+func fakeQuestionSet(t *testing.T, count int, types ...model.QuestionType) []*model.QuestionData {
+	t.Helper()
+	generator, err := newFakeGenerator("")
+	if err != nil {
+		t.Fatalf("fake generator: %v", err)
+	}
+	questions, err := generator.GenerateQuestions(testContext(), &model.QuizReq{Topic: "anything", Count: count, QuestionTypes: types})
+	if err != nil {
+		t.Fatalf("fake generator: %v", err)
+	}
+	return questions
+}
+
+func TestValidateQuestionsAcceptsTheFakeLLM(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		types []model.QuestionType
+	}{
+		{"single choice by default", 5, nil},
+		{"true false", 2, []model.QuestionType{model.TrueFalse}},
+		{"multi select", 2, []model.QuestionType{model.MultiSelect}},
+		{"numeric", 2, []model.QuestionType{model.Numeric}},
+		{"ordering", 2, []model.QuestionType{model.Ordering}},
+		{"free text", 2, []model.QuestionType{model.FreeText}},
+		{"every type", 20, []model.QuestionType{model.SingleChoice, model.TrueFalse, model.MultiSelect, model.Numeric, model.Ordering, model.FreeText}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			questions := fakeQuestionSet(t, tt.count, tt.types...)
+			if err := validateQuestions(questions, tt.count, tt.types); err != nil {
+				t.Errorf("the fake questions should be valid: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateQuestionsListsTheProblems(t *testing.T) {
+	valid := func() *model.QuestionData {
+		return fakeQuestion("What is the capital of France?", 2, "Berlin", "Paris", "Madrid", "Rome")
+	}
+	tests := []struct {
+		name      string
+		questions []*model.QuestionData
+		count     int
+		types     []model.QuestionType
+		problem   string
+	}{
+		{"too few questions", []*model.QuestionData{valid()}, 2, nil, "expected 2 questions, got 1"},
+		{"null question", []*model.QuestionData{nil}, 1, nil, "question 1 is null"},
+		{"empty text", []*model.QuestionData{fakeQuestion("  ", 1, "a", "b", "c", "d")}, 1, nil, "empty question text"},
+		{"duplicate", []*model.QuestionData{valid(), fakeQuestion(" what is the capital of france?", 1, "a", "b", "c", "d")}, 2, nil, "question 2 is a duplicate of question 1"},
+		{"type not asked for", []*model.QuestionData{fakeTypedQuestion(model.TrueFalse, "The sun is a star.", 1, nil, "True", "False")}, 1, nil, "it must be one of single_choice"},
+		{"answer out of range", []*model.QuestionData{fakeQuestion("Pick one", 5, "a", "b", "c", "d")}, 1, nil, "has the answer 5"},
+		{"three options", []*model.QuestionData{fakeQuestion("Pick one", 1, "a", "b", "c")}, 1, nil, "has 3 options, expected exactly 4"},
+		{"same option twice", []*model.QuestionData{fakeQuestion("Pick one", 1, "a", "b", "A", "d")}, 1, nil, `has the option "A" more than once`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateQuestions(tt.questions, tt.count, tt.types)
+			validationErr := &QuestionValidationError{}
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.problem) {
+				t.Errorf("%q does not mention %q", err, tt.problem)
+			}
+		})
+	}
+}
+
+// scriptedProvider answers the llm calls with the responses in order
+type scriptedProvider struct {
+	responses []string
+	calls     [][]LLMMessage
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, messages []LLMMessage, schema *JSONSchema) (string, error) {
+	p.calls = append(p.calls, messages)
+	if len(p.calls) > len(p.responses) {
+		return "", errors.New("no more responses")
+	}
+	return p.responses[len(p.calls)-1], nil
+}
+
+func TestLLMGeneratorRepairsInvalidOutput(t *testing.T) {
+	valid, err := json.Marshal(fakeQuestionSet(t, 3))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	invalid, err := json.Marshal(fakeQuestionSet(t, 2))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	wrapped := `{"questions":` + string(valid) + `}`
+
+	tests := []struct {
+		name      string
+		responses []string
+		calls     int
+		wantErr   bool
+	}{
+		{"valid right away", []string{string(valid)}, 1, false},
+		{"valid in json schema mode", []string{wrapped}, 1, false},
+		{"repaired after a wrong count", []string{string(invalid), string(valid)}, 2, false},
+		{"repaired after broken json", []string{"sure, here you go: [{", string(invalid), wrapped}, 3, false},
+		{"still invalid after the repairs", []string{"nope", string(invalid), string(invalid)}, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &scriptedProvider{responses: tt.responses}
+			generator := &llmGenerator{provider: provider, maxRepairs: 2}
+			questions, err := generator.GenerateQuestions(testContext(), &model.QuizReq{Topic: "general", Count: 3, Difficulty: model.Easy})
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(questions) != 3 {
+				t.Errorf("got %d questions, want 3", len(questions))
+			}
+			if len(provider.calls) != tt.calls {
+				t.Fatalf("llm was called %d times, want %d", len(provider.calls), tt.calls)
+			}
+			// every repair sends the broken output back with what is wrong with it
+			if last := provider.calls[len(provider.calls)-1]; len(last) != 1+2*(tt.calls-1) {
+				t.Errorf("the last call had %d messages, want %d", len(last), 1+2*(tt.calls-1))
+			}
+		})
+	}
+}
+
+func TestLLMGeneratorMaxRepairs(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting any
+		want    int
+	}{
+		{"not set", nil, defaultLLMMaxRepairs},
+		{"repairs turned off", 0, 0},
+		{"more repairs", 5, 5},
+		{"negative", -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Set("llm.maxRepairs", tt.setting)
+			t.Cleanup(func() { viper.Set("llm.maxRepairs", nil) })
+
+			if got := newLLMGenerator(&scriptedProvider{}).maxRepairs; got != tt.want {
+				t.Errorf("got %d repairs, want %d", got, tt.want)
+			}
+		})
+	}
+}
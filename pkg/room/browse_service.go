@@ -0,0 +1,113 @@
+// Public rooms show up in the room browser while they wait for players, anyone can join them from the
+// list without the room code. The owner can lock a room, a locked room takes no new members whether it
+// is public or not, and a room takes no more members than its capacity. Outsiders can only watch
+// the public rooms which are not locked, a private room is only seen by its members.
+
+package room
+
+import (
+	"brainwars/pkg/db/dbal"
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/room/model"
+	"context"
+	"errors"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultRoomCapacity = 10 // members of a room, bots included
+	publicRoomsLimit    = 50 // rooms shown in the room browser
+)
+
+var (
+	ErrRoomLocked  = errors.New("this room is locked, nobody new can join it")
+	ErrRoomFull    = errors.New("this room is full")
+	ErrRoomPrivate = errors.New("this room is private, only its members can watch it")
+)
+
+// RoomCapacity is the number of members a room takes, bots included
+func RoomCapacity() int {
+	capacity := viper.GetInt("room.capacity")
+	if capacity <= 0 {
+		capacity = defaultRoomCapacity
+	}
+	return capacity
+}
+
+// ListPublicRooms lists the open public lobbies, the newest first. locked and full rooms are left out
+func (s *Service) ListPublicRooms(ctx context.Context) (rooms []*model.PublicRoom, err error) {
+	l := logs.GetLoggerctx(ctx)
+	dbRecords, err := s.q.ListPublicRooms(ctx, publicRoomsLimit)
+	if err != nil {
+		l.Sugar().Error("Could not list public rooms in database", err)
+		return nil, err
+	}
+	capacity := RoomCapacity()
+	rooms = []*model.PublicRoom{}
+	for _, room := range dbRecords {
+		if int(room.MemberCount) >= capacity {
+			continue
+		}
+		rooms = append(rooms, &model.PublicRoom{
+			RoomCode:   room.RoomCode,
+			RoomName:   room.RoomName.String,
+			GameType:   model.GT(room.GameType),
+			Topic:      room.Topic,
+			Difficulty: room.Difficulty,
+			Members:    int(room.MemberCount),
+			Capacity:   capacity,
+			CreatedOn:  room.CreatedOn.Time,
+		})
+	}
+	return rooms, nil
+}
+
+// CheckJoinable tells if a user who is not a member of the room yet can join it
+func (s *Service) CheckJoinable(ctx context.Context, roomDetails *model.Room, roomCode string) error {
+	if roomDetails.RoomLock {
+		return ErrRoomLocked
+	}
+	members, err := s.ListRoomMembersByRoomCode(ctx, model.RoomCodeReq{RoomCode: roomCode})
+	if err != nil {
+		return err
+	}
+	seated := 0
+	for _, member := range members {
+		switch member.RoomMemberStatus {
+		case model.LeaveQuiz, model.KickedQuiz, model.BannedQuiz:
+		default:
+			seated++
+		}
+	}
+	if seated >= RoomCapacity() {
+		return ErrRoomFull
+	}
+	return nil
+}
+
+// CheckWatchable tells if a user who is not a member of the room can spectate it
+func (s *Service) CheckWatchable(roomDetails *model.Room) error {
+	if !roomDetails.IsPublic {
+		return ErrRoomPrivate
+	}
+	if roomDetails.RoomLock {
+		return ErrRoomLocked
+	}
+	return nil
+}
+
+// SetRoomLock locks or unlocks the room for new members
+func (s *Service) SetRoomLock(ctx context.Context, roomCode string, locked bool, updatedBy string) error {
+	l := logs.GetLoggerctx(ctx)
+	err := s.q.UpdateRoomLockByRoomCode(ctx, dbal.UpdateRoomLockByRoomCodeParams{
+		RoomCode:  roomCode,
+		RoomLock:  locked,
+		UpdatedBy: updatedBy,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not update room lock in database", err)
+		return err
+	}
+	return nil
+}
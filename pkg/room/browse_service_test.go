@@ -0,0 +1,28 @@
+package room
+
+import (
+	"brainwars/pkg/room/model"
+	"errors"
+	"testing"
+)
+
+func TestCheckWatchable(t *testing.T) {
+	tests := []struct {
+		name string
+		room model.Room
+		want error
+	}{
+		{"public room", model.Room{IsPublic: true}, nil},
+		{"locked public room", model.Room{IsPublic: true, RoomLock: true}, ErrRoomLocked},
+		{"private room", model.Room{}, ErrRoomPrivate},
+		{"locked private room", model.Room{RoomLock: true}, ErrRoomPrivate},
+	}
+	s := &Service{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.CheckWatchable(&tt.room); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+// Room codes are uuids, which nobody wants to read out in a call. A room gets a short invite code
+// instead, six characters with the letters and digits that look alike left out. The code leads to the
+// room till it expires, and the owner can revoke it which hands out a new one.
+
+package room
+
+import (
+	"brainwars/pkg/db/dbal"
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/room/model"
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/spf13/viper"
+)
+
+const (
+	inviteAlphabet           = "ABCDEFGHJKMNPQRSTUVWXYZ23456789" // no I, L, O, 0 or 1
+	inviteCodeLength         = 6
+	inviteCodeAttempts       = 5 // new codes tried when a code is taken already
+	defaultInviteExpiryHours = 24
+	uniqueViolation          = "23505"
+)
+
+var ErrInviteInvalid = errors.New("this invite code does not exist, has expired or was revoked")
+
+// inviteExpiryHours is how long an invite code can be used
+func inviteExpiryHours() int {
+	hours := viper.GetInt("invite.expiryHours")
+	if hours <= 0 {
+		hours = defaultInviteExpiryHours
+	}
+	return hours
+}
+
+func newInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	max := big.NewInt(int64(len(inviteAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = inviteAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// NormalizeInviteCode makes the code the user typed comparable, invite codes ignore case and spaces
+func NormalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// IsInviteCode tells if the text has the shape of an invite code
+func IsInviteCode(code string) bool {
+	if len(code) != inviteCodeLength {
+		return false
+	}
+	for _, r := range code {
+		if !strings.ContainsRune(inviteAlphabet, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func toRoomInvite(invite dbal.RoomInvite) *model.RoomInvite {
+	return &model.RoomInvite{
+		InviteCode: invite.InviteCode,
+		RoomCode:   invite.RoomCode,
+		ExpiresOn:  invite.ExpiresOn.Time,
+		CreatedBy:  invite.CreatedBy,
+	}
+}
+
+// GetActiveInvite returns the invite code of the room that can still be used, nil if there is none
+func (s *Service) GetActiveInvite(ctx context.Context, roomCode string) (*model.RoomInvite, error) {
+	l := logs.GetLoggerctx(ctx)
+	invite, err := s.q.GetActiveRoomInviteByRoomCode(ctx, roomCode)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		l.Sugar().Error("Could not get room invite in database", err)
+		return nil, err
+	}
+	return toRoomInvite(invite), nil
+}
+
+// GetOrCreateInvite returns the invite code of the room, a new one is made when the room has none left
+func (s *Service) GetOrCreateInvite(ctx context.Context, roomCode, createdBy string) (*model.RoomInvite, error) {
+	invite, err := s.GetActiveInvite(ctx, roomCode)
+	if err != nil || invite != nil {
+		return invite, err
+	}
+	return s.CreateInvite(ctx, roomCode, createdBy)
+}
+
+// CreateInvite makes a new invite code for the room
+func (s *Service) CreateInvite(ctx context.Context, roomCode, createdBy string) (*model.RoomInvite, error) {
+	l := logs.GetLoggerctx(ctx)
+	for attempt := 0; ; attempt++ {
+		code, err := newInviteCode()
+		if err != nil {
+			l.Sugar().Error("Could not generate invite code", err)
+			return nil, err
+		}
+		invite, err := s.q.CreateRoomInvite(ctx, dbal.CreateRoomInviteParams{
+			ID:          pgtype.UUID{Bytes: uuid.New(), Valid: true},
+			InviteCode:  code,
+			RoomCode:    roomCode,
+			ExpiryHours: int32(inviteExpiryHours()),
+			CreatedBy:   createdBy,
+		})
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && attempt < inviteCodeAttempts {
+			continue // the code is taken, try another one
+		}
+		if err != nil {
+			l.Sugar().Error("Could not create room invite in database", err)
+			return nil, err
+		}
+		return toRoomInvite(invite), nil
+	}
+}
+
+// RevokeInvites stops the invite codes of the room from working and hands out a new one
+func (s *Service) RevokeInvites(ctx context.Context, roomCode, updatedBy string) (*model.RoomInvite, error) {
+	l := logs.GetLoggerctx(ctx)
+	err := s.q.RevokeRoomInvitesByRoomCode(ctx, dbal.RevokeRoomInvitesByRoomCodeParams{
+		RoomCode:  roomCode,
+		UpdatedBy: updatedBy,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not revoke room invites in database", err)
+		return nil, err
+	}
+	return s.CreateInvite(ctx, roomCode, updatedBy)
+}
+
+// ResolveInvite returns the room code the invite code leads to
+func (s *Service) ResolveInvite(ctx context.Context, inviteCode string) (string, error) {
+	l := logs.GetLoggerctx(ctx)
+	inviteCode = NormalizeInviteCode(inviteCode)
+	if !IsInviteCode(inviteCode) {
+		return "", ErrInviteInvalid
+	}
+	invite, err := s.q.GetValidRoomInviteByInviteCode(ctx, inviteCode)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrInviteInvalid
+	}
+	if err != nil {
+		l.Sugar().Error("Could not get room invite in database", err)
+		return "", err
+	}
+	return invite.RoomCode, nil
+}
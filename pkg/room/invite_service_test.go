@@ -0,0 +1,149 @@
+package room
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var errDBDown = errors.New("db is down")
+
+// roomInviteRow is a row of the room invite table
+func roomInviteRow(inviteCode, roomCode string) []any {
+	return []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, inviteCode, roomCode, pgtype.Timestamp{}, false,
+		pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner",
+	}
+}
+
+func TestNewInviteCode(t *testing.T) {
+	seen := map[string]bool{}
+	for range 100 {
+		code, err := newInviteCode()
+		if err != nil {
+			t.Fatalf("new invite code: %v", err)
+		}
+		if !IsInviteCode(code) {
+			t.Fatalf("got the code %q, want six characters of the invite alphabet", code)
+		}
+		if strings.ContainsAny(code, "IL01O") {
+			t.Errorf("the code %q has a character which looks like another one", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 95 {
+		t.Errorf("got %d different codes out of 100, the codes should be random", len(seen))
+	}
+}
+
+func TestIsInviteCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"ABC234", true},
+		{"ZZZZZZ", true},
+		{"ABC23", false},   // too short
+		{"ABC2345", false}, // too long
+		{"abc234", false},  // not normalized
+		{"ABC 23", false},
+		{"ABCI23", false}, // I looks like 1
+		{"ABC0O1", false}, // 0, O and 1 are left out
+		{"", false},
+		{"ABC23!", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := IsInviteCode(tt.code); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeInviteCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"already normalized", "ABC234", "ABC234"},
+		{"lower case", "abc234", "ABC234"},
+		{"spaces around", "  abc234\n", "ABC234"},
+		{"read out in two parts", "ABC 234", "ABC234"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeInviteCode(tt.code); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveInvite(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		setup     func(db *dbtest.FakeDB)
+		want      string
+		wantErr   error
+		wantQuery bool // the code has the shape of an invite code so it is looked up
+	}{
+		{
+			name:      "valid code",
+			code:      "abc 234",
+			setup:     func(db *dbtest.FakeDB) { db.Returns("GetValidRoomInviteByInviteCode", roomInviteRow("ABC234", "ROOM")) },
+			want:      "ROOM",
+			wantQuery: true,
+		},
+		{
+			name:      "expired, revoked or unknown code",
+			code:      "ABC234",
+			wantErr:   ErrInviteInvalid,
+			wantQuery: true,
+		},
+		{
+			name:    "not an invite code",
+			code:    "ABC-234",
+			wantErr: ErrInviteInvalid,
+		},
+		{
+			name:      "the database is down",
+			code:      "ABC234",
+			setup:     func(db *dbtest.FakeDB) { db.Fails("GetValidRoomInviteByInviteCode", errDBDown) },
+			wantErr:   errDBDown,
+			wantQuery: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New()
+			if tt.setup != nil {
+				tt.setup(db)
+			}
+			s := NewService(db, nil, nil)
+
+			roomCode, err := s.ResolveInvite(testContext(), tt.code)
+
+			if !errors.Is(err, tt.wantErr) || roomCode != tt.want {
+				t.Errorf("got room %q and error %v, want room %q and error %v", roomCode, err, tt.want, tt.wantErr)
+			}
+			queries := db.Calls("GetValidRoomInviteByInviteCode")
+			if !tt.wantQuery {
+				if len(queries) != 0 {
+					t.Errorf("a code which cannot be an invite code should not be looked up")
+				}
+				return
+			}
+			if len(queries) != 1 || queries[0].Args[0] != NormalizeInviteCode(tt.code) {
+				t.Errorf("got the lookups %+v, want the normalized code looked up", queries)
+			}
+		})
+	}
+}
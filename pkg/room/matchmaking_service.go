@@ -0,0 +1,312 @@
+// The matchmaking queue lets players find a game without sharing a room code. A player queues for a
+// topic category and difficulty, and once enough players wait in the same queue, or the first of them
+// has waited long enough, they are put in a new multiplayer room. The empty seats go to bots. The
+// players poll their status and are sent to the room once it is ready. The queues are kept in the
+// database, a player can queue, poll and leave on any instance and every instance forms rooms from
+// the same queues.
+
+package room
+
+import (
+	"brainwars/pkg/db/dbal"
+	logs "brainwars/pkg/logger"
+	quizmodel "brainwars/pkg/quiz/model"
+	"brainwars/pkg/room/model"
+	usermodel "brainwars/pkg/users/model"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultMatchMinPlayers     = 2  // players in a queue before a room is formed right away
+	defaultMatchMaxPlayers     = 6  // players put in one room
+	defaultMatchWaitSeconds    = 30 // wait of the first player in a queue before a room is formed with whoever is there
+	defaultMatchSeats          = 4  // seats of a matched room, the ones the players dont take go to bots
+	defaultMatchQuestions      = 5
+	defaultMatchTimeLimit      = 20
+	defaultMatchFormingSeconds = 120 // a room still forming after this long was left behind by an instance that went away
+)
+
+// bots in the order they take the empty seats
+var matchBots = []string{"20 sec", "30 sec", "15 sec", "45 sec", "10 sec", "1 min", "2 min"}
+
+var ErrUnknownCategory = errors.New("unknown matchmaking category")
+
+// matchConfig reads a matchmaking setting, the default is used when it is not set
+func matchConfig(key string, defaultValue int) int {
+	value := viper.GetInt("matchmaking." + key)
+	if value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+type matchTicket struct {
+	req    model.MatchReq
+	waited bool // he waited long enough for a room to be formed with whoever is there
+}
+
+// Matchmaker forms the rooms of the matchmaking queues, a queue for every category and difficulty
+type Matchmaker struct {
+	rooms *Service
+}
+
+// NewMatchmaker starts the matchmaking queues, rooms are formed till the context is done
+func NewMatchmaker(ctx context.Context, rooms *Service) *Matchmaker {
+	m := &Matchmaker{rooms: rooms}
+	go m.run(ctx)
+	return m
+}
+
+func queueKey(category, difficulty string) string {
+	return category + "|" + difficulty
+}
+
+// Join puts the player in the queue of the category and difficulty, a player waits in one queue at a time
+func (m *Matchmaker) Join(ctx context.Context, req model.MatchReq) (model.MatchStatus, error) {
+	l := logs.GetLoggerctx(ctx)
+	if !slices.Contains(model.MatchCategories, req.Category) {
+		return model.MatchStatus{}, ErrUnknownCategory
+	}
+
+	// a player whose room is already on its way keeps his ticket
+	err := m.rooms.q.UpsertMatchTicket(ctx, dbal.UpsertMatchTicketParams{
+		UserID:       pgtype.UUID{Bytes: req.UserID, Valid: true},
+		Username:     req.Username,
+		Category:     req.Category,
+		Difficulty:   req.Difficulty,
+		StaleSeconds: int32(matchConfig("formingSeconds", defaultMatchFormingSeconds)),
+	})
+	if err != nil {
+		l.Sugar().Error("Could not queue the user for matchmaking", err)
+		return model.MatchStatus{}, err
+	}
+	l.Sugar().Infof("user %s queued for %s", req.UserID, queueKey(req.Category, req.Difficulty))
+	return m.status(ctx, req.UserID)
+}
+
+// Leave takes the player out of his queue
+func (m *Matchmaker) Leave(ctx context.Context, userID uuid.UUID) error {
+	l := logs.GetLoggerctx(ctx)
+
+	err := m.rooms.q.DeleteMatchTicketByUserIDAndStatus(ctx, dbal.DeleteMatchTicketByUserIDAndStatusParams{
+		UserID: pgtype.UUID{Bytes: userID, Valid: true},
+		Status: string(model.MatchQueued),
+	})
+	if err != nil {
+		l.Sugar().Error("Could not take the user out of the matchmaking queue", err)
+		return err
+	}
+	return nil
+}
+
+// Status tells the player where he is at, the room code of a matched player is handed out once
+func (m *Matchmaker) Status(ctx context.Context, userID uuid.UUID) (model.MatchStatus, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	status, err := m.status(ctx, userID)
+	if err != nil || (status.RoomCode == "" && !status.Failed) {
+		return status, err
+	}
+	ticketStatus := model.MatchMatched
+	if status.Failed {
+		ticketStatus = model.MatchFailed
+	}
+	err = m.rooms.q.DeleteMatchTicketByUserIDAndStatus(ctx, dbal.DeleteMatchTicketByUserIDAndStatusParams{
+		UserID: pgtype.UUID{Bytes: userID, Valid: true},
+		Status: string(ticketStatus),
+	})
+	if err != nil {
+		// he is told anyway, the ticket is replaced when he queues again
+		l.Sugar().Error("Could not delete the matchmaking ticket", err)
+	}
+	return status, nil
+}
+
+// status of the player from his ticket
+func (m *Matchmaker) status(ctx context.Context, userID uuid.UUID) (model.MatchStatus, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	ticket, err := m.rooms.q.GetMatchTicketByUserID(ctx, pgtype.UUID{Bytes: userID, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.MatchStatus{}, nil
+	}
+	if err != nil {
+		l.Sugar().Error("Could not get the matchmaking ticket", err)
+		return model.MatchStatus{}, err
+	}
+
+	switch model.MatchTicketStatus(ticket.Status) {
+	case model.MatchMatched:
+		return model.MatchStatus{RoomCode: ticket.RoomCode}, nil
+	case model.MatchFailed:
+		return model.MatchStatus{Failed: true}, nil
+	case model.MatchForming:
+		return model.MatchStatus{Queued: true, Forming: true}, nil
+	}
+
+	waiting, err := m.rooms.q.CountQueuedMatchTickets(ctx, dbal.CountQueuedMatchTicketsParams{
+		Category:   ticket.Category,
+		Difficulty: ticket.Difficulty,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not count the players in the matchmaking queue", err)
+		return model.MatchStatus{}, err
+	}
+	return model.MatchStatus{
+		Queued:     true,
+		Category:   ticket.Category,
+		Difficulty: ticket.Difficulty,
+		Waiting:    int(waiting),
+		QueuedAt:   ticket.QueuedOn.Time,
+	}, nil
+}
+
+func (m *Matchmaker) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			groups, err := m.takeGroups(ctx)
+			if err != nil {
+				continue
+			}
+			for _, group := range groups {
+				go m.setupMatch(ctx, group)
+			}
+		}
+	}
+}
+
+// takeGroups takes the players out of the queues who are ready to be put in a room, their tickets are
+// marked as forming in the same transaction they are read in so no other instance takes them
+func (m *Matchmaker) takeGroups(ctx context.Context) ([][]*matchTicket, error) {
+	l := logs.GetLoggerctx(ctx)
+	minPlayers := matchConfig("minPlayers", defaultMatchMinPlayers)
+	maxPlayers := matchConfig("maxPlayers", defaultMatchMaxPlayers)
+	wait := matchConfig("waitSeconds", defaultMatchWaitSeconds)
+
+	groups := [][]*matchTicket{}
+	err := m.rooms.withTx(ctx, func(qtx *dbal.Queries) error {
+		tickets, err := qtx.ListQueuedMatchTickets(ctx, int32(wait))
+		if err != nil {
+			l.Sugar().Error("Could not list the matchmaking queues", err)
+			return err
+		}
+
+		// the tickets come in the order of their queue and the order they were queued in
+		queues := [][]*matchTicket{}
+		for i, ticket := range tickets {
+			if i == 0 || ticket.Category != tickets[i-1].Category || ticket.Difficulty != tickets[i-1].Difficulty {
+				queues = append(queues, []*matchTicket{})
+			}
+			queues[len(queues)-1] = append(queues[len(queues)-1], &matchTicket{
+				req: model.MatchReq{
+					UserID:     ticket.UserID.Bytes,
+					Username:   ticket.Username,
+					Category:   ticket.Category,
+					Difficulty: ticket.Difficulty,
+				},
+				waited: ticket.Waited,
+			})
+		}
+
+		userIDs := []pgtype.UUID{}
+		for _, queue := range queues {
+			for len(queue) > 0 && (len(queue) >= minPlayers || queue[0].waited) {
+				size := min(len(queue), maxPlayers)
+				groups = append(groups, queue[:size:size])
+				for _, ticket := range queue[:size] {
+					userIDs = append(userIDs, pgtype.UUID{Bytes: ticket.req.UserID, Valid: true})
+				}
+				queue = queue[size:]
+			}
+		}
+		if len(userIDs) == 0 {
+			return nil
+		}
+
+		err = qtx.UpdateMatchTicketStatusByUserIDs(ctx, dbal.UpdateMatchTicketStatusByUserIDsParams{
+			Status:  string(model.MatchForming),
+			UserIds: userIDs,
+		})
+		if err != nil {
+			l.Sugar().Error("Could not mark the matchmaking tickets as forming", err)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// setupMatch sets up a multiplayer room for the group, the first player in the queue owns it and the
+// empty seats are filled with bots
+func (m *Matchmaker) setupMatch(ctx context.Context, group []*matchTicket) {
+	l := logs.GetLoggerctx(ctx)
+	owner := group[0].req
+
+	status := model.MatchMatched
+	roomCode, err := m.createMatchRoom(ctx, group)
+	if err != nil {
+		l.Sugar().Error("Could not set up the matchmaking room", err)
+		status = model.MatchFailed
+	}
+
+	userIDs := []pgtype.UUID{}
+	for _, ticket := range group {
+		userIDs = append(userIDs, pgtype.UUID{Bytes: ticket.req.UserID, Valid: true})
+	}
+	err = m.rooms.q.UpdateMatchTicketStatusByUserIDs(ctx, dbal.UpdateMatchTicketStatusByUserIDsParams{
+		Status:   string(status),
+		RoomCode: roomCode,
+		UserIds:  userIDs,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not update the matchmaking tickets", err)
+		return
+	}
+	if status == model.MatchMatched {
+		l.Sugar().Infof("matched %d players for %s in room %s", len(group), queueKey(owner.Category, owner.Difficulty), roomCode)
+	}
+}
+
+// createMatchRoom sets up the room, the other players join it in the transaction the room is created in
+func (m *Matchmaker) createMatchRoom(ctx context.Context, group []*matchTicket) (string, error) {
+	owner := group[0].req
+	playerIDs := []model.UserIDReq{}
+	for _, ticket := range group[1:] {
+		playerIDs = append(playerIDs, model.UserIDReq{UserID: ticket.req.UserID})
+	}
+	botIDs := []model.UserIDReq{}
+	for i := 0; i < matchConfig("seats", defaultMatchSeats)-len(group) && i < len(matchBots); i++ {
+		botIDs = append(botIDs, model.UserIDReq{UserID: usermodel.BotMap[matchBots[i]]})
+	}
+
+	return m.rooms.setupGame(ctx, model.RoomReq{
+		UserID:      owner.UserID,
+		Username:    owner.Username,
+		UserMeta:    "[{}]",
+		RoomName:    fmt.Sprintf("Quick match: %s", owner.Category),
+		GameType:    model.MP,
+		TimeLimit:   matchConfig("timeLimit", defaultMatchTimeLimit),
+		ScoringRule: string(quizmodel.ClassicScoring),
+	}, playerIDs, botIDs, &quizmodel.QuizReq{
+		Topic:      owner.Category,
+		Count:      matchConfig("questionCount", defaultMatchQuestions),
+		Difficulty: quizmodel.Difficulty(owner.Difficulty),
+	})
+}
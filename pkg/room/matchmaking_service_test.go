@@ -0,0 +1,181 @@
+package room
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	"brainwars/pkg/room/model"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// matchTicketRow is a queued ticket the way ListQueuedMatchTickets returns it
+func matchTicketRow(category string, waited bool) []any {
+	return []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, "player", category, "easy", string(model.MatchQueued), "",
+		pgtype.Timestamp{}, pgtype.Timestamp{}, waited,
+	}
+}
+
+// matchGroup is a group of players taken out of the queue of a category
+func matchGroup(size int) []*matchTicket {
+	group := []*matchTicket{}
+	for range size {
+		group = append(group, &matchTicket{req: model.MatchReq{UserID: uuid.New(), Username: "player", Category: "Science", Difficulty: "easy"}})
+	}
+	return group
+}
+
+// containsUser tells if one of the calls got the user as its argument at the index
+func containsUser(calls []dbtest.Call, index int, userID uuid.UUID) bool {
+	return slices.ContainsFunc(calls, func(call dbtest.Call) bool {
+		return call.Args[index] == pgtype.UUID{Bytes: userID, Valid: true}
+	})
+}
+
+func TestTakeGroups(t *testing.T) {
+	tests := []struct {
+		name       string
+		science    int  // players in the science queue
+		waited     bool // the first of them waited long enough
+		history    int  // players in the history queue
+		wantGroups []int
+	}{
+		{name: "not enough players yet", science: 1},
+		{name: "enough players", science: 2, wantGroups: []int{2}},
+		{name: "the first player waited long enough", science: 1, waited: true, wantGroups: []int{1}},
+		{name: "a full queue is split", science: 8, wantGroups: []int{6, 2}},
+		{name: "the rest waits for more players", science: 7, wantGroups: []int{6}},
+		{name: "the queues are not mixed", science: 1, history: 1},
+		{name: "every queue gets its rooms", science: 2, history: 3, wantGroups: []int{3, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New()
+			rows := [][]any{}
+			for i := range tt.history {
+				rows = append(rows, matchTicketRow("History", tt.waited && i == 0))
+			}
+			for i := range tt.science {
+				rows = append(rows, matchTicketRow("Science", tt.waited && i == 0))
+			}
+			db.Returns("ListQueuedMatchTickets", rows...)
+			m := &Matchmaker{rooms: NewService(db, nil, nil)}
+
+			groups, err := m.takeGroups(testContext())
+			if err != nil {
+				t.Fatalf("take groups: %v", err)
+			}
+
+			sizes := []int{}
+			taken := 0
+			for _, group := range groups {
+				sizes = append(sizes, len(group))
+				taken += len(group)
+				for _, ticket := range group[1:] {
+					if ticket.req.Category != group[0].req.Category {
+						t.Errorf("a %s player was put with %s players", ticket.req.Category, group[0].req.Category)
+					}
+				}
+			}
+			if !slices.Equal(sizes, tt.wantGroups) {
+				t.Errorf("got the groups %v, want %v", sizes, tt.wantGroups)
+			}
+			updates := db.Calls("UpdateMatchTicketStatusByUserIDs")
+			if taken == 0 {
+				if len(updates) != 0 {
+					t.Errorf("no ticket should be marked when nobody is taken")
+				}
+				return
+			}
+			if len(updates) != 1 || updates[0].Args[0] != string(model.MatchForming) || len(updates[0].Args[2].([]pgtype.UUID)) != taken {
+				t.Errorf("got the ticket updates %+v, want the %d players taken marked as forming", updates, taken)
+			}
+			if db.Commits() != 1 {
+				t.Errorf("got %d commits, want the tickets read and marked in one transaction", db.Commits())
+			}
+		})
+	}
+
+	t.Run("the queues cannot be read", func(t *testing.T) {
+		db := dbtest.New()
+		db.Fails("ListQueuedMatchTickets", errors.New("db is down"))
+		m := &Matchmaker{rooms: NewService(db, nil, nil)}
+
+		groups, err := m.takeGroups(testContext())
+
+		if err == nil || groups != nil || db.Rollbacks() != 1 {
+			t.Errorf("got the groups %v and error %v, want the transaction rolled back", groups, err)
+		}
+	})
+}
+
+func TestSetupMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing string // query which fails, empty when the room is set up
+	}{
+		{"room set up", ""},
+		{"a member cannot join", "CreateRoomMember"},
+		{"a player cannot get a leaderboard row", "CreatLeaderBoard"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New()
+			db.Returns("CreateRoom", roomRow("ROOM"))
+			db.Returns("GetRoomByID", roomRow("ROOM"))
+			db.Returns("CreateRoomMember", roomMemberRow("ROOM"))
+			db.Returns("CreateGenerationJob", generationJobRow("ROOM", quizmodel.JobPending))
+			db.Returns("UpdateGenerationJobStatus", generationJobRow("ROOM", quizmodel.JobRunning))
+			if tt.failing != "" {
+				db.Fails(tt.failing, errors.New("db is down"))
+			}
+			generator := &recordingGenerator{calls: make(chan *quizmodel.QuizReq, 1)}
+			m := &Matchmaker{rooms: NewService(db, quiz.NewService(db, generator), nil)}
+			group := matchGroup(3)
+
+			m.setupMatch(testContext(), group)
+
+			updates := db.Calls("UpdateMatchTicketStatusByUserIDs")
+			if len(updates) != 1 || len(updates[0].Args[2].([]pgtype.UUID)) != len(group) {
+				t.Fatalf("got the ticket updates %+v, want the tickets of the group updated", updates)
+			}
+			if tt.failing != "" {
+				if updates[0].Args[0] != string(model.MatchFailed) || updates[0].Args[1] != "" {
+					t.Errorf("got the tickets %v in room %q, want them failed", updates[0].Args[0], updates[0].Args[1])
+				}
+				if db.Commits() != 0 || db.Rollbacks() != 1 {
+					t.Errorf("got %d commits and %d rollbacks, want the room rolled back", db.Commits(), db.Rollbacks())
+				}
+				return
+			}
+
+			if updates[0].Args[0] != string(model.MatchMatched) || updates[0].Args[1] != "ROOM" {
+				t.Errorf("got the tickets %v in room %q, want them matched in ROOM", updates[0].Args[0], updates[0].Args[1])
+			}
+			if db.Commits() != 1 || db.Rollbacks() != 0 {
+				t.Errorf("got %d commits and %d rollbacks, want the room and its players in one transaction", db.Commits(), db.Rollbacks())
+			}
+			members, leaderBoards := db.Calls("CreateRoomMember"), db.Calls("CreatLeaderBoard")
+			for _, ticket := range group[1:] {
+				if !containsUser(members, 2, ticket.req.UserID) || !containsUser(leaderBoards, 2, ticket.req.UserID) {
+					t.Errorf("player %s should join the room with a leaderboard row", ticket.req.UserID)
+				}
+			}
+			// the owner and two players leave one of the four seats to a bot
+			if len(members) != 4 {
+				t.Errorf("got %d members, want the owner, two players and a bot", len(members))
+			}
+			select {
+			case <-generator.calls:
+			case <-time.After(2 * time.Second):
+				t.Errorf("the questions of the room were not generated")
+			}
+		})
+	}
+}
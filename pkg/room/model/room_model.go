@@ -20,6 +20,7 @@ type GT string
 const (
 	SP GT = "SINGLE_PLAYER"
 	MP GT = "MULTI_PLAYER"
+	EL GT = "ELIMINATION" // multiplayer where the wrong and the slowest players drop out after every question
 )
 
 type RoomStatus string
@@ -29,18 +30,32 @@ const (
 	Ended   RoomStatus = "ENDED"
 	Waiting RoomStatus = "WAITING" // room is created but the game has not started so people can join in ie waiting for players
 	Deleted RoomStatus = "DELETED"
+	Failed  RoomStatus = "FAILED" // question generation failed so the game can never start
 )
 
 // RoomReq is a struct that defines the request body for creating a room
 type RoomReq struct {
-	UserID    uuid.UUID `validate:"required"`
-	Username  string    `validate:"required"`
-	UserMeta  string    `validate:"required"`
-	RoomName  string    `validate:"required"`
-	GameType  GT        `validate:"required"`
-	TimeLimit int       `validate:"required"` // max time allocated for each question
+	UserID      uuid.UUID `validate:"required"`
+	Username    string    `validate:"required"`
+	UserMeta    string    `validate:"required"`
+	RoomName    string    `validate:"required"`
+	GameType    GT        `validate:"required"`
+	TimeLimit   int       `validate:"required,min=5,max=300"` // max seconds allocated for each question
+	HostMode    bool      // the creator wrote the questions so he hosts the game instead of playing
+	ScoringRule string    `validate:"omitempty,oneof=classic speed streak negative accuracy"` // scoring preset, classic when empty
+	Teams       int       `validate:"omitempty,min=2,max=4"`                                  // number of teams in team mode, 0 is free for all
+	IsPublic    bool      // listed in the room browser so anyone can join without the room code
 }
 
+// MemberRole is the part a member plays in the room
+type MemberRole string
+
+const (
+	Player    MemberRole = "PLAYER"
+	Host      MemberRole = "HOST"      // watches the game with the live answers, cannot answer
+	Spectator MemberRole = "SPECTATOR" // joined late to watch, only lives on the websocket and is never stored as a member
+)
+
 type RoomMemberStatus string
 
 const (
@@ -49,6 +64,7 @@ const (
 	ReadyQuiz    RoomMemberStatus = "READY_QUIZ"
 	LeaveQuiz    RoomMemberStatus = "LEAVE_QUIZ"
 	KickedQuiz   RoomMemberStatus = "KICKED_QUIZ" // KICKED OUT OF THE ROOM
+	BannedQuiz   RoomMemberStatus = "BANNED_QUIZ" // kicked out and cannot join the room again
 	BotReadyQuiz RoomMemberStatus = "BOT_READY_QUIZ"
 )
 
@@ -70,6 +86,21 @@ type Room struct {
 	UpdatedOn     time.Time
 	QuestionTopic string // for listing
 	TimeLimit     int    // for listing
+	Difficulty    string // for listing
+	RoomLock      bool   // nobody who is not a member yet can join
+	IsPublic      bool   // listed in the room browser
+}
+
+// PublicRoom is an open lobby in the room browser
+type PublicRoom struct {
+	RoomCode   string
+	RoomName   string
+	GameType   GT
+	Topic      string
+	Difficulty string
+	Members    int // bots take a seat as well
+	Capacity   int
+	CreatedOn  time.Time
 }
 
 type EditRoomReq struct {
@@ -95,6 +126,7 @@ type RoomMember struct {
 	IsBot            bool
 	JoinedOn         time.Time
 	RoomMemberStatus RoomMemberStatus
+	Role             MemberRole
 	IsActive         bool
 	IsDeleted        bool
 	CreatedBy        string
@@ -110,6 +142,7 @@ type RoomMemberReq struct {
 	RoomCode         string
 	IsBot            bool
 	RoomID           uuid.UUID
+	Role             MemberRole // PLAYER when empty
 }
 
 type UserIDReq struct {
@@ -118,18 +151,61 @@ type UserIDReq struct {
 
 /******** Leader board ***************/
 type Leaderboard struct {
-	ID       uuid.UUID // leaderboard id
-	RoomCode string
-	UserID   uuid.UUID
-	Score    float64
+	ID         uuid.UUID // leaderboard id
+	RoomCode   string
+	UserID     uuid.UUID
+	Score      float64
+	BestStreak int
 }
 type EditLeaderBoardReq struct {
-	UserID   uuid.UUID
-	RoomCode string
-	Score    float64
+	UserID     uuid.UUID
+	RoomCode   string
+	Score      float64
+	BestStreak int // most right answers in a row in the game
 }
 
 type RoomCodeReq struct {
 	UserID   uuid.UUID
 	RoomCode string
 }
+
+// MatchCategories are the topic categories of the matchmaking queue, the category is the topic of the matched room
+var MatchCategories = []string{"General Knowledge", "Science", "History", "Geography", "Sports", "Movies", "Music", "Technology"}
+
+// MatchReq puts a player in the matchmaking queue of a category and difficulty
+type MatchReq struct {
+	UserID     uuid.UUID `validate:"required"`
+	Username   string    `validate:"required"`
+	Category   string    `validate:"required"`
+	Difficulty string    `validate:"required,oneof=easy medium hard"`
+}
+
+// MatchTicketStatus is where the ticket of a player in the matchmaking queue is at
+type MatchTicketStatus string
+
+const (
+	MatchQueued  MatchTicketStatus = "QUEUED"
+	MatchForming MatchTicketStatus = "FORMING" // his room is being set up
+	MatchMatched MatchTicketStatus = "MATCHED" // his room is ready till he picks it up
+	MatchFailed  MatchTicketStatus = "FAILED"  // his room could not be set up till he is told
+)
+
+// MatchStatus is where a player in the matchmaking queue is at
+type MatchStatus struct {
+	Queued     bool
+	Category   string
+	Difficulty string
+	Waiting    int       // players in his queue, himself included
+	QueuedAt   time.Time // when he joined the queue
+	Forming    bool      // his room is being set up
+	RoomCode   string    // filled once his room is ready
+	Failed     bool      // setting up his room failed, he is out of the queue
+}
+
+// RoomInvite is a short code that leads to a room till it expires or is revoked
+type RoomInvite struct {
+	InviteCode string
+	RoomCode   string
+	ExpiresOn  time.Time
+	CreatedBy  string
+}
@@ -22,22 +22,157 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+// Service is the room service, it runs its queries on the shared connection pool
+// and uses the quiz and user services for the questions and members of a room
+type Service struct {
+	db   dbpkg.Pool
+	q    *dbal.Queries
+	quiz *quiz.Service
+	user *user.Service
+}
+
+func NewService(db dbpkg.Pool, quizService *quiz.Service, userService *user.Service) *Service {
+	return &Service{
+		db:   db,
+		q:    dbal.New(db),
+		quiz: quizService,
+		user: userService,
+	}
+}
+
 // SetupGame is a function that sets up a game from room creation,member addition,question generation
-// TODO: make everything in transaction
-func SetupGame(ctx context.Context, req model.RoomReq, botIDs []model.UserIDReq, questReq *quizmodel.QuizReq) (string, error) {
+// the room, its members and their leaderboard rows are created in one transaction so a failure
+// doesnt leave a half built room behind
+func (s *Service) SetupGame(ctx context.Context, req model.RoomReq, botIDs []model.UserIDReq, questReq *quizmodel.QuizReq) (string, error) {
+	return s.setupGame(ctx, req, nil, botIDs, questReq)
+}
+
+// setupGame sets up the game with the players who are put in the room along with its owner, the way the
+// players of a quick match are
+func (s *Service) setupGame(ctx context.Context, req model.RoomReq, playerIDs []model.UserIDReq, botIDs []model.UserIDReq, questReq *quizmodel.QuizReq) (string, error) {
 	l := logs.GetLoggerctx(ctx)
-	// Create a room
-	roomDetails, err := CreateRoom(ctx, req)
+
+	var roomDetails *model.Room
+	var questionReq *quizmodel.QuestionReq
+	var job *quizmodel.GenerationJob
+	err := s.withTx(ctx, func(qtx *dbal.Queries) (err error) {
+		// Create a room
+		roomDetails, err = createRoom(ctx, qtx, req)
+		if err != nil {
+			l.Sugar().Error("Could not create room", err)
+			return err
+		}
+
+		// Add room members
+		err = addPlayers(ctx, qtx, roomDetails, playerIDs)
+		if err != nil {
+			return err
+		}
+		err = addBots(ctx, qtx, roomDetails, botIDs)
+		if err != nil {
+			return err
+		}
+
+		// the questions are generated in the background, the job lets the lobby follow its progress
+		questionReq = &quizmodel.QuestionReq{
+			RoomCode:      roomDetails.RoomCode,
+			Topic:         questReq.Topic,
+			QuestionCount: questReq.Count,
+			QuestionData:  []*quizmodel.QuestionData{},
+			CreatedBy:     roomDetails.CreatedBy,
+			TimeLimit:     req.TimeLimit,
+			Difficulty:    questReq.Difficulty,
+			QuestionTypes: questReq.QuestionTypes,
+		}
+		job, err = quiz.CreateGenerationJob(ctx, qtx, questionReq)
+		return err
+	})
 	if err != nil {
-		l.Sugar().Error("Could not create room", err)
 		return "", err
 	}
 
-	// Add room members
+	// this can be in a go routine coz it calls a external api so we dont have to wait
+	// create questions on that topic which llm will generate
+	go s.generateQuestions(ctx, job, questionReq)
+
+	return roomDetails.RoomCode, nil
+}
+
+// SetupGameFromBank sets up a game with the questions the user picked from his question bank.
+// no question generation is needed so the room is ready to be played as soon as it is created
+func (s *Service) SetupGameFromBank(ctx context.Context, req model.RoomReq, botIDs []model.UserIDReq, topic string, questionIDs []uuid.UUID) (string, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	questData, difficulty, err := s.quiz.BankQuestionData(ctx, req.UserID, questionIDs)
+	if err != nil {
+		l.Sugar().Error("Could not get the selected bank questions", err)
+		return "", err
+	}
+
+	var roomDetails *model.Room
+	err = s.withTx(ctx, func(qtx *dbal.Queries) (err error) {
+		roomDetails, err = createRoom(ctx, qtx, req)
+		if err != nil {
+			l.Sugar().Error("Could not create room", err)
+			return err
+		}
+
+		err = addBots(ctx, qtx, roomDetails, botIDs)
+		if err != nil {
+			return err
+		}
+
+		return quiz.InsertQuestion(ctx, qtx, quizmodel.QuestionReq{
+			RoomCode:      roomDetails.RoomCode,
+			Topic:         topic,
+			QuestionCount: len(questData),
+			QuestionData:  questData,
+			CreatedBy:     roomDetails.CreatedBy,
+			TimeLimit:     req.TimeLimit,
+			Difficulty:    difficulty,
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return roomDetails.RoomCode, nil
+}
+
+// addPlayers adds the players as members of the room with their leaderboard rows, the way they would
+// join with the room code
+func addPlayers(ctx context.Context, qtx *dbal.Queries, roomDetails *model.Room, playerIDs []model.UserIDReq) error {
+	l := logs.GetLoggerctx(ctx)
+
+	for _, player := range playerIDs {
+		_, err := joinRoom(ctx, qtx, model.RoomMemberReq{
+			UserID:   player.UserID,
+			RoomID:   roomDetails.ID,
+			RoomCode: roomDetails.RoomCode,
+		})
+		if err != nil {
+			l.Sugar().Error("Could not join room", err)
+			return err
+		}
+
+		err = createLeaderBoard(ctx, qtx, &model.EditLeaderBoardReq{
+			UserID:   player.UserID,
+			RoomCode: roomDetails.RoomCode,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addBots adds the bots as ready members of the room with their leaderboard rows
+func addBots(ctx context.Context, qtx *dbal.Queries, roomDetails *model.Room, botIDs []model.UserIDReq) error {
+	l := logs.GetLoggerctx(ctx)
+
 	for _, membersID := range botIDs {
 		// TODO: if multiplayer if the bot has already joined the room then dont add it again
-		// get user details
-		_, err = JoinRoom(ctx, model.RoomMemberReq{
+		_, err := joinRoom(ctx, qtx, model.RoomMemberReq{
 			UserID:           membersID.UserID,
 			RoomID:           roomDetails.ID,
 			RoomMemberStatus: model.ReadyQuiz,
@@ -45,35 +180,109 @@ func SetupGame(ctx context.Context, req model.RoomReq, botIDs []model.UserIDReq,
 		})
 		if err != nil {
 			l.Sugar().Error("Could not join room", err)
-			return "", err
+			return err
 		}
 
 		// creating default users leaderboard value to 0
-		err = CreateLeaderBoard(ctx, &model.EditLeaderBoardReq{
+		err = createLeaderBoard(ctx, qtx, &model.EditLeaderBoardReq{
 			UserID:   membersID.UserID,
 			RoomCode: roomDetails.RoomCode,
 			Score:    0,
 		})
 		if err != nil {
-			return "", err
+			return err
 		}
 	}
-	// this can be in a go routine coz it calls a external api so we dont have to wait
-	// create questions on that topic which llm will generate
-	go quiz.SetupQuizQuestions(ctx, &quizmodel.QuestionReq{
-		RoomCode:      roomDetails.RoomCode,
-		Topic:         questReq.Topic,
-		QuestionCount: questReq.Count,
-		QuestionData:  []*quizmodel.QuestionData{},
-		CreatedBy:     roomDetails.CreatedBy,
-		TimeLimit:     req.TimeLimit,
-	})
+	return nil
+}
 
-	return roomDetails.RoomCode, nil
+// generateQuestions runs the question generation job of a room. when it fails the room is marked as
+// failed so that the players are told about it instead of waiting on a room without questions
+func (s *Service) generateQuestions(ctx context.Context, job *quizmodel.GenerationJob, req *quizmodel.QuestionReq) {
+	l := logs.GetLoggerctx(ctx)
+
+	err := s.quiz.RunGenerationJob(ctx, job, req)
+	if err == nil {
+		return
+	}
+
+	err = s.UpdateRoomStatus(ctx, req.RoomCode, model.Failed, req.CreatedBy)
+	if err != nil {
+		l.Sugar().Error("Could not mark the room as failed", err)
+	}
+}
+
+// FailInstanceGenerationJobs fails the generation jobs this instance was running before it went down
+// along with their rooms, so their players dont have to wait for the jobs to go stale
+func (s *Service) FailInstanceGenerationJobs(ctx context.Context) {
+	jobs, err := s.quiz.FailInstanceGenerationJobs(ctx)
+	if err != nil {
+		return
+	}
+	s.failJobRooms(ctx, jobs)
+}
+
+// FailStaleGenerationJobs fails the generation jobs a crash left behind along with their rooms, the same
+// way a job which failed on its own fails its room
+func (s *Service) FailStaleGenerationJobs(ctx context.Context) {
+	jobs, err := s.quiz.FailStaleGenerationJobs(ctx)
+	if err != nil {
+		return
+	}
+	s.failJobRooms(ctx, jobs)
+}
+
+// failJobRooms marks the rooms of the failed jobs as failed
+func (s *Service) failJobRooms(ctx context.Context, jobs []*quizmodel.GenerationJob) {
+	l := logs.GetLoggerctx(ctx)
+
+	for _, job := range jobs {
+		err := s.UpdateRoomStatus(ctx, job.RoomCode, model.Failed, job.CreatedBy)
+		if err != nil {
+			l.Sugar().Error("Could not mark the room as failed", err)
+		}
+	}
+}
+
+// withTx runs fn in a transaction which is rolled back if fn returns an error
+func (s *Service) withTx(ctx context.Context, fn func(qtx *dbal.Queries) error) error {
+	l := logs.GetLoggerctx(ctx)
+
+	tx, err := s.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		l.Sugar().Error("Could not begin transaction", err)
+		return err
+	}
+
+	err = fn(s.q.WithTx(tx))
+	if err != nil {
+		tx.Rollback(ctx)
+		l.Sugar().Error("Transaction rolled back due to error", err)
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		l.Sugar().Error("Could not commit transaction", err)
+		return err
+	}
+	return nil
 }
 
 // CreateRoom is a function that creates a room
-func CreateRoom(ctx context.Context, req model.RoomReq) (roomDetails *model.Room, err error) {
+func (s *Service) CreateRoom(ctx context.Context, req model.RoomReq) (roomDetails *model.Room, err error) {
+	err = s.withTx(ctx, func(qtx *dbal.Queries) (err error) {
+		roomDetails, err = createRoom(ctx, qtx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return roomDetails, nil
+}
+
+// createRoom creates the room along with the owner as its member and his leaderboard row
+func createRoom(ctx context.Context, qtx *dbal.Queries, req model.RoomReq) (roomDetails *model.Room, err error) {
 	l := logs.GetLoggerctx(ctx)
 	roomCode := uuid.New() // TODO: FIGURE OUT how to make it a string rather than uuid
 	var roomStatus model.RoomStatus
@@ -83,6 +292,19 @@ func CreateRoom(ctx context.Context, req model.RoomReq) (roomDetails *model.Room
 		roomStatus = model.Waiting
 	}
 
+	// the scoring rule is recorded in the room meta so the scores can be explained after the game
+	roomMeta, err := quiz.ScoringRoomMeta(quizmodel.ScoringRuleName(req.ScoringRule))
+	if err != nil {
+		l.Sugar().Error("Could not prepare the room meta", err)
+		return nil, err
+	}
+	// so is the number of teams of a room in team mode
+	roomMeta, err = quiz.TeamRoomMeta(roomMeta, req.Teams)
+	if err != nil {
+		l.Sugar().Error("Could not prepare the room meta", err)
+		return nil, err
+	}
+
 	roomID := uuid.New() // primary key of room
 	params := dbal.CreateRoomParams{
 		RoomCode: roomCode.String(),
@@ -91,7 +313,7 @@ func CreateRoom(ctx context.Context, req model.RoomReq) (roomDetails *model.Room
 			Valid: true,
 		},
 		RoomChat:  []byte("[{}]"),
-		RoomMeta:  []byte("[{}]"),
+		RoomMeta:  roomMeta,
 		RoomLock:  false,
 		IsActive:  true,
 		IsDeleted: false,
@@ -107,34 +329,10 @@ func CreateRoom(ctx context.Context, req model.RoomReq) (roomDetails *model.Room
 		},
 		GameType:   string(req.GameType),
 		RoomStatus: string(roomStatus),
+		IsPublic:   req.IsPublic,
 	}
 
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-
-	// Start Transaction
-	tx, err := dbConn.Db.BeginTx(ctx, pgx.TxOptions{})
-	if err != nil {
-		l.Sugar().Error("Could not begin transaction", err)
-		return nil, err
-	}
-
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx) // Rollback if any error occurs
-			l.Sugar().Error("Transaction rolled back due to error", err)
-		} else {
-			tx.Commit(ctx) // Commit only if there is no error
-		}
-	}()
-
-	// Use the transaction for DB operations
-	dBal := dbal.New(tx)
-
-	room, err := dBal.CreateRoom(ctx, params)
+	room, err := qtx.CreateRoom(ctx, params)
 	if err != nil {
 		l.Sugar().Error("Could not create room in database", err)
 		return nil, err
@@ -159,13 +357,39 @@ func CreateRoom(ctx context.Context, req model.RoomReq) (roomDetails *model.Room
 			Bytes: roomID,
 			Valid: true,
 		},
+		Role: string(model.Player),
 	}
-	_, err = dBal.CreateRoomMember(ctx, roomMemberParams)
+	if req.HostMode {
+		// the host does not play so the game does not wait for him to get ready
+		roomMemberParams.Role = string(model.Host)
+		roomMemberParams.RoomMemberStatus = string(model.ReadyQuiz)
+	}
+	_, err = qtx.CreateRoomMember(ctx, roomMemberParams)
 	if err != nil {
 		l.Sugar().Error("Could not create new room member in database", err)
 		return nil, err
 	}
 
+	roomDetails = &model.Room{
+		ID:         room.ID.Bytes,
+		RoomName:   room.RoomName.String,
+		UserMeta:   string(room.RoomMeta),
+		IsActive:   room.IsActive,
+		IsDeleted:  room.IsDeleted,
+		CreatedBy:  req.UserID.String(),
+		CreatedOn:  room.CreatedOn.Time,
+		UpdatedOn:  room.UpdatedOn.Time,
+		GameType:   model.GT(room.GameType),
+		RoomMeta:   string(room.RoomMeta),
+		RoomChat:   string(room.RoomChat),
+		Roomstatus: model.RoomStatus(room.RoomStatus),
+		RoomCode:   room.RoomCode,
+	}
+	// the host has no score so he is not on the leaderboard
+	if req.HostMode {
+		return roomDetails, nil
+	}
+
 	lbParams := dbal.CreatLeaderBoardParams{
 		ID: pgtype.UUID{
 			Bytes: uuid.New(),
@@ -180,48 +404,24 @@ func CreateRoom(ctx context.Context, req model.RoomReq) (roomDetails *model.Room
 		CreatedBy: req.UserID.String(),
 		UpdatedBy: req.UserID.String(),
 	}
-	err = dBal.CreatLeaderBoard(ctx, lbParams)
+	err = qtx.CreatLeaderBoard(ctx, lbParams)
 	if err != nil {
 		l.Sugar().Error("Could not create new leaderboard in database", err)
 		return nil, err
 	}
 
-	roomDetails = &model.Room{
-		ID:         room.ID.Bytes,
-		RoomName:   room.RoomName.String,
-		UserMeta:   string(room.RoomMeta),
-		IsActive:   room.IsActive,
-		IsDeleted:  room.IsDeleted,
-		CreatedBy:  req.UserID.String(),
-		CreatedOn:  room.CreatedOn.Time,
-		UpdatedOn:  room.UpdatedOn.Time,
-		GameType:   model.GT(room.GameType),
-		RoomMeta:   string(room.RoomMeta),
-		RoomChat:   string(room.RoomChat),
-		Roomstatus: model.RoomStatus(room.RoomStatus),
-		RoomCode:   room.RoomCode,
-	}
 	return roomDetails, nil
 }
 
-func UpdateRoom(ctx context.Context, req model.EditRoomReq) (err error) {
+func (s *Service) UpdateRoom(ctx context.Context, req model.EditRoomReq) (err error) {
 	l := logs.GetLoggerctx(ctx)
 
-	room, err := GetRoomByID(ctx, req.ID)
-	if err != nil {
-		return err
-	}
-
-	dbConn, err := dbpkg.InitDB()
+	room, err := s.GetRoomByID(ctx, req.ID)
 	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
 		return err
-
 	}
-	defer dbConn.Db.Close()
 
-	dBal := dbal.New(dbConn.Db)
-	err = dBal.UpdateRoomByID(ctx, dbal.UpdateRoomByIDParams{
+	err = s.q.UpdateRoomByID(ctx, dbal.UpdateRoomByIDParams{
 		ID: pgtype.UUID{
 			Bytes: room.ID,
 			Valid: true,
@@ -246,23 +446,62 @@ func UpdateRoom(ctx context.Context, req model.EditRoomReq) (err error) {
 	return nil
 }
 
-func UpdateRoomMetaAndStatus(ctx context.Context, req model.RoomMetaReq) (err error) {
-	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
+// endGameMetaEvent is the room meta entry the end of the game is recorded in
+const endGameMetaEvent = "end_game"
+
+// roomMetaEntry is an entry of the room meta, it is a list of events like the ones sent over the websocket
+type roomMetaEntry struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// mergeRoomMeta adds the entries to the room meta, an entry replaces the earlier one of its type
+func mergeRoomMeta(roomMeta []byte, added []byte) ([]byte, error) {
+	newEntries := []roomMetaEntry{}
+	err := json.Unmarshal(added, &newEntries)
 	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
+		return nil, err
+	}
+	entries := []roomMetaEntry{}
+	if len(roomMeta) > 0 {
+		err = json.Unmarshal(roomMeta, &entries)
+		if err != nil {
+			return nil, err
+		}
 	}
-	defer dbConn.Db.Close()
-	dBal := dbal.New(dbConn.Db)
-	room, err := dBal.GetRoomByRoomCode(ctx, req.RoomCode)
+
+	replaced := map[string]bool{}
+	for _, entry := range newEntries {
+		replaced[entry.Type] = true
+	}
+	merged := []roomMetaEntry{}
+	for _, entry := range entries {
+		// rooms of the old versions were created with an empty entry
+		if entry.Type != "" && !replaced[entry.Type] {
+			merged = append(merged, entry)
+		}
+	}
+	return json.Marshal(append(merged, newEntries...))
+}
+
+// UpdateRoomMetaAndStatus adds the events to the room meta and ends the room, the settings the room was
+// created with (scoring rule, teams) stay in the meta
+func (s *Service) UpdateRoomMetaAndStatus(ctx context.Context, req model.RoomMetaReq) (err error) {
+	l := logs.GetLoggerctx(ctx)
+	room, err := s.q.GetRoomByRoomCode(ctx, req.RoomCode)
 	if err != nil || len(room) == 0 {
 		l.Sugar().Error("Could not get room by room code in database", err)
 		return err
 	}
 
-	err = dBal.UpdateRoomMetaAndStatusByRoomCode(ctx, dbal.UpdateRoomMetaAndStatusByRoomCodeParams{
-		RoomMeta:   []byte(req.RoomMeta),
+	roomMeta, err := mergeRoomMeta(room[0].RoomMeta, []byte(req.RoomMeta))
+	if err != nil {
+		l.Sugar().Error("Could not merge room meta", err)
+		return err
+	}
+
+	err = s.q.UpdateRoomMetaAndStatusByRoomCode(ctx, dbal.UpdateRoomMetaAndStatusByRoomCodeParams{
+		RoomMeta:   roomMeta,
 		RoomCode:   req.RoomCode,
 		UpdatedBy:  room[0].UpdatedBy,
 		RoomStatus: string(model.Ended),
@@ -274,18 +513,24 @@ func UpdateRoomMetaAndStatus(ctx context.Context, req model.RoomMetaReq) (err er
 	return nil
 }
 
-func GetRoomByID(ctx context.Context, roomID uuid.UUID) (roomDetails *model.Room, err error) {
+// UpdateRoomStatus moves the room to the given status
+func (s *Service) UpdateRoomStatus(ctx context.Context, roomCode string, roomStatus model.RoomStatus, updatedBy string) error {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
+	err := s.q.UpdateRoomStatusByRoomCode(ctx, dbal.UpdateRoomStatusByRoomCodeParams{
+		RoomCode:   roomCode,
+		RoomStatus: string(roomStatus),
+		UpdatedBy:  updatedBy,
+	})
 	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-
+		l.Sugar().Error("Could not update room status by room code in database", err)
+		return err
 	}
-	defer dbConn.Db.Close()
+	return nil
+}
 
-	dBal := dbal.New(dbConn.Db)
-	dbrecord, err := dBal.GetRoomByID(ctx, pgtype.UUID{
+func (s *Service) GetRoomByID(ctx context.Context, roomID uuid.UUID) (roomDetails *model.Room, err error) {
+	l := logs.GetLoggerctx(ctx)
+	dbrecord, err := s.q.GetRoomByID(ctx, pgtype.UUID{
 		Bytes: roomID,
 		Valid: true,
 	})
@@ -309,22 +554,15 @@ func GetRoomByID(ctx context.Context, roomID uuid.UUID) (roomDetails *model.Room
 		CreatedBy:  dbrecord[0].CreatedBy,
 		CreatedOn:  dbrecord[0].CreatedOn.Time,
 		UpdatedOn:  dbrecord[0].UpdatedOn.Time,
+		RoomLock:   dbrecord[0].RoomLock,
+		IsPublic:   dbrecord[0].IsPublic,
 	}
 	return roomDetails, nil
 }
 
-func GetRoomByRoomCode(ctx context.Context, roomCode string) (roomDetails *model.Room, err error) {
+func (s *Service) GetRoomByRoomCode(ctx context.Context, roomCode string) (roomDetails *model.Room, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	dbrecord, err := dBal.GetRoomByRoomCode(ctx, roomCode)
+	dbrecord, err := s.q.GetRoomByRoomCode(ctx, roomCode)
 	if err == nil && dbrecord == nil {
 		return nil, nil
 	}
@@ -346,21 +584,15 @@ func GetRoomByRoomCode(ctx context.Context, roomCode string) (roomDetails *model
 		CreatedBy:  dbrecord[0].CreatedBy,
 		CreatedOn:  dbrecord[0].CreatedOn.Time,
 		UpdatedOn:  dbrecord[0].UpdatedOn.Time,
+		RoomLock:   dbrecord[0].RoomLock,
+		IsPublic:   dbrecord[0].IsPublic,
 	}
 	return roomDetails, nil
 }
 
-func ListRoom(ctx context.Context, req model.UserIDReq) (roomDetails []*model.Room, err error) {
+func (s *Service) ListRoom(ctx context.Context, req model.UserIDReq) (roomDetails []*model.Room, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	rooms, err := dBal.ListRoomsByUserID(ctx, pgtype.UUID{
+	rooms, err := s.q.ListRoomsByUserID(ctx, pgtype.UUID{
 		Bytes: req.UserID,
 		Valid: true,
 	})
@@ -386,39 +618,28 @@ func ListRoom(ctx context.Context, req model.UserIDReq) (roomDetails []*model.Ro
 			UpdatedBy:     room.UpdatedBy,
 			QuestionTopic: room.Topic.String,
 			TimeLimit:     int(room.TimeLimit),
+			Difficulty:    room.Difficulty,
 		})
 	}
 	return roomDetails, nil
 }
 
-func JoinRoom(ctx context.Context, req model.RoomMemberReq) (roomDetails *model.Room, err error) {
-	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-	defer dbConn.Db.Close()
-
-	// Start Transaction
-	tx, err := dbConn.Db.BeginTx(ctx, pgx.TxOptions{})
+func (s *Service) JoinRoom(ctx context.Context, req model.RoomMemberReq) (roomDetails *model.Room, err error) {
+	err = s.withTx(ctx, func(qtx *dbal.Queries) (err error) {
+		roomDetails, err = joinRoom(ctx, qtx, req)
+		return err
+	})
 	if err != nil {
-		l.Sugar().Error("Could not begin transaction", err)
 		return nil, err
 	}
+	return roomDetails, nil
+}
 
-	defer func() {
-		if err != nil {
-			tx.Rollback(ctx) // Rollback if any error occurs
-			l.Sugar().Error("Transaction rolled back due to error", err)
-		} else {
-			tx.Commit(ctx) // Commit only if there is no error
-		}
-	}()
-
-	dBal := dbal.New(tx)
+// joinRoom adds the user as a member of the room
+func joinRoom(ctx context.Context, qtx *dbal.Queries, req model.RoomMemberReq) (roomDetails *model.Room, err error) {
+	l := logs.GetLoggerctx(ctx)
 
-	room, err := dBal.GetRoomByID(ctx, pgtype.UUID{
+	room, err := qtx.GetRoomByID(ctx, pgtype.UUID{
 		Bytes: req.RoomID,
 		Valid: true,
 	})
@@ -427,7 +648,7 @@ func JoinRoom(ctx context.Context, req model.RoomMemberReq) (roomDetails *model.
 		return nil, err
 	}
 
-	existingMember, err := dBal.GetRoomMemberByRoomCodeAndUserID(ctx, dbal.GetRoomMemberByRoomCodeAndUserIDParams{
+	existingMember, err := qtx.GetRoomMemberByRoomCodeAndUserID(ctx, dbal.GetRoomMemberByRoomCodeAndUserIDParams{
 		RoomCode: req.RoomCode,
 		UserID: pgtype.UUID{
 			Bytes: req.UserID,
@@ -442,7 +663,11 @@ func JoinRoom(ctx context.Context, req model.RoomMemberReq) (roomDetails *model.
 	if len(existingMember) > 0 {
 		return nil, errors.New("user already joined the room")
 	}
-	_, err = dBal.CreateRoomMember(ctx, dbal.CreateRoomMemberParams{
+	role := req.Role
+	if role == "" {
+		role = model.Player
+	}
+	_, err = qtx.CreateRoomMember(ctx, dbal.CreateRoomMemberParams{
 		ID:               pgtype.UUID{Bytes: uuid.New(), Valid: true},
 		RoomCode:         room[0].RoomCode,
 		UserID:           pgtype.UUID{Bytes: req.UserID, Valid: true},
@@ -456,6 +681,7 @@ func JoinRoom(ctx context.Context, req model.RoomMemberReq) (roomDetails *model.
 			Bytes: room[0].ID.Bytes,
 			Valid: true,
 		},
+		Role: string(role),
 	})
 
 	if err != nil {
@@ -483,58 +709,60 @@ func JoinRoom(ctx context.Context, req model.RoomMemberReq) (roomDetails *model.
 	return roomDetails, nil
 }
 
-func ListGameAnalytics(ctx context.Context, req model.RoomCodeReq) (meta *quizmodel.EndGamePayload, answers []*quizmodel.Answer, err error) {
+func (s *Service) ListGameAnalytics(ctx context.Context, req model.RoomCodeReq) (meta *quizmodel.EndGamePayload, answers []*quizmodel.Answer, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, nil, err
-	}
-	defer dbConn.Db.Close()
-	dBal := dbal.New(dbConn.Db)
-	room, err := dBal.GetRoomByRoomCode(ctx, req.RoomCode)
+	room, err := s.q.GetRoomByRoomCode(ctx, req.RoomCode)
 	if err != nil {
 		l.Sugar().Error("Could not get room by room code in database", err)
 		return nil, nil, err
 	}
 
-	type event struct {
-		Type    string          `json:"type"`
-		Payload json.RawMessage `json:"payload"`
-	}
-	e := []*event{}
+	e := []*roomMetaEntry{}
 	err = json.Unmarshal(room[0].RoomMeta, &e)
 	if err != nil {
 		l.Sugar().Error("Could not unmarshal room event in database", err)
 		return nil, nil, err
 	}
-	if e[0].Type != "end_game" {
+	// the end of the game comes after the settings the room was created with
+	var endGame *roomMetaEntry
+	for _, entry := range e {
+		if entry.Type == endGameMetaEvent {
+			endGame = entry
+		}
+	}
+	if endGame == nil {
 		l.Sugar().Error("Game is not ended yet")
 		return nil, nil, errors.New("game is not ended yet")
 	}
-	err = json.Unmarshal(e[0].Payload, &meta)
+	err = json.Unmarshal(endGame.Payload, &meta)
 	if err != nil {
 		l.Sugar().Error("Could not unmarshal room event payload in database", err)
 		return nil, nil, err
 	}
 
-	sort.Slice(meta.Participants, func(i, j int) bool {
-		return meta.Participants[i].Score > meta.Participants[j].Score
-	})
-	pos := 1
-	for i := range meta.Participants {
-		meta.Participants[i].Position = pos
-		pos++
+	// the players of an elimination game are ranked by how long they lasted
+	if len(meta.Eliminations) > 0 {
+		quiz.RankByElimination(meta.Participants)
+	} else {
+		sort.Slice(meta.Participants, func(i, j int) bool {
+			return meta.Participants[i].Score > meta.Participants[j].Score
+		})
+		pos := 1
+		for i := range meta.Participants {
+			meta.Participants[i].Position = pos
+			pos++
+		}
 	}
 
-	answers, err = quiz.ListAnswersByRoomCode(ctx, req.RoomCode)
+	answers, err = s.quiz.ListAnswersByRoomCode(ctx, req.RoomCode)
 	if err != nil {
 		l.Sugar().Error("Could not get answers by room code in database", err)
 	}
-	questionData, err := quiz.ListQuestionsByRoomCode(ctx, req.RoomCode)
+	questionData, err := s.quiz.ListQuestionsByRoomCode(ctx, req.RoomCode)
 	if err != nil {
 		return nil, nil, err
 	}
+	meta.Difficulty = questionData.Difficulty
 	questionDataMap := make(map[uuid.UUID]*quizmodel.QuestionData)
 	for _, data := range questionData.QuestionData {
 		questionDataMap[data.ID] = data
@@ -553,7 +781,12 @@ func ListGameAnalytics(ctx context.Context, req model.RoomCodeReq) (meta *quizmo
 			return nil, nil, err
 		}
 		answers[i].QuestionData = questionData
-		userDetails, err := user.GetUserDetailsbyID(ctx, answer.UserID)
+		if !questionData.SingleAnswer() {
+			kind := quiz.GetQuestionKind(questionData.Type)
+			answers[i].GivenAnswer = kind.DescribeAnswer(questionData, int(answer.AnswerOption), answer.AnswerData)
+			answers[i].RightAnswer = kind.RightAnswer(questionData)
+		}
+		userDetails, err := s.user.GetUserDetailsbyID(ctx, answer.UserID)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -568,17 +801,9 @@ func ListGameAnalytics(ctx context.Context, req model.RoomCodeReq) (meta *quizmo
 	return meta, answers, nil
 }
 
-func ListRoomMembersByRoomCode(ctx context.Context, req model.RoomCodeReq) (roomMembers []*model.RoomMember, err error) {
+func (s *Service) ListRoomMembersByRoomCode(ctx context.Context, req model.RoomCodeReq) (roomMembers []*model.RoomMember, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	dbRecord, err := dBal.ListRoomMembersByRoomCode(ctx, req.RoomCode)
+	dbRecord, err := s.q.ListRoomMembersByRoomCode(ctx, req.RoomCode)
 	if err != nil {
 		l.Sugar().Error("Could not list room members by room ID in database", err)
 		return nil, err
@@ -589,6 +814,7 @@ func ListRoomMembersByRoomCode(ctx context.Context, req model.RoomCodeReq) (room
 			UserID:           member.UserID.Bytes,
 			IsBot:            member.IsBot,
 			RoomMemberStatus: model.RoomMemberStatus(member.RoomMemberStatus),
+			Role:             model.MemberRole(member.Role),
 			IsActive:         member.IsActive,
 			IsDeleted:        member.IsDeleted,
 			CreatedBy:        member.CreatedBy,
@@ -613,17 +839,9 @@ func ListRoomMembersByRoomCode(ctx context.Context, req model.RoomCodeReq) (room
 	return roomMembers, nil
 }
 
-func GetRoomMemberByRoomCodeAndUserID(ctx context.Context, req model.RoomMemberReq) (roomMember *model.RoomMember, err error) {
+func (s *Service) GetRoomMemberByRoomCodeAndUserID(ctx context.Context, req model.RoomMemberReq) (roomMember *model.RoomMember, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	dbRecord, err := dBal.GetRoomMemberByRoomCodeAndUserID(ctx, dbal.GetRoomMemberByRoomCodeAndUserIDParams{
+	dbRecord, err := s.q.GetRoomMemberByRoomCodeAndUserID(ctx, dbal.GetRoomMemberByRoomCodeAndUserIDParams{
 		RoomCode: req.RoomCode,
 		UserID: pgtype.UUID{
 			Bytes: req.UserID,
@@ -643,6 +861,7 @@ func GetRoomMemberByRoomCodeAndUserID(ctx context.Context, req model.RoomMemberR
 		UserID:           dbRecord[0].UserID.Bytes,
 		IsBot:            dbRecord[0].IsBot,
 		RoomMemberStatus: model.RoomMemberStatus(dbRecord[0].RoomMemberStatus),
+		Role:             model.MemberRole(dbRecord[0].Role),
 		IsActive:         dbRecord[0].IsActive,
 		IsDeleted:        dbRecord[0].IsDeleted,
 		CreatedBy:        dbRecord[0].CreatedBy,
@@ -668,24 +887,16 @@ func GetRoomMemberByRoomCodeAndUserID(ctx context.Context, req model.RoomMemberR
 
 // JoinRoomWithRoomCode is a function that joins a member into a room with a room code
 // TODO: (V2) implement ability to lock the room so that someone cant join
-func JoinRoomWithRoomCode(ctx context.Context, req model.RoomMemberReq) (roomDetails *model.Room, err error) {
+func (s *Service) JoinRoomWithRoomCode(ctx context.Context, req model.RoomMemberReq) (roomDetails *model.Room, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
 
-	dbRoom, err := dBal.GetRoomByRoomCode(ctx, req.RoomCode)
+	dbRoom, err := s.q.GetRoomByRoomCode(ctx, req.RoomCode)
 	if err != nil {
 		l.Sugar().Error("Could not get room by room code in database", err)
 		return nil, err
 	}
 
-	roomDetails, err = JoinRoom(ctx, model.RoomMemberReq{
+	roomDetails, err = s.JoinRoom(ctx, model.RoomMemberReq{
 		UserID: req.UserID,
 		RoomID: dbRoom[0].ID.Bytes,
 	})
@@ -697,24 +908,16 @@ func JoinRoomWithRoomCode(ctx context.Context, req model.RoomMemberReq) (roomDet
 	return roomDetails, nil
 }
 
-func UpdateRoomMemberStatusByRoomCodeAndUserID(ctx context.Context, roomCodeReq *model.RoomCodeReq, roomStatus model.RoomMemberStatus) (err error) {
+func (s *Service) UpdateRoomMemberStatusByRoomCodeAndUserID(ctx context.Context, roomCodeReq *model.RoomCodeReq, roomStatus model.RoomMemberStatus) (err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
 
-	roomDetails, err := dBal.GetRoomByRoomCode(ctx, roomCodeReq.RoomCode)
+	roomDetails, err := s.q.GetRoomByRoomCode(ctx, roomCodeReq.RoomCode)
 	if err != nil || len(roomDetails) == 0 {
 		l.Sugar().Error("Could not get room member by ID in database", err)
 		return err
 	}
 
-	err = dBal.UpdateRoomMemberByRoomCodeAndUserID(ctx, dbal.UpdateRoomMemberByRoomCodeAndUserIDParams{
+	err = s.q.UpdateRoomMemberByRoomCodeAndUserID(ctx, dbal.UpdateRoomMemberByRoomCodeAndUserIDParams{
 		RoomMemberStatus: string(roomStatus),
 		IsActive:         true,
 		UpdatedBy:        util.GetUserInfoFromctx(ctx).ID.String(),
@@ -729,24 +932,16 @@ func UpdateRoomMemberStatusByRoomCodeAndUserID(ctx context.Context, roomCodeReq
 	return nil
 }
 
-func UpdateRoomMemberStatusByID(ctx context.Context, req model.RoomMemberReq) (err error) {
+func (s *Service) UpdateRoomMemberStatusByID(ctx context.Context, req model.RoomMemberReq) (err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
-	}
-	defer dbConn.Db.Close()
 
-	dBal := dbal.New(dbConn.Db)
-
-	_, err = dBal.GetRoomMemberByID(ctx, pgtype.UUID{Bytes: req.ID, Valid: true})
+	_, err = s.q.GetRoomMemberByID(ctx, pgtype.UUID{Bytes: req.ID, Valid: true})
 	if err != nil {
 		l.Sugar().Error("Could not get room member by ID in database", err)
 		return err
 	}
 
-	err = dBal.UpdateRoomMemberByID(ctx, dbal.UpdateRoomMemberByIDParams{
+	err = s.q.UpdateRoomMemberByID(ctx, dbal.UpdateRoomMemberByIDParams{
 		ID:               pgtype.UUID{Bytes: req.ID, Valid: true},
 		RoomMemberStatus: string(req.RoomMemberStatus),
 		IsActive:         true,
@@ -760,18 +955,9 @@ func UpdateRoomMemberStatusByID(ctx context.Context, req model.RoomMemberReq) (e
 	return nil
 }
 
-func LeaveRoom(ctx context.Context, req model.RoomMemberReq) (err error) {
+func (s *Service) LeaveRoom(ctx context.Context, req model.RoomMemberReq) (err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
-
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	room, err := dBal.GetRoomByID(ctx, pgtype.UUID{
+	room, err := s.q.GetRoomByID(ctx, pgtype.UUID{
 		Bytes: req.RoomID,
 
 		Valid: true,
@@ -781,7 +967,7 @@ func LeaveRoom(ctx context.Context, req model.RoomMemberReq) (err error) {
 		return err
 	}
 
-	err = dBal.UpdateRoomMemberByRoomCodeAndUserID(ctx, dbal.UpdateRoomMemberByRoomCodeAndUserIDParams{
+	err = s.q.UpdateRoomMemberByRoomCodeAndUserID(ctx, dbal.UpdateRoomMemberByRoomCodeAndUserIDParams{
 		RoomCode:         req.RoomCode,
 		RoomMemberStatus: string(req.RoomMemberStatus),
 		IsActive:         false,
@@ -796,18 +982,14 @@ func LeaveRoom(ctx context.Context, req model.RoomMemberReq) (err error) {
 }
 
 /********************** LEADER BOARD **************************************/
-func CreateLeaderBoard(ctx context.Context, req *model.EditLeaderBoardReq) error {
-	l := logs.GetLoggerctx(ctx)
-
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
-	}
+func (s *Service) CreateLeaderBoard(ctx context.Context, req *model.EditLeaderBoardReq) error {
+	return createLeaderBoard(ctx, s.q, req)
+}
 
-	dBal := dbal.New(dbConn.Db)
+func createLeaderBoard(ctx context.Context, q *dbal.Queries, req *model.EditLeaderBoardReq) error {
+	l := logs.GetLoggerctx(ctx)
 
-	err = dBal.CreatLeaderBoard(ctx, dbal.CreatLeaderBoardParams{
+	err := q.CreatLeaderBoard(ctx, dbal.CreatLeaderBoardParams{
 		RoomCode: req.RoomCode,
 		UserID: pgtype.UUID{
 			Bytes: req.UserID,
@@ -827,25 +1009,18 @@ func CreateLeaderBoard(ctx context.Context, req *model.EditLeaderBoardReq) error
 	return nil
 }
 
-func UpdateLeaderBoard(ctx context.Context, req *model.EditLeaderBoardReq) (err error) {
+func (s *Service) UpdateLeaderBoard(ctx context.Context, req *model.EditLeaderBoardReq) (err error) {
 	l := logs.GetLoggerctx(ctx)
 
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return err
-	}
-
-	dBal := dbal.New(dbConn.Db)
-
-	err = dBal.UpdateLeaderBoardScoreByUserIDAndRoomCode(ctx, dbal.UpdateLeaderBoardScoreByUserIDAndRoomCodeParams{
+	err = s.q.UpdateLeaderBoardScoreByUserIDAndRoomCode(ctx, dbal.UpdateLeaderBoardScoreByUserIDAndRoomCodeParams{
 		RoomCode: req.RoomCode,
 		UserID: pgtype.UUID{
 			Bytes: req.UserID,
 			Valid: true,
 		},
-		Score:     float64(req.Score),
-		UpdatedBy: req.UserID.String(),
+		Score:      float64(req.Score),
+		UpdatedBy:  req.UserID.String(),
+		BestStreak: int32(req.BestStreak),
 	})
 	if err != nil {
 		l.Sugar().Error("Update leader board score by user id and room id failed", err)
@@ -855,27 +1030,36 @@ func UpdateLeaderBoard(ctx context.Context, req *model.EditLeaderBoardReq) (err
 	return nil
 }
 
-func ListLeaderBoardByRoomCode(ctx context.Context, req model.RoomCodeReq) (leaderBoard []*model.Leaderboard, err error) {
+func (s *Service) ListLeaderBoardByRoomCode(ctx context.Context, req model.RoomCodeReq) (leaderBoard []*model.Leaderboard, err error) {
 	l := logs.GetLoggerctx(ctx)
 
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-	}
-
-	dBal := dbal.New(dbConn.Db)
-	dbRecord, err := dBal.ListLeaderBoardByRoomCode(ctx, req.RoomCode)
+	dbRecord, err := s.q.ListLeaderBoardByRoomCode(ctx, req.RoomCode)
 	if err != nil {
 		l.Sugar().Error("List leaderboard by room id failed", err)
 		return nil, err
 	}
 	for _, lb := range dbRecord {
 		leaderBoard = append(leaderBoard, &model.Leaderboard{
-			ID:     lb.ID.Bytes,
-			UserID: lb.UserID.Bytes,
-			Score:  lb.Score,
+			ID:         lb.ID.Bytes,
+			UserID:     lb.UserID.Bytes,
+			Score:      lb.Score,
+			BestStreak: int(lb.BestStreak),
 		})
 	}
 	return leaderBoard, err
 }
+
+// GetBestStreak is the best streak the user ever had in a game
+func (s *Service) GetBestStreak(ctx context.Context, req model.UserIDReq) (int, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	bestStreak, err := s.q.GetBestStreakByUserID(ctx, pgtype.UUID{
+		Bytes: req.UserID,
+		Valid: true,
+	})
+	if err != nil {
+		l.Sugar().Error("Could not get the best streak of the user in database", err)
+		return 0, err
+	}
+	return int(bestStreak), nil
+}
@@ -0,0 +1,209 @@
+package room
+
+import (
+	"brainwars/pkg/db/dbtest"
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	"brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+func testContext() context.Context {
+	return logs.SetLoggerctx(context.Background(), zap.NewNop())
+}
+
+// roomRow is a row of the room table, the way CreateRoom and GetRoomByID return it
+func roomRow(roomCode string) []any {
+	return []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, roomCode, pgtype.Text{String: "room", Valid: true}, pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		[]byte("[{}]"), []byte("[]"), false, string(model.MP), string(model.Waiting), true, false,
+		pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", false,
+	}
+}
+
+// roomMemberRow is a row of the room member table the way CreateRoomMember returns it
+func roomMemberRow(roomCode string) []any {
+	return []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, roomCode, pgtype.UUID{Bytes: uuid.New(), Valid: true}, pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		false, pgtype.Timestamp{}, string(model.JoinQuiz), true, false, pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", string(model.Player),
+	}
+}
+
+// generationJobRow is a row of the generation job table
+func generationJobRow(roomCode string, status quizmodel.JobStatus) []any {
+	return []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, roomCode, pgtype.Text{String: "go", Valid: true}, int32(3),
+		string(status), pgtype.Text{}, pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", "instance",
+	}
+}
+
+// recordingGenerator tells when the questions of a room are generated
+type recordingGenerator struct {
+	calls chan *quizmodel.QuizReq
+}
+
+func (g *recordingGenerator) GenerateQuestions(ctx context.Context, req *quizmodel.QuizReq) ([]*quizmodel.QuestionData, error) {
+	g.calls <- req
+	return nil, errors.New("not needed")
+}
+
+func TestSetupGameRollsBackAHalfBuiltRoom(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing string // query which fails, empty when the room is set up
+	}{
+		{"room set up", ""},
+		{"owner cannot join", "CreateRoomMember"},
+		{"bot cannot get a leaderboard row", "CreatLeaderBoard"},
+		{"generation job cannot be created", "CreateGenerationJob"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := dbtest.New()
+			db.Returns("CreateRoom", roomRow("ROOM"))
+			db.Returns("GetRoomByID", roomRow("ROOM"))
+			db.Returns("CreateRoomMember", roomMemberRow("ROOM"))
+			db.Returns("CreateGenerationJob", generationJobRow("ROOM", quizmodel.JobPending))
+			db.Returns("UpdateGenerationJobStatus", generationJobRow("ROOM", quizmodel.JobRunning))
+			if tt.failing != "" {
+				db.Fails(tt.failing, errors.New("db is down"))
+			}
+			generator := &recordingGenerator{calls: make(chan *quizmodel.QuizReq, 1)}
+			s := NewService(db, quiz.NewService(db, generator), nil)
+
+			roomCode, err := s.SetupGame(testContext(),
+				model.RoomReq{UserID: uuid.New(), GameType: model.MP, TimeLimit: 10},
+				[]model.UserIDReq{{UserID: uuid.New()}},
+				&quizmodel.QuizReq{Topic: "go", Count: 3, Difficulty: quizmodel.Easy},
+			)
+
+			if tt.failing == "" {
+				if err != nil || roomCode != "ROOM" {
+					t.Fatalf("got room %q and error %v", roomCode, err)
+				}
+				if db.Commits() != 1 || db.Rollbacks() != 0 {
+					t.Errorf("got %d commits and %d rollbacks, want the room committed", db.Commits(), db.Rollbacks())
+				}
+				select {
+				case <-generator.calls:
+				case <-time.After(2 * time.Second):
+					t.Errorf("the questions of the room were not generated")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("setting up the room should fail when %s fails", tt.failing)
+			}
+			if db.Commits() != 0 || db.Rollbacks() != 1 {
+				t.Errorf("got %d commits and %d rollbacks, want the room rolled back", db.Commits(), db.Rollbacks())
+			}
+			select {
+			case <-generator.calls:
+				t.Errorf("questions were generated for a room which was rolled back")
+			case <-time.After(50 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestFailStaleGenerationJobsFailsTheirRooms(t *testing.T) {
+	ctx := logs.SetLoggerctx(context.Background(), zap.NewNop())
+	db := dbtest.New()
+	quizService := quiz.NewService(db, nil)
+	s := NewService(db, quizService, nil)
+
+	notified := []*quizmodel.GenerationJob{}
+	quizService.OnJobUpdate(func(ctx context.Context, job *quizmodel.GenerationJob) {
+		notified = append(notified, job)
+	})
+	db.Returns("FailStaleGenerationJobs", []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, "CRASHED", pgtype.Text{String: "go", Valid: true}, int32(5),
+		string(quizmodel.JobFailed), pgtype.Text{String: "interrupted", Valid: true},
+		pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", "crashed",
+	})
+
+	s.FailStaleGenerationJobs(ctx)
+
+	calls := db.Calls("FailStaleGenerationJobs")
+	if len(calls) != 1 || calls[0].Args[1] != int32(quiz.JobStaleSecond()) {
+		t.Fatalf("stale jobs were looked up with %+v", calls)
+	}
+	if len(notified) != 1 || notified[0].RoomCode != "CRASHED" || notified[0].Status != quizmodel.JobFailed {
+		t.Errorf("the lobby should hear about the failed job, listeners got %+v", notified)
+	}
+	updates := db.Calls("UpdateRoomStatusByRoomCode")
+	if len(updates) != 1 || updates[0].Args[0] != "CRASHED" || updates[0].Args[1] != string(model.Failed) {
+		t.Errorf("the room of the stale job should be failed, got %+v", updates)
+	}
+}
+
+func TestMergeRoomMeta(t *testing.T) {
+	scoring, err := quiz.ScoringRoomMeta(quizmodel.SpeedScoring)
+	if err != nil {
+		t.Fatalf("scoring room meta: %v", err)
+	}
+	created, err := quiz.TeamRoomMeta(scoring, 3)
+	if err != nil {
+		t.Fatalf("team room meta: %v", err)
+	}
+	endGame := `[{"type":"end_game","payload":{"message":"done"}}]`
+
+	tests := []struct {
+		name     string
+		roomMeta string
+		types    []string
+	}{
+		{"settings are kept", string(created), []string{quizmodel.ScoringMetaEvent, quizmodel.TeamMetaEvent, endGameMetaEvent}},
+		{"a replayed end replaces the earlier one", `[{"type":"end_game","payload":{"message":"old"}}]`, []string{endGameMetaEvent}},
+		{"room of an old version", `[{}]`, []string{endGameMetaEvent}},
+		{"empty meta", ``, []string{endGameMetaEvent}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := mergeRoomMeta([]byte(tt.roomMeta), []byte(endGame))
+			if err != nil {
+				t.Fatalf("merge room meta: %v", err)
+			}
+			entries := []roomMetaEntry{}
+			if err := json.Unmarshal(merged, &entries); err != nil {
+				t.Fatalf("merged room meta: %v", err)
+			}
+			if len(entries) != len(tt.types) {
+				t.Fatalf("got %s, want the entries %v", merged, tt.types)
+			}
+			for i, entry := range entries {
+				if entry.Type != tt.types[i] {
+					t.Fatalf("got %s, want the entries %v", merged, tt.types)
+				}
+			}
+			if last := entries[len(entries)-1]; string(last.Payload) != `{"message":"done"}` {
+				t.Errorf("the end of the game is %s", last.Payload)
+			}
+		})
+	}
+
+	if quiz.ScoringRuleFromRoomMeta(string(mustMerge(t, created, endGame))).Name() != quizmodel.SpeedScoring {
+		t.Error("the scoring rule should still be read from the meta of an ended room")
+	}
+	if quiz.TeamCountFromRoomMeta(string(mustMerge(t, created, endGame))) != 3 {
+		t.Error("the teams should still be read from the meta of an ended room")
+	}
+}
+
+func mustMerge(t *testing.T, roomMeta []byte, added string) []byte {
+	t.Helper()
+	merged, err := mergeRoomMeta(roomMeta, []byte(added))
+	if err != nil {
+		t.Fatalf("merge room meta: %v", err)
+	}
+	return merged
+}
@@ -32,6 +32,7 @@ const (
 	UserReady        UserStatus = "ready"
 	UserJoined       UserStatus = "joined"
 	UserQuestionWait UserStatus = "question_wait"
+	UserHosting      UserStatus = "hosting" // the host watches the game, the game does not wait for him
 )
 
 type UserInfo struct {
@@ -11,17 +11,22 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-func CreateNewUser(ctx context.Context, req *model.NewUserReq) (userDetails *model.UserInfo, err error) {
-	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
+// Service is the user service, it runs its queries on the shared connection pool
+type Service struct {
+	db dbpkg.Pool
+	q  *dbal.Queries
+}
 
+func NewService(db dbpkg.Pool) *Service {
+	return &Service{
+		db: db,
+		q:  dbal.New(db),
 	}
-	defer dbConn.Db.Close()
-	dBal := dbal.New(dbConn.Db)
-	err = dBal.CreateNewUser(ctx, dbal.CreateNewUserParams{
+}
+
+func (s *Service) CreateNewUser(ctx context.Context, req *model.NewUserReq) (userDetails *model.UserInfo, err error) {
+	l := logs.GetLoggerctx(ctx)
+	err = s.q.CreateNewUser(ctx, dbal.CreateNewUserParams{
 		ID: pgtype.UUID{
 			Bytes: uuid.New(),
 			Valid: true,
@@ -48,18 +53,9 @@ func CreateNewUser(ctx context.Context, req *model.NewUserReq) (userDetails *mod
 	return nil, nil
 }
 
-func GetUserDetailsbyID(ctx context.Context, userID uuid.UUID) (userDetails *model.UserInfo, err error) {
+func (s *Service) GetUserDetailsbyID(ctx context.Context, userID uuid.UUID) (userDetails *model.UserInfo, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	dbrecord, err := dBal.GetUserDetailsByID(ctx, pgtype.UUID{
+	dbrecord, err := s.q.GetUserDetailsByID(ctx, pgtype.UUID{
 		Bytes: userID,
 		Valid: true,
 	})
@@ -84,18 +80,9 @@ func GetUserDetailsbyID(ctx context.Context, userID uuid.UUID) (userDetails *mod
 	return userDetails, nil
 }
 
-func GetUserDetailsbyAuth0SubID(ctx context.Context, sub string) (userDetails *model.UserInfo, err error) {
+func (s *Service) GetUserDetailsbyAuth0SubID(ctx context.Context, sub string) (userDetails *model.UserInfo, err error) {
 	l := logs.GetLoggerctx(ctx)
-	dbConn, err := dbpkg.InitDB()
-	if err != nil {
-		l.Sugar().Error("Could not initialize database", err)
-		return nil, err
-
-	}
-	defer dbConn.Db.Close()
-
-	dBal := dbal.New(dbConn.Db)
-	dbrecord, err := dBal.GetUserDetailsByAuth0SubID(ctx, pgtype.Text{
+	dbrecord, err := s.q.GetUserDetailsByAuth0SubID(ctx, pgtype.Text{
 		String: sub,
 		Valid:  true,
 	})
@@ -0,0 +1,71 @@
+// The server doesn't trust the client with the time anymore.
+// Every answer is stamped when it reaches the server and the speed bonus is measured from the moment
+// the question was sent. Answers which come in faster than a human can read the question, or which all
+// take the same time, are flagged and the flag is stored with the answer so the analysis page can show it.
+
+package websocket
+
+import (
+	quizmodel "brainwars/pkg/quiz/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultMinAnswerLatencyMs = 250
+	defaultMinLatencySpreadMs = 40
+	uniformLatencySamples     = 5 // answers needed before we look at how much the latency varies
+)
+
+func minAnswerLatency() time.Duration {
+	ms := viper.GetInt("game.minAnswerLatencyMs")
+	if ms <= 0 {
+		ms = defaultMinAnswerLatencyMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func minLatencySpread() time.Duration {
+	ms := viper.GetInt("game.minLatencySpreadMs")
+	if ms <= 0 {
+		ms = defaultMinLatencySpreadMs
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// answerLatencyFlag tells why the latency of an answer is not humanly possible, empty when it is fine.
+// history is the latency of the player's earlier answers
+func answerLatencyFlag(latency time.Duration, history []time.Duration) string {
+	if latency < minAnswerLatency() {
+		return quizmodel.FlagTooFast
+	}
+	if len(history) < uniformLatencySamples-1 {
+		return ""
+	}
+	fastest, slowest := latency, latency
+	for _, l := range history {
+		fastest = min(fastest, l)
+		slowest = max(slowest, l)
+	}
+	if slowest-fastest < minLatencySpread() {
+		return quizmodel.FlagUniformLatency
+	}
+	return ""
+}
+
+// answerLatencies is the latency of every answer the client gave except for the given question,
+// caller must hold the manager lock
+func (c *Client) answerLatencies(skipQuestionID uuid.UUID) []time.Duration {
+	latencies := []time.Duration{}
+	for questionID, answers := range c.ansHistory {
+		if questionID == skipQuestionID {
+			continue
+		}
+		if answer, ok := answers[c.userID]; ok {
+			latencies = append(latencies, time.Duration(answer.LatencyMs)*time.Millisecond)
+		}
+	}
+	return latencies
+}
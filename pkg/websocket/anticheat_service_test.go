@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	quizmodel "brainwars/pkg/quiz/model"
+	usermodel "brainwars/pkg/users/model"
+	"brainwars/pkg/util"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+func TestAnswerLatencyFlag(t *testing.T) {
+	ms := func(latencies ...int) []time.Duration {
+		durations := []time.Duration{}
+		for _, l := range latencies {
+			durations = append(durations, time.Duration(l)*time.Millisecond)
+		}
+		return durations
+	}
+
+	tests := []struct {
+		name         string
+		minLatencyMs int // game.minAnswerLatencyMs, the default when 0
+		latency      time.Duration
+		history      []time.Duration
+		want         string
+	}{
+		{name: "a human answer", latency: 2 * time.Second, want: ""},
+		{name: "faster than a human can read", latency: 100 * time.Millisecond, want: quizmodel.FlagTooFast},
+		{name: "right at the limit", latency: 250 * time.Millisecond, want: ""},
+		{name: "the limit is configured", minLatencyMs: 1000, latency: 800 * time.Millisecond, want: quizmodel.FlagTooFast},
+		{name: "every answer took the same time", latency: 3 * time.Second, history: ms(3010, 2990, 3020, 3000), want: quizmodel.FlagUniformLatency},
+		{name: "the answers vary", latency: 3 * time.Second, history: ms(3010, 2400, 3020, 5000), want: ""},
+		{name: "too few answers to tell", latency: 3 * time.Second, history: ms(3010, 2990, 3020), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.minLatencyMs > 0 {
+				viper.Set("game.minAnswerLatencyMs", tt.minLatencyMs)
+				t.Cleanup(func() { viper.Set("game.minAnswerLatencyMs", nil) })
+			}
+
+			if got := answerLatencyFlag(tt.latency, tt.history); got != tt.want {
+				t.Errorf("got the flag %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerIsTimedByTheServer(t *testing.T) {
+	tests := []struct {
+		name       string
+		startedAgo time.Duration // since the question was sent
+		want       string
+	}{
+		{name: "answered in time", startedAgo: 3 * time.Second, want: ""},
+		{name: "answered right away", startedAgo: 0, want: quizmodel.FlagTooFast},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			question := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1, Options: []quizmodel.Options{{ID: 1, Option: "a"}, {ID: 2, Option: "b"}}}
+			gameState := startedGame(m, "room-1", question)
+			gameState.QuestionStartTime = time.Now().Add(-tt.startedAgo)
+			player, _ := addTestClient(t, m, "room-1")
+			ctx := util.SetUserInfoInctx(testContext(), &usermodel.UserInfo{ID: player.userID, UserName: "player"})
+			// the client claims it answered the moment the question came
+			payload, _ := json.Marshal(map[string]any{
+				"questionDataID": question.ID, "answerOption": 1, "AnswerTime": gameState.QuestionStartTime, "LatencyMs": 1,
+			})
+
+			err := SubmitAnswerHandler(ctx, Event{Type: EventSubmitAnswer, Payload: payload}, player)
+			if err != nil {
+				t.Fatalf("submit answer: %v", err)
+			}
+
+			answer := player.ansHistory[question.ID][player.userID]
+			if answer == nil {
+				t.Fatalf("the answer was not taken")
+			}
+			took := time.Duration(answer.LatencyMs) * time.Millisecond
+			if took < tt.startedAgo || took > tt.startedAgo+time.Second {
+				t.Errorf("the answer took %s, want the %s since the question was sent", took, tt.startedAgo)
+			}
+			if answer.FlagReason != tt.want {
+				t.Errorf("got the flag %q, want %q", answer.FlagReason, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerToAnotherQuestionIsTurnedDown(t *testing.T) {
+	m := newTestManager(t)
+	previous := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1, Options: []quizmodel.Options{{ID: 1, Option: "a"}}}
+	current := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1, Options: []quizmodel.Options{{ID: 1, Option: "a"}}}
+	gameState := startedGame(m, "room-1", previous, current)
+	gameState.CurrentQuestionIndex = 1
+	player, _ := addTestClient(t, m, "room-1")
+
+	err := SubmitAnswerHandler(testContext(), answerEvent(t, previous, 1), player)
+	if err != nil {
+		t.Fatalf("submit answer: %v", err)
+	}
+
+	event, ok := nextEventOf(t, player, EventGameError)
+	quizError := quizmodel.QuizError{}
+	if !ok || json.Unmarshal(event.Payload, &quizError) != nil || quizError.Message != "this question is already over" {
+		t.Errorf("got the error %+v, want the question to be over", quizError)
+	}
+	if len(gameState.Participants) != 0 || len(player.ansHistory) != 0 {
+		t.Errorf("the answer to the closed question was taken")
+	}
+}
+
+func TestAnswerLatencies(t *testing.T) {
+	player := NewClient(nil, nil, "room-1", false, "", uuid.New(), "player", nil)
+	current, earlier := uuid.New(), uuid.New()
+	player.ansHistory[earlier] = map[uuid.UUID]*quizmodel.AnswerReq{player.userID: {LatencyMs: 1200}}
+	player.ansHistory[current] = map[uuid.UUID]*quizmodel.AnswerReq{player.userID: {LatencyMs: 900}}
+
+	latencies := player.answerLatencies(current)
+
+	if len(latencies) != 1 || latencies[0] != 1200*time.Millisecond {
+		t.Errorf("got the latencies %v, want only the earlier answer", latencies)
+	}
+}
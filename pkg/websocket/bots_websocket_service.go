@@ -2,15 +2,14 @@ package websocket
 
 import (
 	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz"
 	quizmodel "brainwars/pkg/quiz/model"
-	"brainwars/pkg/room"
 
 	roommodel "brainwars/pkg/room/model"
 	usermodel "brainwars/pkg/users/model"
 	"context"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,7 +22,7 @@ func (m *Manager) setupBotsForRoom(ctx context.Context, roomCode string, roomDet
 	l := logs.GetLoggerctx(ctx)
 	// TODO: if the bots are alredy set of that room ignore and move forward
 	// Get all room members including bots
-	roomMembers, err := room.ListRoomMembersByRoomCode(ctx, roommodel.RoomCodeReq{
+	roomMembers, err := m.roomService.ListRoomMembersByRoomCode(ctx, roommodel.RoomCodeReq{
 		RoomCode: roomCode,
 	})
 	if err != nil || len(roomMembers) == 0 {
@@ -126,6 +125,14 @@ func (m *Manager) broadcastToBots(ctx context.Context, roomCode string, event Ev
 func (c *Client) handleBotBehavior(ctx context.Context) {
 	l := logs.GetLoggerctx(ctx)
 
+	// the answer the bot is about to give, kept so that a pause of the host can hold it back
+	var (
+		pendingQuestion uuid.UUID
+		answerAt        time.Time     // when the answer goes out
+		heldBack        time.Duration // what was left of the wait when the game was paused
+		paused          bool
+	)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -156,27 +163,48 @@ func (c *Client) handleBotBehavior(ctx context.Context) {
 				// Calculate answer delay based on bot type
 				delay := time.Duration(usermodel.BotTypeMap[c.botType])
 				l.Sugar().Debugf("Resolved botType %s to delay %v", c.botType, delay)
-				// Ensure delay does not exceed the time limit for the question
-				maxDelay := time.Duration(questionEvent.TimeLimit) * time.Minute
+				// Ensure delay does not exceed the time left on the server's clock for the question
+				maxDelay := time.Duration(questionEvent.TimeLeft) * time.Second
 				if delay > maxDelay {
 					delay = maxDelay
 				}
 
-				// Create a new context for this question
-				qCtx, cancel := context.WithCancel(ctx)
-				c.QuestionCancel = cancel
-
-				// Spawn a new goroutine for delayed answer submission
-				go func(qID uuid.UUID, d time.Duration, botID uuid.UUID) {
-					l.Sugar().Debugf("Bot %v will answer question %v in %v", botID, qID, d)
+				pendingQuestion = questionEvent.Question.ID
+				// a question sent while the game is paused is answered once the host resumes
+				paused = questionEvent.Paused
+				if paused {
+					heldBack = delay
+					continue
+				}
+				answerAt = time.Now().Add(delay)
+				c.answerAfter(ctx, pendingQuestion, delay)
 
-					select {
-					case <-qCtx.Done():
-						return
-					case <-time.After(d):
-						c.submitRandomAnswer(qCtx, qID)
+			case EventHostAction:
+				action := hostActionEvent{}
+				if err := json.Unmarshal(event.Payload, &action); err != nil || pendingQuestion == uuid.Nil {
+					continue
+				}
+				switch {
+				case action.Action == HostActionPause && !paused:
+					// the question timer stands still, so does the bot
+					if c.QuestionCancel != nil {
+						c.QuestionCancel()
 					}
-				}(questionEvent.Question.ID, delay, c.userID)
+					paused = true
+					heldBack = max(time.Until(answerAt), 0)
+				case action.Action == HostActionResume && paused:
+					paused = false
+					delay := min(heldBack, time.Duration(action.TimeLeft)*time.Second)
+					answerAt = time.Now().Add(delay)
+					c.answerAfter(ctx, pendingQuestion, delay)
+				}
+
+			case EventQuestionReveal:
+				// the question is closed, an answer which is still on its way is of no use
+				if c.QuestionCancel != nil {
+					c.QuestionCancel()
+				}
+				pendingQuestion = uuid.Nil
 
 			case EventReadyGame:
 				l.Sugar().Debugf("Bot %s is ready to play", c.userID)
@@ -186,9 +214,10 @@ func (c *Client) handleBotBehavior(ctx context.Context) {
 				if c.QuestionCancel != nil { // cancel if any running answer goroutines coz we no need them
 					c.QuestionCancel()
 				}
+				pendingQuestion = uuid.Nil
 				// Clean up bot resources or perform any necessary actions
 				//Update bot answer history in db
-				err := updateAnswerHistory(ctx, c.ansHistory)
+				err := c.manager.updateAnswerHistory(ctx, c.ansHistory)
 				if err != nil {
 					return
 				}
@@ -201,6 +230,27 @@ func (c *Client) handleBotBehavior(ctx context.Context) {
 	}
 }
 
+// answerAfter answers the question once the delay is over, the answer is dropped when QuestionCancel is called before
+func (c *Client) answerAfter(ctx context.Context, questionID uuid.UUID, delay time.Duration) {
+	l := logs.GetLoggerctx(ctx)
+
+	// Create a new context for this question
+	qCtx, cancel := context.WithCancel(ctx)
+	c.QuestionCancel = cancel
+
+	// Spawn a new goroutine for delayed answer submission
+	go func(qID uuid.UUID, d time.Duration, botID uuid.UUID) {
+		l.Sugar().Debugf("Bot %v will answer question %v in %v", botID, qID, d)
+
+		select {
+		case <-qCtx.Done():
+			return
+		case <-time.After(d):
+			c.submitRandomAnswer(qCtx, qID)
+		}
+	}(questionID, delay, c.userID)
+}
+
 func (c *Client) submitRandomAnswer(ctx context.Context, questionID uuid.UUID) {
 	//	l := logs.GetLoggerctx(ctx)
 
@@ -224,22 +274,23 @@ func (c *Client) submitRandomAnswer(ctx context.Context, questionID uuid.UUID) {
 	}
 	c.manager.RUnlock()
 
-	if !found || len(currentQuestion.Options) == 0 {
+	if !found || (currentQuestion.SingleAnswer() && len(currentQuestion.Options) == 0) {
 		return
 	}
 
-	// Select a random option
-	randomIndex := rand.Intn(len(currentQuestion.Options))
-	selectedOption := currentQuestion.Options[randomIndex]
+	// Select a random option, the other question types are guessed by their kind
+	selectedOption, selectedData := quiz.GetQuestionKind(currentQuestion.Type).BotAnswer(currentQuestion)
 
 	// Create and send the answer event
 	answerPayload := struct {
-		QuestionDataID uuid.UUID `json:"questionDataID"`
-		AnswerOption   int       `json:"answerOption"`
-		PlayerID       uuid.UUID `json:"playerID"`
+		QuestionDataID uuid.UUID             `json:"questionDataID"`
+		AnswerOption   int                   `json:"answerOption"`
+		AnswerData     *quizmodel.AnswerData `json:"answerData,omitempty"`
+		PlayerID       uuid.UUID             `json:"playerID"`
 	}{
 		QuestionDataID: currentQuestion.ID,
-		AnswerOption:   selectedOption.ID,
+		AnswerOption:   selectedOption,
+		AnswerData:     selectedData,
 		PlayerID:       c.userID,
 	}
 
@@ -0,0 +1,541 @@
+// The room bus sits between the Manager and its clients for every room wide event.
+// Handlers publish an event for a room code to the bus and every app instance subscribed
+// to the bus delivers it to the clients it holds for that room. With the in-memory bus this is
+// just the local fan-out we always had, with the redis bus players connected to different
+// instances of brainwars see the same new_question, leaderboard, chat etc. events.
+// The game of a room (its state, ready check and question timer) runs on one instance only, the
+// instance which claimed the room on the bus. The claim is renewed while the instance keeps the room.
+// A connection landing on any other instance is still accepted, that instance forwards what the
+// client sends to the room's command channel and writes back what the owner sends to the client
+// (see relay_service.go), so the load balancer can send a player to any instance.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+const (
+	BusDriverMemory = "memory"
+	BusDriverRedis  = "redis"
+
+	defaultBusChannelPrefix  = "brainwars:room:"
+	defaultRoomClaimPrefix   = "brainwars:room-owner:"
+	defaultCommandPrefix     = "brainwars:room-cmd:" // what the forwarded clients of a room send to its owner
+	defaultReplyPrefix       = "brainwars:instance:" // what an owner sends back to the forwarded clients held by an instance
+	memoryBusInstanceID      = "local"
+	defaultRoomClaimSeconds  = 30
+	roomClaimRenewalDivision = 3 // a claim is renewed three times before it would expire
+)
+
+// DeliverFunc is called by the bus for every event published to a room
+type DeliverFunc func(ctx context.Context, roomCode string, event Event)
+
+// RelayFunc is called by the bus for every message of a forwarded connection
+type RelayFunc func(ctx context.Context, msg RelayMessage)
+
+// RoomBus is the pluggable pub/sub used to broadcast room events across app instances
+type RoomBus interface {
+	// Publish sends the event to every subscriber of the room
+	Publish(ctx context.Context, roomCode string, event Event) error
+	// Subscribe registers the function which delivers the events to the local clients
+	Subscribe(ctx context.Context, deliver DeliverFunc) error
+	// InstanceID tells this instance apart from the other instances on the bus
+	InstanceID() string
+	// ClaimRoom makes this instance the only one running the game of the room when nobody runs it yet,
+	// it returns the instance which runs the room. the claim is kept till ReleaseRoom
+	ClaimRoom(ctx context.Context, roomCode string) (string, error)
+	// RoomOwner returns the instance running the room, empty when nobody runs it
+	RoomOwner(ctx context.Context, roomCode string) (string, error)
+	// ReleaseRoom gives up the claim so another instance can run the room
+	ReleaseRoom(ctx context.Context, roomCode string) error
+	// SendCommand hands a message of a forwarded connection to the instance running the room
+	SendCommand(ctx context.Context, roomCode string, msg RelayMessage) error
+	// SendReply hands a message to the instance holding the websocket of a forwarded connection
+	SendReply(ctx context.Context, instanceID string, msg RelayMessage) error
+	// SubscribeRelay registers the functions handling the commands of the rooms this instance runs
+	// and the replies to the connections this instance forwards
+	SubscribeRelay(ctx context.Context, onCommand RelayFunc, onReply RelayFunc) error
+	Close() error
+}
+
+// busMessage is the envelope which travels over the bus
+type busMessage struct {
+	Origin   string `json:"origin"` // instance id of the publisher, useful while debugging
+	RoomCode string `json:"roomCode"`
+	Event    Event  `json:"event"`
+}
+
+// NewRoomBus picks the bus implementation from the viper config (broadcast.driver)
+func NewRoomBus(ctx context.Context) (RoomBus, error) {
+	driver := viper.GetString("broadcast.driver")
+	switch driver {
+	case "", BusDriverMemory:
+		return newMemoryBus(), nil
+	case BusDriverRedis:
+		return newRedisBus(ctx)
+	default:
+		return nil, fmt.Errorf("unknown broadcast driver %s", driver)
+	}
+}
+
+/********************** IN MEMORY BUS **************************************/
+
+// memoryBus is used when there is only one instance of the app running.
+// publish directly calls the subscribers so the ordering of the events is the same as before
+type memoryBus struct {
+	sync.RWMutex
+	subscribers []DeliverFunc
+	onCommand   RelayFunc
+	onReply     RelayFunc
+}
+
+func newMemoryBus() *memoryBus {
+	return &memoryBus{}
+}
+
+func (b *memoryBus) Publish(ctx context.Context, roomCode string, event Event) error {
+	b.RLock()
+	subscribers := b.subscribers
+	b.RUnlock()
+
+	for _, deliver := range subscribers {
+		deliver(ctx, roomCode, event)
+	}
+	return nil
+}
+
+func (b *memoryBus) Subscribe(ctx context.Context, deliver DeliverFunc) error {
+	b.Lock()
+	b.subscribers = append(b.subscribers, deliver)
+	b.Unlock()
+	return nil
+}
+
+func (b *memoryBus) InstanceID() string {
+	return memoryBusInstanceID
+}
+
+// ClaimRoom always succeeds since this is the only instance
+func (b *memoryBus) ClaimRoom(ctx context.Context, roomCode string) (string, error) {
+	return memoryBusInstanceID, nil
+}
+
+func (b *memoryBus) RoomOwner(ctx context.Context, roomCode string) (string, error) {
+	return memoryBusInstanceID, nil
+}
+
+func (b *memoryBus) ReleaseRoom(ctx context.Context, roomCode string) error {
+	return nil
+}
+
+// SendCommand never happens with one instance since it runs every room, it is looped back anyway
+func (b *memoryBus) SendCommand(ctx context.Context, roomCode string, msg RelayMessage) error {
+	b.RLock()
+	onCommand := b.onCommand
+	b.RUnlock()
+	if onCommand != nil {
+		onCommand(ctx, msg)
+	}
+	return nil
+}
+
+func (b *memoryBus) SendReply(ctx context.Context, instanceID string, msg RelayMessage) error {
+	b.RLock()
+	onReply := b.onReply
+	b.RUnlock()
+	if onReply != nil {
+		onReply(ctx, msg)
+	}
+	return nil
+}
+
+func (b *memoryBus) SubscribeRelay(ctx context.Context, onCommand RelayFunc, onReply RelayFunc) error {
+	b.Lock()
+	b.onCommand = onCommand
+	b.onReply = onReply
+	b.Unlock()
+	return nil
+}
+
+func (b *memoryBus) Close() error {
+	b.Lock()
+	b.subscribers = nil
+	b.onCommand = nil
+	b.onReply = nil
+	b.Unlock()
+	return nil
+}
+
+/********************** REDIS BUS **************************************/
+
+// redisBus publishes every room event into a redis channel per room (prefix + roomCode).
+// each instance pattern subscribes to prefix* so it gets the events of all the rooms and
+// delivers them to whichever clients it holds locally for that room.
+// a room is claimed with a key (claimPrefix + roomCode) holding the instance id, the key expires
+// unless the instance keeps renewing it so a crashed instance does not hold its rooms forever.
+// the owner of a room also subscribes to the command channel of the room (commandPrefix + roomCode)
+// and every instance subscribes to its own reply channel (replyPrefix + instanceID)
+type redisBus struct {
+	client      *redis.Client
+	prefix      string
+	instanceID  string
+	pubsub      *redis.PubSub
+	claimPrefix string
+	claimTTL    time.Duration
+
+	claimMu  sync.Mutex
+	claimed  map[string]bool // rooms this instance runs, their claims are renewed
+	relaySub *redis.PubSub   // reply channel of this instance and the command channels of the claimed rooms
+}
+
+// claimRoomScript takes the claim when nobody holds it and renews it when we hold it already,
+// it returns the instance holding the claim
+var claimRoomScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if owner == false or owner == ARGV[1] then
+  redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+  return ARGV[1]
+end
+return owner
+`)
+
+// releaseRoomScript drops the claim only when we hold it
+var releaseRoomScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+  return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func newRedisBus(ctx context.Context) (*redisBus, error) {
+	prefix := viper.GetString("broadcast.redis.channelPrefix")
+	if prefix == "" {
+		prefix = defaultBusChannelPrefix
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     viper.GetString("broadcast.redis.addr"),
+		Password: os.Getenv("REDIS_PASSWORD"),
+		DB:       viper.GetInt("broadcast.redis.db"),
+	})
+
+	err := client.Ping(ctx).Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %v", err)
+	}
+
+	claimSeconds := viper.GetInt("broadcast.redis.roomClaimSeconds")
+	if claimSeconds <= 0 {
+		claimSeconds = defaultRoomClaimSeconds
+	}
+	b := &redisBus{
+		client:      client,
+		prefix:      prefix,
+		instanceID:  uuid.New().String(),
+		claimPrefix: defaultRoomClaimPrefix,
+		claimTTL:    time.Duration(claimSeconds) * time.Second,
+		claimed:     make(map[string]bool),
+	}
+	go b.renewClaims(ctx)
+	return b, nil
+}
+
+func (b *redisBus) Publish(ctx context.Context, roomCode string, event Event) error {
+	msg, err := json.Marshal(busMessage{
+		Origin:   b.instanceID,
+		RoomCode: roomCode,
+		Event:    event,
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.client.Publish(ctx, b.prefix+roomCode, msg).Err()
+}
+
+func (b *redisBus) Subscribe(ctx context.Context, deliver DeliverFunc) error {
+	l := logs.GetLoggerctx(ctx)
+
+	b.pubsub = b.client.PSubscribe(ctx, b.prefix+"*")
+	// wait for the subscription to be confirmed so that we dont miss the first events
+	_, err := b.pubsub.Receive(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to redis channel: %v", err)
+	}
+
+	go func() {
+		for msg := range b.pubsub.Channel() {
+			bm := busMessage{}
+			err := json.Unmarshal([]byte(msg.Payload), &bm)
+			if err != nil {
+				l.Sugar().Error("bus message json unmarshal failed", err)
+				continue
+			}
+			if bm.RoomCode == "" {
+				bm.RoomCode = strings.TrimPrefix(msg.Channel, b.prefix)
+			}
+			deliver(ctx, bm.RoomCode, bm.Event)
+		}
+		l.Sugar().Info("redis room bus subscription closed")
+	}()
+
+	return nil
+}
+
+func (b *redisBus) InstanceID() string {
+	return b.instanceID
+}
+
+func (b *redisBus) ClaimRoom(ctx context.Context, roomCode string) (string, error) {
+	owner, err := claimRoomScript.Run(ctx, b.client, []string{b.claimPrefix + roomCode}, b.instanceID, b.claimTTL.Milliseconds()).Text()
+	if err != nil {
+		return "", err
+	}
+	if owner == b.instanceID {
+		b.claimMu.Lock()
+		newClaim := !b.claimed[roomCode]
+		b.claimed[roomCode] = true
+		relaySub := b.relaySub
+		b.claimMu.Unlock()
+		// the forwarded clients of the room talk to us from now on
+		if newClaim && relaySub != nil {
+			err = relaySub.Subscribe(ctx, defaultCommandPrefix+roomCode)
+			if err != nil {
+				return "", fmt.Errorf("failed to subscribe to the commands of room %s: %v", roomCode, err)
+			}
+		}
+	}
+	return owner, nil
+}
+
+func (b *redisBus) RoomOwner(ctx context.Context, roomCode string) (string, error) {
+	owner, err := b.client.Get(ctx, b.claimPrefix+roomCode).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return owner, err
+}
+
+func (b *redisBus) ReleaseRoom(ctx context.Context, roomCode string) error {
+	b.dropClaim(ctx, roomCode)
+	return releaseRoomScript.Run(ctx, b.client, []string{b.claimPrefix + roomCode}, b.instanceID).Err()
+}
+
+// dropClaim stops renewing the claim of the room and listening to its commands
+func (b *redisBus) dropClaim(ctx context.Context, roomCode string) {
+	l := logs.GetLoggerctx(ctx)
+	b.claimMu.Lock()
+	claimed := b.claimed[roomCode]
+	delete(b.claimed, roomCode)
+	relaySub := b.relaySub
+	b.claimMu.Unlock()
+	if claimed && relaySub != nil {
+		err := relaySub.Unsubscribe(ctx, defaultCommandPrefix+roomCode)
+		if err != nil {
+			l.Sugar().Errorf("unsubscribe from the commands of room %s failed %v", roomCode, err)
+		}
+	}
+}
+
+func (b *redisBus) SendCommand(ctx context.Context, roomCode string, msg RelayMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, defaultCommandPrefix+roomCode, data).Err()
+}
+
+func (b *redisBus) SendReply(ctx context.Context, instanceID string, msg RelayMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, defaultReplyPrefix+instanceID, data).Err()
+}
+
+func (b *redisBus) SubscribeRelay(ctx context.Context, onCommand RelayFunc, onReply RelayFunc) error {
+	l := logs.GetLoggerctx(ctx)
+
+	b.claimMu.Lock()
+	channels := []string{defaultReplyPrefix + b.instanceID}
+	for roomCode := range b.claimed {
+		channels = append(channels, defaultCommandPrefix+roomCode)
+	}
+	b.relaySub = b.client.Subscribe(ctx, channels...)
+	relaySub := b.relaySub
+	b.claimMu.Unlock()
+
+	// wait for the subscription to be confirmed so that we dont miss the first messages
+	_, err := relaySub.Receive(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to redis relay channels: %v", err)
+	}
+
+	go func() {
+		for msg := range relaySub.Channel() {
+			rm := RelayMessage{}
+			err := json.Unmarshal([]byte(msg.Payload), &rm)
+			if err != nil {
+				l.Sugar().Error("relay message json unmarshal failed", err)
+				continue
+			}
+			if msg.Channel == defaultReplyPrefix+b.instanceID {
+				onReply(ctx, rm)
+				continue
+			}
+			onCommand(ctx, rm)
+		}
+		l.Sugar().Info("redis relay subscription closed")
+	}()
+
+	return nil
+}
+
+// renewClaims keeps the claims of the rooms this instance runs from expiring
+func (b *redisBus) renewClaims(ctx context.Context) {
+	l := logs.GetLoggerctx(ctx)
+	ticker := time.NewTicker(b.claimTTL / roomClaimRenewalDivision)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.claimMu.Lock()
+			rooms := make([]string, 0, len(b.claimed))
+			for roomCode := range b.claimed {
+				rooms = append(rooms, roomCode)
+			}
+			b.claimMu.Unlock()
+			for _, roomCode := range rooms {
+				owner, err := b.ClaimRoom(ctx, roomCode)
+				if err != nil {
+					l.Sugar().Errorf("renew claim of room %s failed %v", roomCode, err)
+					continue
+				}
+				if owner != b.instanceID {
+					// another instance took the room over after our claim ran out
+					l.Sugar().Errorf("lost the claim of room %s to another instance", roomCode)
+					b.dropClaim(ctx, roomCode)
+				}
+			}
+		}
+	}
+}
+
+func (b *redisBus) Close() error {
+	if b.pubsub != nil {
+		b.pubsub.Close()
+	}
+	if b.relaySub != nil {
+		b.relaySub.Close()
+	}
+	return b.client.Close()
+}
+
+/********************** MANAGER **************************************/
+
+// claimRoom makes this instance the one running the game of the room when nobody runs it yet and
+// returns the instance which runs it, empty when the bus could not tell
+func (m *Manager) claimRoom(ctx context.Context, roomCode string) string {
+	l := logs.GetLoggerctx(ctx)
+	owner, err := m.bus.ClaimRoom(ctx, roomCode)
+	if err != nil {
+		l.Sugar().Errorf("claim room %s failed %v", roomCode, err)
+		return ""
+	}
+	return owner
+}
+
+// runsHere tells if the game of the room runs on this instance
+func (m *Manager) runsHere(owner string) bool {
+	return owner == m.bus.InstanceID()
+}
+
+// releaseRoom lets another instance run the room once this instance is done with it
+func (m *Manager) releaseRoom(ctx context.Context, roomCode string) {
+	l := logs.GetLoggerctx(ctx)
+	err := m.bus.ReleaseRoom(ctx, roomCode)
+	if err != nil {
+		l.Sugar().Errorf("release room %s failed %v", roomCode, err)
+	}
+}
+
+// releaseIdleRoom gives up the claim of a room this instance has no clients, no joining connections
+// and no running game of. a suspended player of a running game comes back to this instance
+func (m *Manager) releaseIdleRoom(ctx context.Context, roomCode string) {
+	m.RLock()
+	gameState, exists := m.gameStates[roomCode]
+	idle := len(m.clients[roomCode]) == 0 && m.joining[roomCode] == 0 && (!exists || gameState.RoomStatus != roommodel.Started)
+	m.RUnlock()
+	if idle {
+		m.releaseRoom(ctx, roomCode)
+	}
+}
+
+// broadcast publishes the event to all the human clients of the room on every instance
+func (m *Manager) broadcast(ctx context.Context, roomCode string, event Event) {
+	l := logs.GetLoggerctx(ctx)
+	err := m.bus.Publish(ctx, roomCode, event)
+	if err != nil {
+		l.Sugar().Errorf("publish %s event to room %s failed %v", event.Type, roomCode, err)
+	}
+}
+
+// deliverLocal is the bus subscriber, it hands over the event to the clients connected to this instance.
+// generation job updates are also tracked here since the job may run on another instance, the same
+// goes for the kicks of the room owner since the kicked player may be connected to another instance
+func (m *Manager) deliverLocal(ctx context.Context, roomCode string, event Event) {
+	// a team chat message only goes to the members of that team
+	team := chatTeam(event)
+	m.RLock()
+	clients := make([]*Client, 0, len(m.clients[roomCode]))
+	for client := range m.clients[roomCode] {
+		if team > 0 && m.teamOf(roomCode, client.userID) != team {
+			continue
+		}
+		if client.isBot || client.connection == nil || !client.receives(event) {
+			continue
+		}
+		clients = append(clients, client)
+	}
+	m.RUnlock()
+
+	for _, client := range clients {
+		m.sendToClient(ctx, client, m.personalize(client, event))
+	}
+
+	if event.Type == EventGenerationJob {
+		m.trackGenerationJob(ctx, roomCode, event)
+	}
+	if event.Type == EventHostAction {
+		m.dropKickedClients(roomCode, event)
+	}
+}
+
+// sendToClient hands the event to the writer of the client without waiting. a client whose egress is
+// full cannot keep up, his connection is closed so he resumes instead of stalling every other room
+func (m *Manager) sendToClient(ctx context.Context, client *Client, event Event) {
+	l := logs.GetLoggerctx(ctx)
+	select {
+	case client.egress <- event:
+	default:
+		l.Sugar().Errorf("client %s of room %s is too slow, dropping his connection", client.userID, client.roomCode)
+		if client.connection != nil {
+			client.connection.Close()
+		}
+	}
+}
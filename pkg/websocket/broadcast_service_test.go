@@ -0,0 +1,129 @@
+package websocket
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryBusDeliversToEverySubscriber(t *testing.T) {
+	ctx := testContext()
+	bus := newMemoryBus()
+
+	var mu sync.Mutex
+	got := map[int][]string{}
+	for i := 0; i < 2; i++ {
+		subscriber := i
+		err := bus.Subscribe(ctx, func(ctx context.Context, roomCode string, event Event) {
+			mu.Lock()
+			got[subscriber] = append(got[subscriber], roomCode+":"+event.Type)
+			mu.Unlock()
+		})
+		if err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+	}
+
+	err := bus.Publish(ctx, "room-1", Event{Type: EventLeaderBoard})
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	for subscriber := 0; subscriber < 2; subscriber++ {
+		if len(got[subscriber]) != 1 || got[subscriber][0] != "room-1:"+EventLeaderBoard {
+			t.Errorf("subscriber %d got %v", subscriber, got[subscriber])
+		}
+	}
+}
+
+func TestMemoryBusClaimsEveryRoom(t *testing.T) {
+	ctx := testContext()
+	bus := newMemoryBus()
+	for _, roomCode := range []string{"room-1", "room-1", "room-2"} {
+		owner, err := bus.ClaimRoom(ctx, roomCode)
+		if err != nil || owner != bus.InstanceID() {
+			t.Errorf("claim %s = %q, %v; want %q", roomCode, owner, err, bus.InstanceID())
+		}
+	}
+}
+
+// a fake bus whose rooms are run by another instance
+type claimedElsewhereBus struct {
+	memoryBus
+}
+
+func (b *claimedElsewhereBus) ClaimRoom(ctx context.Context, roomCode string) (string, error) {
+	return "elsewhere", nil
+}
+
+func TestClaimRoomFollowsTheBus(t *testing.T) {
+	m := newTestManager(t)
+	if !m.runsHere(m.claimRoom(testContext(), "room-1")) {
+		t.Errorf("the memory bus should let this instance run the room")
+	}
+	m.bus = &claimedElsewhereBus{}
+	if m.runsHere(m.claimRoom(testContext(), "room-1")) {
+		t.Errorf("a room claimed by another instance should not be run here")
+	}
+}
+
+func TestBroadcastReachesTheClientsOfTheRoom(t *testing.T) {
+	m := newTestManager(t)
+	ctx := testContext()
+	inRoom, _ := addTestClient(t, m, "room-1")
+	otherRoom, _ := addTestClient(t, m, "room-2")
+
+	m.broadcast(ctx, "room-1", Event{Type: EventLeaderBoard})
+
+	select {
+	case event := <-inRoom.egress:
+		if event.Type != EventLeaderBoard {
+			t.Errorf("got %s event, want %s", event.Type, EventLeaderBoard)
+		}
+	default:
+		t.Fatalf("the client of the room did not get the event")
+	}
+	select {
+	case event := <-otherRoom.egress:
+		t.Errorf("the client of another room got %s", event.Type)
+	default:
+	}
+}
+
+func TestSlowClientDoesNotStallTheBroadcast(t *testing.T) {
+	m := newTestManager(t)
+	ctx := testContext()
+	slow, slowPeer := addTestClient(t, m, "room-1")
+	fast, _ := addTestClient(t, m, "room-1")
+
+	// nobody drains the slow client, his egress fills up
+	for i := 0; i < cap(slow.egress); i++ {
+		slow.egress <- Event{Type: EventChatMessage}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.broadcast(ctx, "room-1", Event{Type: EventLeaderBoard})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("broadcast blocked on the slow client")
+	}
+
+	select {
+	case event := <-fast.egress:
+		if event.Type != EventLeaderBoard {
+			t.Errorf("fast client got %s, want %s", event.Type, EventLeaderBoard)
+		}
+	default:
+		t.Errorf("fast client did not get the event")
+	}
+
+	// the slow client is disconnected so he can resume instead
+	slowPeer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := slowPeer.ReadMessage(); err == nil {
+		t.Errorf("the connection of the slow client should be closed")
+	}
+}
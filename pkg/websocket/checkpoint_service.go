@@ -0,0 +1,175 @@
+// The game state of a room lives in the manager's memory while the game runs.
+// At every question transition we checkpoint it (along with every player's answer history which
+// is not yet written to the answer table) so that a deploy or a crash doesn't lose the game.
+// On boot the manager reloads the rooms which are still STARTED and the players can reconnect
+// and continue from the question they were on.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// checkpointGameState stores the current game state of the room in the database
+func (m *Manager) checkpointGameState(ctx context.Context, roomCode string) {
+	l := logs.GetLoggerctx(ctx)
+
+	m.RLock()
+	gameState, exists := m.gameStates[roomCode]
+	if !exists {
+		m.RUnlock()
+		return
+	}
+	// copy everything under the lock since answers keep coming in while we write to the db
+	snapshot := *gameState
+	snapshot.Participants = append([]quizmodel.Participant{}, gameState.Participants...)
+
+	history := map[uuid.UUID]quizmodel.AnswerHistory{}
+	// players who have not reconnected yet after a restore still have their answers here
+	for userID, ansHistory := range m.restoredHistory[roomCode] {
+		history[userID] = copyAnswerHistory(ansHistory)
+	}
+	for userID, s := range m.suspended[roomCode] {
+		history[userID] = copyAnswerHistory(s.client.ansHistory)
+	}
+	for client := range m.clients[roomCode] {
+		if client.isSpectator() && !client.eliminated {
+			continue
+		}
+		history[client.userID] = copyAnswerHistory(client.ansHistory)
+	}
+	for botID, bot := range m.botClients[roomCode] {
+		history[botID] = copyAnswerHistory(bot.ansHistory)
+	}
+	m.RUnlock()
+
+	err := m.quizService.SaveGameCheckpoint(ctx, &quizmodel.GameCheckpoint{
+		RoomCode:      roomCode,
+		GameState:     &snapshot,
+		AnswerHistory: history,
+	})
+	if err != nil {
+		l.Sugar().Errorf("checkpoint game state for room %s failed %v", roomCode, err)
+	}
+}
+
+// restoreGameStates reloads the rooms which were running when the server went down
+func (m *Manager) restoreGameStates(ctx context.Context) {
+	l := logs.GetLoggerctx(ctx)
+
+	checkpoints, err := m.quizService.ListGameCheckpointsByRoomStatus(ctx, roommodel.Started)
+	if err != nil {
+		l.Sugar().Error("list game checkpoints failed", err)
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	for _, checkpoint := range checkpoints {
+		gameState := checkpoint.GameState
+		if gameState.Questions == nil || len(gameState.Questions.QuestionData) == 0 {
+			continue
+		}
+		// another instance that is still up restores the room
+		if !m.runsHere(m.claimRoom(ctx, checkpoint.RoomCode)) {
+			continue
+		}
+		m.gameStates[checkpoint.RoomCode] = gameState
+		m.restoredHistory[checkpoint.RoomCode] = checkpoint.AnswerHistory
+		// the timer is started again only when the first player comes back
+		m.resumePending[checkpoint.RoomCode] = true
+		l.Sugar().Infof("restored game state of room %s at question %d", checkpoint.RoomCode, gameState.CurrentQuestionIndex+1)
+	}
+}
+
+// canResume tells if the user can reconnect to a restored room which has already started
+func (m *Manager) canResume(roomCode string, userID uuid.UUID) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	histories, restored := m.restoredHistory[roomCode]
+	if !restored {
+		return false
+	}
+	for client := range m.clients[roomCode] {
+		if client.userID == userID {
+			return false // already playing from another tab
+		}
+	}
+	if _, ok := histories[userID]; ok {
+		return true
+	}
+	for _, participant := range m.gameStates[roomCode].Participants {
+		if participant.UserID == userID && !participant.IsExited {
+			return true
+		}
+	}
+	return false
+}
+
+// resumeRestoredGame gives the player back his answers and continues the game from the current question
+func (m *Manager) resumeRestoredGame(ctx context.Context, c *Client, roomDetails *roommodel.Room) error {
+	m.Lock()
+	if ansHistory, ok := m.restoredHistory[c.roomCode][c.userID]; ok {
+		c.ansHistory = ansHistory
+		delete(m.restoredHistory[c.roomCode], c.userID)
+	}
+	firstResume := m.resumePending[c.roomCode]
+	delete(m.resumePending, c.roomCode)
+	m.Unlock()
+
+	if !firstResume {
+		// game is already running again, only this player needs the current question
+		return m.sendCurrentQuestion(ctx, c)
+	}
+
+	// bots lived only in memory so they have to be set up again
+	m.setupBotsForRoom(ctx, c.roomCode, roomDetails)
+	m.Lock()
+	for botID, bot := range m.botClients[c.roomCode] {
+		if ansHistory, ok := m.restoredHistory[c.roomCode][botID]; ok {
+			bot.ansHistory = ansHistory
+			delete(m.restoredHistory[c.roomCode], botID)
+		}
+	}
+	m.Unlock()
+
+	// the question which was running during the restart is asked again with a fresh timer
+	return sendNextQuestion(ctx, m, c.roomCode)
+}
+
+// clearGameCheckpoint stores the answers of the players who never came back after a restore
+// and removes the checkpoint once the game has ended
+func (m *Manager) clearGameCheckpoint(ctx context.Context, roomCode string) error {
+	m.Lock()
+	histories := m.restoredHistory[roomCode]
+	delete(m.restoredHistory, roomCode)
+	delete(m.resumePending, roomCode)
+	m.Unlock()
+
+	for _, ansHistory := range histories {
+		err := m.updateAnswerHistory(ctx, ansHistory)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.quizService.DeleteGameCheckpoint(ctx, roomCode)
+}
+
+func copyAnswerHistory(ansHistory quizmodel.AnswerHistory) quizmodel.AnswerHistory {
+	copied := quizmodel.AnswerHistory{}
+	for questionID, answers := range ansHistory {
+		copied[questionID] = make(map[uuid.UUID]*quizmodel.AnswerReq)
+		for userID, answer := range answers {
+			a := *answer
+			copied[questionID][userID] = &a
+		}
+	}
+	return copied
+}
@@ -0,0 +1,100 @@
+package websocket
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// checkpointRow is a row of the game_state table in the order ListGameStatesByRoomStatus scans it
+func checkpointRow(t *testing.T, gameState *quizmodel.GameState, history map[uuid.UUID]quizmodel.AnswerHistory) []any {
+	t.Helper()
+	state, err := json.Marshal(gameState)
+	if err != nil {
+		t.Fatalf("marshal game state: %v", err)
+	}
+	ansHistory, err := json.Marshal(history)
+	if err != nil {
+		t.Fatalf("marshal answer history: %v", err)
+	}
+	return []any{gameState.RoomCode, string(gameState.RoomStatus), state, ansHistory, pgtype.Timestamp{}, pgtype.Timestamp{}, "", ""}
+}
+
+func TestRestoreGameStatesReloadsStartedGames(t *testing.T) {
+	db := dbtest.New()
+	m := newTestManager(t)
+	m.quizService = quiz.NewService(db, nil)
+
+	playerID := uuid.New()
+	questionID := uuid.New()
+	running := &quizmodel.GameState{
+		RoomCode:             "RUNNING",
+		RoomStatus:           roommodel.Started,
+		CurrentQuestionIndex: 2,
+		Questions: &quizmodel.Question{
+			RoomCode:     "RUNNING",
+			QuestionData: []*quizmodel.QuestionData{{ID: questionID}, {ID: uuid.New()}, {ID: uuid.New()}},
+		},
+	}
+	history := map[uuid.UUID]quizmodel.AnswerHistory{
+		playerID: {questionID: {playerID: {QuestionDataID: questionID, AnswerOption: 1}}},
+	}
+	// a game which was stopped before its questions were loaded has nothing to continue from
+	empty := &quizmodel.GameState{RoomCode: "EMPTY", RoomStatus: roommodel.Started, Questions: &quizmodel.Question{}}
+	db.Returns("ListGameStatesByRoomStatus",
+		checkpointRow(t, running, history),
+		checkpointRow(t, empty, nil),
+	)
+
+	m.restoreGameStates(testContext())
+
+	calls := db.Calls("ListGameStatesByRoomStatus")
+	if len(calls) != 1 || calls[0].Args[0] != string(roommodel.Started) {
+		t.Fatalf("checkpoints listed with %+v, want one call for %s", calls, roommodel.Started)
+	}
+	restored, ok := m.gameStates["RUNNING"]
+	if !ok {
+		t.Fatal("the running game was not restored")
+	}
+	if restored.CurrentQuestionIndex != 2 || len(restored.Questions.QuestionData) != 3 {
+		t.Errorf("restored at question %d with %d questions, want 2 with 3", restored.CurrentQuestionIndex, len(restored.Questions.QuestionData))
+	}
+	if !m.resumePending["RUNNING"] {
+		t.Error("the timer of the restored game should wait for the first player")
+	}
+	if answer := m.restoredHistory["RUNNING"][playerID][questionID][playerID]; answer == nil || answer.AnswerOption != 1 {
+		t.Errorf("answer history of the player was not restored, got %+v", answer)
+	}
+	if !m.canResume("RUNNING", playerID) {
+		t.Error("the player with answers should be able to resume")
+	}
+	if _, ok := m.gameStates["EMPTY"]; ok {
+		t.Error("a game without questions should not be restored")
+	}
+}
+
+func TestRestoreGameStatesSkipsRoomsOwnedElsewhere(t *testing.T) {
+	db := dbtest.New()
+	m := newTestManager(t)
+	m.quizService = quiz.NewService(db, nil)
+	m.bus = &claimedElsewhereBus{}
+
+	gameState := &quizmodel.GameState{
+		RoomCode:   "ELSEWHERE",
+		RoomStatus: roommodel.Started,
+		Questions:  &quizmodel.Question{QuestionData: []*quizmodel.QuestionData{{ID: uuid.New()}}},
+	}
+	db.Returns("ListGameStatesByRoomStatus", checkpointRow(t, gameState, nil))
+
+	m.restoreGameStates(testContext())
+
+	if _, ok := m.gameStates["ELSEWHERE"]; ok {
+		t.Error("a room claimed by another instance should be restored there")
+	}
+}
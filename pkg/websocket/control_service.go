@@ -0,0 +1,337 @@
+// The owner of the room controls the game: he can kick or ban a member, lock the room for new members,
+// pause and resume the question timer, skip the current question and end the game early. Every action is checked against
+// the owner of the room and announced to the room with a host_action event.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	HostActionKick   = "kick"
+	HostActionBan    = "ban"
+	HostActionPause  = "pause"
+	HostActionResume = "resume"
+	HostActionSkip   = "skip"
+	HostActionEnd    = "end"
+	HostActionLock   = "lock"
+	HostActionUnlock = "unlock"
+)
+
+// kickClientDelay gives the kicked client the time to get the host_action event before his connection is closed
+var kickClientDelay = time.Second
+
+type kickMemberReq struct {
+	UserID uuid.UUID `json:"userId"`
+	Ban    bool      `json:"ban"` // banned members cannot join the room again
+}
+
+type lockRoomReq struct {
+	Locked bool `json:"locked"`
+}
+
+type hostActionEvent struct {
+	Action   string    `json:"action"`
+	Message  string    `json:"message"`
+	UserID   uuid.UUID `json:"userId,omitempty"` // member who was kicked or banned
+	TimeLeft int       `json:"timeLeft"`         // seconds left for the current question after a pause or resume
+}
+
+// isRoomOwner tells if the client created the room
+func (c *Client) isRoomOwner() bool {
+	return c.room != nil && c.room.CreatedBy == c.userID.String()
+}
+
+// authorizeOwner lets only the owner of the room use the host controls
+func authorizeOwner(c *Client) bool {
+	if !c.isRoomOwner() {
+		sendGameError("only the owner of the room can do this", c)
+		return false
+	}
+	return true
+}
+
+// sendHostAction announces the action to the room, the bots hold back their answers while the game is paused
+func (m *Manager) sendHostAction(ctx context.Context, roomCode string, action hostActionEvent) {
+	data, _ := json.Marshal(action)
+	event := Event{Type: EventHostAction, Payload: data}
+	m.broadcast(ctx, roomCode, event)
+	m.broadcastToBots(ctx, roomCode, event)
+}
+
+// KickMemberHandler removes a member from the room, a banned member cannot come back
+func KickMemberHandler(ctx context.Context, event Event, c *Client) error {
+	l := logs.GetLoggerctx(ctx)
+	if !authorizeOwner(c) {
+		return nil
+	}
+
+	req := kickMemberReq{}
+	err := json.Unmarshal(event.Payload, &req)
+	if err != nil {
+		l.Sugar().Error("bad payload", err)
+		return fmt.Errorf("bad payload: %v", err)
+	}
+	if req.UserID == c.userID {
+		sendGameError("you cannot kick yourself", c)
+		return nil
+	}
+
+	member, err := c.manager.roomService.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
+		UserID:   req.UserID,
+		RoomCode: c.roomCode,
+	})
+	if err != nil {
+		l.Sugar().Error("get room member by room and user id failed", err)
+		return err
+	}
+	if member == nil || member.IsBot {
+		sendGameError("only players of the room can be kicked", c)
+		return nil
+	}
+
+	action, status, verb := HostActionKick, roommodel.KickedQuiz, "kicked"
+	if req.Ban {
+		action, status, verb = HostActionBan, roommodel.BannedQuiz, "banned"
+	}
+	err = c.manager.roomService.UpdateRoomMemberStatusByRoomCodeAndUserID(ctx, &roommodel.RoomCodeReq{
+		UserID:   req.UserID,
+		RoomCode: c.roomCode,
+	}, status)
+	if err != nil {
+		l.Sugar().Error("update kicked member status failed", err)
+		return err
+	}
+
+	// the kicked player is out of the game, his score stays on the leaderboard
+	c.manager.Lock()
+	if gameState, exists := c.manager.gameStates[c.roomCode]; exists {
+		for i := range gameState.Participants {
+			if gameState.Participants[i].UserID == req.UserID {
+				gameState.Participants[i].IsExited = true
+			}
+		}
+	}
+	c.manager.Unlock()
+
+	c.manager.sendHostAction(ctx, c.roomCode, hostActionEvent{
+		Action:  action,
+		Message: fmt.Sprintf("%s was %s by the host", member.UserDetails.UserName, verb),
+		UserID:  req.UserID,
+	})
+	return nil
+}
+
+// dropKickedClients closes the connections the kicked member holds on this instance
+func (m *Manager) dropKickedClients(roomCode string, event Event) {
+	action := hostActionEvent{}
+	err := json.Unmarshal(event.Payload, &action)
+	if err != nil || (action.Action != HostActionKick && action.Action != HostActionBan) {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	for client := range m.clients[roomCode] {
+		if client.userID != action.UserID || client.isSpectator() {
+			continue
+		}
+		client.kicked = true
+		delete(m.clients[roomCode], client)
+		if client.connection != nil {
+			time.AfterFunc(kickClientDelay, func() { client.connection.Close() })
+		}
+	}
+	if s, exists := m.suspended[roomCode][action.UserID]; exists {
+		s.timer.Stop()
+		delete(m.suspended[roomCode], action.UserID)
+	}
+}
+
+// LockRoomHandler locks or unlocks the room, nobody new can join a locked room. the members keep their seats
+func LockRoomHandler(ctx context.Context, event Event, c *Client) error {
+	l := logs.GetLoggerctx(ctx)
+	if !authorizeOwner(c) {
+		return nil
+	}
+
+	req := lockRoomReq{}
+	err := json.Unmarshal(event.Payload, &req)
+	if err != nil {
+		l.Sugar().Error("bad payload", err)
+		return fmt.Errorf("bad payload: %v", err)
+	}
+	err = c.manager.roomService.SetRoomLock(ctx, c.roomCode, req.Locked, c.userID.String())
+	if err != nil {
+		l.Sugar().Error("update room lock failed", err)
+		return err
+	}
+
+	action, message := HostActionUnlock, "The host unlocked the room"
+	if req.Locked {
+		action, message = HostActionLock, "The host locked the room, nobody new can join"
+	}
+	c.manager.sendHostAction(ctx, c.roomCode, hostActionEvent{
+		Action:  action,
+		Message: message,
+	})
+	return nil
+}
+
+// PauseGameHandler stops the timer of the current question, the time left is kept for the resume
+func PauseGameHandler(ctx context.Context, event Event, c *Client) error {
+	if !authorizeOwner(c) {
+		return nil
+	}
+
+	c.manager.Lock()
+	gameState, err := runningGameState(c)
+	if err == nil && !gameState.PausedAt.IsZero() {
+		err = errors.New("the game is already paused")
+	}
+	if err != nil {
+		c.manager.Unlock()
+		sendGameError(err.Error(), c)
+		return nil
+	}
+	gameState.PausedAt = time.Now()
+	timeLeft := questionTimeLeft(gameState)
+	if timer, exists := c.manager.questionTimers[c.roomCode]; exists {
+		timer.Stop()
+	}
+	c.manager.Unlock()
+
+	c.manager.checkpointGameState(ctx, c.roomCode)
+	c.manager.sendHostAction(ctx, c.roomCode, hostActionEvent{
+		Action:   HostActionPause,
+		Message:  "The host paused the game",
+		TimeLeft: int(timeLeft.Seconds()),
+	})
+	return nil
+}
+
+// ResumeGameHandler starts the timer of the current question again with the time that was left
+func ResumeGameHandler(ctx context.Context, event Event, c *Client) error {
+	if !authorizeOwner(c) {
+		return nil
+	}
+
+	c.manager.Lock()
+	gameState, err := runningGameState(c)
+	if err == nil && gameState.PausedAt.IsZero() {
+		err = errors.New("the game is not paused")
+	}
+	if err != nil {
+		c.manager.Unlock()
+		sendGameError(err.Error(), c)
+		return nil
+	}
+	// the paused time does not count for the question
+	gameState.QuestionStartTime = gameState.QuestionStartTime.Add(time.Since(gameState.PausedAt))
+	gameState.PausedAt = time.Time{}
+	timeLeft := questionTimeLeft(gameState)
+	currentIndex := gameState.CurrentQuestionIndex
+	c.manager.Unlock()
+
+	c.manager.startQuestionTimer(ctx, c.roomCode, currentIndex, timeLeft)
+	c.manager.checkpointGameState(ctx, c.roomCode)
+	c.manager.sendHostAction(ctx, c.roomCode, hostActionEvent{
+		Action:   HostActionResume,
+		Message:  "The host resumed the game",
+		TimeLeft: int(timeLeft.Seconds()),
+	})
+	return nil
+}
+
+// SkipQuestionHandler closes the current question and moves the room to the next one
+func SkipQuestionHandler(ctx context.Context, event Event, c *Client) error {
+	if !authorizeOwner(c) {
+		return nil
+	}
+
+	c.manager.Lock()
+	gameState, err := runningGameState(c)
+	if err != nil {
+		c.manager.Unlock()
+		sendGameError(err.Error(), c)
+		return nil
+	}
+	skipped := gameState.CurrentQuestionIndex
+	gameState.CurrentQuestionIndex++
+	c.manager.Unlock()
+
+	c.manager.sendHostAction(ctx, c.roomCode, hostActionEvent{
+		Action:  HostActionSkip,
+		Message: fmt.Sprintf("The host skipped question %d", skipped+1),
+	})
+	advanceQuestion(ctx, c.manager, c.roomCode, skipped)
+	return nil
+}
+
+// EndGameEarlyHandler ends the game with the scores the players have right now
+func EndGameEarlyHandler(ctx context.Context, event Event, c *Client) error {
+	if !authorizeOwner(c) {
+		return nil
+	}
+
+	c.manager.Lock()
+	gameState, err := runningGameState(c)
+	if err != nil {
+		c.manager.Unlock()
+		sendGameError(err.Error(), c)
+		return nil
+	}
+	current := gameState.CurrentQuestionIndex
+	gameState.CurrentQuestionIndex = len(gameState.Questions.QuestionData)
+	c.manager.Unlock()
+
+	c.manager.sendHostAction(ctx, c.roomCode, hostActionEvent{
+		Action:  HostActionEnd,
+		Message: "The host ended the game",
+	})
+	advanceQuestion(ctx, c.manager, c.roomCode, current)
+	return nil
+}
+
+// runningGameState returns the game state of the client's room if a question is running, caller must hold the manager lock
+func runningGameState(c *Client) (*quizmodel.GameState, error) {
+	gameState, exists := c.manager.gameStates[c.roomCode]
+	if !exists || gameState.RoomStatus != roommodel.Started || gameState.Questions == nil ||
+		gameState.CurrentQuestionIndex >= len(gameState.Questions.QuestionData) {
+		return nil, errors.New("there is no question running in this room")
+	}
+	if gameState.Revealing {
+		return nil, errors.New("wait for the next question, the answer is being revealed")
+	}
+	return gameState, nil
+}
+
+// questionElapsed is how long the current question has been running, the paused time left out.
+// caller must hold the manager lock
+func questionElapsed(gameState *quizmodel.GameState) time.Duration {
+	now := time.Now()
+	if !gameState.PausedAt.IsZero() {
+		now = gameState.PausedAt
+	}
+	return now.Sub(gameState.QuestionStartTime)
+}
+
+// questionTimeLeft is the time left to answer the current question, caller must hold the manager lock
+func questionTimeLeft(gameState *quizmodel.GameState) time.Duration {
+	timeLimit := gameState.Questions.QuestionTimeLimit(gameState.CurrentQuestionIndex)
+	timeLeft := timeLimit - questionElapsed(gameState)
+	if timeLeft < 0 {
+		return 0
+	}
+	return timeLeft
+}
@@ -0,0 +1,326 @@
+package websocket
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	"brainwars/pkg/room"
+	roommodel "brainwars/pkg/room/model"
+	usermodel "brainwars/pkg/users/model"
+	"brainwars/pkg/util"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// roomOwner puts the owner of the room in it, the context is the one of his requests
+func roomOwner(t *testing.T, m *Manager, roomCode string) (*Client, context.Context) {
+	t.Helper()
+	owner, _ := addTestClient(t, m, roomCode)
+	owner.room.CreatedBy = owner.userID.String()
+	return owner, util.SetUserInfoInctx(testContext(), &usermodel.UserInfo{ID: owner.userID, UserName: "owner"})
+}
+
+// nextEventOf takes the events the client got till one of the type
+func nextEventOf(t *testing.T, c *Client, eventType string) (Event, bool) {
+	t.Helper()
+	for {
+		select {
+		case event := <-c.egress:
+			if event.Type == eventType {
+				return event, true
+			}
+		default:
+			return Event{}, false
+		}
+	}
+}
+
+// hostActionOf is the host_action the client got
+func hostActionOf(t *testing.T, c *Client) hostActionEvent {
+	t.Helper()
+	event, ok := nextEventOf(t, c, EventHostAction)
+	if !ok {
+		t.Fatalf("no %s event", EventHostAction)
+	}
+	action := hostActionEvent{}
+	if err := json.Unmarshal(event.Payload, &action); err != nil {
+		t.Fatalf("host action json: %v", err)
+	}
+	return action
+}
+
+// jsonEvent is the event with the payload
+func jsonEvent(t *testing.T, eventType string, payload any) Event {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", eventType, err)
+	}
+	return Event{Type: eventType, Payload: data}
+}
+
+func TestKickMember(t *testing.T) {
+	tests := []struct {
+		name       string
+		byOwner    bool
+		ban        bool
+		member     bool // the user is a member of the room
+		wantAction string
+		wantStatus roommodel.RoomMemberStatus
+	}{
+		{name: "kick", byOwner: true, member: true, wantAction: HostActionKick, wantStatus: roommodel.KickedQuiz},
+		{name: "ban", byOwner: true, ban: true, member: true, wantAction: HostActionBan, wantStatus: roommodel.BannedQuiz},
+		{name: "not the owner", member: true},
+		{name: "not a member", byOwner: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			owner, ctx := roomOwner(t, m, "room-1")
+			if !tt.byOwner {
+				owner.room.CreatedBy = uuid.NewString()
+			}
+			player, _ := addTestClient(t, m, "room-1")
+			gameState := startedGame(m, "room-1", &quizmodel.QuestionData{ID: uuid.New()})
+			gameState.Participants = []quizmodel.Participant{{UserID: player.userID, Username: "player"}}
+			db := dbtest.New()
+			db.Returns("GetRoomByRoomCode", roomRow("room-1", roommodel.MP, roommodel.Started, false, false))
+			if tt.member {
+				db.Returns("GetRoomMemberByRoomCodeAndUserID", memberRow("room-1", player.userID, "player", roommodel.JoinQuiz, roommodel.Player))
+			}
+			m.roomService = room.NewService(db, nil, nil)
+
+			err := KickMemberHandler(ctx, jsonEvent(t, EventKickMember, kickMemberReq{UserID: player.userID, Ban: tt.ban}), owner)
+			if err != nil {
+				t.Fatalf("kick member: %v", err)
+			}
+
+			updates := db.Calls("UpdateRoomMemberByRoomCodeAndUserID")
+			if tt.wantAction == "" {
+				if _, ok := nextEventOf(t, owner, EventGameError); !ok {
+					t.Errorf("the owner should be told why the kick was turned down")
+				}
+				if len(updates) != 0 || gameState.Participants[0].IsExited || clientCount(m, "room-1") != 2 {
+					t.Errorf("the player should stay in the room")
+				}
+				return
+			}
+			if len(updates) != 1 || updates[0].Args[1] != string(tt.wantStatus) {
+				t.Fatalf("got the member updates %+v, want the status %s", updates, tt.wantStatus)
+			}
+			if action := hostActionOf(t, owner); action.Action != tt.wantAction || action.UserID != player.userID {
+				t.Errorf("got the host action %+v, want %s of the player", action, tt.wantAction)
+			}
+			if !gameState.Participants[0].IsExited {
+				t.Errorf("the kicked player should be out of the game")
+			}
+			m.RLock()
+			_, connected := m.clients["room-1"][player]
+			m.RUnlock()
+			if connected || !player.kicked {
+				t.Errorf("the kicked player should be dropped from the room")
+			}
+		})
+	}
+}
+
+func TestLockRoom(t *testing.T) {
+	for _, locked := range []bool{true, false} {
+		m := newTestManager(t)
+		owner, ctx := roomOwner(t, m, "room-1")
+		db := dbtest.New()
+		m.roomService = room.NewService(db, nil, nil)
+
+		err := LockRoomHandler(ctx, jsonEvent(t, EventLockRoom, lockRoomReq{Locked: locked}), owner)
+		if err != nil {
+			t.Fatalf("lock room: %v", err)
+		}
+
+		calls := db.Calls("UpdateRoomLockByRoomCode")
+		if len(calls) != 1 || calls[0].Args[1] != locked {
+			t.Errorf("got the lock updates %+v, want the room locked %v", calls, locked)
+		}
+		want := HostActionUnlock
+		if locked {
+			want = HostActionLock
+		}
+		if action := hostActionOf(t, owner); action.Action != want {
+			t.Errorf("got the host action %s, want %s", action.Action, want)
+		}
+	}
+}
+
+func TestPauseAndResumeGame(t *testing.T) {
+	m := newTestManager(t)
+	m.quizService = quiz.NewService(dbtest.New(), nil)
+	owner, ctx := roomOwner(t, m, "room-1")
+	gameState := startedGame(m, "room-1", &quizmodel.QuestionData{ID: uuid.New()})
+	gameState.QuestionStartTime = time.Now().Add(-2 * time.Second)
+	m.startQuestionTimer(ctx, "room-1", 0, 8*time.Second)
+	t.Cleanup(func() { m.stopQuestionTimer("room-1") })
+
+	if err := ResumeGameHandler(ctx, Event{Type: EventResumeGame}, owner); err != nil {
+		t.Fatalf("resume game: %v", err)
+	}
+	if _, ok := nextEventOf(t, owner, EventGameError); !ok {
+		t.Errorf("a game which is not paused cannot be resumed")
+	}
+
+	if err := PauseGameHandler(ctx, Event{Type: EventPauseGame}, owner); err != nil {
+		t.Fatalf("pause game: %v", err)
+	}
+	m.RLock()
+	pausedAt, timer := gameState.PausedAt, m.questionTimers["room-1"]
+	m.RUnlock()
+	if pausedAt.IsZero() || timer.Stop() {
+		t.Errorf("the pause should stop the question timer")
+	}
+	if action := hostActionOf(t, owner); action.Action != HostActionPause || action.TimeLeft < 7 || action.TimeLeft > 8 {
+		t.Errorf("got the host action %+v, want a pause with 8 seconds left", action)
+	}
+	if err := PauseGameHandler(ctx, Event{Type: EventPauseGame}, owner); err != nil {
+		t.Fatalf("pause game: %v", err)
+	}
+	if _, ok := nextEventOf(t, owner, EventGameError); !ok {
+		t.Errorf("a paused game cannot be paused again")
+	}
+
+	// the pause lasted a minute, which does not count for the question
+	m.Lock()
+	gameState.QuestionStartTime = gameState.QuestionStartTime.Add(-time.Minute)
+	gameState.PausedAt = gameState.PausedAt.Add(-time.Minute)
+	m.Unlock()
+	if err := ResumeGameHandler(ctx, Event{Type: EventResumeGame}, owner); err != nil {
+		t.Fatalf("resume game: %v", err)
+	}
+	m.RLock()
+	pausedAt, timer, elapsed := gameState.PausedAt, m.questionTimers["room-1"], questionElapsed(gameState)
+	m.RUnlock()
+	if !pausedAt.IsZero() || elapsed < 2*time.Second || elapsed > 3*time.Second {
+		t.Errorf("the question ran for %s after the resume, want the 2 seconds before the pause", elapsed)
+	}
+	if !timer.Stop() {
+		t.Errorf("the resume should start the question timer again")
+	}
+	if action := hostActionOf(t, owner); action.Action != HostActionResume || action.TimeLeft < 7 || action.TimeLeft > 8 {
+		t.Errorf("got the host action %+v, want a resume with 8 seconds left", action)
+	}
+}
+
+func TestOnlyTheOwnerControlsTheGame(t *testing.T) {
+	handlers := map[string]EventHandler{
+		EventPauseGame:    PauseGameHandler,
+		EventResumeGame:   ResumeGameHandler,
+		EventSkipQuestion: SkipQuestionHandler,
+		EventEndGameEarly: EndGameEarlyHandler,
+	}
+	for name, handler := range handlers {
+		m := newTestManager(t)
+		gameState := startedGame(m, "room-1", &quizmodel.QuestionData{ID: uuid.New()}, &quizmodel.QuestionData{ID: uuid.New()})
+		player, _ := addTestClient(t, m, "room-1")
+
+		if err := handler(testContext(), Event{Type: name}, player); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		if _, ok := nextEventOf(t, player, EventGameError); !ok {
+			t.Errorf("%s: the player should be told only the owner can do this", name)
+		}
+		if !gameState.PausedAt.IsZero() || gameState.CurrentQuestionIndex != 0 {
+			t.Errorf("%s: the game of the player changed", name)
+		}
+	}
+}
+
+func TestSkipQuestionAndEndGameEarly(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    EventHandler
+		wantAction string
+		wantIndex  int
+	}{
+		{name: "skip", handler: SkipQuestionHandler, wantAction: HostActionSkip, wantIndex: 1},
+		{name: "end", handler: EndGameEarlyHandler, wantAction: HostActionEnd, wantIndex: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			owner, ctx := roomOwner(t, m, "room-1")
+			question := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1}
+			gameState := startedGame(m, "room-1", question, &quizmodel.QuestionData{ID: uuid.New()}, &quizmodel.QuestionData{ID: uuid.New()})
+			t.Cleanup(func() { m.stopQuestionTimer("room-1") })
+
+			if err := tt.handler(ctx, Event{}, owner); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+
+			if action := hostActionOf(t, owner); action.Action != tt.wantAction {
+				t.Errorf("got the host action %s, want %s", action.Action, tt.wantAction)
+			}
+			event, ok := nextEventOf(t, owner, EventQuestionReveal)
+			reveal := questionRevealEvent{}
+			if !ok || json.Unmarshal(event.Payload, &reveal) != nil || reveal.QuestionID != question.ID {
+				t.Errorf("the question the host left should be revealed, got %+v", reveal)
+			}
+			m.RLock()
+			index, revealing := gameState.CurrentQuestionIndex, gameState.Revealing
+			m.RUnlock()
+			if index != tt.wantIndex || !revealing {
+				t.Errorf("the room is on question %d revealing %v, want %d after the reveal", index, revealing, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func TestHostActionsReachTheBots(t *testing.T) {
+	m := newTestManager(t)
+	m.quizService = quiz.NewService(dbtest.New(), nil)
+	owner, ctx := roomOwner(t, m, "room-1")
+	startedGame(m, "room-1", &quizmodel.QuestionData{ID: uuid.New()})
+	bot := NewClient(nil, m, "room-1", true, usermodel.Sec10, uuid.New(), "bot", &roommodel.Room{RoomCode: "room-1"})
+	bot.botEvents = make(chan Event, 1)
+	m.addBot("room-1", bot)
+
+	if err := PauseGameHandler(ctx, Event{Type: EventPauseGame}, owner); err != nil {
+		t.Fatalf("pause game: %v", err)
+	}
+
+	select {
+	case event := <-bot.botEvents:
+		if event.Type != EventHostAction {
+			t.Errorf("the bot got %s, want %s", event.Type, EventHostAction)
+		}
+	default:
+		t.Errorf("the bot should hear about the pause")
+	}
+}
+
+func TestBotHoldsItsAnswerWhileTheGameIsPaused(t *testing.T) {
+	m := newTestManager(t)
+	question := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1, Options: []quizmodel.Options{{ID: 1, Option: "a"}, {ID: 2, Option: "b"}}}
+	gameState := startedGame(m, "room-1", question)
+	bot := NewClient(nil, m, "room-1", true, usermodel.Sec10, uuid.New(), "bot", &roommodel.Room{RoomCode: "room-1"})
+	ctx, cancel := context.WithCancel(testContext())
+	t.Cleanup(cancel)
+	m.InitializeBot(ctx, bot)
+	answers := func() int {
+		m.RLock()
+		defer m.RUnlock()
+		return len(gameState.Participants)
+	}
+
+	// the bot would answer a second into the question
+	bot.botEvents <- jsonEvent(t, EventNewQuestion, questionEvent{Question: question, TimeLimit: 10, TimeLeft: 1})
+	bot.botEvents <- jsonEvent(t, EventHostAction, hostActionEvent{Action: HostActionPause, TimeLeft: 1})
+	time.Sleep(1500 * time.Millisecond)
+	if answers() != 0 {
+		t.Fatalf("the bot answered while the game was paused")
+	}
+
+	bot.botEvents <- jsonEvent(t, EventHostAction, hostActionEvent{Action: HostActionResume, TimeLeft: 1})
+	eventually(t, "the bot to answer after the resume", func() bool { return answers() == 1 })
+}
@@ -0,0 +1,148 @@
+// In an elimination room the players drop out after every question. Whoever answered wrong or did not
+// answer is out, and when everyone got it right the slowest player goes. When nobody got it right
+// nobody goes. The players who are out watch the rest of the game as spectators and the game ends once
+// one player is left or the questions run out. The order they dropped out in goes with the end of the
+// game so the analysis page can show it.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type eliminationEvent struct {
+	QuestionIndex int                     `json:"questionIndex"`
+	Eliminated    []quizmodel.Elimination `json:"eliminated"`
+	Remaining     int                     `json:"remaining"` // players still in the game
+	Message       string                  `json:"message"`
+}
+
+// enterElimination puts every player and bot of the lobby on the participant list when an elimination
+// game starts, so a player who never answers drops out as well. caller must hold the manager lock
+func (m *Manager) enterElimination(roomCode string) {
+	gameState, exists := m.gameStates[roomCode]
+	if !exists {
+		return
+	}
+	gameState.Elimination = true
+	listed := map[uuid.UUID]bool{}
+	for _, participant := range gameState.Participants {
+		listed[participant.UserID] = true
+	}
+	enter := func(userID uuid.UUID, username string, isBot bool) {
+		if listed[userID] {
+			return
+		}
+		listed[userID] = true
+		gameState.Participants = append(gameState.Participants, quizmodel.Participant{
+			UserID:   userID,
+			Username: username,
+			IsBot:    isBot,
+			IsReady:  true,
+			Team:     m.assignTeam(roomCode, userID),
+		})
+	}
+	for client := range m.clients[roomCode] {
+		if !client.isHost() && !client.isSpectator() && !client.kicked {
+			enter(client.userID, client.UserName, false)
+		}
+	}
+	for userID, bot := range m.botClients[roomCode] {
+		enter(userID, fmt.Sprintf("Bot-%s", bot.botType), true)
+	}
+}
+
+// eliminatePlayers drops the players who lost the question at the index and tells the room. when one
+// player is left the game is moved past the last question so it ends after the reveal
+func eliminatePlayers(ctx context.Context, manager *Manager, roomCode string, questionIndex int) {
+	l := logs.GetLoggerctx(ctx)
+
+	manager.Lock()
+	gameState, exists := manager.gameStates[roomCode]
+	if !exists || !gameState.Elimination || questionIndex < 0 || questionIndex >= len(gameState.Questions.QuestionData) {
+		manager.Unlock()
+		return
+	}
+	question := gameState.Questions.QuestionData[questionIndex]
+	kind := quiz.GetQuestionKind(question.Type)
+
+	alive := []*quizmodel.Participant{}
+	losers := []quizmodel.Elimination{}
+	var slowest *quizmodel.Participant
+	for i := range gameState.Participants {
+		participant := &gameState.Participants[i]
+		if participant.Eliminated || participant.IsExited {
+			continue
+		}
+		alive = append(alive, participant)
+		switch {
+		case participant.LastAnsweredQestion != question.ID:
+			losers = append(losers, quizmodel.Elimination{UserID: participant.UserID, Username: participant.Username, Reason: quizmodel.EliminatedMissed})
+		case kind.Credit(question, participant.LastChoosenOption, participant.LastAnswerData) < 1:
+			losers = append(losers, quizmodel.Elimination{UserID: participant.UserID, Username: participant.Username, Reason: quizmodel.EliminatedWrong})
+		case slowest == nil || participant.LatencyMs > slowest.LatencyMs:
+			slowest = participant
+		}
+	}
+
+	switch {
+	case len(alive) <= 1 || len(losers) == len(alive):
+		// nobody got it right, everyone stays in
+		losers = nil
+	case len(losers) == 0:
+		losers = []quizmodel.Elimination{{UserID: slowest.UserID, Username: slowest.Username, Reason: quizmodel.EliminatedSlowest}}
+	}
+	out := map[uuid.UUID]bool{}
+	for i := range losers {
+		losers[i].Round = questionIndex + 1
+		out[losers[i].UserID] = true
+	}
+	for _, participant := range alive {
+		if out[participant.UserID] {
+			participant.Eliminated = true
+			participant.EliminatedRound = questionIndex + 1
+		}
+	}
+	gameState.Eliminations = append(gameState.Eliminations, losers...)
+	remaining := len(alive) - len(losers)
+	if remaining <= 1 {
+		gameState.CurrentQuestionIndex = len(gameState.Questions.QuestionData)
+	}
+	// the players who are out only watch from now on
+	for client := range manager.clients[roomCode] {
+		if out[client.userID] && client.role == roommodel.Player {
+			client.role = roommodel.Spectator
+			client.eliminated = true
+		}
+	}
+	manager.Unlock()
+
+	if len(losers) == 0 {
+		return
+	}
+	message := fmt.Sprintf("%d players are out, %d left", len(losers), remaining)
+	if len(losers) == 1 {
+		message = fmt.Sprintf("%s is out, %d left", losers[0].Username, remaining)
+	}
+	data, err := json.Marshal(eliminationEvent{
+		QuestionIndex: questionIndex + 1,
+		Eliminated:    losers,
+		Remaining:     remaining,
+		Message:       message,
+	})
+	if err != nil {
+		l.Sugar().Error("elimination json marshal failed", err)
+		return
+	}
+	l.Sugar().Infof("room %s: %s", roomCode, message)
+	manager.broadcast(ctx, roomCode, Event{Type: EventElimination, Payload: data})
+	manager.sendSpectatorCount(ctx, roomCode)
+}
@@ -0,0 +1,123 @@
+package websocket
+
+import (
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// answered is a participant who answered the question with the option in the time
+func answered(name string, question *quizmodel.QuestionData, option int, latencyMs int) quizmodel.Participant {
+	return quizmodel.Participant{
+		UserID:              uuid.New(),
+		Username:            name,
+		LastAnsweredQestion: question.ID,
+		LastChoosenOption:   option,
+		LatencyMs:           latencyMs,
+	}
+}
+
+func TestEliminatePlayers(t *testing.T) {
+	question := &quizmodel.QuestionData{ID: uuid.New(), Question: "What is 7 times 8?", Answer: 4}
+	missed := quizmodel.Participant{UserID: uuid.New(), Username: "missed"}
+
+	tests := []struct {
+		name         string
+		participants []quizmodel.Participant
+		out          map[string]quizmodel.EliminationReason
+		ended        bool
+	}{
+		{
+			name:         "wrong and missed answers go",
+			participants: []quizmodel.Participant{answered("right", question, 4, 900), answered("wrong", question, 1, 100), missed, answered("also right", question, 4, 500)},
+			out:          map[string]quizmodel.EliminationReason{"wrong": quizmodel.EliminatedWrong, "missed": quizmodel.EliminatedMissed},
+		},
+		{
+			name:         "the slowest goes when everyone is right",
+			participants: []quizmodel.Participant{answered("fast", question, 4, 100), answered("slow", question, 4, 900), answered("middle", question, 4, 500)},
+			out:          map[string]quizmodel.EliminationReason{"slow": quizmodel.EliminatedSlowest},
+		},
+		{
+			name:         "nobody goes when nobody is right",
+			participants: []quizmodel.Participant{answered("wrong", question, 1, 100), missed},
+			out:          map[string]quizmodel.EliminationReason{},
+		},
+		{
+			name:         "the game ends with one player left",
+			participants: []quizmodel.Participant{answered("winner", question, 4, 900), answered("wrong", question, 2, 100)},
+			out:          map[string]quizmodel.EliminationReason{"wrong": quizmodel.EliminatedWrong},
+			ended:        true,
+		},
+		{
+			name: "players who are out already are left alone",
+			participants: []quizmodel.Participant{
+				answered("fast", question, 4, 100), answered("slow", question, 4, 900),
+				{UserID: uuid.New(), Username: "gone", Eliminated: true, EliminatedRound: 1},
+				{UserID: uuid.New(), Username: "exited", IsExited: true},
+			},
+			out:   map[string]quizmodel.EliminationReason{"slow": quizmodel.EliminatedSlowest},
+			ended: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			m.gameStates["room-1"] = &quizmodel.GameState{
+				RoomCode:     "room-1",
+				RoomStatus:   roommodel.Started,
+				Elimination:  true,
+				Questions:    &quizmodel.Question{QuestionData: []*quizmodel.QuestionData{{ID: uuid.New()}, question, {ID: uuid.New()}}},
+				Participants: tt.participants,
+			}
+
+			eliminatePlayers(testContext(), m, "room-1", 1)
+
+			gameState := m.gameStates["room-1"]
+			if len(gameState.Eliminations) != len(tt.out) {
+				t.Fatalf("eliminated %+v, want %v", gameState.Eliminations, tt.out)
+			}
+			for _, elimination := range gameState.Eliminations {
+				if reason, ok := tt.out[elimination.Username]; !ok || reason != elimination.Reason || elimination.Round != 2 {
+					t.Errorf("%s went out in round %d for %s, want %v", elimination.Username, elimination.Round, elimination.Reason, tt.out)
+				}
+			}
+			for _, participant := range gameState.Participants {
+				if _, out := tt.out[participant.Username]; out && (!participant.Eliminated || participant.EliminatedRound != 2) {
+					t.Errorf("%s should be marked out in round 2", participant.Username)
+				}
+			}
+			if ended := gameState.CurrentQuestionIndex == len(gameState.Questions.QuestionData); ended != tt.ended {
+				t.Errorf("game ended %v, want %v", ended, tt.ended)
+			}
+		})
+	}
+}
+
+func TestEliminatedPlayerWatchesTheRest(t *testing.T) {
+	m := newTestManager(t)
+	question := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1}
+	winner, _ := addTestClient(t, m, "room-1")
+	loser, _ := addTestClient(t, m, "room-1")
+	m.gameStates["room-1"] = &quizmodel.GameState{
+		RoomCode:    "room-1",
+		Elimination: true,
+		Questions:   &quizmodel.Question{QuestionData: []*quizmodel.QuestionData{question}},
+		Participants: []quizmodel.Participant{
+			{UserID: winner.userID, Username: "winner", LastAnsweredQestion: question.ID, LastChoosenOption: 1},
+			{UserID: loser.userID, Username: "loser", LastAnsweredQestion: question.ID, LastChoosenOption: 2},
+		},
+	}
+
+	eliminatePlayers(testContext(), m, "room-1", 0)
+
+	m.RLock()
+	defer m.RUnlock()
+	if !loser.isSpectator() || !loser.eliminated {
+		t.Error("the eliminated player should only watch from now on")
+	}
+	if winner.isSpectator() || winner.eliminated {
+		t.Error("the winner should still play")
+	}
+}
@@ -27,24 +27,47 @@ const (
 	EventLobbyState  = "lobby_state"
 	EventStartGame   = "start_game" // (step3)
 	//EventReadyGame is that the user is ready to start the game
-	EventJoinedGame   = "joined_game" // Joined into the game (step1)
-	EventReadyGame    = "ready_game"  // ready to play the game (step2)
-	EventLeaveRoom    = "leave_room"  // leave game room
-	EventEndGame      = "end_game"
-	EventBotGameOver  = "bot_game_over" // notifies the bot that the game is over so that it can stop
-	EventGameStatus   = "game_status"
-	EventSubmitAnswer = "submit_answer"
-	EventNewQuestion  = "new_question"
-	EventNextQuestion = "next_question" // user clicks next question
-	EventGameError    = "game_error"
-	EventLeaderBoard  = "leaderboard"
-	EventChatMessage  = "chat_message" // forward the message to every client
+	EventJoinedGame    = "joined_game" // Joined into the game (step1)
+	EventReadyGame     = "ready_game"  // ready to play the game (step2)
+	EventLeaveRoom     = "leave_room"  // leave game room
+	EventEndGame       = "end_game"
+	EventBotGameOver   = "bot_game_over" // notifies the bot that the game is over so that it can stop
+	EventGameStatus    = "game_status"
+	EventSubmitAnswer  = "submit_answer"
+	EventNewQuestion   = "new_question"
+	EventNextQuestion  = "next_question" // user clicks next question
+	EventGameError     = "game_error"
+	EventLeaderBoard   = "leaderboard"
+	EventChatMessage   = "chat_message"   // forward the message to every client
+	EventResumeToken   = "resume_token"   // token the client sends back to take over its slot after a reconnect
+	EventGenerationJob = "generation_job" // progress of the question generation of the room
+	// EventAnswerDistribution is the live count of the answers of the current question, only the host gets it
+	EventAnswerDistribution = "answer_distribution"
+	EventQuestionReveal     = "question_reveal" // right answer, answer counts and score changes of a question once it is closed
+	EventSpectatorCount     = "spectator_count" // number of spectators watching the room
+	EventStreak             = "streak"          // a player's streak started or broke
+	EventUsePowerUp         = "use_powerup"     // player uses one of his power-ups on the current question
+	EventPowerUpUsed        = "powerup_used"    // tells the player what his power-up did
+	EventJoinTeam           = "join_team"       // player picks his team in the lobby, the owner can move anyone
+	EventBalanceTeams       = "balance_teams"   // owner deals the lobby out evenly over the teams
+	EventElimination        = "elimination"     // players who dropped out of an elimination game after a question
+
+	// controls of the room owner
+	EventKickMember   = "kick_member"
+	EventLockRoom     = "lock_room" // nobody new can join a locked room
+	EventPauseGame    = "pause_game"
+	EventResumeGame   = "resume_game"
+	EventSkipQuestion = "skip_question"
+	EventEndGameEarly = "end_game_early"
+	EventHostAction   = "host_action" // tells the room what the owner did
 )
 
 type Payload struct {
+	UserID   string    `json:"userId,omitempty"`
 	UserName string    `json:"username"`
 	Data     string    `json:"data"`
 	Time     time.Time `json:"time"`
+	Team     int       `json:"team,omitempty"` // team of the member in the lobby of a room in team mode
 }
 
 var (
@@ -55,6 +78,8 @@ var (
 	// The reason why it has to be less than PingRequency is becuase otherwise it will send a new Ping before getting response
 	pingInterval = (pongWait * 9) / 10
 	maxReadLimit = 1024 * 1024
+	// egressBufferSize is how many events wait for a slow client before his connection is dropped
+	egressBufferSize = 256
 )
 
 func (m *Manager) setupEventHandlers() {
@@ -65,6 +90,15 @@ func (m *Manager) setupEventHandlers() {
 	m.handlers[EventNextQuestion] = NextQuestionHandler
 	m.handlers[EventLeaveRoom] = LeaveGameRoomHandler
 	m.handlers[EventChatMessage] = ChatGameRoomHandler
+	m.handlers[EventKickMember] = KickMemberHandler
+	m.handlers[EventLockRoom] = LockRoomHandler
+	m.handlers[EventPauseGame] = PauseGameHandler
+	m.handlers[EventResumeGame] = ResumeGameHandler
+	m.handlers[EventSkipQuestion] = SkipQuestionHandler
+	m.handlers[EventEndGameEarly] = EndGameEarlyHandler
+	m.handlers[EventUsePowerUp] = UsePowerUpHandler
+	m.handlers[EventJoinTeam] = JoinTeamHandler
+	m.handlers[EventBalanceTeams] = BalanceTeamsHandler
 }
 
 func (m *Manager) routeEvent(ctx context.Context, event Event, c *Client) error {
@@ -72,6 +106,21 @@ func (m *Manager) routeEvent(ctx context.Context, event Event, c *Client) error
 	l.Sugar().Infof("Routing event type %s from user %s in room %s",
 		event.Type, c.userID, c.roomCode)
 
+	// a kicked client may still have a message on the wire before his connection is closed
+	m.RLock()
+	kicked := c.kicked
+	spectator := c.isSpectator()
+	m.RUnlock()
+	if kicked {
+		return nil
+	}
+
+	// spectators are read only
+	if spectator {
+		sendGameError("spectators can only watch the game", c)
+		return nil
+	}
+
 	if handler, ok := m.handlers[event.Type]; ok {
 		c.manager = m
 		return handler(ctx, event, c)
@@ -84,5 +133,10 @@ type questionEvent struct {
 	TotalQuestions int                     `json:"totalQuestions"`
 	Question       *quizmodel.QuestionData `json:"qs"`
 	StartTime      time.Time               `json:"startTime"`
-	TimeLimit      int                     `json:"timeLimit"`
+	TimeLimit      int                     `json:"timeLimit"` // seconds given for this question
+	TimeLeft       int                     `json:"timeLeft"`  // seconds left to answer, less than the limit when the question is replayed
+	Paused         bool                    `json:"paused"`
+	// filled for every player when the question is sent to him
+	PowerUps     map[quizmodel.PowerUp]int `json:"powerUps,omitempty"`     // power-ups the player has left
+	DoublePoints bool                      `json:"doublePoints,omitempty"` // the player's double points apply to this question
 }
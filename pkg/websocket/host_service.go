@@ -0,0 +1,118 @@
+// A room whose owner wrote the questions himself is played in host mode. The owner joins as the
+// HOST: he gets every event of the room plus the live answer distribution of the current question,
+// but he cannot answer and never becomes a participant of the game state.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// roleEvents are only delivered to the clients with one of the listed roles, the rest go to everyone
+var roleEvents = map[string][]roommodel.MemberRole{
+	EventAnswerDistribution: {roommodel.Host},
+}
+
+type answerDistributionEvent struct {
+	QuestionID    uuid.UUID   `json:"questionId"`
+	QuestionIndex int         `json:"questionIndex"`
+	Counts        map[int]int `json:"counts"` // option id -> number of participants who chose it
+	Answered      int         `json:"answered"`
+	Participants  int         `json:"participants"`
+}
+
+// isHost tells if the client hosts the room, caller must hold the manager lock since a resume changes the role
+func (c *Client) isHost() bool {
+	return c.role == roommodel.Host
+}
+
+// isHostClient is isHost for the callers which do not hold the manager lock
+func (m *Manager) isHostClient(c *Client) bool {
+	m.RLock()
+	defer m.RUnlock()
+	return c.isHost()
+}
+
+// receives tells if the room event has to be sent to this client, caller must hold the manager lock
+func (c *Client) receives(event Event) bool {
+	roles, restricted := roleEvents[event.Type]
+	if !restricted {
+		return true
+	}
+	for _, role := range roles {
+		if c.role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// countAnswers counts the latest answers of the participants who are still in the game for the question.
+// every pick of a multi select answer is counted, the options of the other types which are not single choice
+// are not counted. caller must hold the manager lock
+func countAnswers(gameState *quizmodel.GameState, question *quizmodel.QuestionData) (counts map[int]int, answered int, participants int) {
+	counts = map[int]int{}
+	for _, option := range question.Options {
+		counts[option.ID] = 0
+	}
+	for _, participant := range gameState.Participants {
+		if participant.IsExited || participant.Eliminated {
+			continue
+		}
+		participants++
+		if participant.LastAnsweredQestion == question.ID {
+			answered++
+			switch {
+			case question.SingleAnswer():
+				counts[participant.LastChoosenOption]++
+			case question.Type == quizmodel.MultiSelect && participant.LastAnswerData != nil:
+				for _, id := range participant.LastAnswerData.Options {
+					counts[id]++
+				}
+			}
+		}
+	}
+	return counts, answered, participants
+}
+
+// buildAnswerDistributionEvent counts the latest answers of the participants for the current question, caller must hold the manager lock
+func buildAnswerDistributionEvent(gameState *quizmodel.GameState) (Event, error) {
+	currentQuestion := gameState.Questions.QuestionData[gameState.CurrentQuestionIndex]
+	distribution := answerDistributionEvent{
+		QuestionID:    currentQuestion.ID,
+		QuestionIndex: gameState.CurrentQuestionIndex + 1,
+	}
+	distribution.Counts, distribution.Answered, distribution.Participants = countAnswers(gameState, currentQuestion)
+
+	data, err := json.Marshal(distribution)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: EventAnswerDistribution, Payload: data}, nil
+}
+
+// sendAnswerDistribution pushes the answer distribution of the current question to the host
+func sendAnswerDistribution(ctx context.Context, manager *Manager, roomCode string) {
+	l := logs.GetLoggerctx(ctx)
+
+	manager.Lock()
+	gameState, exists := manager.gameStates[roomCode]
+	if !exists || gameState.Questions == nil || gameState.CurrentQuestionIndex >= len(gameState.Questions.QuestionData) {
+		manager.Unlock()
+		return
+	}
+	event, err := buildAnswerDistributionEvent(gameState)
+	manager.Unlock()
+	if err != nil {
+		l.Sugar().Error("answer distribution json marshal failed", err)
+		return
+	}
+
+	manager.broadcast(ctx, roomCode, event)
+}
@@ -0,0 +1,98 @@
+package websocket
+
+import (
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// startedGame is a game of the room which is on its first question
+func startedGame(m *Manager, roomCode string, questions ...*quizmodel.QuestionData) *quizmodel.GameState {
+	gameState := &quizmodel.GameState{
+		RoomCode:          roomCode,
+		RoomStatus:        roommodel.Started,
+		Questions:         &quizmodel.Question{TimeLimit: 10, QuestionData: questions},
+		QuestionStartTime: time.Now(),
+	}
+	m.gameStates[roomCode] = gameState
+	return gameState
+}
+
+// answerEvent is the answer of a client to the question
+func answerEvent(t *testing.T, question *quizmodel.QuestionData, option int) Event {
+	t.Helper()
+	payload, err := json.Marshal(quizmodel.AnswerReq{QuestionDataID: question.ID, AnswerOption: int32(option)})
+	if err != nil {
+		t.Fatalf("marshal answer: %v", err)
+	}
+	return Event{Type: EventSubmitAnswer, Payload: payload}
+}
+
+func TestHostCannotAnswer(t *testing.T) {
+	m := newTestManager(t)
+	question := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1, Options: []quizmodel.Options{{ID: 1, Option: "a"}, {ID: 2, Option: "b"}}}
+	gameState := startedGame(m, "room-1", question)
+	host, _ := addTestClient(t, m, "room-1")
+	host.role = roommodel.Host
+
+	err := SubmitAnswerHandler(testContext(), answerEvent(t, question, 1), host)
+	if err != nil {
+		t.Fatalf("submit answer: %v", err)
+	}
+
+	select {
+	case event := <-host.egress:
+		if event.Type != EventGameError {
+			t.Errorf("the host got %s, want %s", event.Type, EventGameError)
+		}
+	default:
+		t.Errorf("the host should be told he cannot answer")
+	}
+	if len(gameState.Participants) != 0 || len(host.ansHistory) != 0 {
+		t.Errorf("the answer of the host was taken, participants %+v", gameState.Participants)
+	}
+}
+
+func TestAnswerDistributionOnlyReachesTheHost(t *testing.T) {
+	m := newTestManager(t)
+	question := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1, Options: []quizmodel.Options{{ID: 1, Option: "a"}, {ID: 2, Option: "b"}}}
+	gameState := startedGame(m, "room-1", question)
+	gameState.Participants = []quizmodel.Participant{
+		{UserID: uuid.New(), Username: "first", LastAnsweredQestion: question.ID, LastChoosenOption: 1},
+		{UserID: uuid.New(), Username: "second", LastAnsweredQestion: question.ID, LastChoosenOption: 1},
+		{UserID: uuid.New(), Username: "third", LastAnsweredQestion: question.ID, LastChoosenOption: 2},
+		{UserID: uuid.New(), Username: "slow"},
+		{UserID: uuid.New(), Username: "gone", IsExited: true, LastAnsweredQestion: question.ID, LastChoosenOption: 2},
+	}
+	host, _ := addTestClient(t, m, "room-1")
+	host.role = roommodel.Host
+	player, _ := addTestClient(t, m, "room-1")
+	spectator, _ := addTestClient(t, m, "room-1")
+	spectator.role = roommodel.Spectator
+
+	sendAnswerDistribution(testContext(), m, "room-1")
+
+	select {
+	case event := <-host.egress:
+		distribution := answerDistributionEvent{}
+		if err := json.Unmarshal(event.Payload, &distribution); err != nil || event.Type != EventAnswerDistribution {
+			t.Fatalf("the host got %s %v", event.Type, err)
+		}
+		if distribution.Counts[1] != 2 || distribution.Counts[2] != 1 || distribution.Answered != 3 || distribution.Participants != 4 {
+			t.Errorf("got the distribution %+v, want 2 on a and 1 on b with 3 of 4 answered", distribution)
+		}
+	default:
+		t.Fatalf("the host did not get the answer distribution")
+	}
+	for name, client := range map[string]*Client{"player": player, "spectator": spectator} {
+		select {
+		case event := <-client.egress:
+			t.Errorf("the %s got %s, only the host sees the answers before the question closes", name, event.Type)
+		default:
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// The questions of a room are generated by a background job (see quiz.RunGenerationJob).
+// Players dont wait on the websocket upgrade for it anymore, they are let into the lobby right away
+// and every status change of the job is pushed to them as a generation_job event. A game which is
+// started before the questions are ready is held back and started as soon as the job succeeds.
+// On boot an instance fails the jobs it was running before it went down, the jobs of an instance which
+// does not come back are failed once they go stale. Either way their players are told instead of
+// waiting in the lobby forever.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type generationJobEvent struct {
+	Status        quizmodel.JobStatus `json:"status"`
+	QuestionCount int                 `json:"questionCount"`
+	Message       string              `json:"message"`
+}
+
+var generationJobMessages = map[quizmodel.JobStatus]string{
+	quizmodel.JobPending:   "waiting to generate the questions...",
+	quizmodel.JobRunning:   "generating questions...",
+	quizmodel.JobSucceeded: "questions are ready",
+	quizmodel.JobFailed:    "generating questions for this room failed, please create a new room",
+}
+
+func buildGenerationJobEvent(job *quizmodel.GenerationJob) Event {
+	data, _ := json.Marshal(generationJobEvent{
+		Status:        job.Status,
+		QuestionCount: job.QuestionCount,
+		Message:       generationJobMessages[job.Status],
+	})
+	return Event{Type: EventGenerationJob, Payload: data}
+}
+
+// publishGenerationJob is the job listener of the quiz service, the job update goes over the bus
+// so that the lobby clients on every instance get it
+func (m *Manager) publishGenerationJob(ctx context.Context, job *quizmodel.GenerationJob) {
+	m.broadcast(ctx, job.RoomCode, buildGenerationJobEvent(job))
+}
+
+// watchStaleGenerationJobs fails the jobs which are stuck since their server went down. the jobs this
+// instance ran are failed right away, the jobs of other instances can only be taken as dead once they
+// are older than any generation takes, so that is done again periodically
+func (m *Manager) watchStaleGenerationJobs(ctx context.Context) {
+	m.roomService.FailInstanceGenerationJobs(ctx)
+	m.roomService.FailStaleGenerationJobs(ctx)
+
+	ticker := time.NewTicker(time.Duration(quiz.JobStaleSecond()) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.roomService.FailStaleGenerationJobs(ctx)
+		}
+	}
+}
+
+// loadGenerationJob fetches the generation job of the room and remembers it till the game starts
+func (m *Manager) loadGenerationJob(ctx context.Context, roomCode string) (*quizmodel.GenerationJob, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	job, err := m.quizService.GetGenerationJobByRoomCode(ctx, roomCode)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		// rooms created before the generation jobs only have their questions
+		questions, err := m.quizService.ListQuestionsByRoomCode(ctx, roomCode)
+		if err != nil {
+			return nil, err
+		}
+		if questions.QuestionCount == 0 {
+			return nil, fmt.Errorf("room %s has no questions", roomCode)
+		}
+		job = &quizmodel.GenerationJob{
+			RoomCode:      roomCode,
+			QuestionCount: questions.QuestionCount,
+			Status:        quizmodel.JobSucceeded,
+		}
+	}
+
+	m.Lock()
+	// the job may have moved on while we were reading it, dont go back to an older status
+	if known, exists := m.generationJobs[roomCode]; !exists || job.UpdatedOn.After(known.UpdatedOn) {
+		m.generationJobs[roomCode] = job
+	} else {
+		job = known
+	}
+	m.Unlock()
+
+	l.Sugar().Infof("generation job of room %s is %s", roomCode, job.Status)
+	return job, nil
+}
+
+// sendGenerationJob tells the client where the question generation of his room is
+func (m *Manager) sendGenerationJob(c *Client) {
+	m.RLock()
+	job, exists := m.generationJobs[c.roomCode]
+	m.RUnlock()
+	if !exists {
+		return
+	}
+	c.egress <- buildGenerationJobEvent(job)
+}
+
+// questionsReady tells if the game of the room can be started, caller must hold the manager lock
+func (m *Manager) questionsReady(roomCode string) bool {
+	job, exists := m.generationJobs[roomCode]
+	// a room is forgotten once its game has loaded the questions
+	return !exists || job.Status == quizmodel.JobSucceeded
+}
+
+// holdGameStart keeps the game start of the room till the questions are generated.
+// it returns false when the questions are already there and the game can start right away
+func (m *Manager) holdGameStart(c *Client) bool {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.questionsReady(c.roomCode) {
+		return false
+	}
+	m.pendingStarts[c.roomCode] = c
+	return true
+}
+
+// recheckGenerationJob reads the job again after a game start was held back, the job may have
+// finished before the manager started waiting for it
+func (m *Manager) recheckGenerationJob(ctx context.Context, roomCode string) {
+	l := logs.GetLoggerctx(ctx)
+
+	job, err := m.quizService.GetGenerationJobByRoomCode(ctx, roomCode)
+	if err != nil || job == nil {
+		l.Sugar().Error("recheck generation job failed", err)
+		return
+	}
+	if job.Status == quizmodel.JobSucceeded || job.Status == quizmodel.JobFailed {
+		m.deliverLocal(ctx, roomCode, buildGenerationJobEvent(job))
+	}
+}
+
+// forgetGenerationJob is called once the questions are loaded into the game state
+func (m *Manager) forgetGenerationJob(roomCode string) {
+	m.Lock()
+	delete(m.generationJobs, roomCode)
+	delete(m.pendingStarts, roomCode)
+	m.Unlock()
+}
+
+// trackGenerationJob keeps the job status of the rooms on this instance up to date. once the job
+// succeeds a held back game is started, when it fails the room is closed
+func (m *Manager) trackGenerationJob(ctx context.Context, roomCode string, event Event) {
+	l := logs.GetLoggerctx(ctx)
+
+	jobEvent := generationJobEvent{}
+	err := json.Unmarshal(event.Payload, &jobEvent)
+	if err != nil {
+		l.Sugar().Error("generation job event json unmarshal failed", err)
+		return
+	}
+
+	m.Lock()
+	job, exists := m.generationJobs[roomCode]
+	if !exists {
+		m.Unlock()
+		return // nobody of this room is connected to this instance
+	}
+	job.Status = jobEvent.Status
+	pending := m.pendingStarts[roomCode]
+
+	switch jobEvent.Status {
+	case quizmodel.JobSucceeded:
+		delete(m.pendingStarts, roomCode)
+		m.Unlock()
+		if pending != nil {
+			l.Sugar().Infof("questions of room %s are ready, starting the game", roomCode)
+			go StartGameMessageHandler(ctx, Event{}, pending)
+		}
+	case quizmodel.JobFailed:
+		delete(m.pendingStarts, roomCode)
+		delete(m.generationJobs, roomCode)
+		delete(m.gameStates, roomCode)
+		m.Unlock()
+		l.Sugar().Errorf("question generation of room %s failed, closing the room", roomCode)
+	default:
+		m.Unlock()
+	}
+}
@@ -0,0 +1,151 @@
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.uber.org/zap"
+)
+
+// testContext carries the no-op logger the services expect in the context
+func testContext() context.Context {
+	return logs.SetLoggerctx(context.Background(), zap.NewNop())
+}
+
+// newTestManager is a manager without services, on the in-memory bus
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	return newTestManagerOn(t, newMemoryBus())
+}
+
+// newTestManagerOn is a manager without services on the bus
+func newTestManagerOn(t *testing.T, bus RoomBus) *Manager {
+	t.Helper()
+	m := &Manager{
+		clients:         make(map[string]ClientList),
+		handlers:        make(map[string]EventHandler),
+		botClients:      make(map[string]map[uuid.UUID]*Client),
+		roomStates:      make(map[string]*roommodel.RoomStatus),
+		gameStates:      make(map[string]*quizmodel.GameState),
+		bus:             bus,
+		restoredHistory: make(map[string]map[uuid.UUID]quizmodel.AnswerHistory),
+		resumePending:   make(map[string]bool),
+		suspended:       make(map[string]map[uuid.UUID]*suspendedClient),
+		generationJobs:  make(map[string]*quizmodel.GenerationJob),
+		pendingStarts:   make(map[string]*Client),
+		questionTimers:  make(map[string]*time.Timer),
+		joining:         make(map[string]int),
+		relayed:         make(map[string]*relayConn),
+		forwarded:       make(map[string]*forwardedConn),
+	}
+	err := m.bus.Subscribe(testContext(), m.deliverLocal)
+	if err != nil {
+		t.Fatalf("subscribe to the bus: %v", err)
+	}
+	err = m.bus.SubscribeRelay(testContext(), m.handleCommand, m.handleReply)
+	if err != nil {
+		t.Fatalf("subscribe to the relay: %v", err)
+	}
+	m.setupEventHandlers()
+	return m
+}
+
+// newTestConn opens a websocket, the server side is what a client of the manager holds and the peer is the browser
+func newTestConn(t *testing.T) (server *websocket.Conn, peer *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocketUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { peer.Close() })
+	server = <-conns
+	t.Cleanup(func() { server.Close() })
+	return server, peer
+}
+
+// addTestClient puts a connected player in the room
+func addTestClient(t *testing.T, m *Manager, roomCode string) (*Client, *websocket.Conn) {
+	t.Helper()
+	server, peer := newTestConn(t)
+	client := NewClient(server, m, roomCode, false, "", uuid.New(), "player", &roommodel.Room{RoomCode: roomCode})
+	m.addClient(client)
+	return client, peer
+}
+
+// roomRow is a row of the room table in the order GetRoomByRoomCode scans it
+func roomRow(roomCode string, gameType roommodel.GT, status roommodel.RoomStatus, locked bool, public bool) []any {
+	return []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, roomCode, pgtype.Text{String: "room", Valid: true}, pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		[]byte("[]"), []byte("[]"), locked, string(gameType), string(status), true, false,
+		pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", public,
+	}
+}
+
+// memberRow is a row of the room member joined with the user in the order GetRoomMemberByRoomCodeAndUserID scans it
+func memberRow(roomCode string, userID uuid.UUID, username string, status roommodel.RoomMemberStatus, role roommodel.MemberRole) []any {
+	return []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, roomCode, pgtype.UUID{Bytes: uuid.New(), Valid: true}, pgtype.UUID{Bytes: userID, Valid: true},
+		false, pgtype.Timestamp{}, string(status), true, false, pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", string(role),
+		pgtype.UUID{Bytes: userID, Valid: true}, pgtype.Text{}, username, "USER", pgtype.Text{}, []byte("{}"), false, true, false,
+		pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner",
+	}
+}
+
+// readEvent waits for the next event on the browser side of the websocket
+func readEvent(t *testing.T, peer *websocket.Conn) Event {
+	t.Helper()
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, data, err := peer.ReadMessage()
+	if err != nil {
+		t.Fatalf("read event: %v", err)
+	}
+	event := Event{}
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("event json: %v", err)
+	}
+	return event
+}
+
+// readEventOf skips the events till one of the type comes
+func readEventOf(t *testing.T, peer *websocket.Conn, eventType string) Event {
+	t.Helper()
+	for {
+		if event := readEvent(t, peer); event.Type == eventType {
+			return event
+		}
+	}
+}
+
+// eventually waits for the condition to hold
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
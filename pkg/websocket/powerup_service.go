@@ -0,0 +1,349 @@
+// Every player gets a few power-ups for a game. 50/50 removes two wrong options of the current question
+// for that player only, double points doubles the score of his next answer and time freeze adds seconds
+// to his own deadline, the room waits for him before it moves on. The new questions are personalized
+// for every client on the way out so nobody sees what the power-ups of another player changed.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultPowerUpCount      = 1 // of every power-up for each player in a game
+	defaultTimeFreezeSeconds = 10
+	// answerGrace lets in the answers which were sent just before the deadline but reached us after it
+	answerGrace = time.Second
+)
+
+var powerUps = []quizmodel.PowerUp{quizmodel.FiftyFifty, quizmodel.DoublePoints, quizmodel.TimeFreeze}
+
+func powerUpCount() int {
+	count := viper.GetInt("game.powerUpCount")
+	if count <= 0 {
+		count = defaultPowerUpCount
+	}
+	return count
+}
+
+func timeFreezeSeconds() int {
+	seconds := viper.GetInt("game.timeFreezeSeconds")
+	if seconds <= 0 {
+		seconds = defaultTimeFreezeSeconds
+	}
+	return seconds
+}
+
+type usePowerUpReq struct {
+	PowerUp quizmodel.PowerUp `json:"powerUp"`
+}
+
+// powerUpUsedEvent tells the player what his power-up did, it is only sent to him
+type powerUpUsedEvent struct {
+	PowerUp       quizmodel.PowerUp         `json:"powerUp"`
+	Inventory     map[quizmodel.PowerUp]int `json:"inventory"`
+	HiddenOptions []int                     `json:"hiddenOptions,omitempty"` // options removed by 50/50
+	DoubleOn      int                       `json:"doubleOn,omitempty"`      // question the double points apply to
+	ExtraSeconds  int                       `json:"extraSeconds,omitempty"`  // seconds added by time freeze
+	TimeLeft      int                       `json:"timeLeft"`                // seconds the player has left for the question
+}
+
+func newPowerUpState() *quizmodel.PowerUpState {
+	inventory := make(map[quizmodel.PowerUp]int, len(powerUps))
+	for _, powerUp := range powerUps {
+		inventory[powerUp] = powerUpCount()
+	}
+	return &quizmodel.PowerUpState{Inventory: inventory}
+}
+
+// powerUpState returns the power-ups of the player, he gets the allowance of the game the first time
+// he needs it. caller must hold the manager lock
+func powerUpState(gameState *quizmodel.GameState, userID uuid.UUID) *quizmodel.PowerUpState {
+	if gameState.PowerUps == nil {
+		gameState.PowerUps = make(map[uuid.UUID]*quizmodel.PowerUpState)
+	}
+	state, exists := gameState.PowerUps[userID]
+	if !exists {
+		state = newPowerUpState()
+		gameState.PowerUps[userID] = state
+	}
+	return state
+}
+
+// extraTime is what the time freeze of the player added to the current question, caller must hold the manager lock
+func extraTime(gameState *quizmodel.GameState, userID uuid.UUID) time.Duration {
+	state, exists := gameState.PowerUps[userID]
+	if !exists || state.ExtraOn != gameState.CurrentQuestionIndex+1 {
+		return 0
+	}
+	return time.Duration(state.ExtraSeconds) * time.Second
+}
+
+// playerTimeLeft is the time the player has left for the current question with his time freeze,
+// caller must hold the manager lock
+func playerTimeLeft(gameState *quizmodel.GameState, userID uuid.UUID) time.Duration {
+	timeLimit := gameState.Questions.QuestionTimeLimit(gameState.CurrentQuestionIndex) + extraTime(gameState, userID)
+	timeLeft := timeLimit - questionElapsed(gameState)
+	if timeLeft < 0 {
+		return 0
+	}
+	return timeLeft
+}
+
+// frozenTimeLeft is the longest time a player still has on the current question because of a time freeze,
+// the room does not move on before it runs out. caller must hold the manager lock
+func frozenTimeLeft(gameState *quizmodel.GameState) time.Duration {
+	if gameState.CurrentQuestionIndex >= len(gameState.Questions.QuestionData) {
+		return 0
+	}
+	questionID := gameState.Questions.QuestionData[gameState.CurrentQuestionIndex].ID
+	var longest time.Duration
+	for userID := range gameState.PowerUps {
+		if !stillAnswering(gameState, userID, questionID) {
+			continue
+		}
+		if timeLeft := playerTimeLeft(gameState, userID); timeLeft > longest {
+			longest = timeLeft
+		}
+	}
+	return longest
+}
+
+// stillAnswering tells if the room waits for the player on the question, nobody waits for a player who
+// answered already or who is out of the game. caller must hold the manager lock
+func stillAnswering(gameState *quizmodel.GameState, userID uuid.UUID, questionID uuid.UUID) bool {
+	for _, participant := range gameState.Participants {
+		if participant.UserID == userID {
+			return !participant.IsExited && !participant.Eliminated && participant.LastAnsweredQestion != questionID
+		}
+	}
+	return true
+}
+
+// powerUpsOn lists the power-ups the player used on the current question, caller must hold the manager lock
+func powerUpsOn(gameState *quizmodel.GameState, userID uuid.UUID) []quizmodel.PowerUp {
+	state, exists := gameState.PowerUps[userID]
+	if !exists {
+		return nil
+	}
+	number := gameState.CurrentQuestionIndex + 1
+	used := []quizmodel.PowerUp{}
+	if state.HiddenOn == number {
+		used = append(used, quizmodel.FiftyFifty)
+	}
+	if state.DoubleOn == number {
+		used = append(used, quizmodel.DoublePoints)
+	}
+	if state.ExtraOn == number {
+		used = append(used, quizmodel.TimeFreeze)
+	}
+	return used
+}
+
+// doublePoints applies the double points power-up on the score of an answer
+func doublePoints(score quiz.Score) quiz.Score {
+	if score.Points == 0 {
+		return score
+	}
+	score.Points *= 2
+	score.Reason += ", x2 double points"
+	return score
+}
+
+// pickWrongOptions picks the options a 50/50 removes, two of the wrong ones
+func pickWrongOptions(question *quizmodel.QuestionData) []int {
+	wrong := []int{}
+	for _, option := range question.Options {
+		if option.ID != question.Answer {
+			wrong = append(wrong, option.ID)
+		}
+	}
+	rand.Shuffle(len(wrong), func(i, j int) { wrong[i], wrong[j] = wrong[j], wrong[i] })
+	if len(wrong) > 2 {
+		wrong = wrong[:2]
+	}
+	return wrong
+}
+
+// hasAnswered tells if the player already answered the question, caller must hold the manager lock
+func hasAnswered(gameState *quizmodel.GameState, userID uuid.UUID, questionID uuid.UUID) bool {
+	for _, participant := range gameState.Participants {
+		if participant.UserID == userID {
+			return participant.LastAnsweredQestion == questionID
+		}
+	}
+	return false
+}
+
+// UsePowerUpHandler uses one of the player's power-ups on the current question
+func UsePowerUpHandler(ctx context.Context, event Event, c *Client) error {
+	l := logs.GetLoggerctx(ctx)
+
+	if c.manager.isHostClient(c) || c.isBot {
+		sendGameError("only the players can use power-ups", c)
+		return nil
+	}
+
+	var req usePowerUpReq
+	if err := json.Unmarshal(event.Payload, &req); err != nil {
+		l.Sugar().Error("bad payload", err)
+		return fmt.Errorf("bad payload: %v", err)
+	}
+
+	c.manager.Lock()
+	gameState, exists := c.manager.gameStates[c.roomCode]
+	if !exists || gameState.RoomStatus != roommodel.Started || gameState.Revealing ||
+		gameState.CurrentQuestionIndex >= len(gameState.Questions.QuestionData) {
+		c.manager.Unlock()
+		sendGameError("there is no question to use the power-up on", c)
+		return nil
+	}
+	if !gameState.PausedAt.IsZero() {
+		c.manager.Unlock()
+		sendGameError("the game is paused, wait for the host to resume it", c)
+		return nil
+	}
+	if playerTimeLeft(gameState, c.userID) <= 0 {
+		c.manager.Unlock()
+		sendGameError("the time for this question is up", c)
+		return nil
+	}
+
+	state := powerUpState(gameState, c.userID)
+	if state.Inventory[req.PowerUp] <= 0 {
+		c.manager.Unlock()
+		sendGameError("you have no power-up of this kind left", c)
+		return nil
+	}
+
+	number := gameState.CurrentQuestionIndex + 1
+	currentQuestion := gameState.Questions.QuestionData[gameState.CurrentQuestionIndex]
+	reply := powerUpUsedEvent{PowerUp: req.PowerUp}
+	switch req.PowerUp {
+	case quizmodel.FiftyFifty:
+		if quiz.GetQuestionKind(currentQuestion.Type).Type() != quizmodel.SingleChoice {
+			c.manager.Unlock()
+			sendGameError("50/50 only works on single choice questions", c)
+			return nil
+		}
+		if state.HiddenOn == number {
+			c.manager.Unlock()
+			sendGameError("50/50 is already used on this question", c)
+			return nil
+		}
+		state.HiddenOptions = pickWrongOptions(currentQuestion)
+		state.HiddenOn = number
+		reply.HiddenOptions = state.HiddenOptions
+	case quizmodel.DoublePoints:
+		// an answered question is scored already so the double points wait for the next one
+		target := number
+		if hasAnswered(gameState, c.userID, currentQuestion.ID) {
+			target++
+		}
+		if state.DoubleOn >= target {
+			c.manager.Unlock()
+			sendGameError("double points are already waiting for your next answer", c)
+			return nil
+		}
+		if target > len(gameState.Questions.QuestionData) {
+			c.manager.Unlock()
+			sendGameError("there is no question left to double", c)
+			return nil
+		}
+		state.DoubleOn = target
+		reply.DoubleOn = target
+	case quizmodel.TimeFreeze:
+		if state.ExtraOn == number {
+			c.manager.Unlock()
+			sendGameError("time freeze is already used on this question", c)
+			return nil
+		}
+		state.ExtraSeconds = timeFreezeSeconds()
+		state.ExtraOn = number
+		reply.ExtraSeconds = state.ExtraSeconds
+	}
+	state.Inventory[req.PowerUp]--
+	reply.Inventory = state.Inventory
+	reply.TimeLeft = int(playerTimeLeft(gameState, c.userID).Seconds())
+
+	// an answer which is already given records the power-up as well
+	if answer, exists := c.ansHistory[currentQuestion.ID][c.userID]; exists {
+		answer.PowerUps = powerUpsOn(gameState, c.userID)
+	}
+	data, err := json.Marshal(reply)
+	c.manager.Unlock()
+	if err != nil {
+		l.Sugar().Error("json marshal failed", err)
+		return err
+	}
+
+	l.Sugar().Infof("user %s used %s on question %d in room %s", c.userID, req.PowerUp, number, c.roomCode)
+	// the inventory has to survive a restart as well
+	c.manager.checkpointGameState(ctx, c.roomCode)
+	c.egress <- Event{Type: EventPowerUpUsed, Payload: data}
+	return nil
+}
+
+// personalize fits an outgoing event to the client. a new question shows the player his power-ups,
+// without the options his 50/50 removed and with the time his time freeze added
+func (m *Manager) personalize(c *Client, event Event) Event {
+	if event.Type != EventNewQuestion || c.isBot {
+		return event
+	}
+	var question questionEvent
+	if err := json.Unmarshal(event.Payload, &question); err != nil {
+		return event
+	}
+
+	m.RLock()
+	gameState, exists := m.gameStates[c.roomCode]
+	// the event may be older than the game state, then it is sent as it is
+	if c.isHost() || c.isSpectator() || !exists || gameState.CurrentQuestionIndex+1 != question.QuestionIndex || question.Question == nil {
+		m.RUnlock()
+		return event
+	}
+	state, exists := gameState.PowerUps[c.userID]
+	if !exists {
+		state = newPowerUpState()
+	}
+	question.PowerUps = make(map[quizmodel.PowerUp]int, len(state.Inventory))
+	for powerUp, count := range state.Inventory {
+		question.PowerUps[powerUp] = count
+	}
+	question.DoublePoints = state.DoubleOn == question.QuestionIndex
+	if state.HiddenOn == question.QuestionIndex {
+		cq := *question.Question
+		cq.Options = []quizmodel.Options{}
+		for _, option := range question.Question.Options {
+			hidden := false
+			for _, id := range state.HiddenOptions {
+				hidden = hidden || id == option.ID
+			}
+			if !hidden {
+				cq.Options = append(cq.Options, option)
+			}
+		}
+		question.Question = &cq
+	}
+	if state.ExtraOn == question.QuestionIndex {
+		question.TimeLeft = int(playerTimeLeft(gameState, c.userID).Seconds())
+	}
+	m.RUnlock()
+
+	data, err := json.Marshal(question)
+	if err != nil {
+		return event
+	}
+	return Event{Type: event.Type, Payload: data}
+}
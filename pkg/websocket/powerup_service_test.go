@@ -0,0 +1,234 @@
+package websocket
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fourOptions is a single choice question with the right answer on option 1
+func fourOptions() *quizmodel.QuestionData {
+	return &quizmodel.QuestionData{ID: uuid.New(), Question: "What is 7 times 8?", Answer: 1, Options: []quizmodel.Options{
+		{ID: 1, Option: "56"}, {ID: 2, Option: "54"}, {ID: 3, Option: "64"}, {ID: 4, Option: "48"},
+	}}
+}
+
+func TestFrozenTimeLeft(t *testing.T) {
+	question := fourOptions()
+	tests := []struct {
+		name        string
+		participant *quizmodel.Participant // the player with the time freeze, not on the list yet when nil
+		waits       bool
+	}{
+		{name: "no answer yet", waits: true},
+		{name: "still thinking", participant: &quizmodel.Participant{}, waits: true},
+		{name: "answered an earlier question", participant: &quizmodel.Participant{LastAnsweredQestion: uuid.New()}, waits: true},
+		{name: "answered already", participant: &quizmodel.Participant{LastAnsweredQestion: question.ID}},
+		{name: "left the game", participant: &quizmodel.Participant{IsExited: true}},
+		{name: "eliminated", participant: &quizmodel.Participant{Eliminated: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			gameState := startedGame(m, "room-1", question)
+			// the time of the room is up, the time freeze gives the player 10 seconds more
+			gameState.QuestionStartTime = time.Now().Add(-11 * time.Second)
+			userID := uuid.New()
+			gameState.PowerUps = map[uuid.UUID]*quizmodel.PowerUpState{userID: {ExtraOn: 1, ExtraSeconds: 10}}
+			if tt.participant != nil {
+				tt.participant.UserID = userID
+				gameState.Participants = []quizmodel.Participant{*tt.participant}
+			}
+
+			frozen := frozenTimeLeft(gameState)
+
+			if waits := frozen > 0; waits != tt.waits {
+				t.Errorf("the room waits %s, want waiting %v", frozen, tt.waits)
+			}
+			if tt.waits && frozen > 9*time.Second {
+				t.Errorf("the room waits %s, more than the 9 seconds the player has left", frozen)
+			}
+		})
+	}
+}
+
+func TestPersonalize(t *testing.T) {
+	m := newTestManager(t)
+	question := fourOptions()
+	gameState := startedGame(m, "room-1", question)
+	player, _ := addTestClient(t, m, "room-1")
+	host, _ := addTestClient(t, m, "room-1")
+	host.role = roommodel.Host
+	other, _ := addTestClient(t, m, "room-1")
+	gameState.PowerUps = map[uuid.UUID]*quizmodel.PowerUpState{player.userID: {
+		Inventory:     map[quizmodel.PowerUp]int{quizmodel.FiftyFifty: 0, quizmodel.DoublePoints: 0, quizmodel.TimeFreeze: 0},
+		HiddenOptions: []int{2, 4},
+		HiddenOn:      1,
+		DoubleOn:      1,
+		ExtraOn:       1,
+		ExtraSeconds:  10,
+	}}
+	event, err := buildQuestionEvent(gameState)
+	if err != nil {
+		t.Fatalf("question event: %v", err)
+	}
+	sent := func(c *Client) questionEvent {
+		t.Helper()
+		question := questionEvent{}
+		if err := json.Unmarshal(m.personalize(c, event).Payload, &question); err != nil {
+			t.Fatalf("question json: %v", err)
+		}
+		return question
+	}
+
+	mine := sent(player)
+	if len(mine.Question.Options) != 2 || mine.Question.Options[0].ID != 1 || mine.Question.Options[1].ID != 3 {
+		t.Errorf("got the options %+v, want the ones the 50/50 left", mine.Question.Options)
+	}
+	if !mine.DoublePoints || mine.PowerUps[quizmodel.FiftyFifty] != 0 || mine.TimeLeft < 19 {
+		t.Errorf("got %+v, want double points, no power-ups left and 20 seconds", mine)
+	}
+	theirs := sent(other)
+	if len(theirs.Question.Options) != 4 || theirs.DoublePoints || theirs.PowerUps[quizmodel.TimeFreeze] != defaultPowerUpCount || theirs.TimeLeft > 10 {
+		t.Errorf("got %+v, another player should not see what the power-ups of the player did", theirs)
+	}
+	if hosts := sent(host); len(hosts.Question.Options) != 4 || hosts.PowerUps != nil {
+		t.Errorf("got %+v, the host gets the question as it is", hosts)
+	}
+}
+
+func TestUsePowerUpHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		powerUp   quizmodel.PowerUp
+		setup     func(gameState *quizmodel.GameState, player *Client)
+		wantError bool
+		check     func(t *testing.T, state *quizmodel.PowerUpState, reply powerUpUsedEvent)
+	}{
+		{
+			name:    "50/50 removes two wrong options",
+			powerUp: quizmodel.FiftyFifty,
+			check: func(t *testing.T, state *quizmodel.PowerUpState, reply powerUpUsedEvent) {
+				if len(reply.HiddenOptions) != 2 || reply.HiddenOptions[0] == 1 || reply.HiddenOptions[1] == 1 || state.HiddenOn != 1 {
+					t.Errorf("got the hidden options %v, want two wrong ones", reply.HiddenOptions)
+				}
+			},
+		},
+		{
+			name:    "50/50 on a question which is not single choice",
+			powerUp: quizmodel.FiftyFifty,
+			setup: func(gameState *quizmodel.GameState, player *Client) {
+				gameState.Questions.QuestionData[0].Type = quizmodel.Numeric
+			},
+			wantError: true,
+		},
+		{
+			name:    "double points on the current question",
+			powerUp: quizmodel.DoublePoints,
+			check: func(t *testing.T, state *quizmodel.PowerUpState, reply powerUpUsedEvent) {
+				if state.DoubleOn != 1 || reply.DoubleOn != 1 {
+					t.Errorf("double points on question %d, want 1", state.DoubleOn)
+				}
+			},
+		},
+		{
+			name:    "double points after the answer wait for the next question",
+			powerUp: quizmodel.DoublePoints,
+			setup: func(gameState *quizmodel.GameState, player *Client) {
+				gameState.Participants = []quizmodel.Participant{{UserID: player.userID, LastAnsweredQestion: gameState.Questions.QuestionData[0].ID}}
+			},
+			check: func(t *testing.T, state *quizmodel.PowerUpState, reply powerUpUsedEvent) {
+				if state.DoubleOn != 2 {
+					t.Errorf("double points on question %d, want 2", state.DoubleOn)
+				}
+			},
+		},
+		{
+			name:    "time freeze adds seconds",
+			powerUp: quizmodel.TimeFreeze,
+			check: func(t *testing.T, state *quizmodel.PowerUpState, reply powerUpUsedEvent) {
+				if state.ExtraOn != 1 || reply.ExtraSeconds != defaultTimeFreezeSeconds || reply.TimeLeft < 19 {
+					t.Errorf("got %+v, want 10 extra seconds on question 1", reply)
+				}
+			},
+		},
+		{
+			name:    "none left",
+			powerUp: quizmodel.TimeFreeze,
+			setup: func(gameState *quizmodel.GameState, player *Client) {
+				powerUpState(gameState, player.userID).Inventory[quizmodel.TimeFreeze] = 0
+			},
+			wantError: true,
+		},
+		{
+			name:    "the game is paused",
+			powerUp: quizmodel.TimeFreeze,
+			setup: func(gameState *quizmodel.GameState, player *Client) {
+				gameState.PausedAt = time.Now()
+			},
+			wantError: true,
+		},
+		{
+			name:    "the time is up",
+			powerUp: quizmodel.TimeFreeze,
+			setup: func(gameState *quizmodel.GameState, player *Client) {
+				gameState.QuestionStartTime = time.Now().Add(-time.Minute)
+			},
+			wantError: true,
+		},
+		{
+			name:    "the host has no power-ups",
+			powerUp: quizmodel.TimeFreeze,
+			setup: func(gameState *quizmodel.GameState, player *Client) {
+				player.role = roommodel.Host
+			},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			m.quizService = quiz.NewService(dbtest.New(), nil)
+			gameState := startedGame(m, "room-1", fourOptions(), fourOptions())
+			player, _ := addTestClient(t, m, "room-1")
+			if tt.setup != nil {
+				tt.setup(gameState, player)
+			}
+			left := powerUpCount()
+			if state, exists := gameState.PowerUps[player.userID]; exists {
+				left = state.Inventory[tt.powerUp]
+			}
+
+			err := UsePowerUpHandler(testContext(), jsonEvent(t, EventUsePowerUp, usePowerUpReq{PowerUp: tt.powerUp}), player)
+			if err != nil {
+				t.Fatalf("use power-up: %v", err)
+			}
+
+			if tt.wantError {
+				if _, ok := nextEventOf(t, player, EventGameError); !ok {
+					t.Errorf("the player should be told why the power-up cannot be used")
+				}
+				if state, exists := gameState.PowerUps[player.userID]; exists && state.Inventory[tt.powerUp] != left {
+					t.Errorf("the power-up was taken from the player")
+				}
+				return
+			}
+			event, ok := nextEventOf(t, player, EventPowerUpUsed)
+			reply := powerUpUsedEvent{}
+			if !ok || json.Unmarshal(event.Payload, &reply) != nil {
+				t.Fatalf("the player should be told what the power-up did")
+			}
+			state := gameState.PowerUps[player.userID]
+			if state.Inventory[tt.powerUp] != left-1 || reply.Inventory[tt.powerUp] != left-1 {
+				t.Errorf("got the inventory %v, want one %s used", state.Inventory, tt.powerUp)
+			}
+			tt.check(t, state, reply)
+		})
+	}
+}
@@ -0,0 +1,358 @@
+// A room's game runs on the instance which claimed the room, but its players can connect to any instance.
+// The instance a player lands on keeps his websocket and forwards what he sends to the room's command
+// channel on the bus. The owner of the room stands the player in as a client whose connection is a
+// relayConn, so the game treats him like any local client. What the owner writes to that client goes
+// back over the bus to the instance holding the websocket, which writes it out and keeps the pings going.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	quizmodel "brainwars/pkg/quiz/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/spf13/viper"
+)
+
+const (
+	relayConnect = "connect" // a client connected to another instance joins the room
+	relayFrame   = "frame"   // a websocket message, from the client in a command and to him in a reply
+	relayClose   = "close"   // the websocket is gone, or has to be closed
+)
+
+var errRelayClosed = errors.New("forwarded connection is closed")
+
+// RelayMessage is the envelope of a forwarded connection which travels over the bus
+type RelayMessage struct {
+	Kind     string   `json:"kind"`
+	ConnID   string   `json:"connId"`
+	Origin   string   `json:"origin"` // instance holding the websocket
+	RoomCode string   `json:"roomCode"`
+	Join     *joinReq `json:"join,omitempty"` // what the client asked for when he connected
+	Data     []byte   `json:"data,omitempty"`
+}
+
+// clientConn is what a client talks through, a websocket or a relayConn
+type clientConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	SetPongHandler(h func(appData string) error)
+	SetReadLimit(limit int64)
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+/********************** ROOM OWNER **************************************/
+
+// relayConn is the connection of a client whose websocket is held by another instance
+type relayConn struct {
+	ctx      context.Context
+	bus      RoomBus
+	connID   string
+	origin   string
+	roomCode string
+	inbound  chan []byte
+	closed   chan struct{}
+	once     sync.Once
+	onClose  func() // forgets the connection once it is closed
+}
+
+func newRelayConn(ctx context.Context, bus RoomBus, msg RelayMessage, onClose func()) *relayConn {
+	return &relayConn{
+		ctx:      ctx,
+		bus:      bus,
+		connID:   msg.ConnID,
+		origin:   msg.Origin,
+		roomCode: msg.RoomCode,
+		inbound:  make(chan []byte, egressBufferSize),
+		closed:   make(chan struct{}),
+		onClose:  onClose,
+	}
+}
+
+func (r *relayConn) ReadMessage() (int, []byte, error) {
+	select {
+	case data := <-r.inbound:
+		return websocket.TextMessage, data, nil
+	case <-r.closed:
+		return 0, nil, errRelayClosed
+	}
+}
+
+// WriteMessage sends the text messages back to the client, the pings are done by the instance holding his websocket
+func (r *relayConn) WriteMessage(messageType int, data []byte) error {
+	select {
+	case <-r.closed:
+		return errRelayClosed
+	default:
+	}
+	switch messageType {
+	case websocket.TextMessage:
+		return r.bus.SendReply(r.ctx, r.origin, RelayMessage{Kind: relayFrame, ConnID: r.connID, RoomCode: r.roomCode, Data: data})
+	case websocket.CloseMessage:
+		return r.Close()
+	}
+	return nil
+}
+
+func (r *relayConn) SetPongHandler(h func(appData string) error) {}
+func (r *relayConn) SetReadLimit(limit int64)                    {}
+func (r *relayConn) SetReadDeadline(t time.Time) error           { return nil }
+
+// Close tells the instance holding the websocket to close it
+func (r *relayConn) Close() error {
+	if !r.shutdown() {
+		return nil
+	}
+	return r.bus.SendReply(r.ctx, r.origin, RelayMessage{Kind: relayClose, ConnID: r.connID, RoomCode: r.roomCode})
+}
+
+// shutdown ends the reads of the connection, false when it was closed already
+func (r *relayConn) shutdown() bool {
+	done := false
+	r.once.Do(func() {
+		close(r.closed)
+		r.onClose()
+		done = true
+	})
+	return done
+}
+
+// push hands over a message of the client, a client who sends faster than the game reads is dropped
+func (r *relayConn) push(data []byte) {
+	select {
+	case r.inbound <- data:
+	default:
+		r.Close()
+	}
+}
+
+// reject turns the client away before he joined the room
+func (r *relayConn) reject(message string) {
+	data, _ := json.Marshal(quizmodel.QuizError{Message: message})
+	event, _ := json.Marshal(Event{Type: EventGameError, Payload: data})
+	r.WriteMessage(websocket.TextMessage, event)
+	r.Close()
+}
+
+// handleCommand is the bus subscriber for the commands of the rooms this instance runs
+func (m *Manager) handleCommand(ctx context.Context, msg RelayMessage) {
+	l := logs.GetLoggerctx(ctx)
+
+	if msg.Kind == relayConnect {
+		if msg.Join == nil {
+			l.Sugar().Errorf("connect of connection %s to room %s has no join request", msg.ConnID, msg.RoomCode)
+			return
+		}
+		conn := newRelayConn(ctx, m.bus, msg, func() {
+			m.relayMu.Lock()
+			delete(m.relayed, msg.ConnID)
+			m.relayMu.Unlock()
+		})
+		// registered before the join so that the messages sent right after the connect are kept in order
+		m.relayMu.Lock()
+		m.relayed[msg.ConnID] = conn
+		m.relayMu.Unlock()
+		go m.joinRelayed(ctx, conn, *msg.Join)
+		return
+	}
+
+	m.relayMu.Lock()
+	conn, exists := m.relayed[msg.ConnID]
+	m.relayMu.Unlock()
+	if !exists {
+		// the room moved to this instance after the connection was forwarded, the client has to connect again
+		if msg.Kind == relayFrame {
+			err := m.bus.SendReply(ctx, msg.Origin, RelayMessage{Kind: relayClose, ConnID: msg.ConnID, RoomCode: msg.RoomCode})
+			if err != nil {
+				l.Sugar().Errorf("close unknown connection %s failed %v", msg.ConnID, err)
+			}
+		}
+		return
+	}
+
+	switch msg.Kind {
+	case relayFrame:
+		conn.push(msg.Data)
+	case relayClose:
+		// the websocket is gone already, the reads end and the client is dropped like a local one
+		conn.shutdown()
+	}
+}
+
+// joinRelayed runs the join of a client connected to another instance
+func (m *Manager) joinRelayed(ctx context.Context, conn *relayConn, req joinReq) {
+	l := logs.GetLoggerctx(ctx)
+
+	m.enterRoom(req.RoomCode)
+	defer m.leaveRoom(ctx, req.RoomCode)
+
+	roomDetails, roomMember, err := m.checkJoin(ctx, req)
+	if err == nil {
+		err = m.join(ctx, req, roomDetails, roomMember, func() (clientConn, error) { return conn, nil })
+	}
+	if err != nil {
+		l.Sugar().Errorf("forwarded connection of user %s to room %s turned away %v", req.UserID, req.RoomCode, err)
+		rejected := &joinError{}
+		if errors.As(err, &rejected) {
+			conn.reject(rejected.message)
+			return
+		}
+		conn.reject("could not join the room, please try again")
+	}
+}
+
+/********************** INSTANCE HOLDING THE WEBSOCKET **************************************/
+
+// forwardedConn is a websocket this instance holds for a room running on another instance
+type forwardedConn struct {
+	id       string
+	conn     *websocket.Conn
+	owner    string
+	roomCode string
+	egress   chan []byte
+	done     chan struct{}
+	once     sync.Once
+}
+
+// close makes the writer close the websocket
+func (f *forwardedConn) close() {
+	f.once.Do(func() { close(f.done) })
+}
+
+// forwardConnection hands the websocket of a room running on another instance over to that instance
+func (m *Manager) forwardConnection(ctx context.Context, conn *websocket.Conn, owner string, req joinReq) {
+	l := logs.GetLoggerctx(ctx)
+
+	f := &forwardedConn{
+		id:       uuid.New().String(),
+		conn:     conn,
+		owner:    owner,
+		roomCode: req.RoomCode,
+		egress:   make(chan []byte, egressBufferSize),
+		done:     make(chan struct{}),
+	}
+	m.relayMu.Lock()
+	m.forwarded[f.id] = f
+	m.relayMu.Unlock()
+	go m.writeForwarded(ctx, f)
+
+	err := m.bus.SendCommand(ctx, req.RoomCode, RelayMessage{Kind: relayConnect, ConnID: f.id, Origin: m.bus.InstanceID(), RoomCode: req.RoomCode, Join: &req})
+	if err != nil {
+		l.Sugar().Errorf("forward connection of user %s to room %s failed %v", req.UserID, req.RoomCode, err)
+		m.dropForwarded(f)
+		return
+	}
+	go m.readForwarded(ctx, f)
+}
+
+// dropForwarded forgets the websocket and closes it
+func (m *Manager) dropForwarded(f *forwardedConn) {
+	m.relayMu.Lock()
+	delete(m.forwarded, f.id)
+	m.relayMu.Unlock()
+	f.close()
+}
+
+// readForwarded sends what the client writes to the instance running the room
+func (m *Manager) readForwarded(ctx context.Context, f *forwardedConn) {
+	l := logs.GetLoggerctx(ctx)
+
+	defer func() {
+		m.dropForwarded(f)
+		// the owner drops the client the same way as when his local websocket dies
+		err := m.bus.SendCommand(ctx, f.roomCode, RelayMessage{Kind: relayClose, ConnID: f.id, Origin: m.bus.InstanceID(), RoomCode: f.roomCode})
+		if err != nil {
+			l.Sugar().Errorf("tell room %s about the closed connection %s failed %v", f.roomCode, f.id, err)
+		}
+	}()
+
+	f.conn.SetPongHandler(func(string) error {
+		return f.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	readLimit := viper.GetInt64("ws.maxReadSize")
+	if readLimit <= 0 {
+		readLimit = int64(maxReadLimit)
+	}
+	f.conn.SetReadLimit(readLimit)
+
+	for {
+		_, payload, err := f.conn.ReadMessage()
+		if err != nil {
+			l.Sugar().Infof("forwarded connection %s of room %s closed %v", f.id, f.roomCode, err)
+			return
+		}
+		err = m.bus.SendCommand(ctx, f.roomCode, RelayMessage{Kind: relayFrame, ConnID: f.id, Origin: m.bus.InstanceID(), RoomCode: f.roomCode, Data: payload})
+		if err != nil {
+			l.Sugar().Errorf("forward message of connection %s to room %s failed %v", f.id, f.roomCode, err)
+			return
+		}
+	}
+}
+
+// writeForwarded writes what the instance running the room sends and pings the client. when the room
+// changes hands the websocket is closed, the client connects again and gets to the new owner
+func (m *Manager) writeForwarded(ctx context.Context, f *forwardedConn) {
+	l := logs.GetLoggerctx(ctx)
+	ticker := time.NewTicker(pingInterval)
+	defer func() {
+		ticker.Stop()
+		f.conn.Close()
+	}()
+
+	for {
+		select {
+		case data := <-f.egress:
+			err := f.conn.WriteMessage(websocket.TextMessage, data)
+			if err != nil {
+				l.Sugar().Error("write forwarded message failed", err)
+			}
+		case <-f.done:
+			f.conn.WriteMessage(websocket.CloseMessage, nil)
+			return
+		case <-ticker.C:
+			err := f.conn.WriteMessage(websocket.PingMessage, nil)
+			if err != nil {
+				l.Sugar().Error("ping error", err)
+				return
+			}
+			owner, err := m.bus.RoomOwner(ctx, f.roomCode)
+			if err == nil && owner != f.owner {
+				l.Sugar().Errorf("room %s moved from instance %s, closing connection %s", f.roomCode, f.owner, f.id)
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handleReply is the bus subscriber for what the room owners send to the websockets this instance holds
+func (m *Manager) handleReply(ctx context.Context, msg RelayMessage) {
+	l := logs.GetLoggerctx(ctx)
+
+	m.relayMu.Lock()
+	f, exists := m.forwarded[msg.ConnID]
+	m.relayMu.Unlock()
+	if !exists {
+		return
+	}
+
+	switch msg.Kind {
+	case relayFrame:
+		select {
+		case f.egress <- msg.Data:
+		default:
+			l.Sugar().Errorf("forwarded connection %s of room %s is too slow, dropping it", f.id, f.roomCode)
+			m.dropForwarded(f)
+		}
+	case relayClose:
+		m.dropForwarded(f)
+	}
+}
@@ -0,0 +1,306 @@
+package websocket
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz"
+	"brainwars/pkg/room"
+	roommodel "brainwars/pkg/room/model"
+	usermodel "brainwars/pkg/users/model"
+	"brainwars/pkg/util"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// cluster is the redis several instances share, every instance talks to it through its clusterBus
+type cluster struct {
+	mu        sync.Mutex
+	owners    map[string]string // map[roomCode]instanceID
+	claims    []string          // rooms in the order they were claimed
+	instances map[string]*clusterBus
+}
+
+func newCluster() *cluster {
+	return &cluster{owners: make(map[string]string), instances: make(map[string]*clusterBus)}
+}
+
+type clusterBus struct {
+	cluster     *cluster
+	id          string
+	subscribers []DeliverFunc
+	onCommand   RelayFunc
+	onReply     RelayFunc
+}
+
+func (c *cluster) bus(id string) *clusterBus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := &clusterBus{cluster: c, id: id}
+	c.instances[id] = b
+	return b
+}
+
+func (c *cluster) owner(roomCode string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.owners[roomCode]
+}
+
+func (b *clusterBus) Publish(ctx context.Context, roomCode string, event Event) error {
+	b.cluster.mu.Lock()
+	subscribers := []DeliverFunc{}
+	for _, instance := range b.cluster.instances {
+		subscribers = append(subscribers, instance.subscribers...)
+	}
+	b.cluster.mu.Unlock()
+	for _, deliver := range subscribers {
+		deliver(ctx, roomCode, event)
+	}
+	return nil
+}
+
+func (b *clusterBus) Subscribe(ctx context.Context, deliver DeliverFunc) error {
+	b.cluster.mu.Lock()
+	b.subscribers = append(b.subscribers, deliver)
+	b.cluster.mu.Unlock()
+	return nil
+}
+
+func (b *clusterBus) InstanceID() string {
+	return b.id
+}
+
+func (b *clusterBus) ClaimRoom(ctx context.Context, roomCode string) (string, error) {
+	b.cluster.mu.Lock()
+	defer b.cluster.mu.Unlock()
+	b.cluster.claims = append(b.cluster.claims, roomCode)
+	if b.cluster.owners[roomCode] == "" {
+		b.cluster.owners[roomCode] = b.id
+	}
+	return b.cluster.owners[roomCode], nil
+}
+
+func (b *clusterBus) RoomOwner(ctx context.Context, roomCode string) (string, error) {
+	return b.cluster.owner(roomCode), nil
+}
+
+func (b *clusterBus) ReleaseRoom(ctx context.Context, roomCode string) error {
+	b.cluster.mu.Lock()
+	defer b.cluster.mu.Unlock()
+	if b.cluster.owners[roomCode] == b.id {
+		delete(b.cluster.owners, roomCode)
+	}
+	return nil
+}
+
+func (b *clusterBus) SendCommand(ctx context.Context, roomCode string, msg RelayMessage) error {
+	b.cluster.mu.Lock()
+	owner := b.cluster.instances[b.cluster.owners[roomCode]]
+	b.cluster.mu.Unlock()
+	if owner != nil && owner.onCommand != nil {
+		owner.onCommand(ctx, msg)
+	}
+	return nil
+}
+
+func (b *clusterBus) SendReply(ctx context.Context, instanceID string, msg RelayMessage) error {
+	b.cluster.mu.Lock()
+	target := b.cluster.instances[instanceID]
+	b.cluster.mu.Unlock()
+	if target != nil && target.onReply != nil {
+		target.onReply(ctx, msg)
+	}
+	return nil
+}
+
+func (b *clusterBus) SubscribeRelay(ctx context.Context, onCommand RelayFunc, onReply RelayFunc) error {
+	b.cluster.mu.Lock()
+	b.onCommand = onCommand
+	b.onReply = onReply
+	b.cluster.mu.Unlock()
+	return nil
+}
+
+func (b *clusterBus) Close() error {
+	return nil
+}
+
+// clientCount is the number of clients the manager holds for the room
+func clientCount(m *Manager, roomCode string) int {
+	m.RLock()
+	defer m.RUnlock()
+	return len(m.clients[roomCode])
+}
+
+// newOwnerAndEdge are two instances, the owner runs the public room and the edge holds a spectator's websocket
+func newOwnerAndEdge(t *testing.T) (owner *Manager, edge *Manager, c *cluster) {
+	t.Helper()
+	c = newCluster()
+	owner = newTestManagerOn(t, c.bus("owner"))
+	edge = newTestManagerOn(t, c.bus("edge"))
+
+	db := dbtest.New()
+	db.Returns("GetRoomByRoomCode", roomRow("ROOM", roommodel.MP, roommodel.Waiting, false, true))
+	owner.roomService = room.NewService(db, nil, nil)
+	if !owner.runsHere(owner.claimRoom(testContext(), "ROOM")) {
+		t.Fatalf("the owner should claim the room")
+	}
+	return owner, edge, c
+}
+
+func TestForwardedClientTalksToTheOwnerOfTheRoom(t *testing.T) {
+	owner, edge, _ := newOwnerAndEdge(t)
+	ctx := testContext()
+	server, peer := newTestConn(t)
+
+	edge.forwardConnection(ctx, server, "owner", joinReq{RoomCode: "ROOM", UserID: uuid.New(), UserName: "watcher", Spectate: true})
+
+	// the spectator joins the game on the owner and hears the room events from there
+	eventually(t, "the spectator to join on the owner", func() bool { return clientCount(owner, "ROOM") == 1 })
+	if clientCount(edge, "ROOM") != 0 {
+		t.Errorf("the edge should not run the room")
+	}
+	count := spectatorCountEvent{}
+	if err := json.Unmarshal(readEventOf(t, peer, EventSpectatorCount).Payload, &count); err != nil || count.Count != 1 {
+		t.Errorf("got the spectator count %+v, %v; want 1", count, err)
+	}
+	owner.broadcast(ctx, "ROOM", Event{Type: EventLeaderBoard})
+	readEventOf(t, peer, EventLeaderBoard)
+
+	// what he sends is handled by the game on the owner
+	command, _ := json.Marshal(Event{Type: EventChatMessage, Payload: json.RawMessage(`{}`)})
+	if err := peer.WriteJSON(json.RawMessage(command)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	readEventOf(t, peer, EventGameError)
+
+	// the browser goes away, the owner drops the client like a local one
+	peer.Close()
+	eventually(t, "the owner to drop the client", func() bool { return clientCount(owner, "ROOM") == 0 })
+	owner.relayMu.Lock()
+	relayed := len(owner.relayed)
+	owner.relayMu.Unlock()
+	edge.relayMu.Lock()
+	forwarded := len(edge.forwarded)
+	edge.relayMu.Unlock()
+	if relayed != 0 || forwarded != 0 {
+		t.Errorf("the closed connection is still known, %d relayed and %d forwarded", relayed, forwarded)
+	}
+}
+
+func TestOwnerClosesTheForwardedWebsocket(t *testing.T) {
+	owner, edge, _ := newOwnerAndEdge(t)
+	ctx := testContext()
+	server, peer := newTestConn(t)
+
+	edge.forwardConnection(ctx, server, "owner", joinReq{RoomCode: "ROOM", UserID: uuid.New(), UserName: "watcher", Spectate: true})
+	eventually(t, "the spectator to join on the owner", func() bool { return clientCount(owner, "ROOM") == 1 })
+
+	// a kick or a slow client closes the connection on the owner
+	owner.RLock()
+	for client := range owner.clients["ROOM"] {
+		client.connection.Close()
+	}
+	owner.RUnlock()
+
+	for {
+		peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if _, _, err := peer.ReadMessage(); err != nil {
+			break
+		}
+	}
+	eventually(t, "the owner to drop the client", func() bool { return clientCount(owner, "ROOM") == 0 })
+}
+
+func TestForwardedClientTurnedAwayByTheOwner(t *testing.T) {
+	owner, edge, _ := newOwnerAndEdge(t)
+	ctx := testContext()
+	// the room got locked after the spectator landed on the edge
+	db := dbtest.New()
+	db.Returns("GetRoomByRoomCode", roomRow("ROOM", roommodel.MP, roommodel.Waiting, true, true))
+	owner.roomService = room.NewService(db, nil, nil)
+	server, peer := newTestConn(t)
+
+	edge.forwardConnection(ctx, server, "owner", joinReq{RoomCode: "ROOM", UserID: uuid.New(), UserName: "watcher", Spectate: true})
+
+	event := readEventOf(t, peer, EventGameError)
+	if string(event.Payload) == "" {
+		t.Errorf("the spectator should be told why he was turned away")
+	}
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := peer.ReadMessage(); err == nil {
+		t.Errorf("the websocket of the turned away spectator should be closed")
+	}
+	if clientCount(owner, "ROOM") != 0 {
+		t.Errorf("the turned away spectator joined the room")
+	}
+}
+
+// serveWS runs ServeWS for the user the way the router would
+func serveWS(m *Manager, userID uuid.UUID, query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	ctx := util.SetUserInfoInctx(testContext(), &usermodel.UserInfo{ID: userID, UserName: "player"})
+	c.Request = httptest.NewRequest("GET", "/ws?"+query, nil).WithContext(ctx)
+	m.ServeWS(c)
+	return recorder
+}
+
+func TestServeWSClaimsTheRoomOnlyForValidConnections(t *testing.T) {
+	userID := uuid.New()
+	failedJob := []any{
+		pgtype.UUID{Bytes: uuid.New(), Valid: true}, "ROOM", pgtype.Text{String: "go", Valid: true}, int32(3), "FAILED", pgtype.Text{String: "llm is down", Valid: true},
+		pgtype.Timestamp{}, pgtype.Timestamp{}, "owner", "owner", "instance",
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(db *dbtest.FakeDB)
+		claimed bool // the room was claimed on the way
+	}{
+		{"no such room", func(db *dbtest.FakeDB) {}, false},
+		{"not a member", func(db *dbtest.FakeDB) {
+			db.Returns("GetRoomByRoomCode", roomRow("ROOM", roommodel.MP, roommodel.Waiting, false, false))
+		}, false},
+		{"banned", func(db *dbtest.FakeDB) {
+			db.Returns("GetRoomByRoomCode", roomRow("ROOM", roommodel.MP, roommodel.Waiting, false, false))
+			db.Returns("GetRoomMemberByRoomCodeAndUserID", memberRow("ROOM", userID, "player", roommodel.BannedQuiz, roommodel.Player))
+		}, false},
+		{"kicked", func(db *dbtest.FakeDB) {
+			db.Returns("GetRoomByRoomCode", roomRow("ROOM", roommodel.MP, roommodel.Waiting, false, false))
+			db.Returns("GetRoomMemberByRoomCodeAndUserID", memberRow("ROOM", userID, "player", roommodel.KickedQuiz, roommodel.Player))
+		}, false},
+		{"question generation failed", func(db *dbtest.FakeDB) {
+			db.Returns("GetRoomByRoomCode", roomRow("ROOM", roommodel.MP, roommodel.Waiting, false, false))
+			db.Returns("GetRoomMemberByRoomCodeAndUserID", memberRow("ROOM", userID, "player", roommodel.JoinQuiz, roommodel.Player))
+			db.Returns("GetLatestGenerationJobByRoomCode", failedJob)
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCluster()
+			m := newTestManagerOn(t, c.bus("instance"))
+			db := dbtest.New()
+			tt.setup(db)
+			m.roomService = room.NewService(db, nil, nil)
+			m.quizService = quiz.NewService(db, nil)
+
+			serveWS(m, userID, "roomCode=ROOM")
+
+			if claimed := len(c.claims) > 0; claimed != tt.claimed {
+				t.Errorf("room claimed %v, want %v", claimed, tt.claimed)
+			}
+			// a connection which was turned away leaves no claim behind to be renewed
+			if owner := c.owner("ROOM"); owner != "" {
+				t.Errorf("the room is still claimed by %s", owner)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// A dropped connection doesn't end the game for the player anymore.
+// Every player gets a resume token when he joins a room. When his socket dies while the game is running
+// his client slot (answers and score) is parked for a grace window. If he comes back with the same token
+// within that window he gets his slot back along with the running question, the time left and the leaderboard.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+const defaultResumeGraceSecond = 60
+
+type resumeTokenEvent struct {
+	Token       string `json:"token"`
+	GraceSecond int    `json:"graceSecond"`
+}
+
+// suspendedClient is a client whose connection dropped and is waiting to be resumed
+type suspendedClient struct {
+	client *Client
+	timer  *time.Timer // removes the client for good once the grace window is over
+}
+
+func resumeGraceSecond() int {
+	grace := viper.GetInt("game.resumeGraceSecond")
+	if grace <= 0 {
+		grace = defaultResumeGraceSecond
+	}
+	return grace
+}
+
+// issueResumeToken hands out a fresh resume token to the client
+func (m *Manager) issueResumeToken(ctx context.Context, c *Client) {
+	token := uuid.New().String()
+	m.Lock()
+	c.resumeToken = token
+	m.Unlock()
+
+	data, _ := json.Marshal(resumeTokenEvent{
+		Token:       token,
+		GraceSecond: resumeGraceSecond(),
+	})
+	c.egress <- Event{Type: EventResumeToken, Payload: data}
+}
+
+// canSuspend tells if the dropped client has to be kept for the grace window, caller must hold the manager lock
+func (m *Manager) canSuspend(c *Client) bool {
+	if c.isBot || c.isHost() || c.resumeToken == "" {
+		return false // the host does not need a seat, he can come back any time
+	}
+	gameState, exists := m.gameStates[c.roomCode]
+	if !exists || gameState.RoomStatus != roommodel.Started {
+		return false
+	}
+	for _, participant := range gameState.Participants {
+		if participant.UserID == c.userID && participant.IsExited {
+			return false // left the game on purpose
+		}
+	}
+	return true
+}
+
+// suspendClient parks the client till the grace window is over, caller must hold the manager lock
+func (m *Manager) suspendClient(ctx context.Context, c *Client) {
+	l := logs.GetLoggerctx(ctx)
+
+	if _, exists := m.suspended[c.roomCode]; !exists {
+		m.suspended[c.roomCode] = make(map[uuid.UUID]*suspendedClient)
+	}
+	if old, exists := m.suspended[c.roomCode][c.userID]; exists {
+		old.timer.Stop()
+	}
+	grace := time.Duration(resumeGraceSecond()) * time.Second
+	m.suspended[c.roomCode][c.userID] = &suspendedClient{
+		client: c,
+		timer: time.AfterFunc(grace, func() {
+			m.expireSuspendedClient(ctx, c)
+		}),
+	}
+	l.Sugar().Infof("user %s in room %s disconnected, waiting %v for him to resume", c.userID, c.roomCode, grace)
+}
+
+// expireSuspendedClient removes the client whose grace window is over and saves his answers
+func (m *Manager) expireSuspendedClient(ctx context.Context, c *Client) {
+	l := logs.GetLoggerctx(ctx)
+
+	m.Lock()
+	s, exists := m.suspended[c.roomCode][c.userID]
+	if !exists || s.client != c {
+		m.Unlock()
+		return // already resumed
+	}
+	delete(m.suspended[c.roomCode], c.userID)
+	if len(m.suspended[c.roomCode]) == 0 {
+		delete(m.suspended, c.roomCode)
+	}
+	if gameState, exists := m.gameStates[c.roomCode]; exists {
+		for i := range gameState.Participants {
+			if gameState.Participants[i].UserID == c.userID {
+				gameState.Participants[i].IsExited = true
+			}
+		}
+	}
+	m.Unlock()
+
+	l.Sugar().Infof("resume window of user %s in room %s is over", c.userID, c.roomCode)
+	err := m.updateAnswerHistory(ctx, c.ansHistory)
+	if err != nil {
+		l.Sugar().Error("update answer history of the expired client failed", err)
+	}
+}
+
+// canTakeOver tells if the resume token still has a slot to take over in the room
+func (m *Manager) canTakeOver(roomCode string, userID uuid.UUID, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+	if s, exists := m.suspended[roomCode][userID]; exists && s.client.resumeToken == token {
+		return true
+	}
+	for client := range m.clients[roomCode] {
+		if client.userID == userID && client.resumeToken == token {
+			return true
+		}
+	}
+	return false
+}
+
+// takeOverClient detaches the slot the resume token belongs to. it is either a suspended client
+// or a client whose dead connection is not detected yet (mobile networks dont always close the socket)
+func (m *Manager) takeOverClient(roomCode string, userID uuid.UUID, token string) *Client {
+	if token == "" {
+		return nil
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if s, exists := m.suspended[roomCode][userID]; exists && s.client.resumeToken == token {
+		s.timer.Stop()
+		delete(m.suspended[roomCode], userID)
+		if len(m.suspended[roomCode]) == 0 {
+			delete(m.suspended, roomCode)
+		}
+		return s.client
+	}
+	for client := range m.clients[roomCode] {
+		if client.userID == userID && client.resumeToken == token {
+			delete(m.clients[roomCode], client)
+			return client
+		}
+	}
+	return nil
+}
+
+// releaseSuspendedClients stops waiting for the dropped clients of the room and hands them over
+func (m *Manager) releaseSuspendedClients(roomCode string) []*Client {
+	m.Lock()
+	defer m.Unlock()
+
+	clients := []*Client{}
+	for _, s := range m.suspended[roomCode] {
+		s.timer.Stop()
+		clients = append(clients, s.client)
+	}
+	delete(m.suspended, roomCode)
+	return clients
+}
+
+// resumeClient moves the answers and state of the old slot to the new client. an eliminated player
+// comes back as the spectator he was turned into
+func (m *Manager) resumeClient(old, c *Client) {
+	m.Lock()
+	c.ansHistory = old.ansHistory
+	c.UserStatus = old.UserStatus
+	c.role = old.role
+	c.eliminated = old.eliminated
+	m.Unlock()
+	if old.connection != nil {
+		// the read goroutine of the old client fails and exits, it is not in the client list anymore
+		old.connection.Close()
+	}
+}
+
+// replayGameState brings a resumed client back to where the room is
+func (m *Manager) replayGameState(ctx context.Context, c *Client) error {
+	startData, _ := json.Marshal(struct {
+		Status  string    `json:"status"`
+		Message string    `json:"message"`
+		StartAt time.Time `json:"startAt"`
+	}{
+		Status:  "start_game",
+		Message: "Welcome back, the game is on.",
+		StartAt: time.Now(),
+	})
+	c.egress <- Event{Type: EventStartGame, Payload: startData}
+
+	err := m.sendCurrentQuestion(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	m.Lock()
+	gameState, exists := m.gameStates[c.roomCode]
+	if !exists {
+		m.Unlock()
+		return nil
+	}
+	lbEvent := buildLeaderBoardEvent(gameState)
+	m.Unlock()
+	c.egress <- lbEvent
+
+	return nil
+}
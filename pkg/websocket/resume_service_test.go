@@ -0,0 +1,21 @@
+package websocket
+
+import (
+	roommodel "brainwars/pkg/room/model"
+	"testing"
+)
+
+func TestResumeClientKeepsTheRoleOfTheSlot(t *testing.T) {
+	m := newTestManager(t)
+	old, _ := addTestClient(t, m, "room-1")
+	old.role = roommodel.Spectator
+	old.eliminated = true
+
+	server, _ := newTestConn(t)
+	resumed := NewClient(server, m, "room-1", false, "", old.userID, old.UserName, old.room)
+	m.resumeClient(old, resumed)
+
+	if !resumed.isSpectator() || !resumed.eliminated {
+		t.Errorf("an eliminated player came back as %s, eliminated %v", resumed.role, resumed.eliminated)
+	}
+}
@@ -0,0 +1,139 @@
+// A closed question is followed by a short reveal phase before the next one is sent. The room gets the
+// right answer, how many players picked each option, the fastest right answer and what the question did
+// to the score of every player. The timer and the bots wait for the reveal to finish before moving on.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+const defaultRevealSeconds = 5
+
+func revealDuration() time.Duration {
+	seconds := viper.GetInt("game.revealSeconds")
+	if seconds <= 0 {
+		seconds = defaultRevealSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+type fastestAnswer struct {
+	UserID    uuid.UUID `json:"userId"`
+	Username  string    `json:"username"`
+	LatencyMs int       `json:"latencyMs"`
+}
+
+type revealScore struct {
+	UserID    uuid.UUID `json:"userId"`
+	Username  string    `json:"username"`
+	Answered  bool      `json:"answered"`
+	IsCorrect bool      `json:"isCorrect"`
+	Points    int       `json:"points"` // what the question added to the score
+	Score     int       `json:"score"`
+	// the answer written out for the question types which are not single choice
+	GivenAnswer string `json:"givenAnswer,omitempty"`
+}
+
+type questionRevealEvent struct {
+	QuestionID    uuid.UUID      `json:"questionId"`
+	QuestionIndex int            `json:"questionIndex"`
+	Answer        int            `json:"answer"`            // option id of the right answer
+	Answers       []int          `json:"answers,omitempty"` // multi select: the right options, ordering: the right order
+	AnswerText    string         `json:"answerText"`
+	Counts        map[int]int    `json:"counts"` // option id -> number of participants who chose it
+	Answered      int            `json:"answered"`
+	Participants  int            `json:"participants"`
+	Fastest       *fastestAnswer `json:"fastest,omitempty"` // quickest right answer, empty when nobody got it right
+	Scores        []revealScore  `json:"scores"`
+	RevealSeconds int            `json:"revealSeconds"` // time till the next question
+}
+
+// revealAnswer sends the reveal of the closed question to the room and the bots, the room stays in the
+// reveal phase till the next question is sent
+func revealAnswer(ctx context.Context, manager *Manager, roomCode string, questionIndex int) {
+	l := logs.GetLoggerctx(ctx)
+
+	manager.Lock()
+	gameState, exists := manager.gameStates[roomCode]
+	if !exists || questionIndex < 0 || questionIndex >= len(gameState.Questions.QuestionData) {
+		manager.Unlock()
+		return
+	}
+	gameState.Revealing = true
+	question := gameState.Questions.QuestionData[questionIndex]
+	kind := quiz.GetQuestionKind(question.Type)
+	reveal := questionRevealEvent{
+		QuestionID:    question.ID,
+		QuestionIndex: questionIndex + 1,
+		Answer:        question.Answer,
+		Answers:       question.Answers,
+		AnswerText:    kind.RightAnswer(question),
+		Scores:        []revealScore{},
+		RevealSeconds: int(revealDuration().Seconds()),
+	}
+	reveal.Counts, reveal.Answered, reveal.Participants = countAnswers(gameState, question)
+	for _, participant := range gameState.Participants {
+		if participant.IsExited || participant.Eliminated {
+			continue
+		}
+		score := revealScore{
+			UserID:   participant.UserID,
+			Username: participant.Username,
+			Score:    participant.Score,
+		}
+		if participant.LastAnsweredQestion == question.ID {
+			score.Answered = true
+			score.IsCorrect = kind.Credit(question, participant.LastChoosenOption, participant.LastAnswerData) >= 1
+			score.Points = participant.QuestionPoints
+			if !question.SingleAnswer() {
+				score.GivenAnswer = kind.DescribeAnswer(question, participant.LastChoosenOption, participant.LastAnswerData)
+			}
+		}
+		if score.IsCorrect && (reveal.Fastest == nil || participant.LatencyMs < reveal.Fastest.LatencyMs) {
+			reveal.Fastest = &fastestAnswer{
+				UserID:    participant.UserID,
+				Username:  participant.Username,
+				LatencyMs: participant.LatencyMs,
+			}
+		}
+		reveal.Scores = append(reveal.Scores, score)
+	}
+	manager.Unlock()
+
+	data, err := json.Marshal(reveal)
+	if err != nil {
+		l.Sugar().Error("question reveal json marshal failed", err)
+		return
+	}
+	event := Event{Type: EventQuestionReveal, Payload: data}
+	manager.broadcast(ctx, roomCode, event)
+	// the bots drop the answer they were about to give
+	manager.broadcastToBots(ctx, roomCode, event)
+}
+
+// advanceQuestion closes the question at the index and sends the next question once the reveal is over.
+// the wait runs on the question timer of the room so it is stopped with the room
+func advanceQuestion(ctx context.Context, manager *Manager, roomCode string, questionIndex int) {
+	finishQuestion(ctx, manager, roomCode, questionIndex)
+
+	manager.Lock()
+	defer manager.Unlock()
+	if timer, exists := manager.questionTimers[roomCode]; exists {
+		timer.Stop()
+	}
+	manager.questionTimers[roomCode] = time.AfterFunc(revealDuration(), func() {
+		l := logs.GetLoggerctx(ctx)
+		err := sendNextQuestion(ctx, manager, roomCode)
+		if err != nil {
+			l.Sugar().Error("send next question failed", err)
+		}
+	})
+}
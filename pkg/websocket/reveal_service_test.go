@@ -0,0 +1,89 @@
+package websocket
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	usermodel "brainwars/pkg/users/model"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+func TestRevealIsFollowedByTheNextQuestion(t *testing.T) {
+	viper.Set("game.revealSeconds", 1)
+	t.Cleanup(func() { viper.Set("game.revealSeconds", nil) })
+	m := newTestManager(t)
+	m.quizService = quiz.NewService(dbtest.New(), nil)
+	question, next := fourOptions(), fourOptions()
+	gameState := startedGame(m, "room-1", question, next)
+	gameState.Participants = []quizmodel.Participant{
+		answered("fast", question, 1, 900),
+		answered("slow", question, 1, 3000),
+		answered("wrong", question, 2, 500),
+		{UserID: uuid.New(), Username: "missed"},
+	}
+	watcher, _ := addTestClient(t, m, "room-1")
+	bot := NewClient(nil, m, "room-1", true, usermodel.Sec10, uuid.New(), "bot", &roommodel.Room{RoomCode: "room-1"})
+	bot.botEvents = make(chan Event, 1)
+	m.addBot("room-1", bot)
+	t.Cleanup(func() { m.stopQuestionTimer("room-1") })
+
+	// the time of the first question is up
+	m.Lock()
+	gameState.CurrentQuestionIndex = 1
+	m.Unlock()
+	closed := time.Now()
+	advanceQuestion(testContext(), m, "room-1", 0)
+
+	event, ok := nextEventOf(t, watcher, EventQuestionReveal)
+	reveal := questionRevealEvent{}
+	if !ok || json.Unmarshal(event.Payload, &reveal) != nil {
+		t.Fatalf("the room should get the reveal of the question")
+	}
+	if reveal.QuestionID != question.ID || reveal.Answer != 1 || reveal.RevealSeconds != 1 {
+		t.Errorf("got the reveal %+v, want option 1 of the first question for a second", reveal)
+	}
+	if reveal.Counts[1] != 2 || reveal.Counts[2] != 1 || reveal.Answered != 3 || reveal.Participants != 4 {
+		t.Errorf("got the counts %v with %d of %d answered, want 2 on the right option and 1 on the wrong one", reveal.Counts, reveal.Answered, reveal.Participants)
+	}
+	if reveal.Fastest == nil || reveal.Fastest.Username != "fast" || len(reveal.Scores) != 4 {
+		t.Errorf("got the fastest %+v and %d scores, want fast and every player", reveal.Fastest, len(reveal.Scores))
+	}
+	select {
+	case event := <-bot.botEvents:
+		if event.Type != EventQuestionReveal {
+			t.Errorf("the bot got %s, want %s", event.Type, EventQuestionReveal)
+		}
+	default:
+		t.Errorf("the bot should drop its answer with the reveal")
+	}
+
+	// the answers to the closed question are turned down during the reveal
+	player, _ := addTestClient(t, m, "room-1")
+	if err := SubmitAnswerHandler(testContext(), answerEvent(t, question, 1), player); err != nil {
+		t.Fatalf("submit answer: %v", err)
+	}
+	if _, ok := nextEventOf(t, player, EventGameError); !ok {
+		t.Errorf("an answer during the reveal should be turned down")
+	}
+
+	var sent questionEvent
+	eventually(t, "the next question", func() bool {
+		event, ok := nextEventOf(t, watcher, EventNewQuestion)
+		return ok && json.Unmarshal(event.Payload, &sent) == nil
+	})
+	if waited := time.Since(closed); waited < 900*time.Millisecond {
+		t.Errorf("the next question came %s after the question closed, want the reveal to last a second", waited)
+	}
+	m.RLock()
+	revealing := gameState.Revealing
+	m.RUnlock()
+	if sent.Question.ID != next.ID || sent.QuestionIndex != 2 || revealing {
+		t.Errorf("got question %d, want the second question with the reveal over", sent.QuestionIndex)
+	}
+}
@@ -0,0 +1,80 @@
+// Spectators are friends who come in late and only want to watch. They connect through their own
+// join path, are never stored as room members and never become participants. They get the same room
+// events as the players.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	roommodel "brainwars/pkg/room/model"
+	usermodel "brainwars/pkg/users/model"
+	"context"
+	"encoding/json"
+)
+
+type spectatorCountEvent struct {
+	Count int `json:"count"`
+}
+
+// isSpectator tells if the client only watches the room, caller must hold the manager lock
+func (c *Client) isSpectator() bool {
+	return c.role == roommodel.Spectator
+}
+
+// joinSpectator attaches a read only client to the room
+func (m *Manager) joinSpectator(ctx context.Context, req joinReq, roomDetails *roommodel.Room, upgrade func() (clientConn, error)) error {
+	l := logs.GetLoggerctx(ctx)
+	roomCode := req.RoomCode
+
+	conn, err := upgrade()
+	if err != nil {
+		l.Sugar().Error("websocket upgrade error:", err)
+		return err
+	}
+
+	client := NewClient(conn, m, roomCode, false, "", req.UserID, req.UserName, roomDetails)
+	client.role = roommodel.Spectator
+	client.UserStatus = usermodel.UserJoined
+	go m.readMessages(ctx, client)
+	go m.writeUsersMessages(ctx, client)
+	m.addClient(client)
+	m.sendSpectatorCount(ctx, roomCode)
+
+	// the game state is created by the first player so that he sets up the bots, a spectator only reads it
+	m.RLock()
+	gameState, exists := m.gameStates[roomCode]
+	started := exists && gameState.RoomStatus == roommodel.Started && gameState.Questions != nil && len(gameState.Questions.QuestionData) > 0
+	m.RUnlock()
+
+	if started {
+		err = m.replayGameState(ctx, client)
+		if err != nil {
+			l.Sugar().Error("replay game state for spectator failed ", err)
+		}
+		return nil
+	}
+	if exists {
+		m.sendGenerationJob(client)
+		// lets the spectator see who is in the lobby
+		err = m.sendRoomMemberState(ctx, roomCode, client, usermodel.UserJoined)
+		if err != nil {
+			l.Sugar().Error("send lobby state to spectator failed ", err)
+		}
+	}
+	return nil
+}
+
+// sendSpectatorCount tells the room how many spectators are watching
+func (m *Manager) sendSpectatorCount(ctx context.Context, roomCode string) {
+	count := 0
+	m.RLock()
+	for client := range m.clients[roomCode] {
+		if client.isSpectator() {
+			count++
+		}
+	}
+	m.RUnlock()
+
+	data, _ := json.Marshal(spectatorCountEvent{Count: count})
+	m.broadcast(ctx, roomCode, Event{Type: EventSpectatorCount, Payload: data})
+}
@@ -0,0 +1,73 @@
+// Players build up streaks by answering right in a row. A streak is settled once a question is closed,
+// so changing an answer during the question doesn't start and break streaks back and forth.
+// The room is told when a streak starts or breaks and the best streak of every player is stored
+// with the leaderboard at the end of the game.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const minStreak = 2 // right answers in a row before we call it a streak
+
+type StreakStatus string
+
+const (
+	StreakStarted StreakStatus = "started"
+	StreakBroken  StreakStatus = "broken"
+)
+
+type streakEvent struct {
+	UserID   uuid.UUID    `json:"userId"`
+	Username string       `json:"username"`
+	Status   StreakStatus `json:"status"`
+	Streak   int          `json:"streak"` // length of the streak which started or broke
+}
+
+// settleStreaks updates the streaks of the participants once the question at the index is closed and
+// tells the room about every streak which started or broke
+func settleStreaks(ctx context.Context, manager *Manager, roomCode string, questionIndex int) {
+	l := logs.GetLoggerctx(ctx)
+
+	manager.Lock()
+	gameState, exists := manager.gameStates[roomCode]
+	if !exists || questionIndex < 0 || questionIndex >= len(gameState.Questions.QuestionData) {
+		manager.Unlock()
+		return
+	}
+	question := gameState.Questions.QuestionData[questionIndex]
+
+	events := []streakEvent{}
+	for i := range gameState.Participants {
+		participant := &gameState.Participants[i]
+		before := participant.StreakBefore
+		if participant.LastAnsweredQestion != question.ID {
+			// the question was missed, so the streak is gone
+			before = participant.Streak
+			participant.Streak = 0
+		}
+
+		switch {
+		case participant.Streak >= minStreak && before < minStreak:
+			events = append(events, streakEvent{UserID: participant.UserID, Username: participant.Username, Status: StreakStarted, Streak: participant.Streak})
+		case participant.Streak == 0 && before >= minStreak:
+			events = append(events, streakEvent{UserID: participant.UserID, Username: participant.Username, Status: StreakBroken, Streak: before})
+		}
+		participant.BestStreak = max(participant.BestStreak, participant.Streak)
+	}
+	manager.Unlock()
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			l.Sugar().Error("streak event json marshal failed", err)
+			continue
+		}
+		manager.broadcast(ctx, roomCode, Event{Type: EventStreak, Payload: data})
+	}
+}
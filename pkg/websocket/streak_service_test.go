@@ -0,0 +1,105 @@
+package websocket
+
+import (
+	"brainwars/pkg/db/dbtest"
+	"brainwars/pkg/quiz"
+	quizmodel "brainwars/pkg/quiz/model"
+	"brainwars/pkg/room"
+	roommodel "brainwars/pkg/room/model"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSettleStreaks(t *testing.T) {
+	tests := []struct {
+		name       string
+		answers    string // one per question: r right, w wrong, c wrong changed to right, - missed
+		wantStreak int
+		wantBest   int
+		wantEvents []StreakStatus
+	}{
+		{name: "right answers in a row", answers: "rrr", wantStreak: 3, wantBest: 3, wantEvents: []StreakStatus{StreakStarted}},
+		{name: "one right answer is no streak", answers: "wr", wantStreak: 1, wantBest: 1},
+		{name: "a wrong answer breaks it", answers: "rrw", wantStreak: 0, wantBest: 2, wantEvents: []StreakStatus{StreakStarted, StreakBroken}},
+		{name: "a missed question breaks it once the question closes", answers: "rr-", wantStreak: 0, wantBest: 2, wantEvents: []StreakStatus{StreakStarted, StreakBroken}},
+		{name: "a changed answer counts once", answers: "rrc", wantStreak: 3, wantBest: 3, wantEvents: []StreakStatus{StreakStarted}},
+		{name: "the best streak stays after a break", answers: "rrrwr", wantStreak: 1, wantBest: 3, wantEvents: []StreakStatus{StreakStarted, StreakBroken}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			questions := []*quizmodel.QuestionData{}
+			for range tt.answers {
+				questions = append(questions, &quizmodel.QuestionData{ID: uuid.New(), Answer: 1})
+			}
+			gameState := startedGame(m, "room-1", questions...)
+			gameState.Participants = []quizmodel.Participant{{UserID: uuid.New(), Username: "player"}}
+			watcher, _ := addTestClient(t, m, "room-1")
+
+			events := []StreakStatus{}
+			for i, answer := range tt.answers {
+				gameState.CurrentQuestionIndex = i
+				participant := &gameState.Participants[0]
+				switch answer {
+				case 'r':
+					scoreAnswer(gameState, participant, 1, time.Second)
+				case 'w':
+					scoreAnswer(gameState, participant, 0, time.Second)
+				case 'c':
+					scoreAnswer(gameState, participant, 0, time.Second)
+					scoreAnswer(gameState, participant, 1, 2*time.Second)
+				}
+
+				settleStreaks(testContext(), m, "room-1", i)
+
+				for {
+					event, ok := nextEventOf(t, watcher, EventStreak)
+					if !ok {
+						break
+					}
+					streak := streakEvent{}
+					if err := json.Unmarshal(event.Payload, &streak); err != nil {
+						t.Fatalf("streak event json: %v", err)
+					}
+					events = append(events, streak.Status)
+				}
+			}
+
+			participant := gameState.Participants[0]
+			if participant.Streak != tt.wantStreak || participant.BestStreak != tt.wantBest {
+				t.Errorf("got the streak %d and the best %d, want %d and %d", participant.Streak, participant.BestStreak, tt.wantStreak, tt.wantBest)
+			}
+			if len(events) != len(tt.wantEvents) {
+				t.Fatalf("the room heard %v, want %v", events, tt.wantEvents)
+			}
+			for i := range events {
+				if events[i] != tt.wantEvents[i] {
+					t.Errorf("the room heard %v, want %v", events, tt.wantEvents)
+				}
+			}
+		})
+	}
+}
+
+func TestBestStreakIsStoredWithTheLeaderBoard(t *testing.T) {
+	m := newTestManager(t)
+	db := dbtest.New()
+	db.Returns("GetRoomByRoomCode", roomRow("room-1", roommodel.MP, roommodel.Started, false, false))
+	m.roomService = room.NewService(db, nil, nil)
+	m.quizService = quiz.NewService(db, nil)
+	gameState := startedGame(m, "room-1", &quizmodel.QuestionData{ID: uuid.New()})
+	gameState.CurrentQuestionIndex = 1
+	gameState.Participants = []quizmodel.Participant{{UserID: uuid.New(), Username: "player", Score: 300, Streak: 1, BestStreak: 3}}
+
+	if err := sendNextQuestion(testContext(), m, "room-1"); err != nil {
+		t.Fatalf("end the game: %v", err)
+	}
+
+	updates := db.Calls("UpdateLeaderBoardScoreByUserIDAndRoomCode")
+	if len(updates) != 1 || updates[0].Args[4] != int32(3) {
+		t.Errorf("got the leaderboard updates %+v, want the best streak of 3", updates)
+	}
+}
@@ -0,0 +1,199 @@
+// A room created in team mode splits its players into teams in the lobby. A player picks his own team,
+// the owner can move anyone and auto-balance the teams, and everyone who joins or is still without a
+// team when the game starts goes to the smallest team. The team totals go out with every leaderboard
+// and with the end of the game. Team chat is the room chat scoped to the team of the sender.
+
+package websocket
+
+import (
+	logs "brainwars/pkg/logger"
+	"brainwars/pkg/quiz"
+	roommodel "brainwars/pkg/room/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/google/uuid"
+)
+
+type joinTeamReq struct {
+	Team   int       `json:"team"`
+	UserID uuid.UUID `json:"userId,omitempty"` // member the owner moves, empty when a player picks his own team
+}
+
+// teamOf is the team of the member, 0 when the room is not in team mode. caller must hold the manager lock
+func (m *Manager) teamOf(roomCode string, userID uuid.UUID) int {
+	gameState, exists := m.gameStates[roomCode]
+	if !exists {
+		return 0
+	}
+	return gameState.Teams[userID]
+}
+
+// teamMembers lists the members of the room who play in a team: the players and the bots, not the host
+// and the spectators. caller must hold the manager lock
+func (m *Manager) teamMembers(roomCode string) []uuid.UUID {
+	members := []uuid.UUID{}
+	for client := range m.clients[roomCode] {
+		if !client.isHost() && !client.isSpectator() && !client.kicked {
+			members = append(members, client.userID)
+		}
+	}
+	for userID := range m.botClients[roomCode] {
+		members = append(members, userID)
+	}
+	return members
+}
+
+// assignTeam puts the member in the smallest team unless he already has one and returns his team,
+// 0 when the room is not in team mode. caller must hold the manager lock
+func (m *Manager) assignTeam(roomCode string, userID uuid.UUID) int {
+	gameState, exists := m.gameStates[roomCode]
+	if !exists || gameState.TeamCount <= 0 {
+		return 0
+	}
+	if gameState.Teams == nil {
+		gameState.Teams = make(map[uuid.UUID]int)
+	}
+	if team, assigned := gameState.Teams[userID]; assigned {
+		return team
+	}
+	sizes := make([]int, gameState.TeamCount+1)
+	for _, team := range gameState.Teams {
+		if team >= 1 && team <= gameState.TeamCount {
+			sizes[team]++
+		}
+	}
+	smallest := 1
+	for team := 2; team <= gameState.TeamCount; team++ {
+		if sizes[team] < sizes[smallest] {
+			smallest = team
+		}
+	}
+	gameState.Teams[userID] = smallest
+	return smallest
+}
+
+// joinTeams sets up the teams of a room in team mode when its first member joins and gives a team to
+// the members who have none yet, the host only watches so he gets none
+func (m *Manager) joinTeams(c *Client) {
+	m.Lock()
+	defer m.Unlock()
+	gameState, exists := m.gameStates[c.roomCode]
+	if !exists || c.room == nil {
+		return
+	}
+	if gameState.TeamCount == 0 && gameState.RoomStatus == roommodel.Waiting {
+		gameState.TeamCount = quiz.TeamCountFromRoomMeta(c.room.RoomMeta)
+	}
+	m.fillTeams(c.roomCode)
+}
+
+// fillTeams gives a team to every member of the room who has none, caller must hold the manager lock
+func (m *Manager) fillTeams(roomCode string) {
+	for _, userID := range m.teamMembers(roomCode) {
+		m.assignTeam(roomCode, userID)
+	}
+}
+
+// JoinTeamHandler moves a player to a team while the room waits in the lobby, the owner can move anyone
+func JoinTeamHandler(ctx context.Context, event Event, c *Client) error {
+	l := logs.GetLoggerctx(ctx)
+
+	var req joinTeamReq
+	if err := json.Unmarshal(event.Payload, &req); err != nil {
+		l.Sugar().Error("bad payload", err)
+		return fmt.Errorf("bad payload: %v", err)
+	}
+	target := c.userID
+	if req.UserID != uuid.Nil && req.UserID != c.userID {
+		if !authorizeOwner(c) {
+			return nil
+		}
+		target = req.UserID
+	} else if c.manager.isHostClient(c) {
+		sendGameError("the host watches the game and is in no team", c)
+		return nil
+	}
+
+	c.manager.Lock()
+	gameState, exists := c.manager.gameStates[c.roomCode]
+	if !exists || gameState.TeamCount <= 0 {
+		c.manager.Unlock()
+		sendGameError("this room is not played in teams", c)
+		return nil
+	}
+	if gameState.RoomStatus != roommodel.Waiting {
+		c.manager.Unlock()
+		sendGameError("the teams are locked once the game starts", c)
+		return nil
+	}
+	if req.Team < 1 || req.Team > gameState.TeamCount {
+		c.manager.Unlock()
+		sendGameError(fmt.Sprintf("pick a team between 1 and %d", gameState.TeamCount), c)
+		return nil
+	}
+	member := false
+	for _, userID := range c.manager.teamMembers(c.roomCode) {
+		member = member || userID == target
+	}
+	if !member {
+		c.manager.Unlock()
+		sendGameError("only the players in the lobby can be put in a team", c)
+		return nil
+	}
+	if gameState.Teams == nil {
+		gameState.Teams = make(map[uuid.UUID]int)
+	}
+	gameState.Teams[target] = req.Team
+	c.manager.Unlock()
+
+	l.Sugar().Infof("user %s is in team %d of room %s", target, req.Team, c.roomCode)
+	return c.manager.sendRoomMemberState(ctx, c.roomCode, c, c.UserStatus)
+}
+
+// BalanceTeamsHandler lets the owner deal the members of the lobby out evenly over the teams
+func BalanceTeamsHandler(ctx context.Context, event Event, c *Client) error {
+	l := logs.GetLoggerctx(ctx)
+	if !authorizeOwner(c) {
+		return nil
+	}
+
+	c.manager.Lock()
+	gameState, exists := c.manager.gameStates[c.roomCode]
+	if !exists || gameState.TeamCount <= 0 {
+		c.manager.Unlock()
+		sendGameError("this room is not played in teams", c)
+		return nil
+	}
+	if gameState.RoomStatus != roommodel.Waiting {
+		c.manager.Unlock()
+		sendGameError("the teams are locked once the game starts", c)
+		return nil
+	}
+	members := c.manager.teamMembers(c.roomCode)
+	rand.Shuffle(len(members), func(i, j int) { members[i], members[j] = members[j], members[i] })
+	gameState.Teams = make(map[uuid.UUID]int, len(members))
+	for i, userID := range members {
+		gameState.Teams[userID] = i%gameState.TeamCount + 1
+	}
+	c.manager.Unlock()
+
+	l.Sugar().Infof("teams of room %s are balanced", c.roomCode)
+	return c.manager.sendRoomMemberState(ctx, c.roomCode, c, c.UserStatus)
+}
+
+// chatTeam is the team a chat message is scoped to, 0 for the messages to the whole room
+func chatTeam(event Event) int {
+	if event.Type != EventChatMessage {
+		return 0
+	}
+	scope := struct {
+		Team int `json:"team"`
+	}{}
+	if err := json.Unmarshal(event.Payload, &scope); err != nil {
+		return 0
+	}
+	return scope.Team
+}
@@ -0,0 +1,182 @@
+package websocket
+
+import (
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	usermodel "brainwars/pkg/users/model"
+	"brainwars/pkg/util"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// teamLobby is a room in team mode which waits in the lobby
+func teamLobby(m *Manager, roomCode string, teams int) *quizmodel.GameState {
+	gameState := &quizmodel.GameState{RoomCode: roomCode, RoomStatus: roommodel.Waiting, TeamCount: teams}
+	m.gameStates[roomCode] = gameState
+	return gameState
+}
+
+func TestAssignTeam(t *testing.T) {
+	m := newTestManager(t)
+	gameState := teamLobby(m, "room-1", 2)
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+
+	teams := []int{m.assignTeam("room-1", first), m.assignTeam("room-1", second), m.assignTeam("room-1", third)}
+
+	if teams[0] != 1 || teams[1] != 2 || teams[2] != 1 {
+		t.Errorf("got the teams %v, want every member in the smallest team", teams)
+	}
+	gameState.Teams[first] = 2
+	if team := m.assignTeam("room-1", first); team != 2 {
+		t.Errorf("a member who has a team keeps it, got %d", team)
+	}
+	teamLobby(m, "room-2", 0)
+	if team := m.assignTeam("room-2", first); team != 0 {
+		t.Errorf("a free for all room has no teams, got %d", team)
+	}
+}
+
+func TestJoinTeamHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		moveOther bool // the team of another member is changed
+		byOwner   bool
+		host      bool
+		status    roommodel.RoomStatus
+		team      int
+		wantTeam  int // team of the moved member, 0 when the move is turned down
+	}{
+		{name: "a player picks his team", status: roommodel.Waiting, team: 2, wantTeam: 2},
+		{name: "the owner moves a player", moveOther: true, byOwner: true, status: roommodel.Waiting, team: 2, wantTeam: 2},
+		{name: "a player cannot move another", moveOther: true, status: roommodel.Waiting, team: 2},
+		{name: "the host is in no team", host: true, status: roommodel.Waiting, team: 2},
+		{name: "no such team", status: roommodel.Waiting, team: 3},
+		{name: "the teams are locked in the game", status: roommodel.Started, team: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			gameState := teamLobby(m, "room-1", 2)
+			gameState.RoomStatus = tt.status
+			sender, _ := addTestClient(t, m, "room-1")
+			other, _ := addTestClient(t, m, "room-1")
+			if tt.byOwner {
+				sender.room.CreatedBy = sender.userID.String()
+			}
+			if tt.host {
+				sender.role = roommodel.Host
+			}
+			target := sender
+			if tt.moveOther {
+				target = other
+			}
+			ctx := util.SetUserInfoInctx(testContext(), &usermodel.UserInfo{ID: sender.userID, UserName: "player"})
+			req := joinTeamReq{Team: tt.team}
+			if tt.moveOther {
+				req.UserID = other.userID
+			}
+
+			if err := JoinTeamHandler(ctx, jsonEvent(t, EventJoinTeam, req), sender); err != nil {
+				t.Fatalf("join team: %v", err)
+			}
+
+			if tt.wantTeam == 0 {
+				if _, ok := nextEventOf(t, sender, EventGameError); !ok {
+					t.Errorf("the move should be turned down")
+				}
+				if gameState.Teams[target.userID] != 0 {
+					t.Errorf("the member moved to team %d", gameState.Teams[target.userID])
+				}
+				return
+			}
+			if gameState.Teams[target.userID] != tt.wantTeam {
+				t.Errorf("the member is in team %d, want %d", gameState.Teams[target.userID], tt.wantTeam)
+			}
+			if _, ok := nextEventOf(t, other, EventLobbyState); !ok {
+				t.Errorf("the lobby should see the new teams")
+			}
+		})
+	}
+}
+
+func TestBalanceTeamsHandler(t *testing.T) {
+	m := newTestManager(t)
+	gameState := teamLobby(m, "room-1", 2)
+	owner, ctx := roomOwner(t, m, "room-1")
+	for range 3 {
+		addTestClient(t, m, "room-1")
+	}
+	spectator, _ := addTestClient(t, m, "room-1")
+	spectator.role = roommodel.Spectator
+	// everyone picked the same team
+	gameState.Teams = map[uuid.UUID]int{}
+	for client := range m.clients["room-1"] {
+		gameState.Teams[client.userID] = 1
+	}
+
+	if err := BalanceTeamsHandler(ctx, Event{Type: EventBalanceTeams}, owner); err != nil {
+		t.Fatalf("balance teams: %v", err)
+	}
+
+	sizes := map[int]int{}
+	for _, team := range gameState.Teams {
+		sizes[team]++
+	}
+	if sizes[1] != 2 || sizes[2] != 2 || len(gameState.Teams) != 4 {
+		t.Errorf("got the team sizes %v, want 2 players in each team and no spectator", sizes)
+	}
+}
+
+func TestTeamScoresGoOutWithTheLeaderBoard(t *testing.T) {
+	m := newTestManager(t)
+	question := fourOptions()
+	gameState := startedGame(m, "room-1", question)
+	gameState.TeamCount = 2
+	gameState.Teams = map[uuid.UUID]int{}
+	watcher, _ := addTestClient(t, m, "room-1")
+	players := []*Client{}
+	for i, team := range []int{1, 2, 1} {
+		player, _ := addTestClient(t, m, "room-1")
+		player.UserName = []string{"ann", "bob", "cid"}[i]
+		gameState.Teams[player.userID] = team
+		players = append(players, player)
+	}
+
+	// ann and bob are right, cid is wrong
+	for i, option := range []int{1, 1, 2} {
+		ctx := util.SetUserInfoInctx(testContext(), &usermodel.UserInfo{ID: players[i].userID, UserName: players[i].UserName})
+		if err := SubmitAnswerHandler(ctx, answerEvent(t, question, option), players[i]); err != nil {
+			t.Fatalf("submit answer: %v", err)
+		}
+	}
+
+	var leaderBoard struct {
+		Teams []quizmodel.TeamScore `json:"teams"`
+	}
+	for {
+		event, ok := nextEventOf(t, watcher, EventLeaderBoard)
+		if !ok {
+			break
+		}
+		if err := json.Unmarshal(event.Payload, &leaderBoard); err != nil {
+			t.Fatalf("leaderboard json: %v", err)
+		}
+	}
+	scores := map[int]quizmodel.TeamScore{}
+	for _, team := range leaderBoard.Teams {
+		scores[team.Team] = team
+	}
+	red, blue := scores[1], scores[2]
+	if len(red.Members) != 2 || len(blue.Members) != 1 || red.Score <= 0 || blue.Score <= 0 {
+		t.Fatalf("got the teams %+v, want ann and cid in red and bob in blue with points", leaderBoard.Teams)
+	}
+	individual := map[uuid.UUID]int{}
+	for _, participant := range gameState.Participants {
+		individual[participant.UserID] = participant.Score
+	}
+	if red.Score != individual[players[0].userID]+individual[players[2].userID] || blue.Score != individual[players[1].userID] {
+		t.Errorf("got red %d and blue %d, want the sums of the scores of their members", red.Score, blue.Score)
+	}
+}
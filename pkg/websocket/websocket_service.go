@@ -14,6 +14,7 @@ import (
 	"brainwars/pkg/room"
 	"brainwars/pkg/room/model"
 	roommodel "brainwars/pkg/room/model"
+	user "brainwars/pkg/users"
 	usermodel "brainwars/pkg/users/model"
 	"brainwars/pkg/util"
 	"brainwars/web/ui/handlers"
@@ -55,13 +56,30 @@ type Manager struct {
 	handlers   map[string]EventHandler
 	roomStates map[string]*roommodel.RoomStatus
 	gameStates map[string]*quizmodel.GameState
+	bus        RoomBus // room events are published here so that they reach clients on every instance
+
+	roomService *room.Service
+	quizService *quiz.Service
+	userService *user.Service
+
+	restoredHistory map[string]map[uuid.UUID]quizmodel.AnswerHistory // answers of the players of a restored room who have not reconnected yet map[roomCode]map[userID]
+	resumePending   map[string]bool                                  // restored rooms whose question timer is not running yet
+	suspended       map[string]map[uuid.UUID]*suspendedClient        // clients who dropped in the middle of a game map[roomCode]map[userID]
+	generationJobs  map[string]*quizmodel.GenerationJob              // question generation job of the rooms whose game has not loaded the questions yet
+	pendingStarts   map[string]*Client                               // rooms whose game start waits for the questions, the client starts it
+	questionTimers  map[string]*time.Timer                           // timer of the running question of the room, stopped while the game is paused
+	joining         map[string]int                                   // connections of the room which are still joining, the claim is kept for them
+
+	relayMu   sync.Mutex                // guards relayed and forwarded, apart from the manager lock since connections are closed under it
+	relayed   map[string]*relayConn     // clients of the rooms running here whose websocket is held by another instance
+	forwarded map[string]*forwardedConn // websockets held here for rooms running on another instance
 }
 
 type ClientList map[*Client]bool
 type Client struct {
 	QuestionCancel context.CancelFunc // cancel func for the current question's goroutine
 	TOC            time.Time          // Time of creation
-	connection     *websocket.Conn
+	connection     clientConn         // websocket of the client, or the relay to the instance holding it
 	manager        *Manager
 	egress         chan Event
 	botEvents      chan Event // Only used for bot clients
@@ -73,6 +91,10 @@ type Client struct {
 	UserStatus     usermodel.UserStatus
 	room           *roommodel.Room
 	ansHistory     map[uuid.UUID]map[uuid.UUID]*quizmodel.AnswerReq // map[questionD]map[userID]answerIW
+	resumeToken    string                                           // lets the user take back this slot if his connection drops
+	role           roommodel.MemberRole                             // PLAYER, HOST or SPECTATOR, bots are always players
+	kicked         bool                                             // kicked out by the room owner, his events are ignored
+	eliminated     bool                                             // dropped out of an elimination game, watches the rest as a spectator
 }
 
 type NewMessageEvent struct {
@@ -84,7 +106,13 @@ type ChangeRoomEvent struct {
 	Name string `json:"name"`
 }
 
-func NewManager(ctx context.Context) *Manager {
+func NewManager(ctx context.Context, roomService *room.Service, quizService *quiz.Service, userService *user.Service) *Manager {
+	l := logs.GetLoggerctx(ctx)
+	bus, err := NewRoomBus(ctx)
+	if err != nil {
+		l.Sugar().Fatal("room bus setup failed ", err)
+	}
+
 	m := &Manager{
 		clients:    make(map[string]ClientList),
 		handlers:   make(map[string]EventHandler),
@@ -92,14 +120,41 @@ func NewManager(ctx context.Context) *Manager {
 
 		roomStates: make(map[string]*roommodel.RoomStatus),
 		gameStates: make(map[string]*quizmodel.GameState),
+		bus:        bus,
+
+		roomService: roomService,
+		quizService: quizService,
+		userService: userService,
+
+		restoredHistory: make(map[string]map[uuid.UUID]quizmodel.AnswerHistory),
+		resumePending:   make(map[string]bool),
+		suspended:       make(map[string]map[uuid.UUID]*suspendedClient),
+		generationJobs:  make(map[string]*quizmodel.GenerationJob),
+		pendingStarts:   make(map[string]*Client),
+		questionTimers:  make(map[string]*time.Timer),
+		joining:         make(map[string]int),
+
+		relayed:   make(map[string]*relayConn),
+		forwarded: make(map[string]*forwardedConn),
+	}
+	err = m.bus.Subscribe(ctx, m.deliverLocal)
+	if err != nil {
+		l.Sugar().Fatal("room bus subscribe failed ", err)
+	}
+	err = m.bus.SubscribeRelay(ctx, m.handleCommand, m.handleReply)
+	if err != nil {
+		l.Sugar().Fatal("room bus relay subscribe failed ", err)
 	}
+	m.quizService.OnJobUpdate(m.publishGenerationJob)
 	m.setupEventHandlers()
+	m.restoreGameStates(ctx)
+	go m.watchStaleGenerationJobs(ctx)
 	m.MemoryCleanup(ctx)
 	go m.startClientHealthCheck(ctx)
 	return m
 }
 
-func NewClient(conn *websocket.Conn, manager *Manager, roomCode string, isBot bool, botType usermodel.BotType, userID uuid.UUID, userName string, room *model.Room) *Client {
+func NewClient(conn clientConn, manager *Manager, roomCode string, isBot bool, botType usermodel.BotType, userID uuid.UUID, userName string, room *model.Room) *Client {
 
 	// // Only set up pong handler for real clients with WebSocket connections
 	// if conn != nil {
@@ -108,7 +163,7 @@ func NewClient(conn *websocket.Conn, manager *Manager, roomCode string, isBot bo
 	return &Client{
 		connection: conn,
 		manager:    manager,
-		egress:     make(chan Event),
+		egress:     make(chan Event, egressBufferSize),
 		botEvents:  make(chan Event),
 		roomCode:   roomCode,
 		isBot:      isBot,
@@ -119,6 +174,7 @@ func NewClient(conn *websocket.Conn, manager *Manager, roomCode string, isBot bo
 		TOC:        time.Now(),
 		UserStatus: usermodel.UserReady,
 		UserName:   userName,
+		role:       roommodel.Player,
 	}
 }
 
@@ -134,6 +190,7 @@ func (m *Manager) MemoryCleanup(ctx context.Context) {
 				return
 			case <-ticker.C:
 				m.Lock()
+				released := []string{}
 				interval := time.Minute * time.Duration(viper.GetInt("cacheCleaner.intervalMinutes"))
 				for roomCode, clients := range m.clients {
 					for client := range clients {
@@ -155,6 +212,10 @@ func (m *Manager) MemoryCleanup(ctx context.Context) {
 					// If no clients are left in the room, remove the room from the manager
 					if len(clients) == 0 {
 						delete(m.clients, roomCode)
+						// a running game keeps its claim so its players come back to this instance
+						if gameState, exists := m.gameStates[roomCode]; !exists || gameState.RoomStatus != roommodel.Started {
+							released = append(released, roomCode)
+						}
 					}
 				}
 
@@ -181,6 +242,10 @@ func (m *Manager) MemoryCleanup(ctx context.Context) {
 					}
 				}
 				m.Unlock()
+				// another instance can take over the rooms nobody is connected to anymore
+				for _, roomCode := range released {
+					m.releaseRoom(ctx, roomCode)
+				}
 			}
 		}
 	}()
@@ -192,13 +257,30 @@ func (m *Manager) MemoryCleanup(ctx context.Context) {
 	}()
 }
 
+// joinReq is what a connection asks for when it opens, it travels to the instance running the room
+// when the connection lands on another instance
+type joinReq struct {
+	RoomCode    string    `json:"roomCode"`
+	UserID      uuid.UUID `json:"userId"`
+	UserName    string    `json:"username"`
+	Spectate    bool      `json:"spectate"`
+	ResumeToken string    `json:"resumeToken"`
+}
+
+// joinError turns a connection away before it is upgraded, the message is shown to the user
+type joinError struct {
+	message string
+}
+
+func (e *joinError) Error() string {
+	return e.message
+}
+
 // when user joins a room this serveWs handler is called
-// TODO: render error templates
 func (m *Manager) ServeWS(c *gin.Context) {
 	ctx := c.Request.Context()
 	l := logs.GetLoggerctx(ctx)
 	userInfo := util.GetUserInfoFromctx(ctx)
-	userID := userInfo.ID
 
 	// get the roomID from the query params
 	roomCode := c.Query("roomCode")
@@ -206,6 +288,110 @@ func (m *Manager) ServeWS(c *gin.Context) {
 		l.Sugar().Error("room code not found", nil)
 		return
 	}
+	req := joinReq{
+		RoomCode: roomCode,
+		UserID:   userInfo.ID,
+		UserName: userInfo.UserName,
+		// spectators only watch so they skip everything a player needs
+		Spectate: c.Query("spectate") == "true",
+		// a player whose connection dropped comes back with the resume token he got when he joined
+		ResumeToken: c.Query("resumeToken"),
+	}
+
+	// the room and the membership are checked before the room is claimed so that a made up room code claims nothing
+	roomDetails, roomMember, err := m.checkJoin(ctx, req)
+	if err != nil {
+		handlers.RenderErrorTemplate(c, "home.html", err.Error(), nil)
+		return
+	}
+
+	m.enterRoom(roomCode)
+	defer m.leaveRoom(ctx, roomCode)
+
+	// the game of a room runs on one instance, a connection landing on another instance is forwarded to it
+	owner := m.claimRoom(ctx, roomCode)
+	if owner == "" {
+		handlers.RenderErrorTemplate(c, "home.html", "could not reach the room, please try again", nil)
+		return
+	}
+	if !m.runsHere(owner) {
+		conn, err := websocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			l.Sugar().Error("websocket upgrade error:", err)
+			return
+		}
+		m.forwardConnection(ctx, conn, owner, req)
+		return
+	}
+
+	err = m.join(ctx, req, roomDetails, roomMember, func() (clientConn, error) {
+		conn, err := websocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			return nil, err
+		}
+		return conn, nil
+	})
+	rejected := &joinError{}
+	if errors.As(err, &rejected) {
+		handlers.RenderErrorTemplate(c, "home.html", rejected.message, nil)
+	}
+}
+
+// checkJoin loads the room and the member and tells if the user may connect to the room
+func (m *Manager) checkJoin(ctx context.Context, req joinReq) (*roommodel.Room, *roommodel.RoomMember, error) {
+	l := logs.GetLoggerctx(ctx)
+
+	roomDetails, err := m.roomService.GetRoomByRoomCode(ctx, req.RoomCode)
+	if err != nil {
+		l.Sugar().Error("get room by room code failed", err)
+		return nil, nil, &joinError{"failed to load the room, please try again"}
+	}
+	if roomDetails == nil {
+		return nil, nil, &joinError{"there is no room with this code"}
+	}
+	roomMember, err := m.roomService.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
+		UserID:   req.UserID,
+		RoomCode: req.RoomCode,
+	})
+	if err != nil {
+		l.Sugar().Error("get room member by room and user id failed", err)
+		return nil, nil, &joinError{"failed to load the room, please try again"}
+	}
+	if roomMember != nil && roomMember.RoomMemberStatus == roommodel.BannedQuiz {
+		return nil, nil, &joinError{"you are banned from this room"}
+	}
+
+	if req.Spectate {
+		if roomDetails.Roomstatus == roommodel.Failed {
+			return nil, nil, &joinError{"generating questions for this room failed, there is nothing to watch"}
+		}
+		// the socket is opened by the spectate page, the gate of that page is checked again for the direct connections
+		err = m.roomService.CheckWatchable(roomDetails)
+		if err != nil {
+			return nil, nil, &joinError{err.Error()}
+		}
+		return roomDetails, roomMember, nil
+	}
+
+	if roomMember == nil {
+		return nil, nil, &joinError{"you are not a member of this room, join it with the room code first"}
+	}
+	if roomMember.RoomMemberStatus == roommodel.KickedQuiz {
+		return nil, nil, &joinError{"you were kicked out of this room, join it again with the room code"}
+	}
+	return roomDetails, roomMember, nil
+}
+
+// join attaches the connection to the room on the instance running its game. upgrade opens the
+// connection once the game agreed to take the user, the joinErrors come before that
+func (m *Manager) join(ctx context.Context, req joinReq, roomDetails *roommodel.Room, roomMember *roommodel.RoomMember, upgrade func() (clientConn, error)) error {
+	l := logs.GetLoggerctx(ctx)
+	roomCode := req.RoomCode
+	userID := req.UserID
+
+	if req.Spectate {
+		return m.joinSpectator(ctx, req, roomDetails, upgrade)
+	}
 
 	// Check if the user is already in the room so when he refreshes the page
 	// he is pushed out of the page and the connection is closed since the game is realtime multiplayer
@@ -213,87 +399,81 @@ func (m *Manager) ServeWS(c *gin.Context) {
 	gameState, exists := m.gameStates[roomCode]
 	m.Unlock()
 
+	// the host does not play, he can come back and watch the game at any time
+	watching := exists && gameState.RoomStatus == roommodel.Started && roomMember.Role == roommodel.Host
+	// a room restored from its checkpoint after a restart lets its players back in
+	resuming := exists && gameState.RoomStatus == roommodel.Started && !watching && m.canResume(roomCode, userID)
+	resumeToken := req.ResumeToken
+	reconnecting := exists && gameState.RoomStatus == roommodel.Started && !watching && !resuming && m.canTakeOver(roomCode, userID, resumeToken)
+
 	if exists {
-		if gameState.RoomStatus == roommodel.Started {
+		if gameState.RoomStatus == roommodel.Started && !watching && !resuming && !reconnecting {
 			l.Sugar().Error("user already in the room (page refreshed)")
-			//http.Error(c.Writer, "REFRESHED_PAGE", http.StatusForbidden)
-			//c.Redirect(http.StatusFound, "/bw/home/")
-			handlers.RenderErrorTemplate(c, "home.html", "refreshing page kicks you out of the game since the game runs realtime!", nil)
-			return
+			return &joinError{"refreshing page kicks you out of the game since the game runs realtime!"}
 		}
 	} else {
-		// time.Sleep(10 * time.Second)
-		// // TODO: questions might not be ready... so put them in lobby if there is a error of no result
-		// questions, err := quiz.ListQuestionsByRoomCode(ctx, roomCode)
-		// if err != nil {
-		// 	l.Sugar().Error("list questions by room code failed", err)
-		// 	return
-		// }
-
-		maxRetries := viper.GetInt("game.maxRetryCount")
-		retryDelay := viper.GetInt("game.waitRetrySecond")
-		var questions *quizmodel.Question
-		var err error
-		for attempt := 1; attempt <= maxRetries; attempt++ {
-			questions, err = quiz.ListQuestionsByRoomCode(ctx, roomCode)
-			if err == nil && questions.QuestionCount > 0 {
-				break
-			}
-
-			if err != nil {
-				l.Sugar().Warnw("Attempt to list questions failed", "attempt", attempt, "error", err)
-			} else if questions.QuestionCount == 0 {
-				l.Sugar().Infow("No questions found yet", "attempt", attempt)
-			}
-
-			time.Sleep(time.Duration(retryDelay) * time.Second)
+		// the questions might still be generated, the player waits for them in the lobby
+		job, err := m.loadGenerationJob(ctx, roomCode)
+		if err != nil {
+			l.Sugar().Errorw("Failed to load the generation job", "roomCode", roomCode, "error", err)
+			return &joinError{"failed to retrive your questions try after some time"}
 		}
-
-		if err != nil || questions.QuestionCount == 0 {
-			l.Sugar().Errorw("Failed to retrieve questions after retries", "roomCode", roomCode)
-			// TODO: Put users back in lobby or handle gracefully
-			handlers.RenderErrorTemplate(c, "home.html", "failed to retrive your questions try after some time", nil)
-			return
+		if job.Status == quizmodel.JobFailed {
+			l.Sugar().Errorw("question generation failed for the room", "roomCode", roomCode)
+			return &joinError{"generating questions for this room failed, please create a new room"}
 		}
 
-		totalQuestions := questions.QuestionCount
 		// Check if the room needs to be initialized
-		m.initializeRoomGameState(ctx, roomCode, totalQuestions)
+		m.initializeRoomGameState(ctx, roomCode, job.QuestionCount)
 	}
 
-	conn, err := websocketUpgrader.Upgrade(c.Writer, c.Request, nil)
+	conn, err := upgrade()
 	if err != nil {
 		l.Sugar().Error("websocket upgrade error:", err)
-		return
+		return err
 	}
 
-	roomDetails, err := room.GetRoomByRoomCode(ctx, roomCode)
-	if err != nil {
-		l.Sugar().Error("get room by room code failed", err)
-		return
+	client := NewClient(conn, m, roomCode, false, "", userID, roomMember.UserDetails.UserName, roomDetails)
+	if roomMember.Role == roommodel.Host {
+		client.role = roommodel.Host
 	}
-	if roomDetails == nil {
-		l.Sugar().Error("room not found")
-		return
+	go m.readMessages(ctx, client)
+	go m.writeUsersMessages(ctx, client)
+
+	if reconnecting {
+		previous := m.takeOverClient(roomCode, userID, resumeToken)
+		if previous == nil {
+			l.Sugar().Errorf("resume window of user %s in room %s is already over", userID, roomCode)
+			sendGameError("your resume window is over, you cannot rejoin this game", client)
+			return nil
+		}
+		m.resumeClient(previous, client)
 	}
+	m.addClient(client)
+	m.issueResumeToken(ctx, client)
 
-	roomMember, err := room.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
-		UserID:   userID,
-		RoomCode: roomCode,
-	})
-	if err != nil {
-		l.Sugar().Error("get room member by room and user id failed", err)
-		return
+	if reconnecting || watching {
+		err = m.replayGameState(ctx, client)
+		if err != nil {
+			l.Sugar().Error("replay game state failed ", err)
+			sendGameError("could not bring you back into the game ", client)
+		}
+		if watching {
+			sendAnswerDistribution(ctx, m, roomCode)
+		}
+		return nil
 	}
-	if roomMember == nil {
-		l.Sugar().Error("there are no room members")
-		return
+
+	if resuming {
+		err = m.resumeRestoredGame(ctx, client, roomDetails)
+		if err != nil {
+			l.Sugar().Error("resume restored game failed ", err)
+			sendGameError("resume restored game failed ", client)
+		}
+		return nil
 	}
 
-	client := NewClient(conn, m, roomCode, false, "", userID, roomMember.UserDetails.UserName, roomDetails)
-	m.addClient(client)
-	go m.readMessages(ctx, client)
-	go m.writeUsersMessages(ctx, client)
+	m.sendGenerationJob(client)
 
 	// checking !exists here because we need to initialize bots just once per room that is for the first time alone
 	if !exists {
@@ -303,30 +483,64 @@ func (m *Manager) ServeWS(c *gin.Context) {
 		m.setupBotsForRoom(ctx, roomCode, roomDetails)
 	}
 
+	// a room in team mode puts the new player and the bots in a team
+	m.joinTeams(client)
+
 	// if the game is a single player game since the user is ready and bots are ready as well
 	//  we automatically display the first question. in terms of multiplayer game a button needs to be triggered
 	// to start the game
-	if roomDetails.GameType == roommodel.SP {
+	if m.isHostClient(client) {
+		// the host shows up in the lobby but the game never waits for him
+		err = m.sendRoomMemberState(ctx, roomCode, client, usermodel.UserHosting)
+		if err != nil {
+			l.Sugar().Error("send host state failed ", err)
+			return nil
+		}
+		if roomDetails.GameType == roommodel.SP {
+			err = StartGameMessageHandler(ctx, Event{}, client)
+			if err != nil {
+				return nil
+			}
+		}
+	} else if roomDetails.GameType == roommodel.SP {
 		err = m.sendRoomMemberState(ctx, roomCode, client, usermodel.UserReady)
 		if err != nil {
 			l.Sugar().Error("send single player user ready state failed ", err)
 			sendGameError("send single player user ready state failed ", client)
-			return
+			return nil
 		}
 
 		err = StartGameMessageHandler(ctx, Event{}, client)
 		if err != nil {
-			return
+			return nil
 		}
 
 	} else { // if it is a multiplayer the user first just joins the common game lobby. he clicks a button and makes himself ready later
 		err = m.sendRoomMemberState(ctx, roomCode, client, usermodel.UserJoined)
 		if err != nil {
 			l.Sugar().Error("setup user for room failerd ", err)
-			return
+			return nil
 		}
 	}
+	return nil
+}
+
+// enterRoom counts a connection which is joining the room, the claim of the room is kept while it joins
+func (m *Manager) enterRoom(roomCode string) {
+	m.Lock()
+	m.joining[roomCode]++
+	m.Unlock()
+}
 
+// leaveRoom is called once the connection joined or was turned away
+func (m *Manager) leaveRoom(ctx context.Context, roomCode string) {
+	m.Lock()
+	m.joining[roomCode]--
+	if m.joining[roomCode] <= 0 {
+		delete(m.joining, roomCode)
+	}
+	m.Unlock()
+	m.releaseIdleRoom(ctx, roomCode)
 }
 
 // send roomMemberState helps to send user and bots state to the ui in lobby
@@ -357,11 +571,16 @@ func (m *Manager) sendRoomMemberState(ctx context.Context, roomCode string, c *C
 	for _, botclient := range m.botClients[roomCode] {
 		userStateNotification = append(userStateNotification, Payload{UserName: botclient.UserName,
 			Data: string(botclient.UserStatus),
+			Team: m.teamOf(roomCode, botclient.userID),
 		})
 	}
 	for client := range m.clients[roomCode] {
-		userStateNotification = append(userStateNotification, Payload{UserName: client.UserName,
+		if client.isSpectator() {
+			continue // spectators are only counted
+		}
+		userStateNotification = append(userStateNotification, Payload{UserID: client.userID.String(), UserName: client.UserName,
 			Data: string(client.UserStatus),
+			Team: m.teamOf(roomCode, client.userID),
 		})
 	}
 	//TODO: we can add time and sort by time for uniform list all the time
@@ -398,15 +617,8 @@ func (m *Manager) sendRoomMemberState(ctx context.Context, roomCode string, c *C
 	// 	time.Sleep(1 * time.Second)
 	// }
 
-	m.Lock()
-	clientList := m.clients[roomCode]
-	m.Unlock()
 	// Broadcast to all user clients in the room
-	for client := range clientList {
-		if !client.isBot && client.connection != nil {
-			client.egress <- JoinEvent
-		}
-	}
+	m.broadcast(ctx, roomCode, JoinEvent)
 
 	return nil
 }
@@ -470,6 +682,12 @@ func StartGameMessageHandler(ctx context.Context, event Event, c *Client) error
 	}
 
 	if gameState.Questions.QuestionData == nil { // init setup all questions for that room
+		if c.manager.holdGameStart(c) {
+			l.Sugar().Infof("questions of room %s are not ready yet, the game starts once they are", c.roomCode)
+			c.manager.recheckGenerationJob(ctx, c.roomCode)
+			return nil
+		}
+
 		c.manager.Lock()
 		// Update game state
 		gameState.RoomStatus = roommodel.Started
@@ -477,17 +695,33 @@ func StartGameMessageHandler(ctx context.Context, event Event, c *Client) error
 		gameState.CurrentQuestionIndex = 0
 		c.manager.Unlock()
 
+		// the room row follows the game so the room browser and the restore after a restart see it running
+		err := c.manager.roomService.UpdateRoomStatus(ctx, c.roomCode, roommodel.Started, c.userID.String())
+		if err != nil {
+			l.Sugar().Error("failed to mark the room as started:", err)
+		}
+
 		// Fetch questions from the database
-		questions, err := quiz.ListQuestionsByRoomCode(ctx, c.roomCode) // TODO: instead of storing all generated questions in the db and fetching them here we need to store in memory and use it and slowly update it back to the database
+		questions, err := c.manager.quizService.ListQuestionsByRoomCode(ctx, c.roomCode) // TODO: instead of storing all generated questions in the db and fetching them here we need to store in memory and use it and slowly update it back to the database
 		if err != nil {
 			l.Sugar().Error("failed to fetch questions:", err)
 			return err
 		}
 
-		// Store questions in game state
+		// Store questions in game state along with the scoring rule the room was created with
 		c.manager.Lock()
 		gameState.Questions = questions
+		gameState.ScoringRule = quizmodel.ClassicScoring
+		if c.room != nil {
+			gameState.ScoringRule = quiz.ScoringRuleFromRoomMeta(c.room.RoomMeta).Name()
+		}
+		// whoever is still without a team is put in one, the teams are locked from now on
+		c.manager.fillTeams(c.roomCode)
+		if c.room != nil && c.room.GameType == roommodel.EL {
+			c.manager.enterElimination(c.roomCode)
+		}
 		c.manager.Unlock()
+		c.manager.forgetGenerationJob(c.roomCode)
 	}
 	// Send the first question to all clients
 	return sendNextQuestion(ctx, c.manager, c.roomCode)
@@ -509,31 +743,34 @@ func sendNextQuestion(ctx context.Context, manager *Manager, roomCode string) er
 	if gameState.CurrentQuestionIndex >= len(gameState.Questions.QuestionData) {
 		// Game is over
 		gameState.RoomStatus = roommodel.Ended
+		gameState.Revealing = false
 		manager.Unlock()
 
+		manager.stopQuestionTimer(roomCode)
+
 		// Send game end event
-		endGamePayload := struct {
-			Message    string                  `json:"message"`
-			Scores     []quizmodel.Participant `json:"scores"`
-			FinishTime time.Time               `json:"finishTime"`
-		}{
-			Message:    "Game has ended. Here are the final scores.",
-			Scores:     gameState.Participants,
-			FinishTime: time.Now(),
+		// the scoring rule goes along with the scores so the analysis page can explain them
+		scoringRule := quiz.GetScoringRule(gameState.ScoringRule)
+		if gameState.Elimination {
+			quiz.RankByElimination(gameState.Participants)
+		}
+		endGamePayload := quizmodel.EndGamePayload{
+			Message:      "Game has ended. Here are the final scores.",
+			Participants: gameState.Participants,
+			FinishTime:   time.Now(),
+			Scoring: &quizmodel.ScoringMeta{
+				Rule:        scoringRule.Name(),
+				Description: scoringRule.Description(),
+			},
+			Teams:        quiz.TeamScores(gameState.Participants, gameState.TeamCount),
+			Eliminations: gameState.Eliminations,
 		}
 
 		endGameData, _ := json.Marshal(endGamePayload)
 		endEvent := Event{Type: EventEndGame, Payload: endGameData}
 
-		manager.Lock()
-		clients := manager.clients[roomCode]
-		manager.Unlock()
 		// Broadcast to all clients
-		for client := range clients {
-			if !client.isBot {
-				client.egress <- endEvent
-			}
-		}
+		manager.broadcast(ctx, roomCode, endEvent)
 
 		// Notify bots about game end so that it can update its answer history and cleanup
 		botGameoverEvent := Event{
@@ -550,7 +787,7 @@ func sendNextQuestion(ctx context.Context, manager *Manager, roomCode string) er
 			l.Sugar().Error("json marshal failed", err)
 			return err
 		}
-		err = room.UpdateRoomMetaAndStatus(ctx, roommodel.RoomMetaReq{
+		err = manager.roomService.UpdateRoomMetaAndStatus(ctx, roommodel.RoomMetaReq{
 			RoomCode: roomCode,
 			RoomMeta: string(eventjson)})
 		if err != nil {
@@ -559,10 +796,11 @@ func sendNextQuestion(ctx context.Context, manager *Manager, roomCode string) er
 
 		// updating leader board
 		for _, player := range gameState.Participants {
-			err := room.UpdateLeaderBoard(ctx, &roommodel.EditLeaderBoardReq{
-				RoomCode: roomCode,
-				UserID:   player.UserID,
-				Score:    float64(player.Score),
+			err := manager.roomService.UpdateLeaderBoard(ctx, &roommodel.EditLeaderBoardReq{
+				RoomCode:   roomCode,
+				UserID:     player.UserID,
+				Score:      float64(player.Score),
+				BestStreak: player.BestStreak,
 			})
 			if err != nil {
 				return err
@@ -572,81 +810,164 @@ func sendNextQuestion(ctx context.Context, manager *Manager, roomCode string) er
 		// update db later and clear the memory as well after updating the pgsql db
 		// updating the answer history in answer table
 		manager.Lock()
-		clients = manager.clients[roomCode]
+		clients := manager.clients[roomCode]
 		manager.Unlock()
 		for client := range clients {
-			err = updateAnswerHistory(ctx, client.ansHistory)
+			err = manager.updateAnswerHistory(ctx, client.ansHistory)
 			if err != nil {
 				return err
 			}
 		}
+		// players who dropped and did not make it back before the game ended
+		for _, client := range manager.releaseSuspendedClients(roomCode) {
+			err = manager.updateAnswerHistory(ctx, client.ansHistory)
+			if err != nil {
+				return err
+			}
+		}
+
+		// game is saved in the answer and leaderboard tables so the checkpoint is not needed anymore
+		err = manager.clearGameCheckpoint(ctx, roomCode)
+		if err != nil {
+			l.Sugar().Error("clear game checkpoint failed", err)
+			return err
+		}
+		manager.releaseRoom(ctx, roomCode)
 
 		return nil
 	}
 
-	// Get the current question
-	currentQuestion := gameState.Questions.QuestionData[gameState.CurrentQuestionIndex]
-	timeLimit := time.Duration(gameState.Questions.TimeLimit) * time.Minute
-	cq := *currentQuestion
-	cq.Answer = -1 // making sure that the answer isnt shown in ws
+	timeLimit := gameState.Questions.QuestionTimeLimit(gameState.CurrentQuestionIndex)
 	// Store current question index for the goroutine
 	currentIndex := gameState.CurrentQuestionIndex
-
-	manager.Unlock()
-
-	questEvent := questionEvent{
-		QuestionIndex:  currentIndex + 1,
-		TotalQuestions: len(gameState.Questions.QuestionData),
-		Question:       &cq,
-		StartTime:      time.Now(),
-		TimeLimit:      gameState.Questions.TimeLimit,
-	}
+	gameState.Revealing = false
+	gameState.QuestionStartTime = time.Now()
+	gameState.PausedAt = time.Time{} // every question starts running
 
 	// Prepare question event
-	questionData, err := json.Marshal(questEvent)
+	questionEvent, err := buildQuestionEvent(gameState)
+	manager.Unlock()
 	if err != nil {
 		l.Sugar().Error("json marshal failed", err)
 		return err
 	}
-	questionEvent := Event{Type: EventNewQuestion, Payload: questionData}
+
+	// checkpoint the game so that it can be resumed if the server restarts during this question
+	manager.checkpointGameState(ctx, roomCode)
 
 	// Broadcast to all clients
-	for client := range manager.clients[roomCode] {
-		if !client.isBot {
-			client.egress <- questionEvent
-		}
-	}
+	manager.broadcast(ctx, roomCode, questionEvent)
 
 	// Notify bots about new question
 	manager.broadcastToBots(ctx, roomCode, questionEvent)
+	// the host starts every question with an empty distribution
+	sendAnswerDistribution(ctx, manager, roomCode)
 
 	// Schedule next question after a delay
-	go func() {
-		l.Sugar().Infof("Question %d timer started for %v seconds", currentIndex+1, timeLimit.Seconds())
-		timer := time.NewTimer(timeLimit)
-		<-timer.C
+	l.Sugar().Infof("Question %d timer started for %v seconds", currentIndex+1, timeLimit.Seconds())
+	manager.startQuestionTimer(ctx, roomCode, currentIndex, timeLimit)
+
+	return nil
+}
+
+// startQuestionTimer moves the room to the next question once the time is up. the timer is kept
+// so that the room owner can pause it
+func (m *Manager) startQuestionTimer(ctx context.Context, roomCode string, currentIndex int, timeLeft time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	if timer, exists := m.questionTimers[roomCode]; exists {
+		timer.Stop()
+	}
+	m.questionTimers[roomCode] = time.AfterFunc(timeLeft, func() {
+		l := logs.GetLoggerctx(ctx)
 		l.Sugar().Infof("Question %d timer completed", currentIndex+1)
 
 		// Move to next question
-		manager.Lock()
-		if gameState, exists := manager.gameStates[roomCode]; exists {
-			// Only increment if we're still on the same question
-			// This prevents race conditions if something else modified the index
-			if gameState.CurrentQuestionIndex == currentIndex {
-				gameState.CurrentQuestionIndex++
-				manager.Unlock()
-				err = sendNextQuestion(ctx, manager, roomCode)
-				if err != nil {
-					l.Sugar().Error("send next question failed", err)
-				}
-			} else {
-				manager.Unlock()
-			}
-		} else {
-			manager.Unlock()
+		m.Lock()
+		gameState, exists := m.gameStates[roomCode]
+		// Only increment if we're still on the same question and it is not paused
+		// This prevents race conditions if something else modified the index
+		if !exists || gameState.CurrentQuestionIndex != currentIndex || !gameState.PausedAt.IsZero() {
+			m.Unlock()
+			return
 		}
-	}()
+		// players who used a time freeze still have time
+		if frozen := frozenTimeLeft(gameState); frozen > 0 {
+			m.Unlock()
+			m.startQuestionTimer(ctx, roomCode, currentIndex, frozen)
+			return
+		}
+		gameState.CurrentQuestionIndex++
+		m.Unlock()
+
+		advanceQuestion(ctx, m, roomCode, currentIndex)
+	})
+}
+
+// finishQuestion closes the question at the index, the answer is revealed and the streaks are settled.
+// it is called once for every question before the room moves on
+func finishQuestion(ctx context.Context, manager *Manager, roomCode string, questionIndex int) {
+	settleStreaks(ctx, manager, roomCode, questionIndex)
+	revealAnswer(ctx, manager, roomCode, questionIndex)
+	eliminatePlayers(ctx, manager, roomCode, questionIndex)
+}
+
+// stopQuestionTimer stops the timer of the running question of the room
+func (m *Manager) stopQuestionTimer(roomCode string) {
+	m.Lock()
+	defer m.Unlock()
+	if timer, exists := m.questionTimers[roomCode]; exists {
+		timer.Stop()
+		delete(m.questionTimers, roomCode)
+	}
+}
+
+// buildQuestionEvent prepares the new_question event of the current question, caller must hold the manager lock
+func buildQuestionEvent(gameState *quizmodel.GameState) (Event, error) {
+	currentQuestion := gameState.Questions.QuestionData[gameState.CurrentQuestionIndex]
+	cq := quiz.HideAnswer(currentQuestion)
+
+	timeLeft := int(questionTimeLeft(gameState).Seconds())
+
+	questEvent := questionEvent{
+		QuestionIndex:  gameState.CurrentQuestionIndex + 1,
+		TotalQuestions: len(gameState.Questions.QuestionData),
+		Question:       cq,
+		StartTime:      gameState.QuestionStartTime,
+		TimeLimit:      int(gameState.Questions.QuestionTimeLimit(gameState.CurrentQuestionIndex).Seconds()),
+		TimeLeft:       timeLeft,
+		Paused:         !gameState.PausedAt.IsZero(),
+	}
+	questionData, err := json.Marshal(questEvent)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: EventNewQuestion, Payload: questionData}, nil
+}
 
+// sendCurrentQuestion sends the question which is running right now to a single client
+func (m *Manager) sendCurrentQuestion(ctx context.Context, c *Client) error {
+	l := logs.GetLoggerctx(ctx)
+
+	m.RLock()
+	gameState, exists := m.gameStates[c.roomCode]
+	if !exists || gameState.CurrentQuestionIndex >= len(gameState.Questions.QuestionData) {
+		m.RUnlock()
+		return fmt.Errorf("no running question for room %s", c.roomCode)
+	}
+	// the next question reaches the client with the rest of the room once the reveal is over
+	if gameState.Revealing {
+		m.RUnlock()
+		return nil
+	}
+	questionEvent, err := buildQuestionEvent(gameState)
+	m.RUnlock()
+	if err != nil {
+		l.Sugar().Error("json marshal failed", err)
+		return err
+	}
+
+	c.egress <- m.personalize(c, questionEvent)
 	return nil
 }
 
@@ -654,6 +975,11 @@ func sendNextQuestion(ctx context.Context, manager *Manager, roomCode string) er
 func SubmitAnswerHandler(ctx context.Context, event Event, c *Client) error {
 	l := logs.GetLoggerctx(ctx)
 
+	if c.manager.isHostClient(c) {
+		sendGameError("the host cannot answer the questions", c)
+		return nil
+	}
+
 	var submission quizmodel.AnswerReq
 	if err := json.Unmarshal(event.Payload, &submission); err != nil {
 		l.Sugar().Error("bad payload", err)
@@ -663,10 +989,8 @@ func SubmitAnswerHandler(ctx context.Context, event Event, c *Client) error {
 	// users dont send their userID but bots send. so for users we fetch from context
 	submission.UserID = c.userID
 
-	// Set timestamp if not provided
-	if submission.AnswerTime.IsZero() {
-		submission.AnswerTime = time.Now()
-	}
+	// the answer time is always ours, a client could claim it answered instantly
+	submission.AnswerTime = time.Now()
 
 	// Get the game state
 	c.manager.Lock() // TODO: check if we are locking for too long
@@ -684,63 +1008,65 @@ func SubmitAnswerHandler(ctx context.Context, event Event, c *Client) error {
 		l.Sugar().Error("game is not in active question phase")
 		return fmt.Errorf("game is not in active question phase")
 	}
+	if !gameState.PausedAt.IsZero() {
+		c.manager.Unlock()
+		sendGameError("the game is paused, wait for the host to resume it", c)
+		return nil
+	}
+	if gameState.Revealing {
+		c.manager.Unlock()
+		sendGameError("this question is already over", c)
+		return nil
+	}
 
 	//  TODO: getting the user data, updating the answer data, everything should happen in db
 
 	// Get current question
 	currentQuestion := gameState.Questions.QuestionData[gameState.CurrentQuestionIndex]
+	if submission.QuestionDataID != currentQuestion.ID {
+		c.manager.Unlock()
+		l.Sugar().Warn(fmt.Sprintf("user %s answered question %s which is not the current question of room %s", c.userID, submission.QuestionDataID, c.roomCode))
+		sendGameError("this question is already over", c)
+		return nil
+	}
 
-	// Check if answer is correct
-	isCorrect := int(submission.AnswerOption) == currentQuestion.Answer
+	latency := submission.AnswerTime.Sub(gameState.QuestionStartTime)
+	// the room may still be waiting for a player with a time freeze, the others are out of time
+	if latency > gameState.Questions.QuestionTimeLimit(gameState.CurrentQuestionIndex)+extraTime(gameState, c.userID)+answerGrace {
+		c.manager.Unlock()
+		sendGameError("the time for this question is up", c)
+		return nil
+	}
+	flagReason := ""
+	if !c.isBot {
+		flagReason = answerLatencyFlag(latency, c.answerLatencies(currentQuestion.ID))
+	}
+	if flagReason != "" {
+		l.Sugar().Warn(fmt.Sprintf("suspicious answer from user %s in room %s: %s after %s", c.userID, c.roomCode, flagReason, latency))
+	}
 
-	// Update participant score
-	found := false
-	for i, participant := range gameState.Participants {
-		if participant.UserID == c.userID {
-			found = true
-			// check to make sure that no scores to be calculated when the same option is clicked again and again
-			if gameState.Participants[i].LastChoosenOption == int(submission.AnswerOption) && gameState.Participants[i].LastAnsweredQestion == currentQuestion.ID {
-				break
-			}
-			// if he has already answered the question we will -50 the points
-			if participant.LastAnsweredQestion == currentQuestion.ID {
-				gameState.Participants[i].Score -= 50
-			}
-			if isCorrect {
-				// Calculate score based on answer speed
-				answerTime := submission.AnswerTime.Sub(gameState.StartTime)
-				speedBonus := float64(gameState.Questions.TimeLimit) - answerTime.Seconds()
-				if speedBonus < 0 {
-					speedBonus = 0
-				}
+	if !currentQuestion.SingleAnswer() && submission.AnswerData == nil {
+		c.manager.Unlock()
+		sendGameError("this question needs an answer of its own type", c)
+		return nil
+	}
+	if currentQuestion.SingleAnswer() {
+		submission.AnswerData = nil
+	}
 
-				// Add score (base 100 + speed bonus up to 100)
-				gameState.Participants[i].Score += 100 + int(speedBonus*3.33)
-			}
-			gameState.Participants[i].LastAnsweredQestion = currentQuestion.ID
-			gameState.Participants[i].LastChoosenOption = int(submission.AnswerOption)
+	// Check if answer is correct, the question types which are not single choice can be partly right
+	credit := quiz.GetQuestionKind(currentQuestion.Type).Credit(currentQuestion, int(submission.AnswerOption), submission.AnswerData)
+	isCorrect := credit >= 1
 
-			// Update the answer history
-			if _, exists := c.ansHistory[currentQuestion.ID]; !exists {
-				c.ansHistory[currentQuestion.ID] = make(map[uuid.UUID]*quizmodel.AnswerReq)
-			}
-			c.ansHistory[currentQuestion.ID][c.userID] = &quizmodel.AnswerReq{
-				RoomCode:       c.roomCode,
-				UserID:         submission.UserID,
-				QuestionID:     currentQuestion.ID,
-				QuestionDataID: submission.QuestionDataID,
-				AnswerOption:   submission.AnswerOption,
-				IsCorrect:      isCorrect,
-				AnswerTime:     submission.AnswerTime,
-				CreatedBy:      "system",
-			}
+	// find the participant, a player who is not on the list yet is added
+	idx := -1
+	for i, participant := range gameState.Participants {
+		if participant.UserID == c.userID {
+			idx = i
 			break
 		}
 	}
-
-	// If participant not found, add them
-	if !found {
-		// Get user info from database
+	if idx == -1 {
 		var username string
 		// TODO: Replace with actual database query to get username
 		if c.isBot {
@@ -749,21 +1075,33 @@ func SubmitAnswerHandler(ctx context.Context, event Event, c *Client) error {
 			userInfo := util.GetUserInfoFromctx(ctx)
 			username = userInfo.UserName
 		}
-
-		score := 0
-		if isCorrect {
-			score = 100 // Base score for correct answer
-		}
 		gameState.Participants = append(gameState.Participants, quizmodel.Participant{
-			UserID:              c.userID,
-			Username:            username,
-			IsBot:               c.isBot,
-			Score:               score,
-			IsReady:             true,
-			LastAnsweredQestion: currentQuestion.ID,
-			LastChoosenOption:   int(submission.AnswerOption),
-			IsExited:            false,
+			UserID:   c.userID,
+			Username: username,
+			IsBot:    c.isBot,
+			IsReady:  true,
+			Team:     c.manager.assignTeam(c.roomCode, c.userID),
 		})
+		idx = len(gameState.Participants) - 1
+	}
+	participant := &gameState.Participants[idx]
+	if participant.Eliminated {
+		c.manager.Unlock()
+		if !c.isBot {
+			sendGameError("you are out of this game, you can only watch now", c)
+		}
+		return nil
+	}
+
+	// check to make sure that no scores to be calculated when the same option is clicked again and again
+	sameAnswer := participant.LastAnsweredQestion == currentQuestion.ID && participant.LastChoosenOption == int(submission.AnswerOption) &&
+		participant.LastAnswerData.Equal(submission.AnswerData)
+	if !sameAnswer {
+		score := scoreAnswer(gameState, participant, credit, latency)
+		participant.LastChoosenOption = int(submission.AnswerOption)
+		participant.LastAnswerData = submission.AnswerData
+		participant.LatencyMs = int(latency.Milliseconds())
+
 		// Update the answer history
 		if _, exists := c.ansHistory[currentQuestion.ID]; !exists {
 			c.ansHistory[currentQuestion.ID] = make(map[uuid.UUID]*quizmodel.AnswerReq)
@@ -774,8 +1112,14 @@ func SubmitAnswerHandler(ctx context.Context, event Event, c *Client) error {
 			QuestionID:     currentQuestion.ID,
 			QuestionDataID: submission.QuestionDataID,
 			AnswerOption:   submission.AnswerOption,
+			AnswerData:     submission.AnswerData,
 			IsCorrect:      isCorrect,
 			AnswerTime:     submission.AnswerTime,
+			LatencyMs:      int(latency.Milliseconds()),
+			FlagReason:     flagReason,
+			Points:         score.Points,
+			ScoreReason:    score.Reason,
+			PowerUps:       powerUpsOn(gameState, c.userID),
 			CreatedBy:      "system",
 		}
 	}
@@ -800,12 +1144,49 @@ func SubmitAnswerHandler(ctx context.Context, event Event, c *Client) error {
 	if err != nil {
 		return err
 	}
+	sendAnswerDistribution(ctx, c.manager, c.roomCode)
 
 	// Send acknowledgment only to the client who submitted
 	c.egress <- ackEvent
 	return nil
 }
 
+// scoreAnswer scores the answer with the scoring rule of the room. a changed answer takes back the points of
+// the earlier one so every question is counted once, caller must hold the manager lock
+func scoreAnswer(gameState *quizmodel.GameState, participant *quizmodel.Participant, credit float64, latency time.Duration) quiz.Score {
+	idx := gameState.CurrentQuestionIndex
+	currentQuestion := gameState.Questions.QuestionData[idx]
+
+	changes := 0
+	if participant.LastAnsweredQestion == currentQuestion.ID {
+		changes = participant.AnswerChanges + 1
+		participant.Score -= participant.QuestionPoints
+	} else {
+		participant.StreakBefore = participant.Streak // missed questions already broke the streak when they were closed
+	}
+
+	score := quiz.GetScoringRule(gameState.ScoringRule).Score(quiz.ScoredAnswer{
+		Credit:     credit,
+		Latency:    latency,
+		TimeLimit:  gameState.Questions.QuestionTimeLimit(idx),
+		Difficulty: gameState.Questions.Difficulty,
+		Changes:    changes,
+		Streak:     participant.StreakBefore,
+	})
+	if state, exists := gameState.PowerUps[participant.UserID]; exists && state.DoubleOn == idx+1 {
+		score = doublePoints(score)
+	}
+	participant.Score += score.Points
+	participant.QuestionPoints = score.Points
+	participant.AnswerChanges = changes
+	participant.Streak = 0
+	if credit >= 1 {
+		participant.Streak = participant.StreakBefore + 1
+	}
+	participant.LastAnsweredQestion = currentQuestion.ID
+	return score
+}
+
 // NextQuestionHandler is manually called by the user by clicking the next question button
 func NextQuestionHandler(ctx context.Context, event Event, c *Client) error {
 
@@ -821,11 +1202,15 @@ func NextQuestionHandler(ctx context.Context, event Event, c *Client) error {
 		l.Sugar().Error(fmt.Sprintf("game state not found for room %s", c.roomCode))
 		return fmt.Errorf("game state not found for room %s", c.roomCode)
 	}
+	// the next question is on its way once the reveal is over
+	if gameState.Revealing {
+		return nil
+	}
 	isAllMembersSubmitted := true
 	currentQuestion := gameState.Questions.QuestionData[gameState.CurrentQuestionIndex]
 
 	for _, participant := range gameState.Participants { // TODO: This logic is not the best logic here we are checking if all of them have answeredby checking if all have the same latest quest id
-		if !participant.IsBot && !participant.IsExited && currentQuestion.ID != participant.LastAnsweredQestion {
+		if !participant.IsBot && !participant.IsExited && !participant.Eliminated && currentQuestion.ID != participant.LastAnsweredQestion {
 			isAllMembersSubmitted = false
 			break
 
@@ -850,7 +1235,8 @@ func NextQuestionHandler(ctx context.Context, event Event, c *Client) error {
 
 			gameState.CurrentQuestionIndex++
 			c.manager.Unlock()
-			return sendNextQuestion(ctx, c.manager, c.roomCode)
+			advanceQuestion(ctx, c.manager, c.roomCode, gameState.CurrentQuestionIndex-1)
+			return nil
 		} else {
 			c.manager.Unlock()
 		}
@@ -890,11 +1276,11 @@ func sendGameError(message string, c *Client) {
 	// }
 }
 
-func updateAnswerHistory(ctx context.Context, ansHistory map[uuid.UUID]map[uuid.UUID]*quizmodel.AnswerReq) error {
+func (m *Manager) updateAnswerHistory(ctx context.Context, ansHistory map[uuid.UUID]map[uuid.UUID]*quizmodel.AnswerReq) error {
 	l := logs.GetLoggerctx(ctx)
 	for _, answermap := range ansHistory {
 		for _, answerreq := range answermap {
-			err := quiz.CreateAnswer(ctx, answerreq)
+			err := m.quizService.CreateAnswer(ctx, answerreq)
 			if err != nil {
 				l.Sugar().Error("create answer failed", err)
 				return err // todo:retry logic needs to be added
@@ -909,40 +1295,88 @@ func sendLiveLeaderBoard(ctx context.Context, manager *Manager, roomCode string)
 
 	manager.Lock()
 	gameState, exists := manager.gameStates[roomCode]
-	manager.Unlock()
 	if !exists {
+		manager.Unlock()
 		l.Sugar().Error("game state not found for room %s", roomCode)
 		return fmt.Errorf("game state not found for room %s", roomCode)
 	}
+	lbEvent := buildLeaderBoardEvent(gameState)
+	manager.Unlock()
+
+	// Broadcast to all clients
+	manager.broadcast(ctx, roomCode, lbEvent)
+
+	// Also notify bots about game end
+	// manager.broadcastToBots(ctx, roomCode, endEvent) TODO: somehow notify bots to exit the routine clear the client memory
+	//	quiz.HandleLastQuestion(ctx,roomCode,)
+
+	return nil
+}
+
+// leaderBoardEntry is a participant as the live leaderboard shows him. the answers are left out since the
+// leaderboard goes out while the question is still running, they reach the room with the reveal
+type leaderBoardEntry struct {
+	UserID          uuid.UUID `json:"userId"`
+	Username        string    `json:"username"`
+	IsBot           bool      `json:"isBot"`
+	Score           int       `json:"score"`
+	IsExited        bool      `json:"exited"`
+	Streak          int       `json:"streak"`
+	BestStreak      int       `json:"bestStreak"`
+	Team            int       `json:"team,omitempty"`
+	Eliminated      bool      `json:"eliminated,omitempty"`
+	EliminatedRound int       `json:"eliminatedRound,omitempty"`
+}
 
+// buildLeaderBoardEvent prepares the leaderboard event with the participants sorted by score, caller must hold the manager lock
+func buildLeaderBoardEvent(gameState *quizmodel.GameState) Event {
 	sort.Slice(gameState.Participants, func(i, j int) bool {
 		return gameState.Participants[i].Score > gameState.Participants[j].Score
 	})
 
-	// Send game end event
+	scores := make([]leaderBoardEntry, 0, len(gameState.Participants))
+	for _, participant := range gameState.Participants {
+		scores = append(scores, leaderBoardEntry{
+			UserID:          participant.UserID,
+			Username:        participant.Username,
+			IsBot:           participant.IsBot,
+			Score:           participant.Score,
+			IsExited:        participant.IsExited,
+			Streak:          participant.Streak,
+			BestStreak:      participant.BestStreak,
+			Team:            participant.Team,
+			Eliminated:      participant.Eliminated,
+			EliminatedRound: participant.EliminatedRound,
+		})
+	}
 	lbPayload := struct {
-		Message string                  `json:"message"`
-		Scores  []quizmodel.Participant `json:"scores"`
+		Message string                `json:"message"`
+		Scores  []leaderBoardEntry    `json:"scores"`
+		Teams   []quizmodel.TeamScore `json:"teams,omitempty"` // team totals of a room in team mode
 	}{
 		Message: "The live leaderboard is updated.",
-		Scores:  gameState.Participants,
+		Scores:  scores,
+		Teams:   quiz.TeamScores(gameState.Participants, gameState.TeamCount),
 	}
 
 	lbData, _ := json.Marshal(lbPayload)
-	lbEvent := Event{Type: EventLeaderBoard, Payload: lbData}
+	return Event{Type: EventLeaderBoard, Payload: lbData}
+}
 
-	// Broadcast to all clients including bots
-	for client := range manager.clients[roomCode] {
-		if !client.isBot {
-			client.egress <- lbEvent
+// everyoneReady tells if the game can start, the members who are gone and the host are not waited for
+func everyoneReady(roomMembers []*roommodel.RoomMember) bool {
+	for _, member := range roomMembers {
+		switch {
+		case member.IsBot || member.Role == roommodel.Host:
+			continue
+		case member.RoomMemberStatus == roommodel.LeaveQuiz || member.RoomMemberStatus == roommodel.KickedQuiz || member.RoomMemberStatus == roommodel.BannedQuiz:
+			continue
+		}
+		if member.RoomMemberStatus != roommodel.ReadyQuiz {
+			return false
 		}
 	}
-
-	// Also notify bots about game end
-	// manager.broadcastToBots(ctx, roomCode, endEvent) TODO: somehow notify bots to exit the routine clear the client memory
-	//	quiz.HandleLastQuestion(ctx,roomCode,)
-
-	return nil
+	return true
 }
 
 // Modified ReadyGameMessageHandler to check if all participants are ready and start game
@@ -954,7 +1388,7 @@ func ReadyGameMessageHandler(ctx context.Context, event Event, c *Client) error
 	// TODO: this api is wrong we can update inmemory
 
 	// Update the room member's ready status
-	err := room.UpdateRoomMemberStatusByRoomCodeAndUserID(ctx, &roommodel.RoomCodeReq{
+	err := c.manager.roomService.UpdateRoomMemberStatusByRoomCodeAndUserID(ctx, &roommodel.RoomCodeReq{
 		UserID:   userDetails.ID,
 		RoomCode: c.roomCode,
 	}, roommodel.ReadyQuiz)
@@ -964,7 +1398,7 @@ func ReadyGameMessageHandler(ctx context.Context, event Event, c *Client) error
 	}
 
 	// Check if all room members are ready TODO: we dont have to go to database room member details are in memory
-	roomMembers, err := room.ListRoomMembersByRoomCode(ctx, roommodel.RoomCodeReq{
+	roomMembers, err := c.manager.roomService.ListRoomMembersByRoomCode(ctx, roommodel.RoomCodeReq{
 		RoomCode: c.roomCode,
 	})
 	if err != nil {
@@ -973,13 +1407,7 @@ func ReadyGameMessageHandler(ctx context.Context, event Event, c *Client) error
 	}
 
 	// Check if everyone is ready
-	allReady := true
-	for _, member := range roomMembers {
-		if member.RoomMemberStatus != roommodel.ReadyQuiz && !member.IsBot {
-			allReady = false
-			break
-		}
-	}
+	allReady := everyoneReady(roomMembers)
 
 	// Everyone is ready, start the game
 	gameReadyNotification := struct {
@@ -998,16 +1426,8 @@ func ReadyGameMessageHandler(ctx context.Context, event Event, c *Client) error
 	}
 	readyEvent := Event{Type: EventReadyGame, Payload: readyEventJson}
 
-	// Broadcast start notification to all clients
-	c.manager.Lock()
-	clients := c.manager.clients[c.roomCode]
-	c.manager.Unlock()
-	for client := range clients {
-		if client.isBot {
-			continue
-		}
-		client.egress <- readyEvent
-	}
+	// Broadcast ready notification to all clients
+	c.manager.broadcast(ctx, c.roomCode, readyEvent)
 
 	if allReady {
 		// Everyone is ready, start the game
@@ -1025,17 +1445,8 @@ func ReadyGameMessageHandler(ctx context.Context, event Event, c *Client) error
 		startData, _ := json.Marshal(gameReadyNotification)
 		startEvent := Event{Type: EventStartGame, Payload: startData}
 
-		c.manager.Lock()
-		clients := c.manager.clients[c.roomCode]
-		c.manager.Unlock()
-
 		// Broadcast start notification to all clients
-		for client := range clients {
-			if client.isBot {
-				continue
-			}
-			client.egress <- startEvent
-		}
+		c.manager.broadcast(ctx, c.roomCode, startEvent)
 
 		// Wait 3 seconds then start the game
 		go func() {
@@ -1112,11 +1523,13 @@ func ChatGameRoomHandler(ctx context.Context, event Event, c *Client) error {
 	// the event send the user message
 	// type: "chat_message", // Client sends this type to the server
 	//     payload: {
-	//       message: message
+	//       message: message,
+	//       scope: "team" // optional, keeps the message inside the team of the sender
 	//     }
 	//   };
 	type payload struct {
 		Message string `json:"message"`
+		Scope   string `json:"scope"`
 	}
 
 	p := &payload{}
@@ -1130,15 +1543,33 @@ func ChatGameRoomHandler(ctx context.Context, event Event, c *Client) error {
 		sendGameError("maximum 200 characters are allowed!", c)
 	}
 
+	// team chat only reaches the members of the team of the sender
+	team := 0
+	if p.Scope == "team" {
+		c.manager.RLock()
+		team = c.manager.teamOf(c.roomCode, c.userID)
+		c.manager.RUnlock()
+		if team == 0 {
+			sendGameError("you are not in a team", c)
+			return nil
+		}
+	}
+
 	// before leaving remove this client
 	chatGameNotfication := struct {
 		UserName string    `json:"username"`
 		Message  string    `json:"message"`
 		Time     time.Time `json:"time"`
+		Team     int       `json:"team,omitempty"`
+		TeamName string    `json:"teamName,omitempty"`
 	}{
 		UserName: userDetails.UserName,
 		Message:  p.Message,
 		Time:     time.Now(),
+		Team:     team,
+	}
+	if team > 0 {
+		chatGameNotfication.TeamName = quiz.TeamName(team)
 	}
 
 	startData, _ := json.Marshal(chatGameNotfication)
@@ -1147,15 +1578,7 @@ func ChatGameRoomHandler(ctx context.Context, event Event, c *Client) error {
 
 	// Broadcast leave notification to that client
 
-	c.manager.Lock()
-	clients := c.manager.clients[c.roomCode]
-	c.manager.Unlock()
-
-	for client := range clients {
-		if !client.isBot {
-			client.egress <- eventPayload
-		}
-	}
+	c.manager.broadcast(ctx, c.roomCode, eventPayload)
 
 	return nil
 }
@@ -1188,14 +1611,27 @@ func (m *Manager) addClient(client *Client) {
 	m.Unlock()
 }
 
-func (m *Manager) removeClient(client *Client) {
+func (m *Manager) removeClient(ctx context.Context, client *Client) {
 
 	m.Lock()
-	if _, exists := m.clients[client.roomCode]; exists {
+	_, exists := m.clients[client.roomCode][client]
+	if exists {
 		delete(m.clients[client.roomCode], client)
 		// client.connection.Close()
+		// a player who drops in the middle of the game gets some time to come back
+		if m.canSuspend(client) {
+			m.suspendClient(ctx, client)
+		}
 	}
+	spectator := client.isSpectator()
 	m.Unlock()
+
+	if exists && spectator {
+		m.sendSpectatorCount(ctx, client.roomCode)
+	}
+	if exists {
+		m.releaseIdleRoom(ctx, client.roomCode)
+	}
 }
 
 func (m *Manager) addBot(roomCode string, bot *Client) {
@@ -1263,7 +1699,7 @@ func (m *Manager) readMessages(ctx context.Context, c *Client) {
 
 	defer func() {
 		l.Sugar().Infof("Ending read goroutine for user %s", c.userID)
-		c.manager.removeClient(c)
+		c.manager.removeClient(ctx, c)
 	}()
 
 	// I am registering pong handler here so that gorilla mux can handle pong
@@ -1328,7 +1764,7 @@ func (m *Manager) writeUsersMessages(ctx context.Context, c *Client) {
 
 	defer func() {
 		ticker.Stop()
-		m.removeClient(c)
+		m.removeClient(ctx, c)
 	}()
 	for {
 		select {
@@ -1355,7 +1791,7 @@ func (m *Manager) writeUsersMessages(ctx context.Context, c *Client) {
 			if err != nil {
 				l.Sugar().Error("ping error", err)
 				ticker.Stop()
-				c.manager.removeClient(c)
+				c.manager.removeClient(ctx, c)
 				return
 			}
 		case <-ctx.Done():
@@ -1386,8 +1822,6 @@ func SendMessageHandler(ctx context.Context, event Event, c *Client) error {
 	data, _ := json.Marshal(broadMessage)
 	outgoing := Event{Type: "send_message", Payload: data}
 
-	for client := range c.manager.clients[c.roomCode] {
-		client.egress <- outgoing
-	}
+	c.manager.broadcast(ctx, c.roomCode, outgoing)
 	return nil
 }
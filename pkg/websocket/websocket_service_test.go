@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	quizmodel "brainwars/pkg/quiz/model"
+	roommodel "brainwars/pkg/room/model"
+	usermodel "brainwars/pkg/users/model"
+	"brainwars/pkg/util"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestLeaderBoardLeavesOutTheAnswers(t *testing.T) {
+	gameState := &quizmodel.GameState{
+		Participants: []quizmodel.Participant{
+			{UserID: uuid.New(), Username: "second", Score: 100, LastAnsweredQestion: uuid.New(), LastChoosenOption: 3},
+			{UserID: uuid.New(), Username: "first", Score: 300, Streak: 2, LastAnswerData: &quizmodel.AnswerData{Text: "Au"}},
+		},
+	}
+
+	event := buildLeaderBoardEvent(gameState)
+
+	payload := string(event.Payload)
+	for _, field := range []string{"chosenOption", "answerData", "answerID", "Au"} {
+		if strings.Contains(payload, field) {
+			t.Errorf("the leaderboard gives away %s: %s", field, payload)
+		}
+	}
+	leaderBoard := struct {
+		Scores []leaderBoardEntry `json:"scores"`
+	}{}
+	if err := json.Unmarshal(event.Payload, &leaderBoard); err != nil {
+		t.Fatalf("leaderboard payload: %v", err)
+	}
+	if len(leaderBoard.Scores) != 2 || leaderBoard.Scores[0].Username != "first" || leaderBoard.Scores[0].Streak != 2 {
+		t.Errorf("got the leaderboard %+v, want first with a streak of 2 on top", leaderBoard.Scores)
+	}
+}
+
+func TestEveryoneReady(t *testing.T) {
+	member := func(status roommodel.RoomMemberStatus) *roommodel.RoomMember {
+		return &roommodel.RoomMember{UserID: uuid.New(), RoomMemberStatus: status, Role: roommodel.Player}
+	}
+	host := &roommodel.RoomMember{UserID: uuid.New(), RoomMemberStatus: roommodel.JoinQuiz, Role: roommodel.Host}
+	bot := &roommodel.RoomMember{UserID: uuid.New(), RoomMemberStatus: roommodel.JoinQuiz, IsBot: true}
+
+	tests := []struct {
+		name    string
+		members []*roommodel.RoomMember
+		ready   bool
+	}{
+		{"everyone ready", []*roommodel.RoomMember{member(roommodel.ReadyQuiz), member(roommodel.ReadyQuiz)}, true},
+		{"someone still joining", []*roommodel.RoomMember{member(roommodel.ReadyQuiz), member(roommodel.JoinQuiz)}, false},
+		{"bots are always ready", []*roommodel.RoomMember{member(roommodel.ReadyQuiz), bot}, true},
+		{"the host is not waited for", []*roommodel.RoomMember{member(roommodel.ReadyQuiz), host}, true},
+		{"members who left are not waited for", []*roommodel.RoomMember{member(roommodel.ReadyQuiz), member(roommodel.LeaveQuiz)}, true},
+		{"kicked members are not waited for", []*roommodel.RoomMember{member(roommodel.ReadyQuiz), member(roommodel.KickedQuiz)}, true},
+		{"banned members are not waited for", []*roommodel.RoomMember{member(roommodel.ReadyQuiz), member(roommodel.BannedQuiz)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ready := everyoneReady(tt.members); ready != tt.ready {
+				t.Errorf("got %v, want %v", ready, tt.ready)
+			}
+		})
+	}
+}
+
+func TestScoreAnswerScalesByTheDifficultyOfTheRoom(t *testing.T) {
+	tests := []struct {
+		name       string
+		difficulty quizmodel.Difficulty
+		points     int
+	}{
+		{"easy", quizmodel.Easy, 150},
+		{"medium", quizmodel.Medium, 225},
+		{"hard", quizmodel.Hard, 300},
+		{"room created before the difficulty", "", 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1}
+			gameState := &quizmodel.GameState{
+				ScoringRule: quizmodel.ClassicScoring,
+				Questions:   &quizmodel.Question{TimeLimit: 10, Difficulty: tt.difficulty, QuestionData: []*quizmodel.QuestionData{question}},
+			}
+			participant := &quizmodel.Participant{UserID: uuid.New()}
+
+			// a right answer at half time is worth 100 points plus a speed bonus of 50
+			score := scoreAnswer(gameState, participant, 1, 5*time.Second)
+
+			if score.Points != tt.points || participant.Score != tt.points {
+				t.Errorf("got %d points (%s) and a score of %d, want %d", score.Points, score.Reason, participant.Score, tt.points)
+			}
+		})
+	}
+}
+
+func TestQuestionsKeepTheirOwnTimeLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		questionLimit int // seconds, the 10 seconds of the room when 0
+		answeredAfter time.Duration
+		taken         bool
+	}{
+		{name: "in the time of the room", answeredAfter: 8 * time.Second, taken: true},
+		{name: "after the time of the room", answeredAfter: 12 * time.Second, taken: false},
+		{name: "a longer question outlasts the room", questionLimit: 30, answeredAfter: 20 * time.Second, taken: true},
+		{name: "a shorter question closes before the room", questionLimit: 5, answeredAfter: 8 * time.Second, taken: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			question := &quizmodel.QuestionData{ID: uuid.New(), Answer: 1, TimeLimit: tt.questionLimit, Options: []quizmodel.Options{{ID: 1, Option: "a"}}}
+			gameState := startedGame(m, "room-1", question)
+			gameState.QuestionStartTime = time.Now().Add(-tt.answeredAfter)
+			player, _ := addTestClient(t, m, "room-1")
+			ctx := util.SetUserInfoInctx(testContext(), &usermodel.UserInfo{ID: player.userID, UserName: "player"})
+
+			wantLimit := tt.questionLimit
+			if wantLimit == 0 {
+				wantLimit = 10
+			}
+			event, err := buildQuestionEvent(gameState)
+			sent := questionEvent{}
+			if err != nil || json.Unmarshal(event.Payload, &sent) != nil || sent.TimeLimit != wantLimit {
+				t.Errorf("the question was sent with %d seconds, want %d", sent.TimeLimit, wantLimit)
+			}
+
+			if err := SubmitAnswerHandler(ctx, answerEvent(t, question, 1), player); err != nil {
+				t.Fatalf("submit answer: %v", err)
+			}
+
+			if taken := len(gameState.Participants) == 1; taken != tt.taken {
+				t.Errorf("answer taken %v, want %v", taken, tt.taken)
+			}
+			if _, late := nextEventOf(t, player, EventGameError); late == tt.taken {
+				t.Errorf("the player should be told the time is up only when the answer is late")
+			}
+		})
+	}
+}
@@ -15,7 +15,7 @@ import (
 //	user is validated in the middleware before this
 //
 // so store the user details from the db into the context so that it can be used accross the app
-func CustomProfileMiddleware() gin.HandlerFunc {
+func CustomProfileMiddleware(userService *user.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context() // this context has logger in it
 
@@ -38,13 +38,13 @@ func CustomProfileMiddleware() gin.HandlerFunc {
 		var userInfo *model.UserInfo
 
 		if uinfo == nil { // session doesnt have the userinfo. we go to the database fecth the info,store it in the session as well as context and use it everywhere
-			userInfo, err = user.GetUserDetailsbyAuth0SubID(ctx, sub)
+			userInfo, err = userService.GetUserDetailsbyAuth0SubID(ctx, sub)
 			if err != nil {
 				log.Fatalln("get user details by id failed", err)
 				return
 			}
 			if userInfo == nil {
-				userInfo, err = user.CreateNewUser(ctx, &model.NewUserReq{
+				userInfo, err = userService.CreateNewUser(ctx, &model.NewUserReq{
 					Auth0SubID: sub,
 					UserName:   username,
 					UserType:   model.User,
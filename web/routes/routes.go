@@ -2,6 +2,9 @@ package routes
 
 import (
 	"brainwars/pkg/auth"
+	"brainwars/pkg/quiz"
+	"brainwars/pkg/room"
+	user "brainwars/pkg/users"
 	"brainwars/pkg/websocket"
 	"brainwars/web/middleware"
 	"brainwars/web/ui/handlers"
@@ -16,7 +19,7 @@ import (
 	"go.uber.org/zap"
 )
 
-func Initialize(ctx context.Context, l *zap.Logger, auth *auth.Authenticator) (router *gin.Engine) {
+func Initialize(ctx context.Context, l *zap.Logger, auth *auth.Authenticator, roomService *room.Service, quizService *quiz.Service, userService *user.Service) (router *gin.Engine) {
 	l.Sugar().Info("Initializing logger")
 
 	router = gin.Default()
@@ -35,14 +38,15 @@ func Initialize(ctx context.Context, l *zap.Logger, auth *auth.Authenticator) (r
 
 	router.LoadHTMLGlob("web/ui/templates/*")
 
-	manager := websocket.NewManager(ctx)
+	manager := websocket.NewManager(ctx, roomService, quizService, userService)
+	matchmaker := room.NewMatchmaker(ctx, roomService)
 	//secure group
 	rSecure := router.Group("/bw")
 	// middleware
 	rSecure.Use(gin.Recovery())
 	rSecure.Use(middleware.ContextMiddleware(ctx))
 	rSecure.Use(middleware.AuthMiddleware)
-	rSecure.Use(middleware.CustomProfileMiddleware())
+	rSecure.Use(middleware.CustomProfileMiddleware(userService))
 	rSecure.Use(middleware.SessionMiddleware())
 
 	// login and auth
@@ -53,6 +57,7 @@ func Initialize(ctx context.Context, l *zap.Logger, auth *auth.Authenticator) (r
 	router.GET("/", handlers.LoginPageHandler)
 	router.GET("/login", handlers.LoginHandler(auth))
 	router.GET("/callback", handlers.LoginCallbackHandler(auth))
+	router.GET("/join/:code", handlers.InviteLinkHandler) // shared invite link, works before login
 	rSecure.GET("/logout", handlers.LogoutHandler)
 
 	// index
@@ -62,22 +67,33 @@ func Initialize(ctx context.Context, l *zap.Logger, auth *auth.Authenticator) (r
 	// navbar
 	rSecure.GET("/navbar", handlers.GetNavbar)
 	// room
-	rSecure.POST("/croom", handlers.CreateRoomHandler)
+	rSecure.POST("/croom", handlers.CreateRoomHandler(roomService))
 	rSecure.GET("/lroom", handlers.ListAllRoomsHanlder)
-	rSecure.GET("/jroom/:code", handlers.JoinRoomHandler)
-	rSecure.GET("/ingame/:code", handlers.InGameHandler)
+	rSecure.GET("/jroom/:code", handlers.JoinRoomHandler(roomService))
+	rSecure.GET("/ingame/:code", handlers.InGameHandler(roomService))
+	rSecure.GET("/spectate/:code", handlers.SpectateRoomHandler(roomService))
+	rSecure.GET("/browse", handlers.BrowseRoomsHandler(roomService))
+	// invite codes
+	rSecure.GET("/invite/:code", handlers.RoomInviteHandler(roomService))
+	rSecure.POST("/invite/:code/revoke", handlers.RevokeInviteHandler(roomService))
+	// matchmaking
+	rSecure.POST("/match", handlers.JoinMatchHandler(matchmaker))
+	rSecure.GET("/match", handlers.MatchStatusHandler(matchmaker))
+	rSecure.POST("/match/leave", handlers.LeaveMatchHandler(matchmaker))
 	// http: //localhost:8080/ingame/?roomCode=c5bb492a-051a-42a6-89ec-24e899ea3c14
 	// websocket
 	rSecure.GET("/ws", manager.ServeWS)
 
 	//questions
 	rSecure.GET("/gquest", handlers.GetQuestionHandler)
-	rSecure.GET("/quest", handlers.CreateQuestionPageHanlder)
-	rSecure.POST("/cquest", handlers.CreateQuestionsHandler)
+	rSecure.GET("/quest", handlers.CreateQuestionPageHanlder(quizService))
+	rSecure.POST("/cquest", handlers.CreateQuestionsHandler(quizService))
+	rSecure.POST("/quest/:id/delete", handlers.DeleteQuestionHandler(quizService))
+	rSecure.POST("/quest/room", handlers.CreateRoomFromBankHandler(roomService))
 
 	// analytics
-	rSecure.GET("/analyze/:code", handlers.AnalyticsHandler)
-	rSecure.GET("/my-quiz", handlers.MyQuizHistoryHandler)
+	rSecure.GET("/analyze/:code", handlers.AnalyticsHandler(roomService))
+	rSecure.GET("/my-quiz", handlers.MyQuizHistoryHandler(roomService))
 
 	for _, route := range router.Routes() {
 		l.Sugar().Infof("Route: %s %s", route.Method, route.Path)
@@ -2,18 +2,22 @@ package handlers
 
 import (
 	"brainwars/pkg/auth"
+	"brainwars/pkg/quiz"
 	quizmodel "brainwars/pkg/quiz/model"
 	"brainwars/pkg/room"
 	"brainwars/pkg/room/model"
 	roommodel "brainwars/pkg/room/model"
 	usermodel "brainwars/pkg/users/model"
 	"brainwars/pkg/util"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -69,6 +73,9 @@ func LoginCallbackHandler(authenticator *auth.Authenticator) gin.HandlerFunc {
 			return
 		}
 		fmt.Println(profile)
+		// the page the user was on before he was sent to login, like an invite link
+		returnTo, _ := session.Get("return_to").(string)
+		session.Delete("return_to")
 		session.Set("access_token", token.AccessToken)
 		session.Set("profile", profile)
 		if err := session.Save(); err != nil {
@@ -77,6 +84,10 @@ func LoginCallbackHandler(authenticator *auth.Authenticator) gin.HandlerFunc {
 		}
 
 		// Redirect to logged in page.
+		if isLocalPath(returnTo) {
+			c.Redirect(http.StatusTemporaryRedirect, returnTo)
+			return
+		}
 		c.Redirect(http.StatusTemporaryRedirect, "/bw/home/") // todo: we need to take a look into authenticating the same in auth middleware
 
 	}
@@ -131,198 +142,334 @@ func LandingPageHandler(c *gin.Context) {
 func HomeHandler(c *gin.Context) {
 	// get the user credentials
 	RenderTemplate(c, "home.html", gin.H{
-		"title": "home Page",
+		"title":      "home Page",
+		"categories": roommodel.MatchCategories,
 	})
 }
 
 // Room
 
-func CreateRoomHandler(c *gin.Context) {
-	ctx := c.Request.Context() // this context has logger in it
-
-	// RenderSuccessTemplate(c, "home.html", "success testing!")
-	// return
-	c.Request.ParseForm()
+func CreateRoomHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context() // this context has logger in it
 
-	userInfo := util.GetUserInfoFromctx(ctx)
-	userID := userInfo.ID
-	gameType := c.PostForm("game-type")
-	bots := c.PostFormArray("bots")
-	topic := c.PostForm("topic")
-	topic = strings.TrimSpace(topic)
-	if len(topic) > 50 {
-		RenderErrorTemplate(c, "home.html", "length of the topic shouldnt be more than 50 characters", nil)
-	}
-	if topic == "" {
-		RenderErrorTemplate(c, "home.html", "topic cannot be empty", nil)
+		// RenderSuccessTemplate(c, "home.html", "success testing!")
+		// return
+		c.Request.ParseForm()
 
-	}
-	timelimit := c.PostForm("timelimit")
-	roomName := c.PostForm("roomName")
-	difficulty := c.PostForm("difficulty")
-	fmt.Println(difficulty)
-	tl, err := strconv.Atoi(timelimit)
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "time limit is a required field", nil)
-		return
-	}
-	questionCount := c.PostForm("questionCount")
-	qc, err := strconv.Atoi(questionCount)
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "question count is in wrong format", err)
-		return
-	}
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+		gameType := c.PostForm("game-type")
+		bots := c.PostFormArray("bots")
+		topic := c.PostForm("topic")
+		topic = strings.TrimSpace(topic)
+		if len(topic) > 50 {
+			RenderErrorTemplate(c, "home.html", "length of the topic shouldnt be more than 50 characters", nil)
+		}
+		if topic == "" {
+			RenderErrorTemplate(c, "home.html", "topic cannot be empty", nil)
 
-	gt := model.SP
-	if gameType == "2" {
-		gt = model.MP
-	}
-	roomreq := roommodel.RoomReq{
-		UserID:    userID,
-		Username:  userInfo.UserName,
-		UserMeta:  "[{}]",
-		RoomName:  roomName,
-		GameType:  gt,
-		TimeLimit: tl,
-	}
-	validate := validator.New(validator.WithRequiredStructEnabled())
+		}
+		timelimit := c.PostForm("timelimit")
+		roomName := c.PostForm("roomName")
+		difficulty := c.PostForm("difficulty")
+		tl, err := strconv.Atoi(timelimit)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "time limit is a required field", nil)
+			return
+		}
+		questionCount := c.PostForm("questionCount")
+		qc, err := strconv.Atoi(questionCount)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "question count is in wrong format", err)
+			return
+		}
 
-	// returns nil or ValidationErrors ( []FieldError )
-	err = validate.Struct(roomreq)
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "invalid user input", err)
+		gt := gameTypeFromForm(gameType)
+		roomreq := roommodel.RoomReq{
+			UserID:      userID,
+			Username:    userInfo.UserName,
+			UserMeta:    "[{}]",
+			RoomName:    roomName,
+			GameType:    gt,
+			TimeLimit:   tl,
+			ScoringRule: c.PostForm("scoring"),
+			Teams:       teamCount(c),
+			IsPublic:    c.PostForm("public") == "on",
+		}
+		validate := validator.New(validator.WithRequiredStructEnabled())
 
-	}
-	botIDs := []roommodel.UserIDReq{}
-	for _, botsInput := range bots {
-		botIDs = append(botIDs, roommodel.UserIDReq{UserID: usermodel.BotMap[botsInput]})
-	}
+		// returns nil or ValidationErrors ( []FieldError )
+		err = validate.Struct(roomreq)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "invalid user input", err)
+			return
+		}
+		botIDs := []roommodel.UserIDReq{}
+		for _, botsInput := range bots {
+			botIDs = append(botIDs, roommodel.UserIDReq{UserID: usermodel.BotMap[botsInput]})
+		}
 
-	questReq := &quizmodel.QuizReq{
-		Topic:      topic,
-		Count:      qc,
-		Difficulty: quizmodel.Difficulty(difficulty),
-	}
+		questionTypes := []quizmodel.QuestionType{}
+		for _, questionType := range c.PostFormArray("questionTypes") {
+			questionTypes = append(questionTypes, quizmodel.QuestionType(questionType))
+		}
+		questReq := &quizmodel.QuizReq{
+			Topic:         topic,
+			Count:         qc,
+			Difficulty:    quizmodel.Difficulty(difficulty),
+			QuestionTypes: questionTypes,
+		}
 
-	err = validate.Struct(questReq)
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "invalid user input", err)
+		err = validate.Struct(questReq)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "invalid user input", err)
+			return
+		}
 
-	}
+		roomCode, err := roomService.SetupGame(ctx, roomreq, botIDs, questReq)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to Setup game", err)
+			return
+		}
+		if roomreq.GameType == model.SP {
+			// redirct to the game room
+			c.Redirect(302, fmt.Sprintf("/bw/ingame/%s", roomCode))
+			// immidiately join the room,start the game
 
-	roomCode, err := room.SetupGame(ctx, roomreq, botIDs, questReq)
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Failed to Setup game", err)
-		return
-	}
-	if roomreq.GameType == model.SP {
-		// redirct to the game room
-		c.Redirect(302, fmt.Sprintf("/bw/ingame/%s", roomCode))
-		// immidiately join the room,start the game
+			return
+		}
+		// if he is a multiplayer mode then redirect to main page through which he can join the game with room code
+		// give a green popup and be in the same page
+		RenderSuccessTemplate(c, "home.html", "Successfully room created. Go to My Quiz for your room code")
+		// c.Redirect(302, "/bw/home/")
 
-		return
 	}
-	// if he is a multiplayer mode then redirect to main page through which he can join the game with room code
-	// give a green popup and be in the same page
-	RenderSuccessTemplate(c, "home.html", "Successfully room created. Go to My Quiz for your room code")
-	// c.Redirect(302, "/bw/home/")
-
 }
 
 // after the room is created, the user can join the room
 // websocket connection is created after the person joins the room
-func JoinRoomHandler(c *gin.Context) {
-	ctx := c.Request.Context() // this context has logger in it
-	// check if there is a room that exists
-	roomCode := c.Param("code")
-	roomCode = strings.TrimSpace(roomCode)
-	_, err := uuid.Parse(roomCode) // checking if the room code is a valid uuid
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
-
-	}
-	if roomCode != "" && len(roomCode) > 50 {
-		RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
-	}
-	userInfo := util.GetUserInfoFromctx(ctx)
-	userID := userInfo.ID
-	roomDetail, err := room.GetRoomByRoomCode(ctx, roomCode)
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "unable to join room", nil)
-	}
-	if roomDetail == nil {
-		RenderErrorTemplate(c, "home.html", "there is no room", nil)
+// resolveRoomCode turns a short invite code into the room code it leads to, a room code is returned as it is
+func resolveRoomCode(ctx context.Context, roomService *room.Service, code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if inviteCode := room.NormalizeInviteCode(code); room.IsInviteCode(inviteCode) {
+		return roomService.ResolveInvite(ctx, inviteCode)
 	}
+	return code, nil
+}
 
-	// check if he has already joined the room if he has then redirect him to the room
-	roomMember, err := room.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
-		UserID:   userID,
-		RoomCode: roomCode,
-	})
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Failed to join room", err)
-	}
-	if roomMember == nil {
-		_, err := room.JoinRoomWithRoomCode(ctx, roommodel.RoomMemberReq{
+func JoinRoomHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context() // this context has logger in it
+		// check if there is a room that exists
+		roomCode, err := resolveRoomCode(ctx, roomService, c.Param("code"))
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", room.ErrInviteInvalid.Error(), err)
+			return
+		}
+		_, err = uuid.Parse(roomCode) // checking if the room code is a valid uuid
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+			return
+		}
+		if roomCode != "" && len(roomCode) > 50 {
+			RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+		}
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+		roomDetail, err := roomService.GetRoomByRoomCode(ctx, roomCode)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "unable to join room", nil)
+		}
+		if roomDetail == nil {
+			RenderErrorTemplate(c, "home.html", "there is no room", nil)
+			return
+		}
+		if roomDetail.Roomstatus == roommodel.Failed {
+			RenderErrorTemplate(c, "home.html", "generating questions for this room failed, please create a new room", nil)
+			return
+		}
+
+		// check if he has already joined the room if he has then redirect him to the room
+		roomMember, err := roomService.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
 			UserID:   userID,
 			RoomCode: roomCode,
 		})
 		if err != nil {
 			RenderErrorTemplate(c, "home.html", "Failed to join room", err)
 		}
-		err = room.CreateLeaderBoard(ctx, &model.EditLeaderBoardReq{
+		if roomMember != nil && roomMember.RoomMemberStatus == roommodel.BannedQuiz {
+			RenderErrorTemplate(c, "home.html", "you are banned from this room", nil)
+			return
+		}
+		kicked := roomMember != nil && roomMember.RoomMemberStatus == roommodel.KickedQuiz
+		if roomMember == nil || kicked {
+			// a new member and a kicked member who comes back need the room to be unlocked and to have a free seat
+			err = roomService.CheckJoinable(ctx, roomDetail, roomCode)
+			if errors.Is(err, room.ErrRoomLocked) || errors.Is(err, room.ErrRoomFull) {
+				RenderErrorTemplate(c, "home.html", err.Error(), nil)
+				return
+			}
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "Failed to join room", err)
+				return
+			}
+		}
+		// a kicked member can come back when he joins again
+		if kicked {
+			err = roomService.UpdateRoomMemberStatusByRoomCodeAndUserID(ctx, &roommodel.RoomCodeReq{
+				UserID:   userID,
+				RoomCode: roomCode,
+			}, roommodel.JoinQuiz)
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "Failed to join room", err)
+				return
+			}
+		}
+		if roomMember == nil {
+			_, err = roomService.JoinRoomWithRoomCode(ctx, roommodel.RoomMemberReq{
+				UserID:   userID,
+				RoomCode: roomCode,
+			})
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "Failed to join room", err)
+			}
+			err = roomService.CreateLeaderBoard(ctx, &model.EditLeaderBoardReq{
+				UserID:   userID,
+				RoomCode: roomCode,
+				Score:    0,
+			})
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "Failed to setup leaderboard", err)
+			}
+		}
+
+		c.Redirect(http.StatusPermanentRedirect, "/bw/ingame/"+roomCode)
+	}
+}
+
+func InGameHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		roomCode := c.Param("code")
+		roomCode = strings.TrimSpace(roomCode)
+		_, err := uuid.Parse(roomCode) // checking if the room code is a valid uuid
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+
+		}
+		if roomCode != "" && len(roomCode) > 50 {
+			RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+		}
+
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+
+		roomDetails, err := roomService.GetRoomByRoomCode(ctx, roomCode)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Room Does not exists", nil)
+		}
+
+		// check if the user is already in the room
+		roomMember, err := roomService.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
 			UserID:   userID,
 			RoomCode: roomCode,
-			Score:    0,
 		})
 		if err != nil {
-			RenderErrorTemplate(c, "home.html", "Failed to setup leaderboard", err)
+			RenderErrorTemplate(c, "home.html", "Failed to join room", err)
 		}
-	}
+		if roomMember == nil {
+			RenderErrorTemplate(c, "home.html", "you are not in the room", err)
+			return
+		}
+
+		RenderTemplate(c, "game.html", gin.H{
+			"title":     "game room",
+			"roomCode":  roomCode,
+			"userID":    userID,
+			"gameType":  roomDetails.GameType,
+			"role":      roomMember.Role,
+			"isOwner":   roomDetails.CreatedBy == userID.String(),
+			"teamCount": quiz.TeamCountFromRoomMeta(roomDetails.RoomMeta),
+			"locked":    roomDetails.RoomLock,
+		})
 
-	c.Redirect(http.StatusPermanentRedirect, "/bw/ingame/"+roomCode)
+	}
 }
 
-func InGameHandler(c *gin.Context) {
-	ctx := c.Request.Context()
-	roomCode := c.Param("code")
-	roomCode = strings.TrimSpace(roomCode)
-	_, err := uuid.Parse(roomCode) // checking if the room code is a valid uuid
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+// SpectateRoomHandler lets a user who is not in the room watch it, even after the game has started
+func SpectateRoomHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		roomCode, err := resolveRoomCode(ctx, roomService, c.Param("code"))
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", room.ErrInviteInvalid.Error(), err)
+			return
+		}
+		_, err = uuid.Parse(roomCode) // checking if the room code is a valid uuid
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+			return
+		}
+		userInfo := util.GetUserInfoFromctx(ctx)
 
-	}
-	if roomCode != "" && len(roomCode) > 50 {
-		RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
-	}
+		roomDetails, err := roomService.GetRoomByRoomCode(ctx, roomCode)
+		if err != nil || roomDetails == nil {
+			RenderErrorTemplate(c, "home.html", "there is no room", err)
+			return
+		}
+		if roomDetails.Roomstatus == roommodel.Failed {
+			RenderErrorTemplate(c, "home.html", "generating questions for this room failed, there is nothing to watch", nil)
+			return
+		}
 
-	userInfo := util.GetUserInfoFromctx(ctx)
-	userID := userInfo.ID
+		// members of the room play or host it, they dont spectate
+		roomMember, err := roomService.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
+			UserID:   userInfo.ID,
+			RoomCode: roomCode,
+		})
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to spectate the room", err)
+			return
+		}
+		if roomMember != nil && roomMember.RoomMemberStatus == roommodel.BannedQuiz {
+			RenderErrorTemplate(c, "home.html", "you are banned from this room", nil)
+			return
+		}
+		if roomMember != nil {
+			c.Redirect(http.StatusFound, "/bw/ingame/"+roomCode)
+			return
+		}
+		err = roomService.CheckWatchable(roomDetails)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", err.Error(), nil)
+			return
+		}
 
-	roomDetails, err := room.GetRoomByRoomCode(ctx, roomCode)
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Room Does not exists", nil)
+		RenderTemplate(c, "game.html", gin.H{
+			"title":    "spectating",
+			"roomCode": roomCode,
+			"userID":   userInfo.ID,
+			"gameType": roomDetails.GameType,
+			"role":     roommodel.Spectator,
+		})
 	}
+}
 
-	// check if the user is already in the room
-	roomMember, err := room.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
-		UserID:   userID,
-		RoomCode: roomCode,
-	})
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Failed to join room", err)
-	}
-	if roomMember == nil {
-		RenderErrorTemplate(c, "home.html", "you are not in the room", err)
+// BrowseRoomsHandler lists the public rooms that are waiting for players, they can be joined without the room code
+func BrowseRoomsHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		rooms, err := roomService.ListPublicRooms(ctx)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to list public rooms", err)
+			return
+		}
+		RenderTemplate(c, "browse.html", gin.H{
+			"title": "Public rooms",
+			"rooms": rooms,
+		})
 	}
-
-	RenderTemplate(c, "game.html", gin.H{
-		"title":    "game room",
-		"roomCode": roomCode,
-		"userID":   userID,
-		"gameType": roomDetails.GameType,
-	})
-
 }
 
 func ListAllRoomsHanlder(c *gin.Context) {
@@ -340,63 +487,538 @@ func GetQuestionHandler(c *gin.Context) {
 	RenderTemplate(c, "game.html", gin.H{})
 }
 
-func CreateQuestionPageHanlder(c *gin.Context) {
-	RenderTemplate(c, "home.html", gin.H{
-		"title": "About Page",
-	})
+// question bank
+
+func CreateQuestionPageHanlder(quizService *quiz.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+
+		search := strings.TrimSpace(c.Query("search"))
+		tag := strings.TrimSpace(c.Query("tag"))
+		questions, err := quizService.ListBankQuestions(ctx, quizmodel.BankQuestionFilter{
+			UserID: userID,
+			Search: search,
+			Tag:    tag,
+		})
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to get your questions", err)
+			return
+		}
+
+		// the question which is being edited is filled in the form
+		var editQuestion *quizmodel.BankQuestion
+		if editID := c.Query("edit"); editID != "" {
+			questionID, err := uuid.Parse(editID)
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "Not a valid question", nil)
+				return
+			}
+			editQuestion, err = quizService.GetBankQuestion(ctx, userID, questionID)
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "Failed to get the question", err)
+				return
+			}
+		}
+
+		RenderTemplate(c, "question_bank.html", gin.H{
+			"title":        "Question bank",
+			"questions":    questions,
+			"search":       search,
+			"tag":          tag,
+			"editQuestion": editQuestion,
+			"optionSlots":  optionSlots(editQuestion),
+		})
+	}
 }
 
-func CreateQuestionsHandler(c *gin.Context) {
-	RenderTemplate(c, "home.html", gin.H{
-		"title": "About Page",
-	})
+// optionSlots are the option fields of the bank form, filled with the options of the question being edited
+func optionSlots(question *quizmodel.BankQuestion) []quizmodel.Options {
+	slots := []quizmodel.Options{}
+	for id := 1; id <= 6; id++ {
+		slot := quizmodel.Options{ID: id}
+		if question != nil && id <= len(question.Options) {
+			slot.Option = question.Options[id-1].Option
+		}
+		slots = append(slots, slot)
+	}
+	return slots
 }
 
-func AnalyticsHandler(c *gin.Context) {
-	ctx := c.Request.Context()
-	roomCode := c.Param("code")
-	roomCode = strings.TrimSpace(roomCode)
-	_, err := uuid.Parse(roomCode) // checking if the room code is a valid uuid
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+// formInts reads a comma separated list of numbers
+func formInts(value string) ([]int, error) {
+	numbers := []int{}
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// formFloat reads an optional number, an empty field is 0
+func formFloat(value string) (float64, error) {
+	if value = strings.TrimSpace(value); value == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// CreateQuestionsHandler creates a question in the bank, or edits it when the form has the question id
+func CreateQuestionsHandler(quizService *quiz.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		c.Request.ParseForm()
+
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+
+		// the types which are not single choice dont use the answer field
+		answer := 0
+		var err error
+		if a := strings.TrimSpace(c.PostForm("answer")); a != "" {
+			answer, err = strconv.Atoi(a)
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "answer is in wrong format", err)
+				return
+			}
+		}
+		answers, err := formInts(c.PostForm("answers"))
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "right options are in wrong format", err)
+			return
+		}
+		numericAnswer, err := formFloat(c.PostForm("numericAnswer"))
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "number is in wrong format", err)
+			return
+		}
+		tolerance, err := formFloat(c.PostForm("tolerance"))
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "tolerance is in wrong format", err)
+			return
+		}
+		// the empty option fields are left out, numeric and free text questions have no options
+		options := []quizmodel.Options{}
+		for _, option := range c.PostFormArray("options") {
+			if strings.TrimSpace(option) != "" {
+				options = append(options, quizmodel.Options{
+					ID:     len(options) + 1,
+					Option: strings.TrimSpace(option),
+				})
+			}
+		}
+		tags := []string{}
+		for _, tag := range strings.Split(c.PostForm("tags"), ",") {
+			if strings.TrimSpace(tag) != "" {
+				tags = append(tags, tag)
+			}
+		}
+
+		// the question can have its own time limit, an empty field keeps the one of the room
+		timeLimit := 0
+		if tl := strings.TrimSpace(c.PostForm("timelimit")); tl != "" {
+			timeLimit, err = strconv.Atoi(tl)
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "time limit is in wrong format", err)
+				return
+			}
+		}
+
+		questionReq := &quizmodel.BankQuestionReq{
+			UserID:          userID,
+			Question:        strings.TrimSpace(c.PostForm("question")),
+			Type:            quizmodel.QuestionType(c.PostForm("type")),
+			Options:         options,
+			Answer:          answer,
+			Answers:         answers,
+			NumericAnswer:   numericAnswer,
+			Tolerance:       tolerance,
+			AcceptedAnswers: strings.Split(c.PostForm("acceptedAnswers"), ","),
+			Difficulty:      quizmodel.Difficulty(c.PostForm("difficulty")),
+			Tags:            tags,
+			TimeLimit:       timeLimit,
+		}
+		questionID := c.PostForm("id")
+		if questionID != "" {
+			questionReq.ID, err = uuid.Parse(questionID)
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "Not a valid question", nil)
+				return
+			}
+		}
+
+		validate := validator.New(validator.WithRequiredStructEnabled())
+		err = validate.Struct(questionReq)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "invalid user input", err)
+			return
+		}
+
+		if questionReq.ID == uuid.Nil {
+			_, err = quizService.CreateBankQuestion(ctx, questionReq)
+		} else {
+			_, err = quizService.UpdateBankQuestion(ctx, questionReq)
+		}
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to save the question", err)
+			return
+		}
 
+		c.Redirect(http.StatusFound, "/bw/quest")
 	}
-	if roomCode != "" && len(roomCode) > 50 {
-		RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+}
+
+func DeleteQuestionHandler(quizService *quiz.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+
+		questionID, err := uuid.Parse(c.Param("id"))
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Not a valid question", nil)
+			return
+		}
+		err = quizService.DeleteBankQuestion(ctx, userID, questionID)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to delete the question", err)
+			return
+		}
+
+		c.Redirect(http.StatusFound, "/bw/quest")
 	}
-	userInfo := util.GetUserInfoFromctx(ctx)
-	userID := userInfo.ID
+}
 
-	meta, answers, err := room.ListGameAnalytics(ctx, roommodel.RoomCodeReq{
-		UserID:   userID,
-		RoomCode: roomCode,
-	})
-	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Failed to get analytics", err)
-		return
+// CreateRoomFromBankHandler creates a room with the questions selected from the bank
+func CreateRoomFromBankHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		c.Request.ParseForm()
+
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+
+		questionIDs := []uuid.UUID{}
+		for _, id := range c.PostFormArray("questions") {
+			questionID, err := uuid.Parse(id)
+			if err != nil {
+				RenderErrorTemplate(c, "home.html", "Not a valid question", nil)
+				return
+			}
+			questionIDs = append(questionIDs, questionID)
+		}
+		if len(questionIDs) == 0 {
+			RenderErrorTemplate(c, "home.html", "select atleast one question for the room", nil)
+			return
+		}
+
+		topic := strings.TrimSpace(c.PostForm("topic"))
+		if topic == "" {
+			topic = "question bank"
+		}
+		if len(topic) > 50 {
+			RenderErrorTemplate(c, "home.html", "length of the topic shouldnt be more than 50 characters", nil)
+			return
+		}
+		tl, err := strconv.Atoi(c.PostForm("timelimit"))
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "time limit is a required field", nil)
+			return
+		}
+
+		gt := gameTypeFromForm(c.PostForm("game-type"))
+		roomreq := roommodel.RoomReq{
+			UserID:      userID,
+			Username:    userInfo.UserName,
+			UserMeta:    "[{}]",
+			RoomName:    c.PostForm("roomName"),
+			GameType:    gt,
+			TimeLimit:   tl,
+			HostMode:    c.PostForm("host") == "on", // he wrote the questions so he can watch instead of playing
+			ScoringRule: c.PostForm("scoring"),
+			Teams:       teamCount(c),
+			IsPublic:    c.PostForm("public") == "on",
+		}
+		validate := validator.New(validator.WithRequiredStructEnabled())
+		err = validate.Struct(roomreq)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "invalid user input", err)
+			return
+		}
+		botIDs := []roommodel.UserIDReq{}
+		for _, botsInput := range c.PostFormArray("bots") {
+			botIDs = append(botIDs, roommodel.UserIDReq{UserID: usermodel.BotMap[botsInput]})
+		}
+
+		roomCode, err := roomService.SetupGameFromBank(ctx, roomreq, botIDs, topic, questionIDs)
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to Setup game", err)
+			return
+		}
+		if roomreq.GameType == model.SP {
+			c.Redirect(302, fmt.Sprintf("/bw/ingame/%s", roomCode))
+			return
+		}
+		RenderSuccessTemplate(c, "home.html", "Successfully room created. Go to My Quiz for your room code")
 	}
-	RenderTemplate(c, "analysis.html", gin.H{
-		"title":    "Analytics",
-		"roomCode": roomCode,
-		"meta":     meta,
-		"answers":  answers,
-	})
 }
 
-func MyQuizHistoryHandler(c *gin.Context) {
-	ctx := c.Request.Context()
-	userInfo := util.GetUserInfoFromctx(ctx)
-	userID := userInfo.ID
+// gameTypeFromForm maps the game type of the create room form, 2 is multiplayer and 3 elimination
+func gameTypeFromForm(gameType string) model.GT {
+	switch gameType {
+	case "2":
+		return model.MP
+	case "3":
+		return model.EL
+	default:
+		return model.SP
+	}
+}
 
-	roomDetails, err := room.ListRoom(ctx, roommodel.UserIDReq{
-		UserID: userID,
-	})
+// teamCount reads the number of teams of the create room form, anything else than a number is free for all
+func teamCount(c *gin.Context) int {
+	teams, err := strconv.Atoi(c.PostForm("teams"))
 	if err != nil {
-		RenderErrorTemplate(c, "home.html", "Failed to get analytics", err)
+		return 0
+	}
+	return teams
+}
+
+func AnalyticsHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		roomCode := c.Param("code")
+		roomCode = strings.TrimSpace(roomCode)
+		_, err := uuid.Parse(roomCode) // checking if the room code is a valid uuid
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+
+		}
+		if roomCode != "" && len(roomCode) > 50 {
+			RenderErrorTemplate(c, "home.html", "Not a valid room code", nil)
+		}
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+
+		meta, answers, err := roomService.ListGameAnalytics(ctx, roommodel.RoomCodeReq{
+			UserID:   userID,
+			RoomCode: roomCode,
+		})
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to get analytics", err)
+			return
+		}
+		RenderTemplate(c, "analysis.html", gin.H{
+			"title":    "Analytics",
+			"roomCode": roomCode,
+			"meta":     meta,
+			"answers":  answers,
+		})
+	}
+}
+
+func MyQuizHistoryHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+
+		roomDetails, err := roomService.ListRoom(ctx, roommodel.UserIDReq{
+			UserID: userID,
+		})
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to get analytics", err)
+			return
+		}
+		bestStreak, err := roomService.GetBestStreak(ctx, roommodel.UserIDReq{
+			UserID: userID,
+		})
+		if err != nil {
+			RenderErrorTemplate(c, "home.html", "Failed to get analytics", err)
+			return
+		}
+		RenderTemplate(c, "my_quiz.html", gin.H{
+			"title":       "My quiz history",
+			"roomDetails": roomDetails,
+			"bestStreak":  bestStreak,
+		})
+	}
+}
+
+// JoinMatchHandler puts the user in the matchmaking queue of the category and difficulty he picked
+func JoinMatchHandler(matchmaker *room.Matchmaker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userInfo := util.GetUserInfoFromctx(ctx)
+		req := roommodel.MatchReq{
+			UserID:     userInfo.ID,
+			Username:   userInfo.UserName,
+			Category:   c.PostForm("category"),
+			Difficulty: c.PostForm("difficulty"),
+		}
+		validate := validator.New(validator.WithRequiredStructEnabled())
+		err := validate.Struct(req)
+		if err != nil {
+			RenderSubTemplate(c, "matchmaking.html", gin.H{"error": "pick a category and a difficulty"})
+			return
+		}
+		status, err := matchmaker.Join(ctx, req)
+		if err != nil {
+			RenderSubTemplate(c, "matchmaking.html", gin.H{"error": err.Error()})
+			return
+		}
+		RenderSubTemplate(c, "matchmaking.html", gin.H{"status": status})
+	}
+}
+
+// MatchStatusHandler is polled by the user while he waits in the queue, he is sent to his room once it is ready
+func MatchStatusHandler(matchmaker *room.Matchmaker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userInfo := util.GetUserInfoFromctx(ctx)
+		status, err := matchmaker.Status(ctx, userInfo.ID)
+		if err != nil {
+			RenderSubTemplate(c, "matchmaking.html", gin.H{"error": "could not get your place in the queue"})
+			return
+		}
+		if status.RoomCode != "" {
+			c.Header("HX-Redirect", "/bw/ingame/"+status.RoomCode)
+			c.Status(http.StatusOK)
+			return
+		}
+		if status.Failed {
+			RenderSubTemplate(c, "matchmaking.html", gin.H{"error": "could not set up your room, please queue again"})
+			return
+		}
+		RenderSubTemplate(c, "matchmaking.html", gin.H{"status": status})
+	}
+}
+
+// LeaveMatchHandler takes the user out of the matchmaking queue
+func LeaveMatchHandler(matchmaker *room.Matchmaker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userInfo := util.GetUserInfoFromctx(ctx)
+		err := matchmaker.Leave(ctx, userInfo.ID)
+		if err != nil {
+			RenderSubTemplate(c, "matchmaking.html", gin.H{"error": "could not take you out of the queue"})
+			return
+		}
+		RenderSubTemplate(c, "matchmaking.html", gin.H{"status": roommodel.MatchStatus{}})
+	}
+}
+
+// isLocalPath tells if the path stays on this site, so a return_to cannot send the user somewhere else.
+// browsers drop tabs and new lines from a url, so "/\t/host" would turn into "//host"
+func isLocalPath(path string) bool {
+	if strings.IndexFunc(path, unicode.IsControl) >= 0 {
+		return false
+	}
+	return strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "//") && !strings.HasPrefix(path, "/\\")
+}
+
+// inviteLink is the link of the invite code that can be shared
+func inviteLink(c *gin.Context, inviteCode string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/join/" + inviteCode
+}
+
+// InviteLinkHandler opens a shared invite link, a user who is not logged in goes through login and comes back to it
+func InviteLinkHandler(c *gin.Context) {
+	inviteCode := url.PathEscape(room.NormalizeInviteCode(c.Param("code")))
+	session := sessions.Default(c)
+	if session.Get("profile") == nil {
+		session.Set("return_to", "/join/"+inviteCode)
+		if err := session.Save(); err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.Redirect(http.StatusSeeOther, "/login")
 		return
 	}
-	RenderTemplate(c, "my_quiz.html", gin.H{
-		"title":       "My quiz history",
-		"roomDetails": roomDetails,
-	})
+	c.Redirect(http.StatusSeeOther, "/bw/jroom/"+inviteCode)
+}
+
+// RoomInviteHandler shows the invite code of the room to its members, the owner gets a new one when the room has none
+func RoomInviteHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+		roomCode := strings.TrimSpace(c.Param("code"))
+
+		roomDetails, err := roomService.GetRoomByRoomCode(ctx, roomCode)
+		if err != nil || roomDetails == nil {
+			RenderSubTemplate(c, "invite.html", gin.H{"error": "there is no room"})
+			return
+		}
+		isOwner := roomDetails.CreatedBy == userID.String()
+		var invite *roommodel.RoomInvite
+		if isOwner {
+			invite, err = roomService.GetOrCreateInvite(ctx, roomCode, userID.String())
+		} else {
+			roomMember, memberErr := roomService.GetRoomMemberByRoomCodeAndUserID(ctx, roommodel.RoomMemberReq{
+				UserID:   userID,
+				RoomCode: roomCode,
+			})
+			if memberErr != nil || roomMember == nil {
+				RenderSubTemplate(c, "invite.html", gin.H{})
+				return
+			}
+			invite, err = roomService.GetActiveInvite(ctx, roomCode)
+		}
+		if err != nil {
+			RenderSubTemplate(c, "invite.html", gin.H{"error": "could not get the invite code"})
+			return
+		}
+		renderInvite(c, roomCode, invite, isOwner)
+	}
+}
+
+// RevokeInviteHandler stops the invite codes of the room from working and hands the owner a new one
+func RevokeInviteHandler(roomService *room.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		userInfo := util.GetUserInfoFromctx(ctx)
+		userID := userInfo.ID
+		roomCode := strings.TrimSpace(c.Param("code"))
+
+		roomDetails, err := roomService.GetRoomByRoomCode(ctx, roomCode)
+		if err != nil || roomDetails == nil {
+			RenderSubTemplate(c, "invite.html", gin.H{"error": "there is no room"})
+			return
+		}
+		if roomDetails.CreatedBy != userID.String() {
+			RenderSubTemplate(c, "invite.html", gin.H{"error": "only the owner of the room can revoke the invite"})
+			return
+		}
+		invite, err := roomService.RevokeInvites(ctx, roomCode, userID.String())
+		if err != nil {
+			RenderSubTemplate(c, "invite.html", gin.H{"error": "could not revoke the invite code"})
+			return
+		}
+		renderInvite(c, roomCode, invite, true)
+	}
+}
+
+func renderInvite(c *gin.Context, roomCode string, invite *roommodel.RoomInvite, isOwner bool) {
+	data := gin.H{
+		"roomCode": roomCode,
+		"isOwner":  isOwner,
+	}
+	if invite != nil {
+		data["invite"] = invite
+		data["link"] = inviteLink(c, invite.InviteCode)
+	}
+	RenderSubTemplate(c, "invite.html", data)
 }
@@ -0,0 +1,30 @@
+package handlers
+
+import "testing"
+
+func TestIsLocalPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want bool
+	}{
+		{"invite link", "/bw/invite/ABC234", true},
+		{"home", "/", true},
+		{"with a query", "/bw/ingame/room?tab=chat", true},
+		{"empty", "", false},
+		{"relative path", "bw/home", false},
+		{"another site", "https://evil.example", false},
+		{"another site without the scheme", "//evil.example", false},
+		{"backslash read as a slash", "/\\evil.example", false},
+		{"tab dropped by the browser", "/\t/evil.example", false},
+		{"new line dropped by the browser", "/\n/evil.example", false},
+		{"javascript", "javascript:alert(1)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isLocalPath(tt.path); got != tt.want {
+				t.Errorf("isLocalPath(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
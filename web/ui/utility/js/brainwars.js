@@ -22,6 +22,13 @@ function handleQuizStart(event, mode) {
         createG.classList.add('hidden');
         startG.classList.remove('hidden');
       }
+    } else if (mode === 'elimination') {
+      gameTypeSelect.value = '3';
+      if (titleHeading) {
+        titleHeading.textContent = 'Set up your Elimination Quiz';
+        startG.classList.add('hidden');
+        createG.classList.remove('hidden');
+      }
     } else {
       gameTypeSelect.value = '2';
       //  roomNameInput.style.display = 'block';
@@ -15,4 +15,17 @@ window.onload = function () {
     }
     window.location.href = "/bw/jroom/" + encodeURIComponent(roomCode);
   });
+
+  // watch the room without playing, works even after the game has started
+  const spectateBtn = document.getElementById("spectate-game-room");
+  if (spectateBtn) {
+    spectateBtn.addEventListener("click", function () {
+      const roomCode = document.getElementById("roomCode").value.trim();
+      if (!roomCode) {
+        alert("Please enter a valid room code.");
+        return;
+      }
+      window.location.href = "/bw/spectate/" + encodeURIComponent(roomCode);
+    });
+  }
 };
\ No newline at end of file
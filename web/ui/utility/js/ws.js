@@ -2,6 +2,29 @@ window.onload = function () {
   if (window["WebSocket"]) {
     let roomcode = document.getElementById("ws-container").dataset.roomcode;
     let gameType = document.getElementById("ws-container").dataset.gametype;
+    // multiplayer and elimination rooms gather in a lobby before the game starts
+    const hasLobby = gameType !== "SINGLE_PLAYER";
+    // the host watches the game, he cannot answer
+    let isHost = document.getElementById("ws-container").dataset.role === "HOST";
+    // a spectator only watches, nothing he sends is accepted
+    let isSpectator = document.getElementById("ws-container").dataset.role === "SPECTATOR";
+    // the owner of the room gets the host controls
+    let isOwner = document.getElementById("ws-container").dataset.owner === "true";
+    let myUserID = document.getElementById("ws-container").dataset.userid;
+    // number of teams of a room in team mode, 0 when everyone plays for himself
+    let teamCount = parseInt(document.getElementById("ws-container").dataset.teams) || 0;
+    // nobody new can join a locked room, the owner can toggle it from the lobby
+    let roomLocked = document.getElementById("ws-container").dataset.locked === "true";
+    const teamNames = ["Red", "Blue", "Green", "Yellow"];
+    const teamColors = ["bg-red-100 text-red-700", "bg-blue-100 text-blue-700", "bg-green-100 text-green-700", "bg-yellow-100 text-yellow-700"];
+    let lobbyPlayerIDs = {};
+    let lobbyPlayerTeams = {};
+    // one countdown for the running question, the host can pause it
+    let questionTimeLeft = 0;
+    let timerPaused = false;
+    let timerInterval = null;
+    let frozenSeconds = 0; // what our time freeze added to the current question
+    let questionClosed = false; // the answer of the question on the screen is revealed
     let lobbyPlayers = {};
     let playerListEl = document.getElementById("player-list");
     let readyGameBtn = document.getElementById("ready-game-btn");
@@ -14,10 +37,34 @@ window.onload = function () {
     let chatErrorEl = document.getElementById("chat-error");
     console.log("WebSocket is supported");
     let protocol = window.location.protocol === "https:" ? "wss://" : "ws://";
-    let conn = new WebSocket(protocol + window.location.host + "/bw/ws?roomCode=" + encodeURIComponent(roomcode));
+    // the server hands out a resume token on join, with it a dropped connection can take back its seat in the game
+    const resumeKey = "bw-resume-" + roomcode;
+    let resumeGraceMs = 0;
+    let resumeDeadline = 0; // till when the server keeps our seat after the connection dropped
+    let gameOver = false;
+    let conn;
+    connect();
 
-    conn.onopen = function (e) {
+    function connect() {
+      let url = protocol + window.location.host + "/bw/ws?roomCode=" + encodeURIComponent(roomcode);
+      if (isSpectator) {
+        url += "&spectate=true";
+      }
+      const resumeToken = sessionStorage.getItem(resumeKey);
+      if (resumeToken) {
+        url += "&resumeToken=" + encodeURIComponent(resumeToken);
+      }
+      conn = new WebSocket(url);
+      conn.onopen = onOpen;
+      conn.onmessage = onMessage;
+      conn.onclose = onClose;
+    }
+
+    function onOpen(e) {
       console.log("Connection established!");
+      if (resumeDeadline || isSpectator) {
+        return; // rejoining or just watching, no need to greet everyone
+      }
       // This initial message might be displayed by the server as a system message in chat
       var payload = { data: "Welcome All! The game is about to begin.", time: new Date().toISOString() }
       // The server should decide if this "send_message" type is broadcasted as a chat message
@@ -25,41 +72,67 @@ window.onload = function () {
       // For this example, we'll assume the server might convert this to a chat message.
       let data = JSON.stringify({ type: "send_message", payload: payload });
       conn.send(data);
-    };
+    }
 
-    conn.onmessage = function (e) {
+    function onMessage(e) {
       const data = JSON.parse(e.data);
 
-      if (data.type === "lobby_state" && gameType === "MULTI_PLAYER") {
+      if (data.type === "resume_token") {
+        sessionStorage.setItem(resumeKey, data.payload.token);
+        resumeGraceMs = data.payload.graceSecond * 1000;
+        resumeDeadline = 0;
+      } else if (data.type === "lobby_state" && hasLobby) {
         lobbyPlayers = {}; // reset
         data.payload.forEach(player => {
           lobbyPlayers[player.username] = player.data;
+          lobbyPlayerIDs[player.username] = player.userId;
+          lobbyPlayerTeams[player.username] = player.team || 0;
         });
         renderLobbyPlayers();
-      } else if (data.type === "joined_game" && gameType === "MULTI_PLAYER") {
+      } else if (data.type === "joined_game" && hasLobby) {
         const username = data.payload.username;
         lobbyPlayers[username] = "joined";
         renderLobbyPlayers();
-      } else if (data.type === "ready_game" && gameType === "MULTI_PLAYER") {
+      } else if (data.type === "ready_game" && hasLobby) {
         const username = data.payload.username;
         lobbyPlayers[username] = "ready";
         renderLobbyPlayers();
       } else if (data.type === "start_game") {
-        if (gameType === "MULTI_PLAYER") {
+        if (hasLobby) {
           const lobbyContainer = document.getElementById("lobby-container");
           if (lobbyContainer) {
             lobbyContainer.classList.add("hidden");
           }
         }
+      } else if (data.type === "generation_job") {
+        renderGenerationJob(data.payload);
       } else if (data.type === "new_question") {
         renderQuestion(data.payload);
       } else if (data.type === "end_game") {
+        gameOver = true;
+        sessionStorage.removeItem(resumeKey);
         renderEndGame(data.payload);
+      } else if (data.type === "host_action") {
+        renderHostAction(data.payload);
+      } else if (data.type === "streak") {
+        renderStreak(data.payload);
+      } else if (data.type === "powerup_used") {
+        renderPowerUpUsed(data.payload);
+      } else if (data.type === "spectator_count") {
+        renderSpectatorCount(data.payload.count);
+      } else if (data.type === "elimination") {
+        renderElimination(data.payload);
+      } else if (data.type === "question_reveal") {
+        renderQuestionReveal(data.payload);
+      } else if (data.type === "answer_distribution") {
+        renderAnswerDistribution(data.payload);
       } else if (data.type === "leaderboard") {
-        renderLeaderboard(data.payload.scores);
+        renderLeaderboard(data.payload.scores, data.payload.teams);
       } else if (data.type === "game_error") {
         renderGameError(data.payload.errorMessage);
       } else if (data.type === "leave_room") {
+        gameOver = true;
+        sessionStorage.removeItem(resumeKey);
         conn.close();
         window.location.href = "/bw/home/";
         return;
@@ -73,10 +146,21 @@ window.onload = function () {
              renderChatMessage({ username: "System", message: data.payload.data });
         }
       }
-    };
+    }
 
-    conn.onclose = function () {
+    function onClose() {
       console.log("Connection closed!");
+      if (!gameOver && sessionStorage.getItem(resumeKey)) {
+        if (!resumeDeadline) {
+          resumeDeadline = Date.now() + resumeGraceMs;
+        }
+        if (Date.now() < resumeDeadline) {
+          renderGameError("Connection to server lost. Trying to reconnect...");
+          setTimeout(connect, 2000);
+          return;
+        }
+        sessionStorage.removeItem(resumeKey);
+      }
       // Avoid redirect if modal is handling it or if it's an unexpected close.
       // For now, keeping the original behavior.
       // Consider showing a message like "Connection lost. Redirecting..."
@@ -85,7 +169,7 @@ window.onload = function () {
         window.location.href = "/bw/home/";
       }, 3000);
       return;
-    };
+    }
 
     // Debounce helpers
     function debounceClick(callback, delay = 500) {
@@ -99,21 +183,39 @@ window.onload = function () {
       };
     }
 
-    if (gameType === "MULTI_PLAYER" && readyGameBtn) {
+    if (hasLobby && readyGameBtn && !isHost && !isSpectator) {
       readyGameBtn.classList.remove("hidden");
       readyGameBtn.onclick = debounceClick(() => {
         conn.send(JSON.stringify({ type: "ready_game" }));
       });
     }
 
-    if (gameType === "MULTI_PLAYER" && startGameBtn) {
+    if (hasLobby && startGameBtn && !isSpectator) {
       startGameBtn.classList.remove("hidden");
       startGameBtn.onclick = debounceClick(() => {
         openModal({ url: '/bw/home/', method: 'ws', body: JSON.stringify({ type: "start_game" }), wsconnection: conn, message: 'Clicking Yes will force start game. Are you sure?' });
       });
     }
 
-    if (gameType === "MULTI_PLAYER" && leaveRoomBtn) {
+    let lockRoomBtn = document.getElementById("lock-room-btn");
+    if (hasLobby && lockRoomBtn && isOwner) {
+      lockRoomBtn.onclick = debounceClick(() => {
+        conn.send(JSON.stringify({ type: "lock_room", payload: { locked: !roomLocked } }));
+      });
+    }
+
+    let balanceTeamsBtn = document.getElementById("balance-teams-btn");
+    if (hasLobby && balanceTeamsBtn && isOwner) {
+      balanceTeamsBtn.onclick = debounceClick(() => {
+        conn.send(JSON.stringify({ type: "balance_teams" }));
+      });
+    }
+
+    if (hasLobby && leaveRoomBtn && isSpectator) {
+      leaveRoomBtn.onclick = () => {
+        window.location.href = "/bw/home/";
+      };
+    } else if (hasLobby && leaveRoomBtn) {
       leaveRoomBtn.classList.remove("hidden");
       leaveRoomBtn.onclick = () => {
         openModal({ url: '/bw/home/', method: 'ws', body: JSON.stringify({ type: "leave_room" }), wsconnection: conn, message: 'Clicking Yes will redirect you to the homepage. Are you sure?' });
@@ -146,16 +248,36 @@ window.onload = function () {
         return;
       }
 
+      const teamOnlyEl = document.getElementById("chat-team-only");
       const chatPayload = {
         type: "chat_message", // Client sends this type to the server
         payload: {
-          message: message
+          message: message,
+          scope: teamOnlyEl && teamOnlyEl.checked ? "team" : ""
         }
       };
       conn.send(JSON.stringify(chatPayload));
       chatInputEl.value = ""; // Clear input field
     }
 
+    // renderElimination tells the room who dropped out, a player who is out only watches from now on
+    function renderElimination(payload) {
+      renderChatMessage({ username: "System", message: payload.message });
+      const me = payload.eliminated.find(e => e.userId === myUserID);
+      if (!me) return;
+      isSpectator = true;
+      const reasons = { wrong: "your answer was wrong", missed: "you did not answer in time", slowest: "you were the slowest" };
+      renderGameError(`You are out of the game since ${reasons[me.reason] || me.reason}. You can keep watching.`);
+    }
+
+    // renderStreak tells the room about a streak which started or broke
+    function renderStreak(payload) {
+      const message = payload.status === "started"
+        ? `🔥 ${payload.username} is on a streak of ${payload.streak}`
+        : `${payload.username}'s streak of ${payload.streak} is broken`;
+      renderChatMessage({ username: "System", message: message });
+    }
+
     function renderChatMessage(payload) {
       if (!chatMessagesEl || !payload || !payload.username || !payload.message) {
         console.error("Invalid payload for renderChatMessage:", payload);
@@ -181,7 +303,7 @@ window.onload = function () {
       // } else {
       usernameSpan.classList.add(username === "System" ? 'text-purple-600' : 'text-primary-600');
       // }
-      usernameSpan.textContent = username;
+      usernameSpan.textContent = payload.teamName ? `[${payload.teamName}] ${username}` : username;
 
       const messageText = document.createElement('span');
       messageText.classList.add('text-sm', 'text-gray-800', 'break-words'); // break-words for long messages
@@ -248,21 +370,145 @@ window.onload = function () {
         playerInfoDiv.appendChild(usernameSpan);
 
         const statusSpan = document.createElement("span");
-        statusSpan.className = `text-sm font-semibold px-2 py-0.5 rounded-full ${status === 'ready' ? 'text-green-700 bg-green-100' : 'text-yellow-700 bg-yellow-100'}`; // Pill-like status
-        statusSpan.textContent = status === 'ready' ? 'Ready' : 'Joined';
+        statusSpan.className = `text-sm font-semibold px-2 py-0.5 rounded-full ${status === 'ready' ? 'text-green-700 bg-green-100' : status === 'hosting' ? 'text-blue-700 bg-blue-100' : 'text-yellow-700 bg-yellow-100'}`; // Pill-like status
+        statusSpan.textContent = status === 'ready' ? 'Ready' : status === 'hosting' ? 'Host' : 'Joined';
 
         li.appendChild(playerInfoDiv);
+        const teamPicker = renderTeamPicker(username);
+        if (teamPicker) {
+          li.appendChild(teamPicker);
+        }
         li.appendChild(statusSpan);
+        const kickButtons = renderKickButtons(lobbyPlayerIDs[username]);
+        if (kickButtons) {
+          li.appendChild(kickButtons);
+        }
         playerListEl.appendChild(li);
       });
     }
 
+    // renderTeamPicker shows the team of a lobby player, a player can switch his own team and the owner anyone's
+    function renderTeamPicker(username) {
+      if (teamCount <= 0 || lobbyPlayers[username] === "hosting") return null;
+      const team = lobbyPlayerTeams[username] || 0;
+      const userID = lobbyPlayerIDs[username];
+      if (!userID || (userID !== myUserID && !isOwner) || isSpectator) {
+        const badge = document.createElement("span");
+        badge.className = `text-xs font-semibold px-2 py-0.5 rounded-full ${team ? teamColors[team - 1] : 'bg-gray-100 text-gray-500'}`;
+        badge.textContent = team ? teamNames[team - 1] : "No team";
+        return badge;
+      }
+      const select = document.createElement("select");
+      select.className = "text-xs border rounded px-1 py-0.5";
+      for (let t = 1; t <= teamCount; t++) {
+        const option = document.createElement("option");
+        option.value = t;
+        option.textContent = teamNames[t - 1];
+        option.selected = t === team;
+        select.appendChild(option);
+      }
+      select.onchange = () => {
+        conn.send(JSON.stringify({ type: "join_team", payload: { team: parseInt(select.value), userId: userID } }));
+      };
+      return select;
+    }
+
+    function renderGenerationJob(payload) {
+      const jobStatusEl = document.getElementById("generation-status");
+      if (jobStatusEl) {
+        jobStatusEl.textContent = payload.message;
+      }
+      if (payload.status === "FAILED") {
+        gameOver = true;
+        sessionStorage.removeItem(resumeKey);
+        renderGameError(payload.message);
+        setTimeout(() => {
+          window.location.href = "/bw/home/";
+        }, 3000);
+      }
+    }
+
+    // runQuestionTimer counts down the time left of the current question
+    function runQuestionTimer() {
+      const timerDisplay = document.getElementById("timer-display");
+      clearInterval(timerInterval);
+      timerInterval = setInterval(() => {
+        if (timerPaused) return;
+        if (questionTimeLeft > 0) {
+          questionTimeLeft--;
+          if (timerDisplay) {
+            timerDisplay.textContent = questionTimeLeft;
+          }
+        } else {
+          clearInterval(timerInterval);
+        }
+      }, 1000);
+    }
+
+    const powerUpLabels = {
+      fifty_fifty: "50/50",
+      double_points: "Double points",
+      time_freeze: "Time freeze",
+    };
+
+    // renderPowerUps shows the power-ups we have left, only the players get them with the question
+    function renderPowerUps(powerUps, doublePoints) {
+      if (!powerUps || isHost || isSpectator) return '';
+      let html = `<div class="mt-4 flex gap-2 flex-wrap items-center" id="powerups">`;
+      Object.keys(powerUpLabels).forEach(powerUp => {
+        const left = powerUps[powerUp] || 0;
+        html += `
+          <button class="powerup-btn bg-purple-500 hover:bg-purple-600 text-white text-sm py-1 px-3 rounded-md disabled:opacity-50"
+            data-powerup="${powerUp}" ${left > 0 ? '' : 'disabled'}>
+            ${powerUpLabels[powerUp]} <span class="powerup-count">${left}</span>
+          </button>`;
+      });
+      html += `<span class="text-sm font-semibold text-purple-600 ${doublePoints ? '' : 'hidden'}" id="double-points-badge">x2 points on this question</span>`;
+      return html + `</div>`;
+    }
+
+    // renderPowerUpUsed applies what our power-up did
+    function renderPowerUpUsed(payload) {
+      document.querySelectorAll(".powerup-btn").forEach(btn => {
+        const left = (payload.inventory || {})[btn.dataset.powerup] || 0;
+        btn.querySelector(".powerup-count").textContent = left;
+        btn.disabled = left <= 0;
+      });
+      (payload.hiddenOptions || []).forEach(optionID => {
+        const option = document.querySelector(`.option-item[data-optionid="${optionID}"]`);
+        if (option) option.classList.add("hidden");
+      });
+      if (payload.powerUp === "time_freeze") {
+        frozenSeconds = payload.extraSeconds || 0;
+        questionTimeLeft = payload.timeLeft;
+        const timerDisplay = document.getElementById("timer-display");
+        if (timerDisplay) timerDisplay.textContent = questionTimeLeft;
+        runQuestionTimer();
+      }
+      if (payload.powerUp === "double_points") {
+        const questionBlock = document.getElementById("question-block");
+        const currentIndex = questionBlock ? parseInt(questionBlock.dataset.questionindex) : 0;
+        if (payload.doubleOn === currentIndex) {
+          document.getElementById("double-points-badge")?.classList.remove("hidden");
+        } else {
+          renderChatMessage({ username: "System", message: "Your double points apply to the next question" });
+        }
+      }
+    }
+
     function renderQuestion(payload) {
       let loadingClass = document.getElementById("game-loading")
       loadingClass.classList.add("hidden")
       const questionBlock = document.getElementById("question-block");
       if (!questionBlock) return;
       const { questionIndex, totalQuestions, qs, timeLimit } = payload;
+      // a rejoining player gets the question with the time that is left
+      questionTimeLeft = payload.timeLeft ?? timeLimit;
+      timerPaused = payload.paused === true;
+      frozenSeconds = 0;
+      questionClosed = false;
+      const revealEl = document.getElementById("answer-reveal");
+      if (revealEl) revealEl.classList.add("hidden");
       console.log(qs)
       // const { ID: id, Question: question, Options:options } = qs;
       const ID =qs.id;
@@ -273,6 +519,10 @@ window.onload = function () {
       console.log(Options);
 
       questionBlock.dataset.questionid = ID;
+      questionBlock.dataset.questionindex = questionIndex;
+      // questions without a type are single choice, they and true/false are answered with a click
+      const questionType = qs.type || 'single_choice';
+      const clickToAnswer = questionType === 'single_choice' || questionType === 'true_false';
 
       // Calculate completion percent
       const percentComplete = Math.round((questionIndex / totalQuestions) * 100);
@@ -311,16 +561,20 @@ window.onload = function () {
               <div class="ml-11 space-y-3" id="options-container">
       `;
 
-      Options.forEach((opt, index) => {
-        const letter = ['A', 'B', 'C', 'D'][index] || '';
+      (Options || []).forEach((opt, index) => {
+        const letter = ['A', 'B', 'C', 'D', 'E', 'F'][index] || '';
+        const moveButtons = questionType === 'ordering' && !isHost && !isSpectator ? `
+              <button type="button" class="ml-auto px-2 text-gray-500 hover:text-primary-600 move-option" data-move="-1">▲</button>
+              <button type="button" class="px-2 text-gray-500 hover:text-primary-600 move-option" data-move="1">▼</button>` : '';
         html += `
-          <div class="border rounded-md p-3 cursor-pointer transition-colors option-item"
+          <div class="border rounded-md p-3 ${isHost || questionType === 'ordering' ? '' : 'cursor-pointer'} transition-colors option-item"
               data-optionid="${opt.id}">
               <div class="flex items-center">
               <div class="w-6 h-6 rounded-md border border-gray-300 flex items-center justify-center mr-3 text-xs font-medium option-box">
                   ${letter}
                 </div>
-              <span>${opt.option}</span>
+              <span>${opt.option}</span>${moveButtons}
+              <span class="ml-auto text-sm text-gray-500 answer-count ${isHost ? '' : 'hidden'}" data-optionid="${opt.id}">0</span>
               </div>
           </div>
         `;
@@ -328,6 +582,7 @@ window.onload = function () {
 
       html += `
                 </div>
+                ${renderAnswerInput(questionType)}
               </div>
       
             <div class="bg-white rounded-lg shadow-md p-6 mb-6" id="user-response">
@@ -340,9 +595,10 @@ window.onload = function () {
                     </svg>
                   </div>
                   <div>
-                  <h3 class="text-lg font-semibold text-gray-800">You</h3>
+                  <h3 class="text-lg font-semibold text-gray-800">${isHost ? 'Host' : isSpectator ? 'Spectating' : 'You'}</h3>
+                  <p class="text-sm text-gray-500 ${isHost ? '' : 'hidden'}" id="answered-count"></p>
                     <div class="mt-2 flex items-center">
-                    <div class="text-2xl font-bold text-primary-600" id="timer-display">${timeLimit * 60}</div>
+                    <div class="text-2xl font-bold text-primary-600" id="timer-display">${questionTimeLeft}</div>
                     <svg class="animate-spin ml-2 h-5 w-5 text-primary-500"
                         xmlns="http://www.w3.org/2000/svg" fill="none" viewBox="0 0 24 24">
                         <circle class="opacity-25" cx="12" cy="12" r="10" stroke="currentColor" stroke-width="4"></circle>
@@ -351,11 +607,17 @@ window.onload = function () {
                         </path>
                       </svg>
                     </div>
-                    <div class="mt-4">
+                    <div class="mt-4 ${isSpectator ? 'hidden' : ''}">
                     <button class="bg-primary-500 hover:bg-primary-600 text-white py-2 px-4 rounded-md transition-colors" id="next-question-btn">
                         Next Question
                       </button>
                     </div>
+                    ${renderPowerUps(payload.powerUps, payload.doublePoints)}
+                    <div class="mt-4 flex gap-2 ${isOwner ? '' : 'hidden'}" id="host-controls">
+                      <button class="bg-yellow-500 hover:bg-yellow-600 text-white py-2 px-4 rounded-md" id="pause-game-btn">${timerPaused ? 'Resume' : 'Pause'}</button>
+                      <button class="bg-gray-500 hover:bg-gray-600 text-white py-2 px-4 rounded-md" id="skip-question-btn">Skip Question</button>
+                      <button class="bg-red-500 hover:bg-red-600 text-white py-2 px-4 rounded-md" id="end-game-early-btn">End for everyone</button>
+                    </div>
                   </div>
                 </div>
               </div>
@@ -364,22 +626,82 @@ window.onload = function () {
         </div>`;
       questionBlock.innerHTML = html;
 
-      let timeLeft = timeLimit * 60;
-      const timerDisplay = document.getElementById("timer-display");
-      const timerInterval = setInterval(() => {
-        if (timeLeft > 0) {
-          timeLeft--;
-          if (timerDisplay) {
-            timerDisplay.textContent = timeLeft;
+      runQuestionTimer();
+
+      document.querySelectorAll(".powerup-btn").forEach(btn => {
+        btn.addEventListener("click", debounceClick(() => {
+          conn.send(JSON.stringify({ type: "use_powerup", payload: { powerUp: btn.dataset.powerup } }));
+        }));
+      });
+
+      if (isOwner) {
+        document.getElementById("pause-game-btn").addEventListener("click", debounceClick(() => {
+          conn.send(JSON.stringify({ type: timerPaused ? "resume_game" : "pause_game" }));
+        }));
+        document.getElementById("skip-question-btn").addEventListener("click", debounceClick(() => {
+          conn.send(JSON.stringify({ type: "skip_question" }));
+        }));
+        document.getElementById("end-game-early-btn").addEventListener("click", () => {
+          openModal({ url: '#', method: 'ws', body: JSON.stringify({ type: "end_game_early" }), wsconnection: conn, message: 'The game ends for everyone with the scores they have now. Are you sure?' });
+        });
+      }
+
+      const sendAnswer = (answerOption, answerData) => {
+        if (isHost || isSpectator || questionClosed) return; // the host and the spectators only watch
+        conn.send(JSON.stringify({
+          type: "submit_answer",
+          payload: { questionDataID: ID, answerOption: answerOption, answerData: answerData }
+        }));
+      };
+
+      // the question types which are not single choice are answered with the submit button
+      document.querySelectorAll(".move-option").forEach(btn => {
+        btn.addEventListener("click", function () {
+          if (questionClosed) return;
+          const item = this.closest(".option-item");
+          const sibling = this.dataset.move === "-1" ? item.previousElementSibling : item.nextElementSibling;
+          if (!sibling) return;
+          if (this.dataset.move === "-1") sibling.before(item); else sibling.after(item);
+        });
+      });
+      const submitBtn = document.getElementById("submit-answer-btn");
+      if (submitBtn) {
+        submitBtn.addEventListener("click", debounceClick(() => {
+          const options = [];
+          if (questionType === 'multi_select') {
+            document.querySelectorAll(".option-item.selected").forEach(opt => options.push(parseInt(opt.dataset.optionid)));
+            if (options.length === 0) return;
+            sendAnswer(0, { options: options });
+          } else if (questionType === 'ordering') {
+            document.querySelectorAll(".option-item").forEach(opt => options.push(parseInt(opt.dataset.optionid)));
+            sendAnswer(0, { options: options });
+          } else if (questionType === 'numeric') {
+            const number = parseFloat(document.getElementById("answer-input").value);
+            if (isNaN(number)) return;
+            sendAnswer(0, { number: number });
+          } else {
+            const text = document.getElementById("answer-input").value.trim();
+            if (text === "") return;
+            sendAnswer(0, { text: text });
           }
-        } else {
-          clearInterval(timerInterval);
-        }
-      }, 1000);
+          submitBtn.textContent = "Change Answer";
+        }));
+      }
 
       // Option click handlers
       document.querySelectorAll(".option-item").forEach(el => {
         el.addEventListener("click", function () {
+          if (isHost || isSpectator || questionClosed) return; // the host and the spectators only watch
+          if (questionType === 'multi_select') {
+            // the picks are sent with the submit button
+            const selected = this.classList.toggle("selected");
+            this.classList.toggle("border-primary-500", selected);
+            this.classList.toggle("bg-primary-50", selected);
+            this.querySelector(".option-box").classList.toggle("bg-primary-500", selected);
+            this.querySelector(".option-box").classList.toggle("text-white", selected);
+            return;
+          }
+          if (!clickToAnswer) return;
           const selectedOptionID = this.dataset.optionid;
 
           // Style updates
@@ -393,14 +715,7 @@ window.onload = function () {
           // Show user response area
           // document.getElementById("user-response").style.display = "block";
 
-          const answerPayload = {
-            type: "submit_answer",
-            payload: {
-              questionDataID: ID,
-              answerOption: parseInt(selectedOptionID),
-            }
-          };
-          conn.send(JSON.stringify(answerPayload));
+          sendAnswer(parseInt(selectedOptionID));
         });
       });
 
@@ -437,13 +752,100 @@ window.onload = function () {
     }
 
 
-    function renderLeaderboard(scoreList) {
+    // renderAnswerInput is the answer box of the question types which are not answered with a click
+    function renderAnswerInput(questionType) {
+      if (isHost || isSpectator || questionType === 'single_choice' || questionType === 'true_false') return '';
+      let input = '';
+      if (questionType === 'numeric') {
+        input = `<input type="number" step="any" id="answer-input" placeholder="Your number" class="flex-1 px-3 py-2 border rounded-md text-sm">`;
+      } else if (questionType === 'free_text') {
+        input = `<input type="text" id="answer-input" maxlength="100" placeholder="Your answer" class="flex-1 px-3 py-2 border rounded-md text-sm">`;
+      } else if (questionType === 'multi_select') {
+        input = `<span class="flex-1 text-sm text-gray-500">Pick every right option</span>`;
+      } else if (questionType === 'ordering') {
+        input = `<span class="flex-1 text-sm text-gray-500">Put the options in the right order</span>`;
+      }
+      return `
+                <div class="ml-11 mt-4 flex items-center gap-2">
+                  ${input}
+                  <button type="button" id="submit-answer-btn" class="bg-primary-500 hover:bg-primary-600 text-white py-2 px-4 rounded-md text-sm">Submit Answer</button>
+                </div>`;
+    }
+
+    function renderSpectatorCount(count) {
+      const countEl = document.getElementById("spectator-count");
+      if (!countEl) return;
+      countEl.textContent = count === 1 ? "1 spectator watching" : `${count} spectators watching`;
+      countEl.classList.toggle("hidden", count === 0);
+    }
+
+    // renderQuestionReveal shows the right answer of the question which just closed, how the room answered
+    // it and what it did to the scores. the next question comes once the countdown is over
+    function renderQuestionReveal(payload) {
+      const revealEl = document.getElementById("answer-reveal");
+      if (revealEl) {
+        let text = `Question ${payload.questionIndex}: the answer was "${payload.answerText}". ${payload.answered} / ${payload.participants} answered.`;
+        if (payload.fastest) {
+          text += ` Fastest right answer: ${payload.fastest.username} (${(payload.fastest.latencyMs / 1000).toFixed(1)}s).`;
+        }
+        const mine = (payload.scores || []).find(score => score.userId === myUserID);
+        if (mine) {
+          const verdict = mine.isCorrect ? 'got it right' : mine.points > 0 ? 'got it partly right' : 'got it wrong';
+          text += mine.answered
+            ? ` You ${verdict}${mine.givenAnswer ? ` with "${mine.givenAnswer}"` : ''}: ${mine.points >= 0 ? '+' : ''}${mine.points} points.`
+            : ' You did not answer.';
+        }
+        revealEl.textContent = text;
+        revealEl.classList.remove("hidden");
+      }
+      // the next question might already be on the screen
+      const questionBlock = document.getElementById("question-block");
+      if (!questionBlock || questionBlock.dataset.questionid !== payload.questionId) return;
+      questionClosed = true;
+      const rightOptions = payload.answers && payload.answers.length > 0 ? payload.answers : [payload.answer];
+      rightOptions.forEach(id => {
+        const rightOption = document.querySelector(`.option-item[data-optionid="${id}"]`);
+        if (rightOption) {
+          rightOption.classList.add("border-green-500", "bg-green-50");
+        }
+      });
+      const submitAnswerBtn = document.getElementById("submit-answer-btn");
+      if (submitAnswerBtn) submitAnswerBtn.disabled = true;
+      document.querySelectorAll(".answer-count").forEach(el => {
+        el.textContent = payload.counts[el.dataset.optionid] ?? 0;
+        el.classList.remove("hidden");
+      });
+      document.querySelectorAll(".powerup-btn").forEach(btn => btn.disabled = true);
+      // the timer counts down to the next question
+      timerPaused = false;
+      frozenSeconds = 0;
+      questionTimeLeft = payload.revealSeconds;
+      const timerDisplay = document.getElementById("timer-display");
+      if (timerDisplay) timerDisplay.textContent = questionTimeLeft;
+      runQuestionTimer();
+    }
+
+    // renderAnswerDistribution shows the host how many picked each option of the current question
+    function renderAnswerDistribution(payload) {
+      const questionBlock = document.getElementById("question-block");
+      if (!isHost || !questionBlock || questionBlock.dataset.questionid !== payload.questionId) return;
+      document.querySelectorAll(".answer-count").forEach(el => {
+        el.textContent = payload.counts[el.dataset.optionid] ?? 0;
+      });
+      const answeredEl = document.getElementById("answered-count");
+      if (answeredEl) {
+        answeredEl.textContent = `${payload.answered} / ${payload.participants} answered`;
+      }
+    }
+
+    function renderLeaderboard(scoreList, teams) {
       const leaderboardList = document.getElementById("leaderboard-list");
       leaderboardList.innerHTML = ""; // Clear previous entries
 
       let tableHTML = `
         <div class="bg-white border-t border-gray-200 p-4">
           <h2 class="text-lg font-semibold mb-3">Live Leaderboard</h2>
+          ${renderTeamTotals(teams)}
           <div class="overflow-x-auto">
             <table class="min-w-full">
               <thead>
@@ -466,9 +868,13 @@ window.onload = function () {
                     <span>${entry.username.slice(0, 2).toUpperCase()}</span>
                   </div>
                   <span>${entry.username}</span>
+                  ${entry.eliminated ? `<span class="ml-2 text-xs font-semibold text-gray-500" title="dropped out on question ${entry.eliminatedRound}">Out (Q${entry.eliminatedRound})</span>` : ''}
+                  ${entry.team ? `<span class="ml-2 text-xs font-semibold px-2 py-0.5 rounded-full ${teamColors[entry.team - 1] || ''}">${teamNames[entry.team - 1] || entry.team}</span>` : ''}
+                  ${entry.streak >= 2 ? `<span class="ml-2 text-xs font-semibold text-orange-600" title="right answers in a row">🔥 ${entry.streak}</span>` : ''}
                 </div>
               </td>
             <td class="px-4 py-2 whitespace-nowrap text-sm text-gray-700">${entry.score}</td>
+            <td class="px-4 py-2 whitespace-nowrap text-sm" data-kick="${entry.isBot || entry.exited ? '' : entry.userId}"></td>
           </tr>
         `;
         });
@@ -481,6 +887,73 @@ window.onload = function () {
       `;
 
       leaderboardList.innerHTML = tableHTML;
+      leaderboardList.querySelectorAll("[data-kick]").forEach(cell => {
+        const kickButtons = renderKickButtons(cell.dataset.kick);
+        if (kickButtons) {
+          cell.appendChild(kickButtons);
+        }
+      });
+    }
+
+    // renderTeamTotals lists the score of every team, nothing when the room is not in team mode
+    function renderTeamTotals(teams) {
+      if (!teams || teams.length === 0) return '';
+      return `
+        <div class="flex flex-wrap gap-2 mb-3">
+          ${teams.map(team => `
+            <span class="text-sm font-semibold px-3 py-1 rounded-full ${teamColors[team.team - 1] || 'bg-gray-100 text-gray-700'}" title="${team.members.join(', ')}">
+              ${team.position}. ${team.name}: ${team.score}
+            </span>
+          `).join('')}
+        </div>
+      `;
+    }
+
+    // renderKickButtons gives the owner the kick and ban buttons of a player
+    function renderKickButtons(userID) {
+      if (!isOwner || !userID || userID === myUserID) return null;
+      const buttons = document.createElement("span");
+      buttons.className = "ml-2 flex gap-2 text-xs";
+      [["Kick", false], ["Ban", true]].forEach(([label, ban]) => {
+        const btn = document.createElement("button");
+        btn.className = "text-red-600 hover:underline";
+        btn.textContent = label;
+        btn.onclick = () => {
+          openModal({ url: '#', method: 'ws', body: JSON.stringify({ type: "kick_member", payload: { userId: userID, ban: ban } }), wsconnection: conn, message: ban ? 'The player cannot join this room again. Are you sure?' : 'Kick the player out of the room?' });
+        };
+        buttons.appendChild(btn);
+      });
+      return buttons;
+    }
+
+    // renderHostAction tells the room what the owner did
+    function renderHostAction(payload) {
+      renderChatMessage({ username: "Host", message: payload.message });
+      if ((payload.action === "kick" || payload.action === "ban") && payload.userId === myUserID) {
+        gameOver = true;
+        sessionStorage.removeItem(resumeKey);
+        renderGameError(payload.message);
+        setTimeout(() => {
+          window.location.href = "/bw/home/";
+        }, 3000);
+      } else if (payload.action === "pause" || payload.action === "resume") {
+        timerPaused = payload.action === "pause";
+        questionTimeLeft = payload.timeLeft + frozenSeconds;
+        const timerDisplay = document.getElementById("timer-display");
+        if (timerDisplay) {
+          timerDisplay.textContent = questionTimeLeft;
+        }
+        const pauseBtn = document.getElementById("pause-game-btn");
+        if (pauseBtn) {
+          pauseBtn.textContent = timerPaused ? "Resume" : "Pause";
+        }
+      } else if (payload.action === "lock" || payload.action === "unlock") {
+        roomLocked = payload.action === "lock";
+        const lockBtn = document.getElementById("lock-room-btn");
+        if (lockBtn) {
+          lockBtn.textContent = roomLocked ? "Unlock Room" : "Lock Room";
+        }
+      }
     }
 
     // Add the canvas-confetti script
@@ -492,7 +965,7 @@ window.onload = function () {
     function renderEndGame(payload) {
       const questionBlock = document.getElementById("question-block");
       if (!questionBlock) return;
-      const { message, scores, finishTime } = payload;
+      const { message, scores, finishTime, teams } = payload;
 
     // Start confetti
         startConfetti();
@@ -507,6 +980,7 @@ window.onload = function () {
           </div>
 
           <div class="space-y-6">
+            ${renderTeamTotals(teams)}
             ${scores.length > 0 ? `
               <div class="flex flex-col">
                 ${scores.map((score, index) => `
@@ -519,6 +993,7 @@ window.onload = function () {
                     <div class="flex-1">
                       <h3 class="font-semibold text-lg">${score.username}</h3>
                       <p class="text-gray-600">Score: ${score.score}</p>
+                      ${score.eliminated ? `<p class="text-gray-500 text-sm">Out on question ${score.eliminatedRound}</p>` : ''}
                     </div>
                     ${index === 0 ? `
                       <div class="ml-4">